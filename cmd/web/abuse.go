@@ -0,0 +1,234 @@
+/*-----------------------------------------------------------
+ @Filename:         abuse.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "sort"
+    "sync"
+    "time"
+)
+
+// The points an IP earns for each kind of abuse. Tripping the spam checks
+// or the blocklist is a deliberate attempt, so it counts for more than
+// going over the rate limit, which a busy script can do by accident.
+const (
+    abuseSpamPoints      = 5
+    abuseRateLimitPoints = 1
+)
+
+// abuseMaxTracked is the number of IPs with a score before the ones whose
+// score has decayed to nothing are cleared out.
+const abuseMaxTracked = 10000
+
+// An abuseTracker keeps a reputation score for each client IP which has
+// misbehaved: it goes up for spam and blocklist hits and rate limit
+// violations, and comes down by one point every decay. An IP whose score
+// reaches the threshold can't create chunks for blockFor. The score isn't
+// reset by a block, so a repeat offender whose score is still high is
+// blocked again on their next offence.
+//
+// The scores are kept in memory, per process. A nil *abuseTracker (the
+// default, -abuse-threshold=0) scores nothing.
+type abuseTracker struct {
+    threshold float64
+    decay     time.Duration
+    blockFor  time.Duration
+
+    mu     sync.Mutex
+    scores map[string]*abuseScore
+}
+
+type abuseScore struct {
+    score        float64
+    updated      time.Time
+    blockedUntil time.Time
+}
+
+// A blockedIP is an IP which is currently blocked, for the admin page.
+type blockedIP struct {
+    IP    string
+    Score int
+    Until time.Time
+}
+
+func newAbuseTracker(threshold float64, decay, blockFor time.Duration) *abuseTracker {
+    if threshold <= 0 {
+        return nil
+    }
+    return &abuseTracker{threshold: threshold, decay: decay, blockFor: blockFor, scores: make(map[string]*abuseScore)}
+}
+
+// decayed brings a score up to date. The caller must hold the lock.
+func (t *abuseTracker) decayed(s *abuseScore, now time.Time) float64 {
+    s.score -= float64(now.Sub(s.updated)) / float64(t.decay)
+    if s.score < 0 {
+        s.score = 0
+    }
+    s.updated = now
+    return s.score
+}
+
+// add adds points to the ip's score, and blocks it if that takes the score
+// to the threshold. It reports whether the ip has just been blocked.
+func (t *abuseTracker) add(ip string, points float64) bool {
+    if t == nil {
+        return false
+    }
+
+    now := time.Now()
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    s, ok := t.scores[ip]
+    if !ok {
+        if len(t.scores) >= abuseMaxTracked {
+            t.prune(now)
+        }
+        s = &abuseScore{updated: now}
+        t.scores[ip] = s
+    }
+    s.score = t.decayed(s, now) + points
+    if s.score < t.threshold || now.Before(s.blockedUntil) {
+        return false
+    }
+    s.blockedUntil = now.Add(t.blockFor)
+    return true
+}
+
+// prune forgets the IPs with nothing against them any more. The caller
+// must hold the lock.
+func (t *abuseTracker) prune(now time.Time) {
+    for ip, s := range t.scores {
+        if t.decayed(s, now) == 0 && !now.Before(s.blockedUntil) {
+            delete(t.scores, ip)
+        }
+    }
+}
+
+// blocked reports whether the ip is blocked from creating chunks.
+func (t *abuseTracker) blocked(ip string) bool {
+    if t == nil {
+        return false
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    s, ok := t.scores[ip]
+    return ok && time.Now().Before(s.blockedUntil)
+}
+
+// unblock lifts the block on an ip and clears its score. It reports whether
+// the ip was blocked.
+func (t *abuseTracker) unblock(ip string) bool {
+    if t == nil {
+        return false
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    s, ok := t.scores[ip]
+    if !ok {
+        return false
+    }
+    delete(t.scores, ip)
+    return time.Now().Before(s.blockedUntil)
+}
+
+// blockedIPs lists the IPs which are blocked, the ones blocked longest
+// first.
+func (t *abuseTracker) blockedIPs() []blockedIP {
+    if t == nil {
+        return nil
+    }
+    now := time.Now()
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    var blocked []blockedIP
+    for ip, s := range t.scores {
+        if now.Before(s.blockedUntil) {
+            blocked = append(blocked, blockedIP{IP: ip, Score: int(t.decayed(s, now)), Until: s.blockedUntil})
+        }
+    }
+    sort.Slice(blocked, func(i, j int) bool {
+        return blocked[i].Until.Before(blocked[j].Until)
+    })
+    return blocked
+}
+
+// recordAbuse adds points to the score of the client's IP, and audits and
+// logs a block it causes.
+func (app *application) recordAbuse(r *http.Request, points float64) {
+    ip := app.realIP(r)
+    if app.abuse.add(ip, points) {
+        app.infoLog.Printf("abuse: blocked %s from creating chunks for %s", ip, app.abuse.blockFor)
+        app.audit(r, 0, auditIPBlock, ipTarget(ip))
+    }
+}
+
+// blockAbusers refuses requests to create chunks from blocked IPs with a
+// 403 Forbidden.
+func (app *application) blockAbusers(next http.Handler) http.Handler {
+    if app.abuse == nil {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if app.abuse.blocked(app.realIP(r)) {
+            if isAPIRequest(r) {
+                app.apiError(w, http.StatusForbidden, "blocked", "your address is temporarily blocked from creating chunks")
+                return
+            }
+            app.clientError(w, http.StatusForbidden)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// ipTarget is the audit target for an action on a client IP.
+func ipTarget(ip string) string {
+    return "ip:" + ip
+}
+
+// adminAbuse lists the IPs which are blocked from creating chunks.
+func (app *application) adminAbuse(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    data := app.newTemplateData(r)
+    data.AbuseEnabled = app.abuse != nil
+    data.Blocked = app.abuse.blockedIPs()
+    app.render(w, http.StatusOK, "abuse.html", data)
+}
+
+// adminUnblockPost lifts the block on the IP in the "ip" form field.
+func (app *application) adminUnblockPost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+    ip := r.PostForm.Get("ip")
+    if net.ParseIP(ip) == nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    flash := fmt.Sprintf("%s wasn't blocked.", ip)
+    if app.abuse.unblock(ip) {
+        app.audit(r, app.authenticatedUserID(r), auditIPUnblock, ipTarget(ip))
+        flash = fmt.Sprintf("%s has been unblocked.", ip)
+    }
+    app.sessionManager.Put(r.Context(), "flash", flash)
+    http.Redirect(w, r, app.url("/admin/abuse"), http.StatusSeeOther)
+}
@@ -0,0 +1,242 @@
+/*-----------------------------------------------------------
+ @Filename:         access.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "html/template"
+    "net"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// The owner of a chunk can turn on its access log on /chunk/{id}/stats, to
+// see who looks at it: the views of its page, and its raw content and
+// downloads. See models.Access for what is kept about each visitor. Entries
+// older than -access-log-days are pruned, and each chunk keeps at most
+// -access-log-max of them. The page of a chunk with an access log is never
+// kept by the response cache, so none of its views go unrecorded.
+const (
+    // accessRecentLimit is the number of accesses listed on the stats page.
+    accessRecentLimit = 50
+    // accessChartDays is the most days the chart on the stats page covers.
+    accessChartDays = 30
+    // accessPruneInterval is how often entries past -access-log-days are
+    // deleted.
+    accessPruneInterval = time.Hour
+)
+
+// The ways a chunk is accessed, as recorded.
+const (
+    accessView     = "view"
+    accessRaw      = "raw"
+    accessDownload = "download"
+)
+
+// A statsPage is the data for the /chunk/{id}/stats page. Daily covers the
+// last Days days, with Total accesses, and KeepDays is -access-log-days.
+type statsPage struct {
+    Chunk    *models.Chunk
+    Enabled  bool
+    Recent   []*models.Access
+    Daily    []models.DailyAccesses
+    Chart    template.HTML
+    Days     int
+    Total    int
+    KeepDays int
+}
+
+// truncateIP zeroes the part of an address which tells one visitor from
+// its neighbours: the last byte of an IPv4 address, and everything after
+// the first 48 bits of an IPv6 one. What isn't an IP is left out.
+func truncateIP(ip string) string {
+    parsed := net.ParseIP(ip)
+    if parsed == nil {
+        return ""
+    }
+    if v4 := parsed.To4(); v4 != nil {
+        return v4.Mask(net.CIDRMask(24, 32)).String()
+    }
+    return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// agentFamilies are the user agent families told apart, by a substring of
+// the User-Agent header, in the order they are tried. Edge and Opera say
+// they are Chrome, and Chrome says it is Safari, so they come first.
+var agentFamilies = []struct{ match, family string }{
+    {"curl/", "curl"},
+    {"Wget/", "Wget"},
+    {"Go-http-client/", "Go"},
+    {"python-requests/", "Python"},
+    {"Edg/", "Edge"},
+    {"OPR/", "Opera"},
+    {"Firefox/", "Firefox"},
+    {"Chrome/", "Chrome"},
+    {"Safari/", "Safari"},
+}
+
+// agentFamily returns the family of a User-Agent header, without any of
+// the versions and platforms which could tell visitors apart.
+func agentFamily(ua string) string {
+    if ua == "" {
+        return "Unknown"
+    }
+    lower := strings.ToLower(ua)
+    if strings.Contains(lower, "bot") || strings.Contains(lower, "spider") || strings.Contains(lower, "crawl") {
+        return "Bot"
+    }
+    for _, f := range agentFamilies {
+        if strings.Contains(ua, f.match) {
+            return f.family
+        }
+    }
+    return "Other"
+}
+
+// logAccess records an access to the chunk, if its owner asked for that.
+// A failure is logged rather than failing the request.
+func (app *application) logAccess(r *http.Request, chunk *models.Chunk, kind string) {
+    if app.accessLog == nil || !chunk.AccessLog {
+        return
+    }
+    err := app.accessLog.Record(r.Context(), chunk.ID, kind, truncateIP(app.realIP(r)), agentFamily(r.UserAgent()))
+    if err != nil {
+        app.errorLog.Printf("recording an access to chunk %d: %v", chunk.ID, err)
+    }
+}
+
+// chunkStats serves /chunk/{id}/stats to the owner of the chunk: GET shows
+// its access log, and POST turns the log on or off. Anybody else gets the
+// same 404 as for a chunk which doesn't exist.
+func (app *application) chunkStats(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+        return
+    }
+    if !app.isAuthenticated(r) {
+        next := ""
+        if r.Method == http.MethodGet {
+            next = app.url(r.URL.RequestURI())
+        }
+        http.Redirect(w, r, app.loginURL(next), http.StatusSeeOther)
+        return
+    }
+    // The page shows who looked at the chunk, so it mustn't be kept.
+    w.Header().Set("Cache-Control", "private, no-store")
+
+    chunk, err := app.chunks.GetMetaByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if chunk.UserID == 0 || chunk.UserID != app.authenticatedUserID(r) {
+        app.notFound(w)
+        return
+    }
+
+    if r.Method == http.MethodPost {
+        err := r.ParseForm()
+        if err != nil {
+            app.clientError(w, http.StatusBadRequest)
+            return
+        }
+        enabled := r.PostForm.Get("enabled") == "1"
+        if err := app.accessLog.SetEnabled(chunk.ID, enabled); err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if enabled {
+            app.sessionManager.Put(r.Context(), "flash", "Accesses to this chunk are now recorded.")
+        } else {
+            app.sessionManager.Put(r.Context(), "flash", "The access log of this chunk is off, and its entries are deleted.")
+        }
+        http.Redirect(w, r, app.url("/chunk/"+chunk.PublicID+"/stats"), http.StatusSeeOther)
+        return
+    }
+
+    page := &statsPage{Chunk: chunk, Enabled: chunk.AccessLog, Days: app.accessLogDays, KeepDays: app.accessLogDays}
+    if page.Days > accessChartDays {
+        page.Days = accessChartDays
+    }
+    if page.Enabled {
+        page.Recent, err = app.accessLog.Recent(chunk.ID, accessRecentLimit)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        page.Daily, err = app.accessLog.Daily(chunk.ID, page.Days)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        for _, d := range page.Daily {
+            page.Total += d.Count
+        }
+        page.Chart = accessChart(page.Daily)
+    }
+
+    data := app.newTemplateData(r)
+    data.Stats = page
+    app.render(w, http.StatusOK, "stats.html", data)
+}
+
+// accessChart draws the daily counts as an SVG bar chart, to be put in the
+// page as it is (an <img> would need a request of its own, and the CSP
+// doesn't allow inline styles for bars made of HTML). It has nothing but
+// numbers and dates in it, so there is nothing to escape.
+func accessChart(daily []models.DailyAccesses) template.HTML {
+    const barWidth, gap, height = 12, 2, 80
+    most := 1
+    for _, d := range daily {
+        if d.Count > most {
+            most = d.Count
+        }
+    }
+
+    var b strings.Builder
+    fmt.Fprintf(&b, `<svg class='access-chart' xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="Accesses per day">`, len(daily)*(barWidth+gap), height)
+    for i, d := range daily {
+        h := d.Count * height / most
+        if d.Count > 0 && h == 0 {
+            h = 1
+        }
+        fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#34495E"><title>%s: %d</title></rect>`,
+            i*(barWidth+gap), height-h, barWidth, h, d.Day.Format("2006-01-02"), d.Count)
+    }
+    b.WriteString(`</svg>`)
+    return template.HTML(b.String())
+}
+
+// startAccessPruner deletes the entries older than -access-log-days every
+// accessPruneInterval until shutdown, starting straight away.
+func (app *application) startAccessPruner() {
+    app.background.Go(func(ctx context.Context) {
+        ticker := time.NewTicker(accessPruneInterval)
+        defer ticker.Stop()
+        for {
+            n, err := app.accessLog.Prune(ctx, time.Now().AddDate(0, 0, -app.accessLogDays))
+            if err != nil && ctx.Err() == nil {
+                app.errorLog.Printf("pruning the access log: %v", err)
+            } else if n > 0 {
+                app.infoLog.Printf("Pruned %d access log entries", n)
+            }
+            select {
+            case <-ticker.C:
+            case <-ctx.Done():
+                return
+            }
+        }
+    })
+}
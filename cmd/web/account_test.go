@@ -0,0 +1,72 @@
+package main
+
+import (
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+)
+
+// TestAccountWithoutPassword checks that users who signed up through an
+// OAuth provider, and so have no password, are asked to set one before
+// changing their email address or deleting their account.
+func TestAccountWithoutPassword(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    mailer := withMailer(app)
+    id := users.add(t, "Alice", "alice@example.com", "pa55word")
+    ts := newTestServer(t, app.routes())
+    ts.login(t, "alice@example.com", "pa55word")
+
+    // Take the password away once logged in, as if Alice had logged in with
+    // a provider.
+    users.mu.Lock()
+    delete(users.passwords, id)
+    users.users[id].HasPassword = false
+    users.mu.Unlock()
+
+    resp, body := ts.get(t, "/account/update")
+    if resp.StatusCode != http.StatusOK || strings.Contains(body, "name='currentPassword'") {
+        t.Errorf("update form: status %d, asks for the current password %t", resp.StatusCode, strings.Contains(body, "name='currentPassword'"))
+    }
+    resp, body = ts.postForm(t, "/account/update", url.Values{"name": {"Alice"}, "email": {"new@example.com"}})
+    if resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, "Set a password first to change your email address") {
+        t.Errorf("changing the email: status %d: %s", resp.StatusCode, body)
+    }
+    if user, _ := users.Get(id); user.PendingEmail != "" {
+        t.Errorf("pending email %q", user.PendingEmail)
+    }
+    select {
+    case sent := <-mailer.sent:
+        t.Errorf("email sent to %s", sent.to)
+    default:
+    }
+
+    resp, body = ts.postFormFrom(t, "/account/view", "/account/delete", url.Values{})
+    if resp.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, "Set a password first to delete your account") {
+        t.Errorf("deleting: status %d: %s", resp.StatusCode, body)
+    }
+    if exists, _ := users.Exists(id); !exists {
+        t.Fatal("account deleted")
+    }
+
+    // Once a password is set both work with it.
+    resp, body = ts.postForm(t, "/account/password/update", url.Values{"newPassword": {"n3wpa55word"}, "newPasswordConfirmation": {"n3wpa55word"}})
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Fatalf("setting a password: status %d: %s", resp.StatusCode, body)
+    }
+    resp, _ = ts.postForm(t, "/account/update", url.Values{"name": {"Alice"}, "email": {"new@example.com"}, "currentPassword": {"n3wpa55word"}})
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Errorf("changing the email with a password: status %d", resp.StatusCode)
+    }
+    if sent := mailer.next(t); sent.to != "new@example.com" {
+        t.Errorf("confirmation sent to %s", sent.to)
+    }
+    resp, _ = ts.postFormFrom(t, "/account/view", "/account/delete", url.Values{"password": {"n3wpa55word"}})
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Errorf("deleting with a password: status %d", resp.StatusCode)
+    }
+    if exists, _ := users.Exists(id); exists {
+        t.Error("account not deleted")
+    }
+}
@@ -0,0 +1,104 @@
+/*-----------------------------------------------------------
+ @Filename:         adminapi.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// maxBulkDeleteIDs is the most chunk ids one bulk delete request can list.
+// A filter can match any number of chunks.
+const maxBulkDeleteIDs = 1000
+
+// apiBulkDeleteInput is the body of DELETE /api/v1/admin/chunks. The chunks
+// to delete are the ones listed in IDs, or those matching the filter
+// fields, or both: every field given must match. Confirm must be true, so
+// a request built by mistake doesn't wipe out chunks.
+type apiBulkDeleteInput struct {
+    IDs           []string   `json:"ids"`
+    CreatedBefore *time.Time `json:"created_before"`
+    IP            string     `json:"ip"`
+    Confirm       bool       `json:"confirm"`
+}
+
+// apiAdminChunks lets administrators delete many chunks at once, to clean
+// up after a spam wave. The chunks are deleted in one transaction, expired
+// or not, and the number deleted is returned.
+func (app *application) apiAdminChunks(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodDelete {
+        app.apiMethodNotAllowed(w, http.MethodDelete)
+        return
+    }
+
+    var input apiBulkDeleteInput
+    err := app.readJSON(w, r, &input)
+    if err != nil {
+        app.apiReadError(w, err)
+        return
+    }
+
+    filter := models.ChunkFilter{PublicIDs: input.IDs}
+    if input.CreatedBefore != nil {
+        filter.CreatedBefore = *input.CreatedBefore
+    }
+    var v validator.Validator
+    if input.IP != "" {
+        // Stored addresses are in the form net.IP prints them.
+        ip := net.ParseIP(input.IP)
+        v.CheckField(ip != nil, "ip", "This field must be an IP address")
+        if ip != nil {
+            filter.CreatorIP = ip.String()
+        }
+    }
+    v.CheckField(len(input.IDs) <= maxBulkDeleteIDs, "ids", fmt.Sprintf("This field cannot list more than %d chunks", maxBulkDeleteIDs))
+    v.CheckField(!filter.Empty(), "ids", "Give the ids of the chunks to delete, or a created_before or ip filter")
+    v.CheckField(input.Confirm, "confirm", "This field must be true to delete chunks")
+    if !v.Valid() {
+        app.writeAPIError(w, apiErr{
+            Status:      http.StatusUnprocessableEntity,
+            Code:        "validation_failed",
+            Message:     "the request is invalid, nothing was deleted",
+            FieldErrors: v.FieldErrors,
+        })
+        return
+    }
+
+    deleted, err := app.chunks.DeleteMatching(filter)
+    if err != nil {
+        app.apiServerError(w, err)
+        return
+    }
+    // Some of the chunks may already have expired, so this can undercount
+    // the quota for a moment, until it is next read from the database.
+    app.quota.removed(deleted)
+    app.responseCache.clear()
+    app.audit(r, app.authenticatedUserID(r), auditChunksBulkDelete, bulkDeleteTarget(deleted, filter))
+
+    app.writeJSON(w, http.StatusOK, envelope{"deleted": deleted})
+}
+
+// bulkDeleteTarget is the audit target for a bulk delete: how many chunks
+// went, and the filter which chose them. The ids themselves wouldn't fit.
+func bulkDeleteTarget(deleted int, filter models.ChunkFilter) string {
+    target := []string{fmt.Sprintf("%d chunks", deleted)}
+    if len(filter.PublicIDs) > 0 {
+        target = append(target, fmt.Sprintf("ids=%d", len(filter.PublicIDs)))
+    }
+    if !filter.CreatedBefore.IsZero() {
+        target = append(target, "created_before="+filter.CreatedBefore.UTC().Format(time.RFC3339))
+    }
+    if filter.CreatorIP != "" {
+        target = append(target, "ip="+filter.CreatorIP)
+    }
+    return strings.Join(target, " ")
+}
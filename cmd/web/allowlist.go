@@ -0,0 +1,61 @@
+/*-----------------------------------------------------------
+ @Filename:         allowlist.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net/netip"
+    "strings"
+)
+
+// An ipList is a set of networks, used for the -create-allowlist,
+// -read-allowlist and -trusted-proxies flags. A nil ipList matches nothing.
+type ipList []netip.Prefix
+
+// parseIPList parses a comma-separated list of CIDRs like "10.0.0.0/8". A
+// bare address is taken to mean just that one address. An empty string
+// gives a nil list.
+func parseIPList(s string) (ipList, error) {
+    var list ipList
+    for _, item := range strings.Split(s, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        if !strings.Contains(item, "/") {
+            addr, err := netip.ParseAddr(item)
+            if err != nil {
+                return nil, fmt.Errorf("invalid IP address %q", item)
+            }
+            list = append(list, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+            continue
+        }
+        prefix, err := netip.ParsePrefix(item)
+        if err != nil {
+            return nil, fmt.Errorf("invalid CIDR %q", item)
+        }
+        list = append(list, prefix.Masked())
+    }
+    return list, nil
+}
+
+// contains reports whether the IP address ip is in one of the networks. It
+// is false for anything that doesn't parse as an IP address.
+func (l ipList) contains(ip string) bool {
+    addr, err := netip.ParseAddr(ip)
+    if err != nil {
+        return false
+    }
+    // IPv4 addresses can arrive in their IPv6 form (::ffff:10.0.0.1), and
+    // link-local IPv6 addresses with a zone.
+    addr = addr.Unmap().WithZone("")
+    for _, prefix := range l {
+        if prefix.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,526 @@
+/*-----------------------------------------------------------
+ @Filename:         api.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "compress/flate"
+    "compress/gzip"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "runtime/debug"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/textnorm"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// maxAPIBodyBytes limits the size of a JSON request body.
+const maxAPIBodyBytes = 10 << 20
+
+// Define an envelope type for the top-level JSON objects we send.
+type envelope map[string]any
+
+// The writeJSON helper encodes data as JSON and sends it with the given
+// status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+    js, err := json.Marshal(data)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    w.Write(js)
+    w.Write([]byte("\n"))
+}
+
+// apiErr is an error response from the JSON API. Code is a stable,
+// machine-readable name for the kind of error ("not_found",
+// "validation_failed", ...) and Message a human-readable description.
+// FieldErrors holds the validation errors of a single object and Items
+// those of the elements of a batch.
+type apiErr struct {
+    Status      int
+    Code        string
+    Message     string
+    FieldErrors map[string]string
+    Items       []apiItemError
+}
+
+// The writeAPIError helper sends an API error. By default it is wrapped in
+// an envelope:
+//
+//  {"error": {"code": "...", "message": "...", "field_errors": {...}}}
+//
+// With -api-errors=problem it is an RFC 7807 application/problem+json
+// document instead, with the code and field errors as extension members:
+//
+//  {"type": "about:blank", "title": "Not Found", "status": 404,
+//   "detail": "...", "code": "..."}
+func (app *application) writeAPIError(w http.ResponseWriter, e apiErr) {
+    body := envelope{"code": e.Code}
+    if e.FieldErrors != nil {
+        body["field_errors"] = e.FieldErrors
+    }
+    if e.Items != nil {
+        body["items"] = e.Items
+    }
+
+    if !app.problemJSON {
+        body["message"] = e.Message
+        app.writeJSON(w, e.Status, envelope{"error": body})
+        return
+    }
+
+    body["type"] = "about:blank"
+    body["title"] = http.StatusText(e.Status)
+    body["status"] = e.Status
+    body["detail"] = e.Message
+    js, err := json.Marshal(body)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/problem+json")
+    w.WriteHeader(e.Status)
+    w.Write(js)
+    w.Write([]byte("\n"))
+}
+
+// The apiError helper sends an API error without any field errors.
+func (app *application) apiError(w http.ResponseWriter, status int, code, message string) {
+    app.writeAPIError(w, apiErr{Status: status, Code: code, Message: message})
+}
+
+// The apiServerError helper is the API's serverError: it logs the error and
+// stack trace, and sends a generic 500 response.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+    trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+    app.errorLog.Output(2, trace)
+    app.apiError(w, http.StatusInternalServerError, "internal_error", "the server encountered a problem and could not process your request")
+}
+
+// The apiMethodNotAllowed helper is the API's methodNotAllowed.
+func (app *application) apiMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+    for _, method := range allowed {
+        w.Header().Add("Allow", method)
+    }
+    app.apiError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+        fmt.Sprintf("this endpoint only supports %s", strings.Join(allowed, ", ")))
+}
+
+// apiNotFound handles every path under /api/ which isn't an endpoint.
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+    app.apiError(w, http.StatusNotFound, "not_found", "the requested resource could not be found")
+}
+
+// isAPIRequest reports whether r is for the JSON API, so the middleware
+// shared with the HTML pages can answer it with an API error.
+func isAPIRequest(r *http.Request) bool {
+    return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// errUnsupportedEncoding is returned by readJSON for a body in a
+// Content-Encoding other than gzip.
+var errUnsupportedEncoding = errors.New("Content-Encoding must be gzip, or none")
+
+// The readJSON helper decodes a JSON request body into dst. The request must
+// declare a JSON Content-Type (which also means a cross-site HTML form can't
+// submit it), the body is size limited, and unknown fields or trailing data
+// are treated as errors. The body may be gzip-compressed, see requestBody.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if mediaType != "application/json" {
+        return errors.New("Content-Type must be application/json")
+    }
+
+    body, err := requestBody(w, r)
+    if err != nil {
+        return err
+    }
+
+    dec := json.NewDecoder(body)
+    dec.DisallowUnknownFields()
+
+    err = dec.Decode(dst)
+    if err != nil {
+        var maxBytesError *http.MaxBytesError
+        if errors.As(err, &maxBytesError) {
+            return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+        }
+        var corrupt flate.CorruptInputError
+        if errors.Is(err, gzip.ErrChecksum) || errors.As(err, &corrupt) {
+            return fmt.Errorf("body is not valid gzip: %v", err)
+        }
+        return fmt.Errorf("body contains badly-formed JSON: %v", err)
+    }
+
+    if err = dec.Decode(&struct{}{}); err != io.EOF {
+        return errors.New("body must only contain a single JSON value")
+    }
+
+    return nil
+}
+
+// The apiReadError helper answers a request readJSON couldn't read: a 415
+// for a body in an unsupported encoding, and a 400 otherwise.
+func (app *application) apiReadError(w http.ResponseWriter, err error) {
+    if errors.Is(err, errUnsupportedEncoding) {
+        app.apiError(w, http.StatusUnsupportedMediaType, "unsupported_encoding", err.Error())
+        return
+    }
+    app.apiError(w, http.StatusBadRequest, "bad_request", err.Error())
+}
+
+// requestBody returns the reader for an API request body, limited to
+// maxAPIBodyBytes. A gzip-compressed body (Content-Encoding: gzip) is
+// decompressed, and the limit applies to both the compressed body and what
+// it decompresses to, so a small body which expands to gigabytes is cut off
+// as soon as it passes the limit. Any other encoding gets
+// errUnsupportedEncoding.
+func requestBody(w http.ResponseWriter, r *http.Request) (io.Reader, error) {
+    r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+    switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+    case "", "identity":
+        return r.Body, nil
+    case "gzip", "x-gzip":
+        gz, err := gzip.NewReader(r.Body)
+        if err != nil {
+            return nil, fmt.Errorf("body is not valid gzip: %v", err)
+        }
+        return http.MaxBytesReader(w, gz, maxAPIBodyBytes), nil
+    }
+    return nil, errUnsupportedEncoding
+}
+
+// The apiChunkInput struct is the JSON representation of a new chunk.
+type apiChunkInput struct {
+    Title    string `json:"title"`
+    Content  string `json:"content"`
+    // Expires is in days. Left out, it is the default of the -anon-* or
+    // -user-* expiry policy.
+    Expires  int    `json:"expires"`
+    // Language is optional and defaults to plain text, like a blank form
+    // field does. "auto" detects it from the content.
+    Language string `json:"language"`
+    Private  bool   `json:"private"`
+    // KeepAlive extends the expiry when the chunk is viewed, on instances
+    // with -extend-on-view.
+    KeepAlive bool  `json:"keep_alive"`
+    Tags     []string `json:"tags"`
+    // Files are the other files of a multi-file chunk (-max-files); the
+    // chunk's own content is its first file.
+    Files    []apiChunkFile `json:"files"`
+}
+
+// An apiChunkFile is one of the other files of a multi-file chunk. Its
+// language works like the chunk's.
+type apiChunkFile struct {
+    Filename string `json:"filename"`
+    Language string `json:"language"`
+    Content  string `json:"content"`
+}
+
+// The apiRefuseAnonymous helper refuses a request to create chunks from a
+// visitor who isn't logged in, and reports whether it did: on a login-only
+// instance, and when anonymous chunks need a CAPTCHA (-captcha-provider),
+// which only the HTML form can ask for. Otherwise the API would be a way
+// around it.
+func (app *application) apiRefuseAnonymous(w http.ResponseWriter, r *http.Request) bool {
+    if app.isAuthenticated(r) {
+        return false
+    }
+    if !app.allowAnonymous {
+        app.apiError(w, http.StatusUnauthorized, "unauthorized", "you must be authenticated to create chunks")
+        return true
+    }
+    if app.captcha != nil {
+        app.apiError(w, http.StatusForbidden, "captcha_required", "anonymous chunks can only be created through the web form; log in to use the API")
+        return true
+    }
+    return false
+}
+
+// apiItemError reports the validation errors for one element of a batch.
+// ExistingURL is the chunk it duplicates, if it was refused for that
+// (-unique-per-user=reject).
+type apiItemError struct {
+    Index       int               `json:"index"`
+    Errors      map[string]string `json:"errors"`
+    ExistingURL string            `json:"existing_url,omitempty"`
+}
+
+// apiChunksBatch creates several chunks in one request. The body is a JSON
+// array of chunk objects. Every item is validated first, and if any of them
+// is invalid nothing is created and the errors are reported per index.
+// Otherwise all the chunks are inserted in a single transaction and their
+// ids and URLs are returned in the same order.
+func (app *application) apiChunksBatch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.apiMethodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    if app.apiRefuseAnonymous(w, r) {
+        return
+    }
+
+    var items []apiChunkInput
+    err := app.readJSON(w, r, &items)
+    if err != nil {
+        app.apiReadError(w, err)
+        return
+    }
+
+    if len(items) == 0 {
+        app.apiError(w, http.StatusBadRequest, "bad_request", "the batch must contain at least one chunk")
+        return
+    }
+    if len(items) > app.maxBatchSize {
+        app.apiError(w, http.StatusRequestEntityTooLarge, "batch_too_large",
+            fmt.Sprintf("the batch must not contain more than %d chunks", app.maxBatchSize))
+        return
+    }
+
+    policy := app.expiryPolicy(r)
+    inputs := make([]models.ChunkInput, len(items))
+    var itemErrors []apiItemError
+
+    for i, item := range items {
+        // JSON strings are always UTF-8, so only the line endings can need
+        // normalizing.
+        input, fieldErrors, err := app.prepareAPIChunk(r, item, "", policy)
+        if err != nil {
+            app.apiServerError(w, err)
+            return
+        }
+        if fieldErrors != nil {
+            itemErrors = append(itemErrors, apiItemError{Index: i, Errors: fieldErrors})
+            continue
+        }
+        inputs[i] = input
+    }
+
+    if len(itemErrors) > 0 {
+        app.writeAPIError(w, apiErr{
+            Status:  http.StatusUnprocessableEntity,
+            Code:    "validation_failed",
+            Message: "the batch contains invalid chunks, nothing was created",
+            Items:   itemErrors,
+        })
+        return
+    }
+
+    created := app.createAPIChunks(w, r, inputs)
+    if created == nil {
+        return
+    }
+
+    app.writeJSON(w, createdStatus(created), envelope{"chunks": created})
+}
+
+// prepareAPIChunk normalizes and validates a chunk submitted to the API,
+// whose content is in charset (empty for UTF-8). It returns the chunk ready
+// to insert, or the validation errors if it is invalid.
+func (app *application) prepareAPIChunk(r *http.Request, item apiChunkInput, charset string, policy expiryPolicy) (models.ChunkInput, map[string]string, error) {
+    var v validator.Validator
+    userID := app.authenticatedUserID(r)
+    content, normalized, err := app.normalizeContent(item.Content, charset)
+    switch {
+    case errors.Is(err, textnorm.ErrUnknownCharset):
+        v.AddFieldError("content", fmt.Sprintf("Text in the %q charset can't be accepted", charset))
+        return models.ChunkInput{}, v.FieldErrors, nil
+    case errors.Is(err, textnorm.ErrInvalidUTF8):
+        v.AddFieldError("content", "This field must be valid UTF-8 text")
+        return models.ChunkInput{}, v.FieldErrors, nil
+    case err != nil:
+        return models.ChunkInput{}, nil, err
+    }
+    item.Content = content
+    files := make([]models.ChunkFile, len(item.Files))
+    for i, f := range item.Files {
+        var fileNormalized bool
+        files[i] = models.ChunkFile{Filename: strings.TrimSpace(f.Filename), Language: app.normalizeLanguage(f.Language)}
+        files[i].Content, fileNormalized, err = app.normalizeContent(f.Content, charset)
+        if err != nil {
+            v.AddFieldError("files", fmt.Sprintf("File %d must be valid UTF-8 text", i+2))
+            return models.ChunkInput{}, v.FieldErrors, nil
+        }
+        normalized = normalized || fileNormalized
+    }
+    language := app.normalizeLanguage(item.Language)
+    app.validateChunk(&v, item.Title, item.Content, item.Expires, language, policy)
+    tags := normalizeTags(item.Tags)
+    app.validateTags(&v, tags)
+    app.validateFiles(&v, item.Content, files)
+    v.CheckField(!item.Private || userID != 0, "private", "Only authenticated users can create private chunks")
+    v.CheckField(!item.KeepAlive || app.extendOnView > 0, "keep_alive", "This server doesn't extend expiries when chunks are viewed")
+    if v.Valid() && app.blocklist.Load().Matches(append(append([]string{item.Title, item.Content}, tags...), filesContent(files)...)...) {
+        app.infoLog.Printf("blocklist: rejected API chunk from %s: title=%q content=%q",
+            app.realIP(r), truncate(item.Title, 100), truncate(item.Content, 200))
+        v.AddFieldError("content", "This chunk could not be saved")
+        app.recordAbuse(r, abuseSpamPoints)
+    }
+    // A secret in the content is refused with -secret-action=block. With
+    // warn, createAPIChunks adds the warning to the response instead.
+    if v.Valid() {
+        app.checkSecret(&v, r, item.Content, files, true)
+    }
+    if !v.Valid() {
+        return models.ChunkInput{}, v.FieldErrors, nil
+    }
+
+    // Resolve "auto" now the item is known to be valid. The detected
+    // language is returned in the response.
+    if language == autoLanguage {
+        lang, _ := app.detectLanguage(item.Content)
+        language = lang.Name
+    }
+    // Left out, the expiry depends on the language (-language-expiry).
+    item.Expires = policy.resolve(item.Expires, language)
+    content, transformed := app.transformContent(&v, language, item.Content)
+    files, filesTransformed := app.prepareFiles(&v, files)
+    if !v.Valid() {
+        return models.ChunkInput{}, v.FieldErrors, nil
+    }
+
+    return models.ChunkInput{
+        Title:    item.Title,
+        Content:  content,
+        Expires:  item.Expires,
+        Language: language,
+        UserID:   userID,
+        Private:  item.Private,
+        KeepAlive: item.KeepAlive,
+        Normalized: normalized || transformed || filesTransformed,
+        Tags:     tags,
+        Files:    files,
+        IP:       app.realIP(r),
+    }, nil, nil
+}
+
+// createAPIChunks inserts chunks prepared by prepareAPIChunk in a single
+// transaction, and returns their ids and URLs in the same order. With
+// -unique-per-user=redirect, a chunk the creator has already is returned in
+// place of a new one, marked "existing". If they can't be created it sends
+// the error response and returns nil.
+func (app *application) createAPIChunks(w http.ResponseWriter, r *http.Request, all []models.ChunkInput) []envelope {
+    userID := app.authenticatedUserID(r)
+
+    // Content the creator has saved already isn't saved again (see
+    // unique.go). Only the other chunks are inserted; positions has the
+    // index of each of them in the request.
+    existing, repeats, err := app.existingContents(r, all)
+    if err != nil {
+        app.apiServerError(w, err)
+        return nil
+    }
+    var inputs []models.ChunkInput
+    var positions []int
+    for i, in := range all {
+        if _, repeat := repeats[i]; existing != nil && (existing[i] != nil || repeat) {
+            continue
+        }
+        inputs = append(inputs, in)
+        positions = append(positions, i)
+    }
+    if len(inputs) < len(all) && app.uniquePerUser == uniqueReject {
+        app.writeAPIError(w, apiErr{
+            Status:  http.StatusConflict,
+            Code:    "duplicate_content",
+            Message: "you already have chunks with this content, nothing was created",
+            Items:   app.duplicateErrors(r, existing, repeats),
+        })
+        return nil
+    }
+
+    // The -max-chunks quota applies to anonymous API chunks like it does to the
+    // create form.
+    if userID == 0 && len(inputs) > 0 {
+        ok, err := app.allowChunks(r, len(inputs))
+        if err != nil {
+            app.apiServerError(w, err)
+            return nil
+        }
+        if !ok {
+            app.apiError(w, http.StatusServiceUnavailable, "server_full", "the server is full and isn't accepting new chunks")
+            return nil
+        }
+    }
+
+    // A whole batch counts against the -user-byte-quota.
+    var size int64
+    for _, in := range inputs {
+        size += int64(len(in.Content)) + filesSize(in.Files)
+    }
+    ok, err := app.withinByteQuota(userID, size)
+    if err != nil {
+        app.apiServerError(w, err)
+        return nil
+    }
+    if !ok {
+        app.apiError(w, http.StatusForbidden, "over_quota", app.byteQuotaMessage())
+        return nil
+    }
+
+    ids, err := app.chunks.InsertBatch(inputs)
+    if err != nil {
+        app.apiServerError(w, err)
+        return nil
+    }
+    app.quota.added(len(ids))
+    for i, id := range ids {
+        app.notifyCreated(r, id, inputs[i].Title, inputs[i].Language, inputs[i].Private)
+    }
+
+    created := make([]envelope, len(all))
+    for k, id := range ids {
+        i := positions[k]
+        created[i] = envelope{
+            "id":       id,
+            "url":      app.absoluteURL(r, "/chunkbox/view?id="+id),
+            "raw_url":  app.absoluteURL(r, "/chunkbox/raw?id="+id),
+            "language": inputs[k].Language,
+            // The hash of the content as stored, which may differ from
+            // what was sent if it was normalized.
+            "sha256": models.ContentSHA256(inputs[k].Content),
+        }
+        // Anonymous chunks come with their secret edit link, as on the
+        // create form.
+        if link, expires := app.issueEditToken(r, id, userID, inputs[k].Expires); link != "" {
+            created[i]["edit_url"] = link
+            created[i]["edit_expires"] = expires.UTC().Truncate(time.Second)
+        }
+        if app.secretScanner != nil && app.secretAction == secretWarn {
+            if finding := app.findSecret(r, inputs[k].Content, inputs[k].Files); finding != nil {
+                created[i]["warnings"] = []string{secretMessage(finding)}
+            }
+        }
+    }
+    // The repeats come after the chunk they repeat, which is in created by
+    // now.
+    for i := range created {
+        switch j, repeat := repeats[i]; {
+        case existing != nil && existing[i] != nil:
+            created[i] = app.existingEnvelope(r, existing[i], all[i].Content)
+        case repeat:
+            created[i] = envelope{"existing": true}
+            for _, key := range []string{"id", "url", "raw_url", "language", "sha256"} {
+                created[i][key] = created[j][key]
+            }
+        }
+    }
+
+    return created
+}
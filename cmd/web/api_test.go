@@ -0,0 +1,119 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "testing"
+)
+
+// TestAPIErrors checks that every kind of API error has the same shape, in
+// both -api-errors formats.
+func TestAPIErrors(t *testing.T) {
+    tests := []struct {
+        name   string
+        setup  func(app *application)
+        method string
+        path   string
+        body   string
+        status int
+        code   string
+    }{
+        {name: "unknown endpoint", method: http.MethodGet, path: "/api/v1/nothing", status: http.StatusNotFound, code: "not_found"},
+        {name: "wrong method", method: http.MethodGet, path: "/api/v1/chunks/batch", status: http.StatusMethodNotAllowed, code: "method_not_allowed"},
+        {
+            name:   "anonymous",
+            setup:  func(app *application) { app.allowAnonymous = false },
+            method: http.MethodPost, path: "/api/v1/chunks/batch", body: `[{"title": "t", "content": "c"}]`,
+            status: http.StatusUnauthorized, code: "unauthorized",
+        },
+        {
+            name:   "malformed",
+            method: http.MethodPost, path: "/api/v1/chunks/batch", body: `[{"title": `,
+            status: http.StatusBadRequest, code: "bad_request",
+        },
+        {
+            name:   "invalid",
+            method: http.MethodPost, path: "/api/v1/chunks/batch", body: `[{"title": "", "content": "c"}]`,
+            status: http.StatusUnprocessableEntity, code: "validation_failed",
+        },
+        {
+            // The burst of 1 goes on the first request.
+            name:   "rate limited",
+            setup:  func(app *application) { app.limiter.Store(newRateLimiter(1, 1, nil)) },
+            method: http.MethodPost, path: "/api/v1/chunks/batch", body: `[{"title": "t", "content": "c"}]`,
+            status: http.StatusTooManyRequests, code: "rate_limited",
+        },
+    }
+
+    for _, problem := range []bool{false, true} {
+        for _, tt := range tests {
+            name := tt.name
+            if problem {
+                name += ", problem+json"
+            }
+            t.Run(name, func(t *testing.T) {
+                app := newTestApplication(t)
+                app.problemJSON = problem
+                if tt.setup != nil {
+                    tt.setup(app)
+                }
+                ts := newTestServer(t, app.routes())
+
+                var resp *http.Response
+                var body string
+                for i := 0; i < 2; i++ {
+                    if tt.method == http.MethodPost {
+                        resp, body = ts.postJSON(t, tt.path, tt.body)
+                    } else {
+                        resp, body = ts.get(t, tt.path)
+                    }
+                    if tt.status != http.StatusTooManyRequests {
+                        break
+                    }
+                }
+
+                if resp.StatusCode != tt.status {
+                    t.Fatalf("status %d, want %d: %s", resp.StatusCode, tt.status, body)
+                }
+                var got map[string]any
+                if err := json.Unmarshal([]byte(body), &got); err != nil {
+                    t.Fatalf("body %q: %v", body, err)
+                }
+
+                wantType := "application/json"
+                if problem {
+                    wantType = "application/problem+json"
+                    if got["status"] != float64(tt.status) || got["title"] != http.StatusText(tt.status) || got["type"] != "about:blank" {
+                        t.Errorf("problem %v", got)
+                    }
+                    if detail, _ := got["detail"].(string); detail == "" {
+                        t.Errorf("problem without a detail: %v", got)
+                    }
+                } else {
+                    errObj, ok := got["error"].(map[string]any)
+                    if !ok || len(got) != 1 {
+                        t.Fatalf("no error envelope: %v", got)
+                    }
+                    if message, _ := errObj["message"].(string); message == "" {
+                        t.Errorf("error without a message: %v", errObj)
+                    }
+                    got = errObj
+                }
+                if ct := resp.Header.Get("Content-Type"); ct != wantType {
+                    t.Errorf("Content-Type %q, want %q", ct, wantType)
+                }
+                if got["code"] != tt.code {
+                    t.Errorf("code %v, want %s", got["code"], tt.code)
+                }
+                if tt.status == http.StatusUnprocessableEntity {
+                    if items, _ := got["items"].([]any); len(items) != 1 {
+                        t.Errorf("items %v, want the invalid chunk", got["items"])
+                    }
+                }
+                if tt.status == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+                    t.Error("no Retry-After")
+                }
+            })
+        }
+    }
+}
@@ -0,0 +1,108 @@
+/*-----------------------------------------------------------
+ @Filename:         audit.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "net/http"
+    "strconv"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// The audit actions. They are stored in the audit_log table, so existing
+// values shouldn't be renamed.
+const (
+    auditSignup           = "user.signup"
+    auditLogin            = "user.login"
+    auditLoginFailed      = "user.login_failed"
+    auditLogout           = "user.logout"
+    auditOAuthLink        = "user.oauth_link"
+    auditEmailUpdate      = "account.email_update"
+    auditPasswordUpdate   = "account.password_update"
+    auditAccountDelete    = "account.delete"
+    auditChunksEvict      = "chunk.evict"
+    auditChunkEdit        = "chunk.edit"
+    auditChunkDelete      = "chunk.delete"
+    auditChunksBulkDelete = "chunk.bulk_delete"
+    auditIPBlock          = "ip.block"
+    auditIPUnblock        = "ip.unblock"
+    auditBannerUpdate     = "setting.banner"
+)
+
+// auditPageSize is the number of entries on each page of /admin/audit.
+const auditPageSize = 50
+
+// The audit helper records a security-relevant event. Recording is best
+// effort: a failure is logged, but never stops the request it belongs to.
+// Without a database (-db-driver=memory) there is no audit log.
+func (app *application) audit(r *http.Request, actorID int, action, target string) {
+    if app.auditLog == nil {
+        return
+    }
+    err := app.auditLog.Record(r.Context(), actorID, action, target, app.realIP(r))
+    if err != nil {
+        app.errorLog.Printf("audit: recording %s %q: %v", action, target, err)
+    }
+}
+
+// userTarget is the audit target for an action on a user account.
+func userTarget(id int) string {
+    return "user:" + strconv.Itoa(id)
+}
+
+// chunkTarget is the audit target for an action on a chunk.
+func chunkTarget(publicID string) string {
+    return "chunk:" + publicID
+}
+
+// An auditPage is the data for the /admin/audit page.
+type auditPage struct {
+    Entries  []*models.AuditEntry
+    // Actions are the choices for the filter, and Action the one selected
+    // (empty for all).
+    Actions  []string
+    Action   string
+    // PrevPage and NextPage are the neighbouring page numbers, or 0 if
+    // there is no such page.
+    PrevPage int
+    NextPage int
+}
+
+// adminAudit lists the audit log, newest first, optionally filtered by the
+// action in the "action" query parameter and paginated by "page".
+func (app *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    page, ok := app.pageNumber(w, r)
+    if !ok {
+        return
+    }
+    action := r.URL.Query().Get("action")
+
+    entries, hasNext, err := app.auditLog.List(action, page, auditPageSize)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    actions, err := app.auditLog.Actions()
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    data := app.newTemplateData(r)
+    data.Audit = &auditPage{
+        Entries:  entries,
+        Actions:  actions,
+        Action:   action,
+        PrevPage: page - 1,
+        NextPage: app.nextPage(page, hasNext),
+    }
+    app.render(w, http.StatusOK, "audit.html", data)
+}
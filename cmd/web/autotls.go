@@ -0,0 +1,65 @@
+/*-----------------------------------------------------------
+ @Filename:         autotls.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "crypto/tls"
+    "log"
+    "strings"
+
+    "golang.org/x/crypto/acme/autocert"
+)
+
+// newAutocertManager returns the Let's Encrypt certificate manager for
+// -autotls-hosts. Certificates are requested on demand, the first time a
+// client asks for one of the hosts, and kept in the cache directory so a
+// restart doesn't request them again.
+func newAutocertManager(hosts []string, cacheDir string, infoLog *log.Logger) *autocert.Manager {
+    return &autocert.Manager{
+        Prompt:     autocert.AcceptTOS,
+        HostPolicy: autocert.HostWhitelist(hosts...),
+        Cache:      loggingCache{Cache: autocert.DirCache(cacheDir), infoLog: infoLog},
+    }
+}
+
+// autocertTLSConfig returns the TLS configuration for serving with the
+// manager's certificates. autocert offers HTTP/2 itself, so it is taken out
+// again when -http2 is off.
+func autocertTLSConfig(m *autocert.Manager, http2 bool) *tls.Config {
+    cfg := m.TLSConfig()
+    if !http2 {
+        var protos []string
+        for _, p := range cfg.NextProtos {
+            if p != "h2" {
+                protos = append(protos, p)
+            }
+        }
+        cfg.NextProtos = protos
+    }
+    return cfg
+}
+
+// loggingCache is an autocert.Cache which logs the certificates it is given
+// to store. autocert has no hooks of its own, but it stores every
+// certificate it obtains or renews straight away, so this is where they
+// show up.
+type loggingCache struct {
+    autocert.Cache
+    infoLog *log.Logger
+}
+
+func (c loggingCache) Put(ctx context.Context, key string, data []byte) error {
+    // Besides the certificates (named after the host, with "+rsa" for the
+    // RSA ones) the cache holds the account key and challenge tokens.
+    switch {
+    case key == "acme_account+key":
+        c.infoLog.Print("autotls: registered a new ACME account")
+    case !strings.HasSuffix(key, "+token") && !strings.HasSuffix(key, "+http-01"):
+        c.infoLog.Printf("autotls: obtained a certificate for %s", strings.TrimSuffix(key, "+rsa"))
+    }
+    return c.Cache.Put(ctx, key, data)
+}
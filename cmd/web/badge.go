@@ -0,0 +1,148 @@
+/*-----------------------------------------------------------
+ @Filename:         badge.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "html"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// A badge is a small SVG image in the style of shields.io, for linking to a
+// chunk from a README:
+//
+//  [![chunk](https://example.com/chunk/ID/badge.svg)](https://example.com/chunkbox/view?id=ID)
+//
+// It reads "label | view". The label defaults to the site name, and
+// ?label=, ?color= (the "view" side) and ?labelColor= change it.
+const (
+    badgeMaxLabelChars     = 40
+    badgeDefaultColor      = "#007EC6"
+    badgeDefaultLabelColor = "#555"
+)
+
+// badgeColors are the shields.io color names a badge accepts besides hex
+// colors.
+var badgeColors = map[string]string{
+    "brightgreen": "#4C1",
+    "green":       "#97CA00",
+    "yellowgreen": "#A4A61D",
+    "yellow":      "#DFB317",
+    "orange":      "#FE7D37",
+    "red":         "#E05D44",
+    "blue":        "#007EC6",
+    "lightgrey":   "#9F9F9F",
+    "grey":        "#555",
+    "gray":        "#555",
+}
+
+var hexColorRX = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// badgeColor returns the SVG color for a ?color= value: a name from
+// badgeColors or a hex color, with or without its #. Anything else gives
+// def, so nothing but a color ever reaches the SVG.
+func badgeColor(value, def string) string {
+    if c, ok := badgeColors[strings.ToLower(value)]; ok {
+        return c
+    }
+    if hexColorRX.MatchString(value) {
+        return "#" + strings.TrimPrefix(value, "#")
+    }
+    return def
+}
+
+// badgeTextWidth estimates how wide text is in 11px Verdana. Badges are
+// drawn without measuring the font, like shields.io's own fallback.
+func badgeTextWidth(text string) int {
+    var w float64
+    for _, r := range text {
+        switch {
+        case r > unicode.MaxLatin1:
+            w += 11
+        case unicode.IsUpper(r) || r == 'm' || r == 'w':
+            w += 8.5
+        case r == 'i' || r == 'l' || r == 'j' || r == '.' || r == ',' || r == ' ' || r == '\'':
+            w += 3.5
+        default:
+            w += 6.5
+        }
+    }
+    return int(w + 0.5)
+}
+
+// renderBadge draws a badge. The label and message are escaped, and the
+// colors must come from badgeColor.
+func renderBadge(label, message, labelColor, color string) []byte {
+    lw := badgeTextWidth(label) + 10
+    mw := badgeTextWidth(message) + 10
+    w := lw + mw
+    title := html.EscapeString(label + ": " + message)
+    label, message = html.EscapeString(label), html.EscapeString(message)
+
+    var b strings.Builder
+    fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s">`, w, title)
+    fmt.Fprintf(&b, `<title>%s</title>`, title)
+    b.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+    fmt.Fprintf(&b, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, w)
+    fmt.Fprintf(&b, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="%s"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`, lw, labelColor, lw, mw, color, w)
+    b.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+    fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw/2, label, lw/2, label)
+    fmt.Fprintf(&b, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`, lw+mw/2, message, lw+mw/2, message)
+    b.WriteString(`</g></svg>`)
+    return []byte(b.String())
+}
+
+// chunkBadge serves GET /chunk/{id}/badge.svg. Like the preview images,
+// badges are for public chunks: private and expired ones are a 404 whoever
+// asks.
+func (app *application) chunkBadge(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+
+    chunk, err := app.chunks.GetMetaByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if chunk.Private {
+        app.notFound(w)
+        return
+    }
+
+    q := r.URL.Query()
+    label := strings.Join(strings.FieldsFunc(q.Get("label"), unicode.IsControl), " ")
+    if strings.TrimSpace(label) == "" {
+        label = app.branding.siteName
+    }
+    if utf8.RuneCountInString(label) > badgeMaxLabelChars {
+        label = string([]rune(label)[:badgeMaxLabelChars])
+    }
+    svg := renderBadge(label, "view", badgeColor(q.Get("labelColor"), badgeDefaultLabelColor), badgeColor(q.Get("color"), badgeDefaultColor))
+
+    w.Header().Set("Content-Type", "image/svg+xml")
+    w.Header().Set("Content-Length", strconv.Itoa(len(svg)))
+    // READMEs are shown through caching proxies, so the badge of a chunk
+    // which is deleted may be seen for up to an hour.
+    w.Header().Set("Cache-Control", "public, max-age=3600")
+    if r.Method == http.MethodHead {
+        return
+    }
+    w.Write(svg)
+}
@@ -0,0 +1,242 @@
+/*-----------------------------------------------------------
+ @Filename:         banner.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// The banner levels. They only change how the banner looks.
+const (
+    bannerInfo    = "info"
+    bannerWarning = "warning"
+)
+
+// bannerMaxChars is the longest banner message administrators can save.
+const bannerMaxChars = 500
+
+// bannerCookie remembers the banner a visitor dismissed, by its ID, so it
+// stays hidden until the message changes.
+const bannerCookie = "banner_dismissed"
+
+// The names of the banner settings in the settings table.
+const (
+    settingBanner      = "banner"
+    settingBannerLevel = "banner_level"
+)
+
+// A banner is a notice shown at the top of every page, such as a warning
+// about upcoming maintenance. An empty Message shows nothing. Return is
+// where the dismiss button sends the visitor back to.
+type banner struct {
+    Message string
+    Level   string
+    Return  string
+}
+
+// ID identifies the text of the banner, so dismissing one doesn't hide the
+// next.
+func (b banner) ID() string {
+    sum := sha256.Sum256([]byte(b.Level + "\n" + b.Message))
+    return hex.EncodeToString(sum[:8])
+}
+
+// A bannerState is the current banner: the one saved by an administrator
+// on /admin/banner, or else the one from -banner and -banner-level.
+// Without a database there is nowhere to save one, so it is always the
+// flags'.
+type bannerState struct {
+    mu       sync.RWMutex
+    current  banner
+    defaults banner
+    // saved is true while current is the administrator's.
+    saved    bool
+    settings *models.SettingModel
+}
+
+// newBannerState loads the saved banner, if there is one.
+func newBannerState(defaults banner, settings *models.SettingModel) (*bannerState, error) {
+    s := &bannerState{current: defaults, defaults: defaults, settings: settings}
+    if settings == nil {
+        return s, nil
+    }
+    message, err := settings.Get(settingBanner)
+    if errors.Is(err, models.ErrNoRecord) {
+        return s, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    level, err := settings.Get(settingBannerLevel)
+    if err != nil && !errors.Is(err, models.ErrNoRecord) {
+        return nil, err
+    }
+    if level != bannerWarning {
+        level = bannerInfo
+    }
+    s.current, s.saved = banner{Message: message, Level: level}, true
+    return s, nil
+}
+
+func (s *bannerState) get() banner {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.current
+}
+
+// set saves the banner, which is shown from the next page on.
+func (s *bannerState) set(b banner) error {
+    err := s.settings.Set(map[string]string{settingBanner: b.Message, settingBannerLevel: b.Level})
+    if err != nil {
+        return err
+    }
+    s.mu.Lock()
+    s.current, s.saved = b, true
+    s.mu.Unlock()
+    return nil
+}
+
+// reset forgets the saved banner, going back to the flags'.
+func (s *bannerState) reset() error {
+    err := s.settings.Delete(settingBanner, settingBannerLevel)
+    if err != nil {
+        return err
+    }
+    s.mu.Lock()
+    s.current, s.saved = s.defaults, false
+    s.mu.Unlock()
+    return nil
+}
+
+// setDefaults changes the flags' banner, on a reload. A saved banner still
+// wins over it.
+func (s *bannerState) setDefaults(b banner) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.defaults = b
+    if !s.saved {
+        s.current = b
+    }
+}
+
+// pageBanner returns the banner to show on a page, or nil if there is none
+// or the visitor dismissed it.
+func (app *application) pageBanner(r *http.Request) *banner {
+    b := app.banner.get()
+    if b.Message == "" {
+        return nil
+    }
+    if c, err := r.Cookie(bannerCookie); err == nil && c.Value == b.ID() {
+        return nil
+    }
+    b.Return = app.url(r.URL.RequestURI())
+    return &b
+}
+
+// bannerDismissPost hides the current banner for the visitor, with a
+// cookie, and sends them back to the page they were on.
+func (app *application) bannerDismissPost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    http.SetCookie(w, &http.Cookie{
+        Name:     bannerCookie,
+        Value:    app.banner.get().ID(),
+        Path:     app.basePath + "/",
+        MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+        HttpOnly: true,
+        Secure:   app.isHTTPS(r),
+        SameSite: http.SameSiteLaxMode,
+    })
+
+    // Only send the visitor back to a page of this site.
+    back := r.PostForm.Get("return")
+    if !app.localPath(back) {
+        back = app.url("/")
+    }
+    http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+type bannerForm struct {
+    Message string
+    Level   string
+    validator.Validator
+}
+
+// adminBanner shows the form to change the banner on GET, and saves it on
+// POST. Saving an empty message hides the banner, even one set by -banner;
+// the reset button goes back to the flags.
+func (app *application) adminBanner(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        b := app.banner.get()
+        data := app.newTemplateData(r)
+        data.Form = bannerForm{Message: b.Message, Level: b.Level}
+        app.render(w, http.StatusOK, "banner.html", data)
+    case http.MethodPost:
+        app.adminBannerPost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+func (app *application) adminBannerPost(w http.ResponseWriter, r *http.Request) {
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    var flash string
+    if r.PostForm.Get("reset") != "" {
+        err = app.banner.reset()
+        flash = "The banner is back to the one set with -banner."
+    } else {
+        form := bannerForm{
+            Message: strings.TrimSpace(r.PostForm.Get("message")),
+            Level:   r.PostForm.Get("level"),
+        }
+        form.CheckField(validator.MaxChars(form.Message, bannerMaxChars), "message", "This field cannot be more than 500 characters long")
+        form.CheckField(form.Level == bannerInfo || form.Level == bannerWarning, "level", "This field must be info or warning")
+        if !form.Valid() {
+            data := app.newTemplateData(r)
+            data.Form = form
+            app.render(w, http.StatusUnprocessableEntity, "banner.html", data)
+            return
+        }
+        err = app.banner.set(banner{Message: form.Message, Level: form.Level})
+        flash = "The banner has been saved."
+        if form.Message == "" {
+            flash = "The banner has been removed."
+        }
+    }
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // Every cached page has the old banner on it.
+    app.responseCache.clear()
+    app.audit(r, app.authenticatedUserID(r), auditBannerUpdate, "setting:"+settingBanner)
+    app.sessionManager.Put(r.Context(), "flash", flash)
+    http.Redirect(w, r, app.url("/admin/banner"), http.StatusSeeOther)
+}
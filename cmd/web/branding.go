@@ -0,0 +1,110 @@
+/*-----------------------------------------------------------
+ @Filename:         branding.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/ui"
+)
+
+// The branding struct holds the values used to rebrand an instance without
+// editing the templates. They come from the -site-name, -site-logo-url and
+// -favicon-path command-line flags.
+type branding struct {
+    siteName    string
+    siteLogoURL string
+    // favicon is the icon served at /favicon.ico, read once at startup
+    // either from -favicon-path or from the embedded default.
+    favicon     []byte
+    faviconName string
+    faviconTime time.Time
+}
+
+// newBranding validates the branding flags and loads the favicon. Any
+// problem is returned as an error so main() can fail at startup rather than
+// serving broken pages.
+func newBranding(siteName, siteLogoURL, faviconPath string) (*branding, error) {
+    siteName = strings.TrimSpace(siteName)
+    if siteName == "" {
+        return nil, errors.New("-site-name must not be blank")
+    }
+
+    // The logo may either be a path on this server (like /static/img/logo.png)
+    // or an absolute http(s) URL.
+    if siteLogoURL != "" {
+        u, err := url.Parse(siteLogoURL)
+        if err != nil {
+            return nil, fmt.Errorf("-site-logo-url: %w", err)
+        }
+        isLocal := u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+        isRemote := (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+        if !isLocal && !isRemote {
+            return nil, fmt.Errorf("-site-logo-url %q must be an absolute path or an http(s) URL", siteLogoURL)
+        }
+    }
+
+    b := &branding{
+        siteName:    siteName,
+        siteLogoURL: siteLogoURL,
+        faviconTime: time.Now(),
+    }
+
+    if faviconPath == "" {
+        // Fall back to the favicon embedded in the binary.
+        icon, err := ui.Files.ReadFile("static/img/favicon.ico")
+        if err != nil {
+            return nil, err
+        }
+        b.favicon = icon
+        b.faviconName = "favicon.ico"
+        return b, nil
+    }
+
+    info, err := os.Stat(faviconPath)
+    if err != nil {
+        return nil, fmt.Errorf("-favicon-path: %w", err)
+    }
+    if !info.Mode().IsRegular() {
+        return nil, fmt.Errorf("-favicon-path %q is not a regular file", faviconPath)
+    }
+    icon, err := os.ReadFile(faviconPath)
+    if err != nil {
+        return nil, fmt.Errorf("-favicon-path: %w", err)
+    }
+    b.favicon = icon
+    // The file name is used by http.ServeContent to pick the Content-Type,
+    // so a custom .png or .svg icon is served correctly.
+    b.faviconName = filepath.Base(faviconPath)
+    b.faviconTime = info.ModTime()
+
+    return b, nil
+}
+
+// logoOrigin returns the scheme and host of a remote logo URL (for the
+// Content-Security-Policy img-src directive), or "" for a local logo.
+func (b *branding) logoOrigin() string {
+    u, err := url.Parse(b.siteLogoURL)
+    if err != nil || u.Host == "" {
+        return ""
+    }
+    return u.Scheme + "://" + u.Host
+}
+
+// favicon serves the configured favicon (or the embedded default).
+// http.ServeContent takes care of the Content-Type and the conditional
+// request headers.
+func (app *application) favicon(w http.ResponseWriter, r *http.Request) {
+    http.ServeContent(w, r, app.branding.faviconName, app.branding.faviconTime, bytes.NewReader(app.branding.favicon))
+}
@@ -0,0 +1,65 @@
+/*-----------------------------------------------------------
+ @Filename:         bytequota.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "strconv"
+)
+
+// withinByteQuota reports whether a user can store extra more bytes of
+// content without their non-expired chunks going over the -user-byte-quota.
+// extra may be negative, for an edit which shortens a chunk. Anonymous
+// chunks (a userID of 0) and servers without a quota always fit.
+func (app *application) withinByteQuota(userID int, extra int64) (bool, error) {
+    if app.userByteQuota == 0 || userID == 0 || extra <= 0 {
+        return true, nil
+    }
+    used, err := app.chunks.TotalBytesByUser(userID)
+    if err != nil {
+        return false, err
+    }
+    return used+extra <= app.userByteQuota, nil
+}
+
+// byteQuotaMessage is the error shown on the forms, and returned by the API,
+// when a user's chunks would go over the -user-byte-quota.
+func (app *application) byteQuotaMessage() string {
+    return fmt.Sprintf("Your chunks can take up at most %s in total. Delete some of them to make room for this one.", formatBytes(int(app.userByteQuota)))
+}
+
+// storageUsage is the space a user's chunks take up, for the account page.
+// Quota and Percent are only set when there is a -user-byte-quota.
+type storageUsage struct {
+    Used    string
+    Quota   string
+    Percent int
+}
+
+func (app *application) storageUsage(userID int) (*storageUsage, error) {
+    used, err := app.chunks.TotalBytesByUser(userID)
+    if err != nil {
+        return nil, err
+    }
+    usage := &storageUsage{Used: approxBytes(used)}
+    if app.userByteQuota > 0 {
+        usage.Quota = formatBytes(int(app.userByteQuota))
+        usage.Percent = int(used * 100 / app.userByteQuota)
+    }
+    return usage, nil
+}
+
+// approxBytes formats a size in bytes for people to one decimal place, as
+// in "1.5 MB", unlike formatBytes which is exact for limits.
+func approxBytes(n int64) string {
+    switch {
+    case n >= 1<<20:
+        return strconv.FormatFloat(float64(n)/(1<<20), 'f', 1, 64) + " MB"
+    case n >= 1<<10:
+        return strconv.FormatFloat(float64(n)/(1<<10), 'f', 1, 64) + " KB"
+    }
+    return strconv.FormatInt(n, 10) + " bytes"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/captcha"
+    "github.com/cpucortexm/chunkbox/internal/fetch"
+)
+
+// TestAPICaptcha checks that the API isn't a way around the CAPTCHA asked
+// for on the create form: anonymous requests are refused, and logged-in
+// users aren't asked for one anywhere.
+func TestAPICaptcha(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    users.add(t, "Alice", "alice@example.com", "pa55word")
+    verifier, err := captcha.New("hcaptcha", "secret", "sitekey")
+    if err != nil {
+        t.Fatal(err)
+    }
+    app.captcha = verifier
+    app.fetcher = fetch.New(false, 1<<20, time.Second)
+    ts := newTestServer(t, app.routes())
+
+    requests := []struct {
+        path string
+        body string
+    }{
+        {"/api/v1/chunks/batch", `[{"title": "t", "content": "c"}]`},
+        {"/api/v1/fetch", `{"url": "http://127.0.0.1/"}`},
+    }
+    for _, req := range requests {
+        resp, body := ts.postJSON(t, req.path, req.body)
+        if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "captcha_required") {
+            t.Errorf("anonymous %s: status %d: %s", req.path, resp.StatusCode, body)
+        }
+    }
+
+    ts.login(t, "alice@example.com", "pa55word")
+    if resp, body := ts.postJSON(t, requests[0].path, requests[0].body); resp.StatusCode != http.StatusCreated {
+        t.Errorf("logged in: status %d: %s", resp.StatusCode, body)
+    }
+}
@@ -0,0 +1,95 @@
+/*-----------------------------------------------------------
+ @Filename:         comments.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "unicode"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// maxCommentChars is the longest comment we accept.
+const maxCommentChars = 2000
+
+// The commentForm holds the form data and validation errors for posting a
+// comment under a chunk.
+type commentForm struct {
+    Body string
+    validator.Validator
+}
+
+// commentPost adds a comment to a chunk. Only logged-in users can comment,
+// and only on chunks they can see.
+func (app *application) commentPost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    chunkID := r.PostForm.Get("chunk_id")
+    if chunkID == "" {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    chunk, err := app.chunks.GetByPublicID(chunkID)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if !app.canView(r, chunk) {
+        app.notFound(w)
+        return
+    }
+
+    form := commentForm{Body: sanitizeComment(r.PostForm.Get("body"))}
+    form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+    form.CheckField(validator.MaxChars(form.Body, maxCommentChars), "body", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentChars))
+    if !form.Valid() {
+        app.renderChunkView(w, r, http.StatusUnprocessableEntity, chunk, form)
+        return
+    }
+
+    _, err = app.comments.Insert(chunk.ID, app.authenticatedUserID(r), form.Body)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    app.responseCache.forget(chunk.PublicID)
+
+    app.sessionManager.Put(r.Context(), "flash", "Your comment has been posted.")
+    http.Redirect(w, r, app.url("/chunkbox/view?id="+chunk.PublicID+"#comments"), http.StatusSeeOther)
+}
+
+// sanitizeComment tidies up a submitted comment before it is stored: line
+// endings are normalized, control characters other than line breaks and tabs
+// are dropped, and surrounding whitespace is trimmed. The templates escape
+// the body when it is displayed, so no markup survives either way.
+func sanitizeComment(s string) string {
+    s = strings.ReplaceAll(s, "\r\n", "\n")
+    s = strings.Map(func(r rune) rune {
+        if r != '\n' && r != '\t' && unicode.IsControl(r) {
+            return -1
+        }
+        return r
+    }, s)
+    return strings.TrimSpace(s)
+}
@@ -0,0 +1,127 @@
+/*-----------------------------------------------------------
+ @Filename:         createqueue.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "time"
+)
+
+// With -create-queue-depth, requests creating chunks which are over the
+// -rate-limit wait in a queue instead of getting 429 straight away, and are
+// let through as the limit allows: a script creating 50 chunks in a loop
+// gets them all created, just more slowly. Only when the queue is full is
+// the request refused. A worker goes through the queue in order, so one
+// client over its limit holds up the clients queued behind it; the queue
+// is meant for bursts, not as a way around the limit.
+type createQueue struct {
+    items chan *queuedCreate
+    // stopped is closed when the worker has stopped, at shutdown.
+    stopped chan struct{}
+}
+
+// A queuedCreate is a request waiting in the queue. The worker closes
+// ready when the rate limit lets it through.
+type queuedCreate struct {
+    ctx   context.Context
+    ip    string
+    ready chan struct{}
+}
+
+// errQueueStopped is what waiting gives when the server shuts down first.
+var errQueueStopped = errors.New("the create queue has stopped")
+
+// newCreateQueue returns a queue for depth requests: the one the worker is
+// on and depth-1 in the channel.
+func newCreateQueue(depth int) *createQueue {
+    return &createQueue{items: make(chan *queuedCreate, depth-1), stopped: make(chan struct{})}
+}
+
+// wait queues a request from the ip and blocks until its turn. It reports
+// false if the queue is full, and an error if the request's context ended
+// (the client went away or timed out) or the server shut down before then.
+func (q *createQueue) wait(ctx context.Context, ip string) (bool, error) {
+    item := &queuedCreate{ctx: ctx, ip: ip, ready: make(chan struct{})}
+    select {
+    case q.items <- item:
+    default:
+        return false, nil
+    }
+
+    select {
+    case <-item.ready:
+        return true, nil
+    case <-ctx.Done():
+        return true, ctx.Err()
+    case <-q.stopped:
+        return true, errQueueStopped
+    }
+}
+
+// startCreateQueue runs the worker of the queue until shutdown.
+func (app *application) startCreateQueue() {
+    app.background.Go(func(ctx context.Context) {
+        defer close(app.createQueue.stopped)
+        for {
+            select {
+            case item := <-app.createQueue.items:
+                app.admitQueued(ctx, item)
+            case <-ctx.Done():
+                return
+            }
+        }
+    })
+}
+
+// admitQueued waits until the rate limit allows the request, and lets it
+// through. A request which gave up while waiting is dropped.
+func (app *application) admitQueued(ctx context.Context, item *queuedCreate) {
+    for item.ctx.Err() == nil {
+        // The limiter is looked up each time, as a SIGHUP can change it.
+        limiter := app.limiter.Load()
+        if limiter == nil {
+            close(item.ready)
+            return
+        }
+        // Like rateLimit, let the request through if the limiter fails.
+        ok, err := limiter.Allow(item.ctx, item.ip)
+        if ok || (err != nil && item.ctx.Err() == nil) {
+            close(item.ready)
+            return
+        }
+
+        // A token comes back every interval.
+        timer := time.NewTimer(limiter.interval)
+        select {
+        case <-timer.C:
+        case <-item.ctx.Done():
+            timer.Stop()
+        case <-ctx.Done():
+            timer.Stop()
+            return
+        }
+    }
+}
+
+// queueCreates marks the requests of the routes which create chunks, for
+// rateLimit to queue them rather than refuse them (-create-queue-depth).
+func (app *application) queueCreates(next http.Handler) http.Handler {
+    if app.createQueue == nil {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ctx := context.WithValue(r.Context(), queueableContextKey, true)
+        next.ServeHTTP(w, r.WithContext(ctx))
+    })
+}
+
+// queueable reports whether queueCreates marked the request.
+func queueable(r *http.Request) bool {
+    ok, _ := r.Context().Value(queueableContextKey).(bool)
+    return ok
+}
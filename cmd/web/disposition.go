@@ -0,0 +1,81 @@
+/*-----------------------------------------------------------
+ @Filename:         disposition.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "mime"
+    "strings"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// scriptableTypes are never served inline, whatever -inline-types says: a
+// browser runs the scripts in them, in our origin.
+var scriptableTypes = map[string]bool{
+    "text/html":             true,
+    "application/xhtml+xml": true,
+    "image/svg+xml":         true,
+    "text/xml":              true,
+    "application/xml":       true,
+}
+
+// An inlinePolicy lists the media types the download endpoint serves with
+// Content-Disposition: inline, so the browser displays them instead of
+// saving them. Everything else, and everything by default, is sent as an
+// attachment. Entries are either a full media type, like "image/png", or a
+// whole top-level type, like "image/*".
+type inlinePolicy []string
+
+// parseInlinePolicy parses the -inline-types flag, a comma-separated list
+// of media types.
+func parseInlinePolicy(s string) (inlinePolicy, error) {
+    var policy inlinePolicy
+    for _, t := range strings.Split(s, ",") {
+        t = strings.ToLower(strings.TrimSpace(t))
+        if t == "" {
+            continue
+        }
+        mediaType, params, err := mime.ParseMediaType(t)
+        if err != nil || len(params) != 0 || !strings.Contains(mediaType, "/") || strings.HasPrefix(mediaType, "*") {
+            return nil, fmt.Errorf("-inline-types: %q is not a media type like image/png or image/*", t)
+        }
+        if scriptableTypes[mediaType] {
+            return nil, fmt.Errorf("-inline-types: %s can't be served inline", mediaType)
+        }
+        policy = append(policy, mediaType)
+    }
+    return policy, nil
+}
+
+// inline reports whether content of the media type is served inline.
+func (p inlinePolicy) inline(mediaType string) bool {
+    if scriptableTypes[mediaType] {
+        return false
+    }
+    for _, t := range p {
+        if t == mediaType || strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+            return true
+        }
+    }
+    return false
+}
+
+// disposition returns the Content-Disposition type for downloading content
+// of the media type: "inline" or "attachment".
+func (p inlinePolicy) disposition(mediaType string) string {
+    if p.inline(mediaType) {
+        return "inline"
+    }
+    return "attachment"
+}
+
+// chunkMediaType returns the media type a chunk's content is served as.
+// Chunks only hold text for now; this is where other kinds of content
+// would get their own type.
+func chunkMediaType(chunk *models.Chunk) string {
+    return "text/plain"
+}
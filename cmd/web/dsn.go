@@ -0,0 +1,57 @@
+/*-----------------------------------------------------------
+ @Filename:         dsn.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net"
+    "strconv"
+
+    "github.com/go-sql-driver/mysql"
+)
+
+// dsnExample is a -dsn to copy in the error messages.
+const dsnExample = "user:password@tcp(localhost:3306)/chunkbox?parseTime=true"
+
+// checkDSN parses the -dsn and checks the parts the driver would only
+// complain about on the first connection, if at all: the address and the
+// database name. sql.Open doesn't parse the DSN, so without this a typo
+// shows up as a puzzling error from Ping, or not until the first query.
+// The errors name the part which is wrong and never include the password.
+func checkDSN(dsn string) (*mysql.Config, error) {
+    cfg, err := mysql.ParseDSN(dsn)
+    if err != nil {
+        return nil, fmt.Errorf("-dsn is not a valid MySQL DSN (%v); it should look like %s", err, dsnExample)
+    }
+
+    switch cfg.Net {
+    case "tcp", "tcp4", "tcp6":
+        _, port, err := net.SplitHostPort(cfg.Addr)
+        if err != nil {
+            return nil, fmt.Errorf("-dsn: the address %q should be host:port, as in tcp(localhost:3306)", cfg.Addr)
+        }
+        if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+            return nil, fmt.Errorf("-dsn: the port %q of the address %q must be a number from 1 to 65535", port, cfg.Addr)
+        }
+    case "unix":
+        if cfg.Addr == "" {
+            return nil, errors.New("-dsn: the unix socket has no path, as in unix(/var/run/mysqld/mysqld.sock)")
+        }
+    default:
+        return nil, fmt.Errorf("-dsn: unknown protocol %q (choose tcp or unix)", cfg.Net)
+    }
+
+    if cfg.DBName == "" {
+        return nil, fmt.Errorf("-dsn names no database: put its name after the slash, as in %s", dsnExample)
+    }
+    // The models scan DATETIME columns into time.Time, which the driver only
+    // does with parseTime.
+    if !cfg.ParseTime {
+        return nil, errors.New("-dsn must set parseTime=true, as in " + dsnExample)
+    }
+    return cfg, nil
+}
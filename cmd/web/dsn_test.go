@@ -0,0 +1,52 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestCheckDSN(t *testing.T) {
+    tests := []struct {
+        name string
+        dsn  string
+        // want is in the error, or empty for a valid DSN.
+        want string
+    }{
+        {name: "default", dsn: "web:pass@/chunkbox?parseTime=true"},
+        {name: "tcp", dsn: "web:pass@tcp(db.example.com:3306)/chunkbox?parseTime=true"},
+        {name: "ipv6", dsn: "web:pass@tcp([::1]:3306)/chunkbox?parseTime=true"},
+        {name: "unix socket", dsn: "web:pass@unix(/var/run/mysqld/mysqld.sock)/chunkbox?parseTime=true"},
+        {name: "no slash", dsn: "web:pass@tcp(localhost:3306)", want: "not a valid MySQL DSN"},
+        {name: "bad parameter", dsn: "web:pass@/chunkbox?parseTime=maybe", want: "not a valid MySQL DSN"},
+        {name: "unclosed address", dsn: "web:pass@tcp(localhost:3306/chunkbox?parseTime=true", want: "not a valid MySQL DSN"},
+        // The driver fills in the default port and socket.
+        {name: "no port", dsn: "web:pass@tcp(localhost)/chunkbox?parseTime=true"},
+        {name: "default socket", dsn: "web:pass@unix()/chunkbox?parseTime=true"},
+        {name: "empty port", dsn: "web:pass@tcp(localhost:)/chunkbox?parseTime=true", want: `the port ""`},
+        {name: "port not a number", dsn: "web:pass@tcp(localhost:mysql)/chunkbox?parseTime=true", want: `the port "mysql"`},
+        {name: "port out of range", dsn: "web:pass@tcp(localhost:70000)/chunkbox?parseTime=true", want: `the port "70000"`},
+        {name: "unknown protocol", dsn: "web:pass@udp(localhost:3306)/chunkbox?parseTime=true", want: `unknown protocol "udp"`},
+        {name: "no database", dsn: "web:pass@tcp(localhost:3306)/?parseTime=true", want: "names no database"},
+        {name: "no parseTime", dsn: "web:pass@/chunkbox", want: "parseTime=true"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := checkDSN(tt.dsn)
+            if tt.want == "" {
+                if err != nil {
+                    t.Fatal(err)
+                }
+                return
+            }
+            if err == nil {
+                t.Fatalf("no error, want one about %q", tt.want)
+            }
+            if !strings.Contains(err.Error(), tt.want) {
+                t.Errorf("error %q, want one about %q", err, tt.want)
+            }
+            if strings.Contains(err.Error(), "pass@") || strings.Contains(err.Error(), "web:pass") {
+                t.Errorf("error %q has the password", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,311 @@
+/*-----------------------------------------------------------
+ @Filename:         edit.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/textnorm"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// Chunks can be edited and deleted by their owner, or, for chunks created
+// anonymously, by whoever holds the chunk's edit token. The token is handed
+// out once, as a secret link on the page the creator lands on, and works
+// for -edit-window after the chunk was created.
+//
+// A token has the form <expiry unix time>.<signature>, where the signature
+// covers the chunk's public ID and the expiry, so it can't be moved to
+// another chunk or extended.
+
+// editToken returns an edit token for the chunk with the public ID id which
+// stops working at expires.
+func (app *application) editToken(id string, expires time.Time) string {
+    exp := strconv.FormatInt(expires.Unix(), 10)
+    return exp + "." + app.signer.Sign("edit", id, exp)
+}
+
+// validEditToken reports whether token is a valid, unexpired edit token for
+// the chunk with the public ID id.
+func (app *application) validEditToken(id, token string) bool {
+    exp, sig, ok := strings.Cut(token, ".")
+    if !ok || !app.signer.Verify(sig, "edit", id, exp) {
+        return false
+    }
+    unix, err := strconv.ParseInt(exp, 10, 64)
+    return err == nil && time.Now().Unix() < unix
+}
+
+// issueEditToken creates the edit token for a chunk just created by an
+// anonymous visitor and returns the secret edit link. It returns an empty
+// string when anonymous editing is off (-edit-window=0) or the chunk has an
+// owner, who can edit it anyway. The token never outlives the chunk.
+func (app *application) issueEditToken(r *http.Request, id string, userID, expiresDays int) (string, time.Time) {
+    if app.editWindow <= 0 || userID != 0 {
+        return "", time.Time{}
+    }
+    expires := time.Now().Add(app.editWindow)
+    if chunkExpires := time.Now().AddDate(0, 0, expiresDays); chunkExpires.Before(expires) {
+        expires = chunkExpires
+    }
+
+    query := url.Values{}
+    query.Set("id", id)
+    query.Set("token", app.editToken(id, expires))
+    return app.absoluteURL(r, "/chunkbox/edit?"+query.Encode()), expires
+}
+
+// canEdit reports whether the request may edit or delete the chunk: the
+// owner of the chunk always can, anyone else only with a valid edit token.
+func (app *application) canEdit(r *http.Request, chunk *models.Chunk, token string) bool {
+    if chunk.UserID != 0 && chunk.UserID == app.authenticatedUserID(r) {
+        return true
+    }
+    return token != "" && app.validEditToken(chunk.PublicID, token)
+}
+
+// editLinkKey is the session key the edit link of a new chunk is kept under
+// until the chunk's page has shown it.
+func editLinkKey(id string) string {
+    return "editLink:" + id
+}
+
+// chunkEditForm holds the fields of the edit form. Token is the edit token
+// the form was opened with, if any.
+type chunkEditForm struct {
+    ID       string
+    Token    string
+    Title    string
+    Content  string
+    Language string
+    Tags     string
+    // Secret and SecretConfirmed work like on the create form.
+    Secret          string
+    SecretConfirmed bool
+    validator.Validator
+}
+
+// editableChunk loads the chunk a request to the edit or delete endpoints is
+// for, checking that it may be changed. It writes the error response itself
+// and returns nil if not. Visitors who aren't the owner and bring no token
+// get a 404, like for a private chunk; a token which is wrong or has expired
+// gets a 403.
+func (app *application) editableChunk(w http.ResponseWriter, r *http.Request, id, token string) *models.Chunk {
+    if id == "" {
+        app.notFound(w)
+        return nil
+    }
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return nil
+    }
+
+    if !app.canEdit(r, chunk, token) {
+        if token != "" && app.canView(r, chunk) {
+            app.clientError(w, http.StatusForbidden)
+        } else {
+            app.notFound(w)
+        }
+        return nil
+    }
+    return chunk
+}
+
+// chunkEdit shows the edit form for a chunk (GET) and saves it (POST).
+func (app *application) chunkEdit(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet, http.MethodHead:
+        id, token := r.URL.Query().Get("id"), r.URL.Query().Get("token")
+        chunk := app.editableChunk(w, r, id, token)
+        if chunk == nil {
+            return
+        }
+        // The page carries the token, so keep it out of caches and
+        // referrers.
+        w.Header().Set("Cache-Control", "private, no-store")
+        w.Header().Set("Referrer-Policy", "no-referrer")
+        app.renderEdit(w, r, http.StatusOK, chunkEditForm{
+            ID:       chunk.PublicID,
+            Token:    token,
+            Title:    chunk.Title,
+            Content:  chunk.Content,
+            Language: chunk.Language,
+            Tags:     strings.Join(chunk.Tags, ", "),
+        })
+    case http.MethodPost:
+        app.chunkEditPost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead, http.MethodPost)
+    }
+}
+
+func (app *application) chunkEditPost(w http.ResponseWriter, r *http.Request) {
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    chunk := app.editableChunk(w, r, r.PostForm.Get("id"), r.PostForm.Get("token"))
+    if chunk == nil {
+        return
+    }
+
+    // The same conversion and checks as for a new chunk, see chunkCreatePost.
+    charset := submittedCharset(r)
+    title, err := app.decodeText(r.PostForm.Get("title"), charset)
+    var content string
+    var normalized bool
+    if err == nil {
+        content, normalized, err = app.normalizeContent(r.PostForm.Get("content"), charset)
+    }
+
+    form := chunkEditForm{
+        ID:       chunk.PublicID,
+        Token:    r.PostForm.Get("token"),
+        Title:    title,
+        Content:  content,
+        Language: app.normalizeLanguage(r.PostForm.Get("language")),
+        Tags:     r.PostForm.Get("tags"),
+    }
+    tags := parseTags(form.Tags)
+
+    switch {
+    case errors.Is(err, textnorm.ErrUnknownCharset):
+        form.AddNonFieldError(fmt.Sprintf("Text in the %q charset can't be accepted, please submit UTF-8.", charset))
+    case errors.Is(err, textnorm.ErrInvalidUTF8):
+        form.AddNonFieldError("Your chunk isn't valid UTF-8 text.")
+    case err != nil:
+        app.serverError(w, err)
+        return
+    default:
+        app.validateChunkText(&form.Validator, form.Title, form.Content, form.Language)
+        app.validateTags(&form.Validator, tags)
+    }
+    if form.Valid() && app.blocklist.Load().Matches(append([]string{form.Title, form.Content}, tags...)...) {
+        app.infoLog.Printf("blocklist: rejected chunk edit from %s: title=%q content=%q",
+            app.realIP(r), truncate(form.Title, 100), truncate(form.Content, 200))
+        form.AddNonFieldError("Your chunk could not be saved. Please check its content and try again.")
+        app.recordAbuse(r, abuseSpamPoints)
+    }
+    form.SecretConfirmed = r.PostForm.Get("confirm_secret") != ""
+    if form.Valid() {
+        form.Secret = app.checkSecret(&form.Validator, r, form.Content, nil, form.SecretConfirmed)
+        if form.Secret != "" {
+            app.renderEdit(w, r, http.StatusOK, form)
+            return
+        }
+    }
+    if !form.Valid() {
+        app.renderEdit(w, r, http.StatusUnprocessableEntity, form)
+        return
+    }
+
+    flash := "Chunk successfully updated!"
+    language := form.Language
+    if language == autoLanguage {
+        lang, ok := app.detectLanguage(form.Content)
+        language = lang.Name
+        if ok {
+            flash += " Detected language: " + lang.Label + "."
+        }
+    }
+
+    content, transformed := app.transformContent(&form.Validator, language, form.Content)
+    if !form.Valid() {
+        app.renderEdit(w, r, http.StatusUnprocessableEntity, form)
+        return
+    }
+
+    // An edit which makes the chunk bigger counts against the owner's
+    // -user-byte-quota.
+    ok, err := app.withinByteQuota(chunk.UserID, int64(len(content)-len(chunk.Content)))
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    if !ok {
+        form.AddNonFieldError(app.byteQuotaMessage())
+        app.renderEdit(w, r, http.StatusForbidden, form)
+        return
+    }
+
+    // Content the chunk already had normalized stays marked as such.
+    err = app.chunks.Update(chunk.ID, form.Title, content, language, normalized || transformed || chunk.Normalized, tags)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    app.forgetHighlight(chunk)
+    app.related.forget(chunk)
+    app.responseCache.forget(chunk.PublicID)
+    app.audit(r, app.authenticatedUserID(r), auditChunkEdit, chunkTarget(chunk.PublicID))
+
+    app.sessionManager.Put(r.Context(), "flash", flash)
+    http.Redirect(w, r, app.url("/chunkbox/view?id="+chunk.PublicID), http.StatusSeeOther)
+}
+
+// renderEdit displays the edit form.
+func (app *application) renderEdit(w http.ResponseWriter, r *http.Request, status int, form chunkEditForm) {
+    data := app.newTemplateData(r)
+    data.Form = form
+    data.Languages = app.languages
+    data.TagsEnabled = app.maxTags > 0
+    app.render(w, status, "edit.html", data)
+}
+
+// chunkDeletePost deletes a chunk. Like editing, it is open to the owner and
+// to holders of the edit token.
+func (app *application) chunkDeletePost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    chunk := app.editableChunk(w, r, r.PostForm.Get("id"), r.PostForm.Get("token"))
+    if chunk == nil {
+        return
+    }
+
+    err = app.chunks.Delete(chunk.ID)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    app.quota.removed(1)
+    app.forgetHighlight(chunk)
+    app.related.forget(chunk)
+    app.responseCache.forget(chunk.PublicID)
+    app.audit(r, app.authenticatedUserID(r), auditChunkDelete, chunkTarget(chunk.PublicID))
+
+    app.sessionManager.Put(r.Context(), "flash", "Chunk deleted.")
+    http.Redirect(w, r, app.url("/"), http.StatusSeeOther)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "net/http"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+)
+
+func TestChunkEditToken(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Anonymous", "old content")
+    other := insertChunk(t, app, "Other", "other content")
+    token := app.editToken(id, time.Now().Add(time.Hour))
+    _, sig, _ := strings.Cut(token, ".")
+
+    tests := []struct {
+        name  string
+        id    string
+        token string
+        want  int
+    }{
+        {"valid token", id, token, http.StatusOK},
+        {"token of another chunk", other, token, http.StatusForbidden},
+        {"expired token", id, app.editToken(id, time.Now().Add(-time.Second)), http.StatusForbidden},
+        {"extended token", id, "9999999999." + sig, http.StatusForbidden},
+        {"no token", id, "", http.StatusNotFound},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            query := url.Values{"id": {tt.id}, "token": {tt.token}}
+            if resp, _ := ts.get(t, "/chunkbox/edit?"+query.Encode()); resp.StatusCode != tt.want {
+                t.Errorf("status %d, want %d", resp.StatusCode, tt.want)
+            }
+        })
+    }
+
+    form := url.Values{"id": {id}, "token": {token}, "title": {"Edited"}, "content": {"new content"}, "language": {highlight.PlainText}}
+    resp, body := ts.postForm(t, "/chunkbox/edit?"+url.Values{"id": {id}, "token": {token}}.Encode(), form)
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Fatalf("saving: status %d: %s", resp.StatusCode, body)
+    }
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if chunk.Title != "Edited" || chunk.Content != "new content" {
+        t.Errorf("saved %q, %q", chunk.Title, chunk.Content)
+    }
+}
+
+func TestChunkEditOwner(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    owner := users.add(t, "Owner", "owner@example.com", "pa55word")
+    users.add(t, "Other", "other@example.com", "pa55word")
+    ts := newTestServer(t, app.routes())
+    id, err := app.chunks.Insert("Owned", "old content", 7, highlight.PlainText, owner, false, false, false, nil, nil, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    path := "/chunkbox/edit?id=" + id
+
+    otherServer := newTestServer(t, app.routes())
+    otherServer.login(t, "other@example.com", "pa55word")
+    if resp, _ := otherServer.get(t, path); resp.StatusCode != http.StatusNotFound {
+        t.Errorf("another user: status %d, want 404", resp.StatusCode)
+    }
+
+    ts.login(t, "owner@example.com", "pa55word")
+    if resp, _ := ts.get(t, path); resp.StatusCode != http.StatusOK {
+        t.Fatalf("owner: status %d", resp.StatusCode)
+    }
+    form := url.Values{"id": {id}, "title": {"Edited"}, "content": {"new content"}, "language": {highlight.PlainText}}
+    resp, body := ts.postForm(t, path, form)
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Fatalf("saving: status %d: %s", resp.StatusCode, body)
+    }
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if chunk.Content != "new content" {
+        t.Errorf("saved %q", chunk.Content)
+    }
+}
@@ -0,0 +1,104 @@
+/*-----------------------------------------------------------
+ @Filename:         email.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// An emailSender sends the emails of the application, see mailer.Mailer.
+type emailSender interface {
+    Send(to, subject, body, inReplyTo string) error
+}
+
+// A new email address is only used once the user has followed the link
+// emailed to it, which shows the address is theirs. Until then it is kept
+// as their pending email. The link is signed over the user's ID, the
+// address and its expiry, so it can't be used for another address or by
+// another account, and it has to be followed while logged in.
+
+// emailConfirmTTL is how long the link confirming a new email address works.
+const emailConfirmTTL = 24 * time.Hour
+
+// emailConfirmToken returns the signature for the link confirming the
+// address for the user, which stops working at the unix time exp.
+func (app *application) emailConfirmToken(userID int, email string, exp int64) string {
+    return app.signer.Sign("email", strconv.Itoa(userID), email, strconv.FormatInt(exp, 10))
+}
+
+// sendEmailConfirmation emails the link confirming a new address to it, in
+// the background.
+func (app *application) sendEmailConfirmation(r *http.Request, userID int, email string) {
+    exp := time.Now().Add(emailConfirmTTL).Unix()
+    query := url.Values{}
+    query.Set("email", email)
+    query.Set("exp", strconv.FormatInt(exp, 10))
+    query.Set("t", app.emailConfirmToken(userID, email, exp))
+    link := app.absoluteURL(r, "/account/email/confirm?"+query.Encode())
+
+    body := fmt.Sprintf("Someone, hopefully you, asked for this to be the email address of their %s account.\n\n"+
+        "To confirm it, follow this link within a day, logged in to the account:\n\n    %s\n\n"+
+        "If it wasn't you, you can ignore this email and the address won't be used.\n",
+        app.branding.siteName, link)
+    started := app.background.Go(func(context.Context) {
+        if err := app.mailer.Send(email, "Confirm your email address", body, ""); err != nil {
+            app.errorLog.Printf("email confirmation: sending to %s: %v", email, err)
+        }
+    })
+    if !started {
+        app.errorLog.Printf("email confirmation: not sent to %s, shutting down", email)
+    }
+}
+
+// accountEmailConfirm makes the pending email address of the logged-in user
+// theirs, from the link sent to it. A link which was changed, has expired
+// or is for another account gets a 403. One for an address the user has
+// since replaced with another is out of date.
+func (app *application) accountEmailConfirm(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    userID := app.authenticatedUserID(r)
+    email := r.URL.Query().Get("email")
+    exp, err := strconv.ParseInt(r.URL.Query().Get("exp"), 10, 64)
+    if err != nil || !app.signer.Verify(r.URL.Query().Get("t"), "email", strconv.Itoa(userID), email, strconv.FormatInt(exp, 10)) {
+        app.clientError(w, http.StatusForbidden)
+        return
+    }
+    if time.Now().Unix() >= exp {
+        app.clientError(w, http.StatusForbidden)
+        return
+    }
+
+    err = app.users.ConfirmEmail(userID, email)
+    if err != nil {
+        switch {
+        case errors.Is(err, models.ErrNoRecord):
+            app.sessionManager.Put(r.Context(), "flash", "That link is out of date: you have asked for another email address since.")
+        case errors.Is(err, models.ErrDuplicateEmail):
+            app.sessionManager.Put(r.Context(), "flash", "That email address is now used by another account.")
+        default:
+            app.serverError(w, err)
+            return
+        }
+        http.Redirect(w, r, app.url("/account/view"), http.StatusSeeOther)
+        return
+    }
+    app.audit(r, userID, auditEmailUpdate, userTarget(userID))
+
+    app.sessionManager.Put(r.Context(), "flash", "Your email address has been changed.")
+    http.Redirect(w, r, app.url("/account/view"), http.StatusSeeOther)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+    "net/http"
+    "net/url"
+    "regexp"
+    "strconv"
+    "testing"
+    "time"
+)
+
+// A sentEmail is an email the application sent through a fakeMailer.
+type sentEmail struct {
+    to, subject, body string
+}
+
+// A fakeMailer hands the emails sent through it to the test.
+type fakeMailer struct {
+    sent chan sentEmail
+}
+
+func withMailer(app *application) *fakeMailer {
+    m := &fakeMailer{sent: make(chan sentEmail, 10)}
+    app.mailer = m
+    return m
+}
+
+func (m *fakeMailer) Send(to, subject, body, inReplyTo string) error {
+    m.sent <- sentEmail{to, subject, body}
+    return nil
+}
+
+// next returns the next email sent, which is sent in the background.
+func (m *fakeMailer) next(t *testing.T) sentEmail {
+    t.Helper()
+
+    select {
+    case email := <-m.sent:
+        return email
+    case <-time.After(5 * time.Second):
+        t.Fatal("no email sent")
+    }
+    return sentEmail{}
+}
+
+var confirmLinkRX = regexp.MustCompile(`http\S+/account/email/confirm\?\S+`)
+
+// confirmPath returns the path of the confirmation link in an email.
+func confirmPath(t *testing.T, email sentEmail) string {
+    t.Helper()
+
+    link, err := url.Parse(confirmLinkRX.FindString(email.body))
+    if err != nil || link.Path == "" {
+        t.Fatalf("no confirmation link in %q", email.body)
+    }
+    return link.RequestURI()
+}
+
+func TestAccountEmailChange(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    mailer := withMailer(app)
+    alice := users.add(t, "Alice", "alice@example.com", "pa55word")
+    users.add(t, "Bob", "bob@example.com", "pa55word")
+    ts := newTestServer(t, app.routes())
+    ts.login(t, "alice@example.com", "pa55word")
+
+    update := func(email, password string) int {
+        t.Helper()
+        resp, _ := ts.postForm(t, "/account/update", url.Values{"name": {"Alice"}, "email": {email}, "currentPassword": {password}})
+        return resp.StatusCode
+    }
+    // emails checks the address and the pending one of Alice.
+    emails := func(email, pending string) {
+        t.Helper()
+        user, err := users.Get(alice)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if user.Email != email || user.PendingEmail != pending {
+            t.Errorf("email %q, pending %q; want %q, %q", user.Email, user.PendingEmail, email, pending)
+        }
+    }
+
+    if status := update("new@example.com", "wrong"); status != http.StatusUnprocessableEntity {
+        t.Errorf("wrong password: status %d", status)
+    }
+    if status := update("bob@example.com", "pa55word"); status != http.StatusUnprocessableEntity {
+        t.Errorf("address in use: status %d", status)
+    }
+    emails("alice@example.com", "")
+
+    if status := update("old@example.com", "pa55word"); status != http.StatusSeeOther {
+        t.Fatalf("status %d", status)
+    }
+    oldLink := confirmPath(t, mailer.next(t))
+    if status := update("new@example.com", "pa55word"); status != http.StatusSeeOther {
+        t.Fatalf("status %d", status)
+    }
+    sent := mailer.next(t)
+    if sent.to != "new@example.com" {
+        t.Errorf("confirmation sent to %q", sent.to)
+    }
+    link := confirmPath(t, sent)
+    // Nothing changes until the link is followed.
+    emails("alice@example.com", "new@example.com")
+
+    // Another account can't use the link, and it can't be changed.
+    bob := newTestServer(t, app.routes())
+    bob.login(t, "bob@example.com", "pa55word")
+    if resp, _ := bob.get(t, link); resp.StatusCode != http.StatusForbidden {
+        t.Errorf("another account: status %d, want 403", resp.StatusCode)
+    }
+    u, _ := url.Parse(link)
+    query := u.Query()
+    query.Set("email", "evil@example.com")
+    if resp, _ := ts.get(t, u.Path+"?"+query.Encode()); resp.StatusCode != http.StatusForbidden {
+        t.Errorf("changed address: status %d, want 403", resp.StatusCode)
+    }
+    exp := time.Now().Add(-time.Second).Unix()
+    expired := url.Values{"email": {"new@example.com"}, "exp": {strconv.FormatInt(exp, 10)}, "t": {app.emailConfirmToken(alice, "new@example.com", exp)}}
+    if resp, _ := ts.get(t, u.Path+"?"+expired.Encode()); resp.StatusCode != http.StatusForbidden {
+        t.Errorf("expired link: status %d, want 403", resp.StatusCode)
+    }
+    // The first address asked for was replaced by the second.
+    if resp, _ := ts.get(t, oldLink); resp.StatusCode != http.StatusSeeOther {
+        t.Errorf("replaced address: status %d", resp.StatusCode)
+    }
+    emails("alice@example.com", "new@example.com")
+
+    if resp, _ := ts.get(t, link); resp.StatusCode != http.StatusSeeOther {
+        t.Fatalf("confirming: status %d", resp.StatusCode)
+    }
+    emails("new@example.com", "")
+}
+
+func TestAccountEmailChangeWithoutMailer(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    id := users.add(t, "Alice", "alice@example.com", "pa55word")
+    ts := newTestServer(t, app.routes())
+    ts.login(t, "alice@example.com", "pa55word")
+
+    resp, _ := ts.postForm(t, "/account/update", url.Values{"name": {"Alice"}, "email": {"new@example.com"}, "currentPassword": {"pa55word"}})
+    if resp.StatusCode != http.StatusUnprocessableEntity {
+        t.Errorf("status %d, want 422", resp.StatusCode)
+    }
+    if user, _ := users.Get(id); user.PendingEmail != "" {
+        t.Errorf("pending email %q", user.PendingEmail)
+    }
+
+    // The name can still be changed.
+    resp, _ = ts.postForm(t, "/account/update", url.Values{"name": {"Alice B"}, "email": {"alice@example.com"}})
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Errorf("changing the name: status %d", resp.StatusCode)
+    }
+    if user, _ := users.Get(id); user.Name != "Alice B" {
+        t.Errorf("name %q", user.Name)
+    }
+}
@@ -0,0 +1,182 @@
+/*-----------------------------------------------------------
+ @Filename:         expiry.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// standardExpiries are the expiries, in days, the create form offers when
+// the limits allow them.
+var standardExpiries = []int{365, 7, 1}
+
+// An expiryPolicy is the expiry, in days, new chunks get by default and the
+// longest one they can ask for. Anonymous visitors get the -anon-* policy and
+// logged-in users the -user-* one; which applies only depends on whether the
+// creator is authenticated. Within a policy an expiry the creator chose wins
+// over the default, as long as it is one of the choices.
+//
+// Languages are the defaults of -language-expiry, which both policies share:
+// a shell one-liner can be gone in a day while a config file is kept for a
+// year. For a creator who didn't choose (an expiry of 0), the default of the
+// chunk's language applies, capped at Max, and Default otherwise. So an
+// explicit choice wins over the language's default, which wins over the
+// policy's.
+type expiryPolicy struct {
+    Default   int
+    Max       int
+    Languages map[string]int
+}
+
+// parseLanguageExpiries parses the -language-expiry flag, a comma-separated
+// list of language=days pairs. Like -language-size-limits, the languages
+// are stored under their canonical names.
+func parseLanguageExpiries(s string) (map[string]int, error) {
+    expiries := map[string]int{}
+    for _, pair := range strings.Split(s, ",") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(pair, "=")
+        if !ok {
+            return nil, fmt.Errorf("-language-expiry: %q is not language=days", strings.TrimSpace(pair))
+        }
+        lang, ok := highlight.Lookup(name)
+        if !ok {
+            return nil, fmt.Errorf("-language-expiry: unknown language %q", strings.TrimSpace(name))
+        }
+        n, err := strconv.Atoi(strings.TrimSpace(value))
+        if err != nil || n < 1 {
+            return nil, fmt.Errorf("-language-expiry: the expiry of %s must be a positive number of days", lang.Name)
+        }
+        expiries[lang.Name] = n
+    }
+    return expiries, nil
+}
+
+// resolve returns the expiry of a chunk in the language (as stored, not
+// "auto") which the creator gave as expires, 0 when they didn't choose.
+func (p expiryPolicy) resolve(expires int, language string) int {
+    if expires != 0 {
+        return expires
+    }
+    if days, ok := p.Languages[language]; ok {
+        if days > p.Max {
+            return p.Max
+        }
+        return days
+    }
+    return p.Default
+}
+
+// formDefault is the expiry checked on a new create form: Default, or the
+// choice of the language's default when there are any, as the language
+// isn't known until the form is sent.
+func (p expiryPolicy) formDefault() int {
+    if len(p.Languages) > 0 {
+        return 0
+    }
+    return p.Default
+}
+
+// An expiryOption is one of the expiry choices on the create form.
+type expiryOption struct {
+    Days  int
+    Label string
+}
+
+// choices returns the expiries the policy allows, longest first: the
+// standard ones up to Max, and Default and Max themselves.
+func (p expiryPolicy) choices() []int {
+    days := []int{p.Max}
+    if p.Default != p.Max {
+        days = append(days, p.Default)
+    }
+    for _, d := range standardExpiries {
+        if d < p.Max && d != p.Default {
+            days = append(days, d)
+        }
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(days)))
+    return days
+}
+
+// options returns the choices with their labels, for the create form, led
+// by the default of the chunk's language if there are any.
+func (p expiryPolicy) options() []expiryOption {
+    var options []expiryOption
+    if len(p.Languages) > 0 {
+        options = append(options, expiryOption{Days: 0, Label: "Default for the Language"})
+    }
+    for _, d := range p.choices() {
+        options = append(options, expiryOption{Days: d, Label: expiryLabel(d)})
+    }
+    return options
+}
+
+// message is the validation error for an expiry which isn't a choice.
+func (p expiryPolicy) message() string {
+    choices := p.choices()
+    s := make([]string, len(choices))
+    for i, d := range choices {
+        s[i] = strconv.Itoa(d)
+    }
+    if len(s) == 1 {
+        return "This field must equal " + s[0]
+    }
+    return "This field must equal " + strings.Join(s[:len(s)-1], ", ") + " or " + s[len(s)-1]
+}
+
+// expiryLabel names an expiry of the given number of days.
+func expiryLabel(days int) string {
+    switch days {
+    case 1:
+        return "One Day"
+    case 7:
+        return "One Week"
+    case 365:
+        return "One Year"
+    }
+    return strconv.Itoa(days) + " Days"
+}
+
+// expiryPolicy returns the policy for chunks created by this request.
+func (app *application) expiryPolicy(r *http.Request) expiryPolicy {
+    if app.isAuthenticated(r) {
+        return app.userExpiry
+    }
+    return app.anonExpiry
+}
+
+// keepAlive pushes back the expiry of a chunk being viewed, if it was
+// created to be kept alive while viewed (-extend-on-view), and updates
+// chunk.Expires to match, so the page shows the new expiry. The pages of
+// such chunks aren't kept by the response cache, so every view gets here.
+func (app *application) keepAlive(chunk *models.Chunk) error {
+    if app.extendOnView == 0 || !chunk.KeepAlive {
+        return nil
+    }
+    err := app.chunks.ExtendExpiry(chunk.ID, app.extendOnView, app.keepAliveMaxLifetime)
+    if err != nil {
+        return err
+    }
+    expires := time.Now().UTC().Add(app.extendOnView)
+    if limit := chunk.Created.Add(app.keepAliveMaxLifetime); expires.After(limit) {
+        expires = limit
+    }
+    if expires.After(chunk.Expires) {
+        chunk.Expires = expires.Truncate(time.Second)
+    }
+    return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "reflect"
+    "strconv"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+)
+
+func TestParseLanguageExpiries(t *testing.T) {
+    tests := []struct {
+        s       string
+        want    map[string]int
+        wantErr bool
+    }{
+        {s: "", want: map[string]int{}},
+        {s: "bash=1", want: map[string]int{"bash": 1}},
+        // Names are stored canonical, and spaces and empty pairs ignored.
+        {s: " sh = 1 ,, yml=365,", want: map[string]int{"bash": 1, "yaml": 365}},
+        {s: "bash", wantErr: true},
+        {s: "no-such-language=1", wantErr: true},
+        {s: "bash=0", wantErr: true},
+        {s: "bash=-1", wantErr: true},
+        {s: "bash=day", wantErr: true},
+    }
+    for _, tt := range tests {
+        got, err := parseLanguageExpiries(tt.s)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("parseLanguageExpiries(%q) = %v, want an error", tt.s, got)
+            }
+            continue
+        }
+        if err != nil || !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("parseLanguageExpiries(%q) = %v, %v; want %v", tt.s, got, err, tt.want)
+        }
+    }
+}
+
+func TestExpiryPolicyResolve(t *testing.T) {
+    p := expiryPolicy{Default: 7, Max: 30, Languages: map[string]int{"bash": 1, "yaml": 1000}}
+    tests := []struct {
+        name     string
+        expires  int
+        language string
+        want     int
+    }{
+        {"explicit choice over the language", 30, "bash", 30},
+        {"explicit choice without a language default", 1, highlight.PlainText, 1},
+        {"language default", 0, "bash", 1},
+        {"language default capped at max", 0, "yaml", 30},
+        {"policy default", 0, highlight.PlainText, 7},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := p.resolve(tt.expires, tt.language); got != tt.want {
+                t.Errorf("resolve(%d, %q) = %d, want %d", tt.expires, tt.language, got, tt.want)
+            }
+        })
+    }
+
+    if got := (expiryPolicy{Default: 7, Max: 30}).resolve(0, "bash"); got != 7 {
+        t.Errorf("resolve without -language-expiry = %d, want 7", got)
+    }
+}
+
+func TestChunkCreateLanguageExpiry(t *testing.T) {
+    app := newTestApplication(t)
+    app.anonExpiry = expiryPolicy{Default: 7, Max: 30, Languages: map[string]int{"bash": 1, "yaml": 1000}}
+    ts := newTestServer(t, app.routes())
+
+    // days checks a chunk expires in about the number of days.
+    days := func(t *testing.T, id string, want int) {
+        t.Helper()
+
+        chunk, err := app.chunks.GetMetaByPublicID(id)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := time.Until(chunk.Expires).Hours() / 24; got < float64(want)-0.01 || got > float64(want) {
+            t.Errorf("expires in %.2f days, want %d", got, want)
+        }
+    }
+
+    tests := []struct {
+        expires  int
+        language string
+        want     int
+    }{
+        {30, "bash", 30},
+        {0, "bash", 1},
+        {0, "yaml", 30},
+        {0, highlight.PlainText, 7},
+    }
+    for _, tt := range tests {
+        t.Run(strconv.Itoa(tt.expires)+"/"+tt.language, func(t *testing.T) {
+            form := createForm("Expiring", "content of "+t.Name())
+            form.Set("expires", strconv.Itoa(tt.expires))
+            form.Set("language", tt.language)
+            resp, body := ts.postForm(t, "/chunkbox/create", form)
+            if resp.StatusCode != http.StatusSeeOther {
+                t.Fatalf("form: status %d: %s", resp.StatusCode, body)
+            }
+            days(t, chunkIDFrom(t, resp), tt.want)
+
+            // Left out of an API request, the expiry is 0 too.
+            item := map[string]any{"title": "Expiring", "content": "API content of " + t.Name(), "language": tt.language}
+            if tt.expires != 0 {
+                item["expires"] = tt.expires
+            }
+            req, err := json.Marshal([]any{item})
+            if err != nil {
+                t.Fatal(err)
+            }
+            resp, body = ts.postJSON(t, "/api/v1/chunks/batch", string(req))
+            if resp.StatusCode != http.StatusCreated {
+                t.Fatalf("API: status %d: %s", resp.StatusCode, body)
+            }
+            var created struct {
+                Chunks []struct {
+                    ID string `json:"id"`
+                } `json:"chunks"`
+            }
+            if err := json.Unmarshal([]byte(body), &created); err != nil || len(created.Chunks) != 1 {
+                t.Fatalf("created %s: %v", body, err)
+            }
+            days(t, created.Chunks[0].ID, tt.want)
+        })
+    }
+}
@@ -0,0 +1,94 @@
+/*-----------------------------------------------------------
+ @Filename:         favorites.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// favoritesPageSize is the number of chunks on each page of
+// /account/favorites.
+const favoritesPageSize = 20
+
+// A favoritesPage is the data for the /account/favorites page. PrevPage and
+// NextPage are the neighbouring page numbers, or 0 if there is no such page.
+type favoritesPage struct {
+    Chunks   []*models.Chunk
+    PrevPage int
+    NextPage int
+}
+
+// favoritePost stars or unstars a chunk for the logged-in user, at POST
+// /chunk/{id}/favorite, and sends them back to the chunk.
+func (app *application) favoritePost(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    // Only chunks the user can see can be starred, and a private chunk gets
+    // the same 404 as one that doesn't exist.
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if !app.canView(r, chunk) {
+        app.notFound(w)
+        return
+    }
+
+    starred, err := app.favorites.Toggle(app.authenticatedUserID(r), chunk.ID)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    // The number of stars shows on the page.
+    app.responseCache.forget(chunk.PublicID)
+
+    if starred {
+        app.sessionManager.Put(r.Context(), "flash", "Added to your favorites.")
+    } else {
+        app.sessionManager.Put(r.Context(), "flash", "Removed from your favorites.")
+    }
+    http.Redirect(w, r, app.url("/chunkbox/view?id="+chunk.PublicID), http.StatusSeeOther)
+}
+
+// accountFavorites lists the chunks the logged-in user has starred, most
+// recently starred first, paginated by the "page" query parameter.
+func (app *application) accountFavorites(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    page, ok := app.pageNumber(w, r)
+    if !ok {
+        return
+    }
+
+    chunks, hasNext, err := app.favorites.ByUser(app.authenticatedUserID(r), page, favoritesPageSize)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    app.encodePublicIDs(chunks...)
+
+    data := app.newTemplateData(r)
+    data.Favorites = &favoritesPage{
+        Chunks:   chunks,
+        PrevPage: page - 1,
+        NextPage: app.nextPage(page, hasNext),
+    }
+    app.render(w, http.StatusOK, "favorites.html", data)
+}
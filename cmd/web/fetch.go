@@ -0,0 +1,154 @@
+/*-----------------------------------------------------------
+ @Filename:         fetch.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "path"
+    "strings"
+
+    "github.com/cpucortexm/chunkbox/internal/fetch"
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// apiFetchInput is the body of POST /api/v1/fetch: the URL to fetch and,
+// optionally, the same fields as a new chunk other than its content.
+type apiFetchInput struct {
+    URL      string   `json:"url"`
+    Title    string   `json:"title"`
+    Expires  int      `json:"expires"`
+    Language string   `json:"language"`
+    Private  bool     `json:"private"`
+    Tags     []string `json:"tags"`
+}
+
+// apiFetch creates a chunk from the resource at a URL, for archiving remote
+// snippets. The server downloads it with app.fetcher, which refuses private
+// addresses unless -fetch-allow-private is set, and it is then validated and
+// stored like a chunk created through the API. The title defaults to the
+// file name in the URL, and the language to the one the Content-Type or the
+// file name points to, or else to the one detected from the content.
+func (app *application) apiFetch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.apiMethodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    if app.apiRefuseAnonymous(w, r) {
+        return
+    }
+
+    var input apiFetchInput
+    err := app.readJSON(w, r, &input)
+    if err != nil {
+        app.apiReadError(w, err)
+        return
+    }
+    if strings.TrimSpace(input.URL) == "" {
+        app.writeAPIError(w, apiErr{
+            Status:      http.StatusUnprocessableEntity,
+            Code:        "validation_failed",
+            Message:     "the request is invalid",
+            FieldErrors: map[string]string{"url": "This field cannot be blank"},
+        })
+        return
+    }
+
+    resp, err := app.fetcher.Get(r.Context(), strings.TrimSpace(input.URL))
+    if err != nil {
+        app.infoLog.Printf("fetch: %s could not fetch %q: %v", app.realIP(r), truncate(input.URL, 200), err)
+        var status *fetch.StatusError
+        switch {
+        case errors.Is(err, fetch.ErrForbiddenAddress):
+            app.apiError(w, http.StatusForbidden, "forbidden_url", err.Error())
+        case errors.Is(err, fetch.ErrTooLarge):
+            app.apiError(w, http.StatusRequestEntityTooLarge, "too_large",
+                fmt.Sprintf("the resource is larger than %s", formatBytes(int(app.fetcher.MaxBytes))))
+        case errors.Is(err, fetch.ErrInvalidURL), errors.Is(err, fetch.ErrScheme):
+            app.writeAPIError(w, apiErr{
+                Status:      http.StatusUnprocessableEntity,
+                Code:        "validation_failed",
+                Message:     "the request is invalid",
+                FieldErrors: map[string]string{"url": err.Error()},
+            })
+        case errors.As(err, &status):
+            app.apiError(w, http.StatusBadGateway, "fetch_failed", err.Error())
+        default:
+            app.apiError(w, http.StatusBadGateway, "fetch_failed", "the URL could not be fetched: "+err.Error())
+        }
+        return
+    }
+
+    item := apiChunkInput{
+        Title:    input.Title,
+        Content:  string(resp.Body),
+        Expires:  input.Expires,
+        Language: input.Language,
+        Private:  input.Private,
+        Tags:     input.Tags,
+    }
+    if strings.TrimSpace(item.Title) == "" {
+        item.Title = fetchTitle(resp.URL, app.maxTitleLength)
+    }
+    if strings.TrimSpace(item.Language) == "" {
+        item.Language = app.fetchLanguage(resp)
+    }
+
+    chunk, fieldErrors, err := app.prepareAPIChunk(r, item, resp.Charset, app.expiryPolicy(r))
+    if err != nil {
+        app.apiServerError(w, err)
+        return
+    }
+    if fieldErrors != nil {
+        app.writeAPIError(w, apiErr{
+            Status:      http.StatusUnprocessableEntity,
+            Code:        "validation_failed",
+            Message:     "the fetched chunk is invalid, nothing was created",
+            FieldErrors: fieldErrors,
+        })
+        return
+    }
+
+    created := app.createAPIChunks(w, r, []models.ChunkInput{chunk})
+    if created == nil {
+        return
+    }
+    created[0]["source_url"] = resp.URL.String()
+
+    app.writeJSON(w, createdStatus(created), envelope{"chunk": created[0]})
+}
+
+// fetchTitle is the default title of a chunk fetched from u: the file name
+// at the end of its path, or the host if there is none, cut to max
+// characters.
+func fetchTitle(u *url.URL, max int) string {
+    title := path.Base(u.Path)
+    if title == "/" || title == "." {
+        title = u.Hostname()
+    }
+    if runes := []rune(title); len(runes) > max {
+        title = string(runes[:max])
+    }
+    return title
+}
+
+// fetchLanguage picks the language of a fetched chunk from its media type,
+// then from the file name in its URL. Most servers send code as text/plain,
+// which names no language, in which case it is detected from the content.
+func (app *application) fetchLanguage(resp *fetch.Response) string {
+    lang, ok := highlight.ForMediaType(resp.MediaType)
+    if !ok {
+        lang, ok = highlight.ForFilename(path.Base(resp.URL.Path))
+    }
+    if ok && app.languageAllowed(lang.Name) {
+        return lang.Name
+    }
+    return autoLanguage
+}
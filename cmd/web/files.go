@@ -0,0 +1,254 @@
+/*-----------------------------------------------------------
+ @Filename:         files.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "archive/zip"
+    "fmt"
+    "html/template"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// maxFilenameChars is the longest name a file of a multi-file chunk can
+// have, the width of the filename column.
+const maxFilenameChars = 100
+
+// A multi-file chunk is a chunk with up to -max-files files, like a gist.
+// Its first file is the chunk itself, named by chunkFilename; the others
+// have their own name, language and content (models.ChunkFile). Edits only
+// change the first file.
+
+// fileFields returns the other files of a create form, from the repeated
+// file_name, file_language and file_content fields, in order, with their
+// languages normalized. Files left completely blank are dropped unless
+// keepBlank is set, for showing the form again with the slots added by
+// "Add file".
+func (app *application) fileFields(form url.Values, keepBlank bool) []models.ChunkFile {
+    names, languages, contents := form["file_name"], form["file_language"], form["file_content"]
+    var files []models.ChunkFile
+    for i := range contents {
+        var f models.ChunkFile
+        f.Content = contents[i]
+        if i < len(names) {
+            f.Filename = strings.TrimSpace(names[i])
+        }
+        if i < len(languages) {
+            f.Language = languages[i]
+        }
+        f.Language = app.normalizeLanguage(f.Language)
+        if !keepBlank && f.Filename == "" && strings.TrimSpace(f.Content) == "" {
+            continue
+        }
+        files = append(files, f)
+    }
+    return files
+}
+
+// validFilename reports whether a file can be saved under the name: it has
+// no path in it, nor characters which would need quoting in headers.
+func validFilename(name string) bool {
+    if name == "." || name == ".." {
+        return false
+    }
+    for _, r := range name {
+        if r == '/' || r == '\\' || r == '"' || unicode.IsControl(r) {
+            return false
+        }
+    }
+    return true
+}
+
+// validateFiles checks the other files of a chunk whose first file has the
+// content. Their errors are reported together on the "files" field. Each
+// file gets the size limit of its language, and all of them together,
+// the first included, get -max-chunk-bytes.
+func (app *application) validateFiles(v *validator.Validator, content string, files []models.ChunkFile) {
+    if len(files) == 0 {
+        return
+    }
+    if len(files)+1 > app.maxFiles {
+        if app.maxFiles == 1 {
+            v.AddFieldError("files", "Chunks with several files are not enabled on this site")
+        } else {
+            v.AddFieldError("files", fmt.Sprintf("A chunk cannot have more than %d files", app.maxFiles))
+        }
+        return
+    }
+
+    seen := make(map[string]bool, len(files))
+    total := len(content)
+    for i, f := range files {
+        n := i + 2
+        total += len(f.Content)
+        switch {
+        case f.Filename == "":
+            v.AddFieldError("files", fmt.Sprintf("File %d needs a name", n))
+        case utf8.RuneCountInString(f.Filename) > maxFilenameChars:
+            v.AddFieldError("files", fmt.Sprintf("The name of file %d cannot be more than %d characters long", n, maxFilenameChars))
+        case !validFilename(f.Filename):
+            v.AddFieldError("files", fmt.Sprintf("The name of file %d cannot contain slashes, quotes or control characters", n))
+        case seen[f.Filename]:
+            v.AddFieldError("files", fmt.Sprintf("Two files are named %q", f.Filename))
+        case !validator.NotBlank(f.Content):
+            v.AddFieldError("files", fmt.Sprintf("File %s cannot be blank", f.Filename))
+        case f.Language != autoLanguage && !app.languageAllowed(f.Language):
+            v.AddFieldError("files", fmt.Sprintf("The language of %s is not supported", f.Filename))
+        case !validator.MaxBytes(f.Content, app.maxContentBytes(f.Language)):
+            v.AddFieldError("files", fmt.Sprintf("File %s cannot be larger than %s", f.Filename, formatBytes(app.maxContentBytes(f.Language))))
+        }
+        seen[f.Filename] = true
+    }
+    if total > app.maxChunkBytes {
+        v.AddFieldError("files", fmt.Sprintf("All the files together cannot be larger than %s", formatBytes(app.maxChunkBytes)))
+    }
+}
+
+// prepareFiles finishes the other files of a valid chunk for saving: their
+// "auto" languages are detected and the content transformers run on them.
+// It reports whether a transformer changed any of them.
+func (app *application) prepareFiles(v *validator.Validator, files []models.ChunkFile) ([]models.ChunkFile, bool) {
+    var changed bool
+    for i, f := range files {
+        if f.Language == autoLanguage {
+            lang, _ := app.detectLanguage(f.Content)
+            files[i].Language = lang.Name
+        }
+        var transformed bool
+        files[i].Content, transformed = app.transformContent(v, files[i].Language, f.Content)
+        changed = changed || transformed
+    }
+    return files, changed
+}
+
+// filesContent returns the content of every file of a chunk, for checking
+// them all against the blocklist.
+func filesContent(files []models.ChunkFile) []string {
+    contents := make([]string, 0, 2*len(files))
+    for _, f := range files {
+        contents = append(contents, f.Filename, f.Content)
+    }
+    return contents
+}
+
+// filesSize is the size of the other files of a chunk, for the byte quota.
+func filesSize(files []models.ChunkFile) int64 {
+    var n int64
+    for _, f := range files {
+        n += int64(len(f.Content))
+    }
+    return n
+}
+
+// A chunkFileView is one of the tabs of a multi-file chunk's view page.
+type chunkFileView struct {
+    Filename      string
+    Language      string
+    Content       string
+    Highlighted   template.HTML
+    RenderedPlain bool
+    RawURL        string
+    DownloadURL   string
+}
+
+// displayFiles adds the tabs of a multi-file chunk to a view page which
+// displayChunk has already filled in: the first file, as displayChunk
+// rendered it, then the others, highlighted the same way. Single-file
+// chunks get no tabs.
+func (app *application) displayFiles(r *http.Request, data *templateData, chunk *models.Chunk) {
+    if len(chunk.Files) == 0 {
+        return
+    }
+    base := "?id=" + url.QueryEscape(chunk.PublicID)
+    data.Files = append(data.Files, chunkFileView{
+        Filename:      chunkFilename(chunk),
+        Language:      data.Chunk.Language,
+        Content:       data.Chunk.Content,
+        Highlighted:   data.Highlighted,
+        RenderedPlain: data.RenderedPlain,
+        RawURL:        app.url("/chunkbox/raw" + base),
+        DownloadURL:   app.url("/chunkbox/download" + base),
+    })
+    for i, f := range chunk.Files {
+        // Each file is highlighted as a chunk of its own, cached under a
+        // variant of the chunk's key.
+        c := *chunk
+        c.Content, c.Language = f.Content, f.Language
+        variant := "file" + strconv.Itoa(i+1)
+        if data.Wrap == wrapTruncate {
+            content, _ := truncateLines(c.Content, app.wrapWidth)
+            c.Content = content
+            variant += "-" + app.truncateVariant()
+        }
+        view := chunkFileView{
+            Filename:    f.Filename,
+            Language:    f.Language,
+            Content:     c.Content,
+            RawURL:      app.url("/chunkbox/raw" + base + "&file=" + url.QueryEscape(f.Filename)),
+            DownloadURL: app.url("/chunkbox/download" + base + "&file=" + url.QueryEscape(f.Filename)),
+        }
+        if app.maxRenderBytes > 0 && len(c.Content) > app.maxRenderBytes {
+            view.RenderedPlain = true
+        } else {
+            view.Highlighted = app.highlightChunk(r.Context(), &c, variant)
+        }
+        data.Files = append(data.Files, view)
+    }
+}
+
+// findFile returns the index in chunk.Files of the other file with the
+// name, or -1.
+func findFile(chunk *models.Chunk, name string) int {
+    for i, f := range chunk.Files {
+        if f.Filename == name {
+            return i
+        }
+    }
+    return -1
+}
+
+// zipFilename is the name the zip of all of a chunk's files is saved under.
+func zipFilename(chunk *models.Chunk) string {
+    return strings.TrimSuffix(chunkFilename(chunk), ".txt") + ".zip"
+}
+
+// writeChunkZip sends every file of a chunk as a zip archive, for
+// /chunkbox/download?zip=1. The archive is written as it is made, so there
+// is no Content-Length.
+func (app *application) writeChunkZip(w http.ResponseWriter, r *http.Request, chunk *models.Chunk) {
+    w.Header().Set("Content-Type", "application/zip")
+    w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, zipFilename(chunk)))
+    w.Header().Set("ETag", chunkETag(chunk, "zip"))
+    w.Header().Set("Last-Modified", chunk.Modified().UTC().Format(http.TimeFormat))
+    w.Header().Set("X-Chunk-Expires", expiresHeader(chunk))
+    if r.Method == http.MethodHead {
+        return
+    }
+
+    files := append([]models.ChunkFile{{Filename: chunkFilename(chunk), Content: chunk.Content}}, chunk.Files...)
+    zw := zip.NewWriter(w)
+    for _, f := range files {
+        fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Filename, Method: zip.Deflate, Modified: chunk.Modified()})
+        if err == nil {
+            _, err = fw.Write([]byte(f.Content))
+        }
+        if err != nil {
+            // Part of the archive has been sent already.
+            app.errorLog.Printf("zipping chunk %d: %v", chunk.ID, err)
+            return
+        }
+    }
+    if err := zw.Close(); err != nil {
+        app.errorLog.Printf("zipping chunk %d: %v", chunk.ID, err)
+    }
+}
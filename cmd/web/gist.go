@@ -0,0 +1,123 @@
+/*-----------------------------------------------------------
+ @Filename:         gist.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "net/http"
+    "net/url"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// A gist is a chunk in the shape of a GitHub Gist, as returned by GitHub's
+// GET /gists/{id}, so tools which read gists can read chunks too. It is a
+// compatibility shim, not part of the chunkbox API: it only has the fields
+// a chunk can fill in, and its errors are GitHub's {"message": ...} rather
+// than our error envelope. A chunk is a gist with its files: one, or more
+// for a multi-file chunk.
+type gist struct {
+    ID          string              `json:"id"`
+    URL         string              `json:"url"`
+    HTMLURL     string              `json:"html_url"`
+    Description string              `json:"description"`
+    Public      bool                `json:"public"`
+    CreatedAt   time.Time           `json:"created_at"`
+    UpdatedAt   time.Time           `json:"updated_at"`
+    Files       map[string]gistFile `json:"files"`
+    Truncated   bool                `json:"truncated"`
+}
+
+type gistFile struct {
+    Filename  string `json:"filename"`
+    Type      string `json:"type"`
+    Language  string `json:"language"`
+    RawURL    string `json:"raw_url"`
+    Size      int    `json:"size"`
+    Truncated bool   `json:"truncated"`
+    Content   string `json:"content"`
+    // SHA256 isn't one of GitHub's fields: it is the hash of Content, as in
+    // the X-Content-SHA256 header of the raw endpoint.
+    SHA256    string `json:"sha256"`
+}
+
+// chunkGist serves GET /chunk/{id}.json, a chunk as a gist (-gist-json).
+// Private chunks are only served to their owner and expired ones not at
+// all, with a 404 like the view page.
+func (app *application) chunkGist(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        w.Header().Add("Allow", http.MethodGet)
+        w.Header().Add("Allow", http.MethodHead)
+        app.writeJSON(w, http.StatusMethodNotAllowed, envelope{"message": "Method Not Allowed"})
+        return
+    }
+
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.writeJSON(w, http.StatusNotFound, envelope{"message": "Not Found"})
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if !app.canView(r, chunk) {
+        app.writeJSON(w, http.StatusNotFound, envelope{"message": "Not Found"})
+        return
+    }
+
+    language := chunk.Language
+    if lang, ok := highlight.Lookup(chunk.Language); ok {
+        language = lang.Label
+    }
+    filename := chunkFilename(chunk)
+    sha := models.ContentSHA256(chunk.Content)
+    g := gist{
+        ID:          chunk.PublicID,
+        URL:         app.absoluteURL(r, "/chunk/"+chunk.PublicID+".json"),
+        HTMLURL:     app.absoluteURL(r, "/chunkbox/view?id="+chunk.PublicID),
+        Description: chunk.Title,
+        Public:      !chunk.Private,
+        CreatedAt:   chunk.Created.UTC().Truncate(time.Second),
+        UpdatedAt:   chunk.Modified().UTC().Truncate(time.Second),
+        Files: map[string]gistFile{
+            filename: {
+                Filename: filename,
+                Type:     chunkMediaType(chunk),
+                Language: language,
+                RawURL:   app.absoluteURL(r, "/chunkbox/raw?id="+chunk.PublicID),
+                Size:     len(chunk.Content),
+                Content:  chunk.Content,
+                SHA256:   sha,
+            },
+        },
+    }
+
+    if chunk.Private {
+        w.Header().Set("Cache-Control", "private, no-store")
+    }
+    for _, f := range chunk.Files {
+        language := f.Language
+        if lang, ok := highlight.Lookup(f.Language); ok {
+            language = lang.Label
+        }
+        g.Files[f.Filename] = gistFile{
+            Filename: f.Filename,
+            Type:     "text/plain",
+            Language: language,
+            RawURL:   app.absoluteURL(r, "/chunkbox/raw?id="+chunk.PublicID+"&file="+url.QueryEscape(f.Filename)),
+            Size:     len(f.Content),
+            Content:  f.Content,
+            SHA256:   models.ContentSHA256(f.Content),
+        }
+    }
+
+    // The header is the hash of the first file, as on the raw endpoint.
+    w.Header().Set("X-Content-SHA256", sha)
+    app.writeJSON(w, http.StatusOK, g)
+}
@@ -9,17 +9,24 @@ package main
 import (
 	"errors"
 	"fmt"
-	"html/template"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cpucortexm/chunkbox/internal/models"
+	"github.com/cpucortexm/chunkbox/internal/textnorm"
+	"github.com/cpucortexm/chunkbox/internal/validator"
 )
 
 // Start using the applications custom logger instead of the
 // Go's standard logger. Update handler functions so that they become
 // methods against the application struct.
 
+// homePageSize is the number of chunks on each page of the home page.
+const homePageSize = 10
+
 func (app *application) home(w http.ResponseWriter, r *http.Request){
     // Check if the current request URL path exactly matches "/". If it doesn't, use
     // the http.NotFound() function to send a 404 response to the client.
@@ -29,46 +36,93 @@ func (app *application) home(w http.ResponseWriter, r *http.Request){
         app.notFound(w) // use the app.notFound helper
         return
     }
-    // Initialize a slice containing the paths to the two files. It's important
-    // to note that the file containing our base template must be the *first*
-    // file in the slice.
-    files := []string{
-        "./ui/html/base.html",
-        "./ui/html/partials/nav.html",
-        "./ui/html/pages/home.html",
+    // Crawlers and repeat visitors can revalidate the page against the
+    // newest chunk instead of fetching it again. Pages which differ from
+    // visitor to visitor are always rendered in full.
+    if app.listingCacheable(r) {
+        modified, err := app.chunks.LatestModified()
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if app.notModified(w, r, modified) {
+            return
+        }
     }
-    // Use the template.ParseFiles() function to read the template file into a
-    // template set. If there's an error, we log the detailed error message and use
-    // the http.Error() function to send a generic 500 Internal Server Error
-    // response to the user.
-    ts, err := template.ParseFiles(files...)
-    if err != nil {
-        app.serverError(w,err) // Use the serverError() helper.
+
+    // Older chunks are paged through with the "after" parameter, the public
+    // ID of the last chunk on the previous page.
+    afterID := 0
+    if after := r.URL.Query().Get("after"); after != "" {
+        cursor, err := app.chunks.GetMetaByPublicID(after)
+        if err != nil {
+            if errors.Is(err, models.ErrNoRecord) {
+                app.notFound(w)
+            } else {
+                app.serverError(w, err)
+            }
+            return
+        }
+        if cursor.Private {
+            app.notFound(w)
+            return
+        }
+        afterID = cursor.ID
+    }
+    // HEAD gets the headers without the chunks being loaded.
+    if app.headOnly(w, r) {
         return
     }
 
-    // Use the ExecuteTemplate() method to write the content of the "base" 
-    // template as the response body.
-    err = ts.ExecuteTemplate(w, "base", nil)
+    // Only a short preview of each chunk's content is loaded, see the
+    // -preview-chars flag. One chunk more than fits is asked for, to know
+    // whether there is another page.
+    chunks, err := app.chunks.ListAfter(afterID, homePageSize+1, app.previewChars)
     if err != nil {
-        app.serverError(w,err) // Use the serverError() helper.
+        app.serverError(w, err)
+        return
+    }
+
+    data := app.newTemplateData(r)
+    if len(chunks) > homePageSize {
+        chunks = chunks[:homePageSize]
+        data.OlderCursor = chunks[len(chunks)-1].PublicID
     }
+    data.Chunks = chunks
+    data.Paged = afterID != 0
+
+    // On a fresh instance show a call to action instead of an empty list.
+    // The list can also be empty because every chunk is private, which isn't
+    // worth a welcome message.
+    if len(chunks) == 0 && afterID == 0 {
+        count, err := app.chunks.Count()
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if count == 0 {
+            data.EmptyMessage = app.emptyMessage
+        }
+    }
+
+    // Use the new render helper. The template set for the page is fetched
+    // from the cache built at startup, so we no longer parse the files on
+    // every request.
+    app.render(w, http.StatusOK, "home.html", data)
 }
 
 func (app *application)chunkView(w http.ResponseWriter, r *http.Request){
-    // Extract the value of the id parameter from the query string and try to
-    // convert it to an integer using the strconv.Atoi() function. If it can't
-    // be converted to an integer, or the value is less than 1, we return a 404 page
-    // not found response.
-    id, err :=  strconv.Atoi(r.URL.Query().Get("id"))
-    if err != nil || id < 1{
+    // Extract the value of the id parameter from the query string. This is
+    // the chunk's random public ID, never its database ID.
+    id := r.URL.Query().Get("id")
+    if id == ""{
         app.notFound(w) // use the app.notFound helper
         return
     }
-    // Use the ChunkModel object's Get method to retrieve the data for a
-    // specific record based on its ID. If no matching record is found,
-    // return a 404 Not Found response.
-    chunk, err := app.chunks.Get(id)
+    // Use the ChunkModel object's GetByPublicID method to retrieve the data
+    // for a specific record. If no matching record is found, return a 404
+    // Not Found response.
+    chunk, err := app.chunks.GetByPublicID(id)
 
     if err != nil{
         if errors.Is(err, models.ErrNoRecord){
@@ -79,34 +133,1289 @@ func (app *application)chunkView(w http.ResponseWriter, r *http.Request){
         return
     }
 
-      // Write the snippet data as a plain-text HTTP response body.
-    fmt.Fprintf(w, "%+v", chunk)
+    // Private chunks are only visible to their owner. Everyone else gets the
+    // same 404 as for a chunk that doesn't exist, so the response doesn't
+    // reveal that the id is in use.
+    if !app.canView(r, chunk) {
+        app.notFound(w)
+        return
+    }
+
+    err = app.keepAlive(chunk)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    // A view count which can't be saved isn't worth failing the page for.
+    views, err := app.countView(chunk.ID)
+    if err != nil {
+        app.errorLog.Printf("counting a view of chunk %d: %v", chunk.ID, err)
+    }
+    chunk.Views += views
+    if viewID, ok := r.Context().Value(viewedChunkContextKey).(*int); ok {
+        *viewID = chunk.ID
+    }
+    app.logAccess(r, chunk, accessView)
+    // Every view of a chunk with an access log has to be recorded, and
+    // every view of a keep-alive chunk has to extend it, so their pages are
+    // kept out of the response cache.
+    if (chunk.AccessLog && app.accessLog != nil) || (chunk.KeepAlive && app.extendOnView > 0) {
+        w.Header().Set("Cache-Control", "private, no-store")
+    }
+
+    // Use the renderChunkView helper to display the chunk.
+    app.renderChunkView(w, r, http.StatusOK, chunk, commentForm{})
+}
+
+// renderChunkView displays a chunk together with its comments (if they are
+// enabled). The form holds a comment that failed validation, so it can be
+// shown again with its errors.
+func (app *application) renderChunkView(w http.ResponseWriter, r *http.Request, status int, chunk *models.Chunk, form commentForm) {
+    data := app.newTemplateData(r)
+    app.displayChunk(r, data, chunk)
+    app.displayFiles(r, data, chunk)
+    data.IsOwner = chunk.UserID != 0 && chunk.UserID == app.authenticatedUserID(r)
+    data.StatsEnabled = data.IsOwner && app.accessLog != nil
+    if link := app.sessionManager.PopString(r.Context(), editLinkKey(chunk.PublicID)); link != "" {
+        data.EditURL = link
+        data.EditExpires = time.Unix(int64(app.sessionManager.PopInt(r.Context(), editLinkKey(chunk.PublicID)+":expires")), 0)
+    }
+
+    if app.comments != nil {
+        comments, err := app.comments.ByChunk(chunk.ID)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        data.CommentsEnabled = true
+        data.Comments = comments
+        data.Form = form
+    }
+
+    // Show how many users starred the chunk, and whether the current user
+    // is one of them.
+    if app.favorites != nil {
+        count, err := app.favorites.Count(chunk.ID)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        data.FavoritesEnabled = true
+        data.FavoriteCount = count
+        if data.IsAuthenticated {
+            data.IsFavorite, err = app.favorites.IsFavorite(app.authenticatedUserID(r), chunk.ID)
+            if err != nil {
+                app.serverError(w, err)
+                return
+            }
+        }
+    }
+
+    related, err := app.related.get(chunk)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    data.Related = related
+
+    data.OpenGraph = app.openGraphFor(r, chunk)
+
+    setViewHeaders(w, chunk)
+    app.render(w, status, "view.html", data)
+}
+
+// chunkPath serves the paths under /chunk/: /chunk/{id}.json, a chunk as a
+// gist (-gist-json), /chunk/{id}/og.png, its link preview image
+// (-open-graph), /chunk/{id}/badge.svg, its badge (see badge.go), and
+// /chunk/{id}/stats, its access log (see access.go). /chunk/{id}/share makes
+// a share link (see share.go) and POST /chunk/{id}/favorite stars it (see
+// favorites.go), for logged-in users only. /chunk/{id} itself is
+// the chunk's raw content, like /chunkbox/raw, and answers HEAD with its
+// metadata headers.
+func (app *application) chunkPath(w http.ResponseWriter, r *http.Request) {
+    rest := strings.TrimPrefix(r.URL.Path, "/chunk/")
+    if id, ok := strings.CutSuffix(rest, ".json"); ok && app.gistJSON {
+        if id == "" || strings.Contains(id, "/") {
+            app.writeJSON(w, http.StatusNotFound, envelope{"message": "Not Found"})
+            return
+        }
+        app.chunkGist(w, r, id)
+        return
+    }
+    if id, ok := strings.CutSuffix(rest, "/og.png"); ok && app.ogImages != nil && id != "" && !strings.Contains(id, "/") {
+        app.chunkOGImage(w, r, id)
+        return
+    }
+    if id, ok := strings.CutSuffix(rest, "/badge.svg"); ok && id != "" && !strings.Contains(id, "/") {
+        app.chunkBadge(w, r, id)
+        return
+    }
+    if id, ok := strings.CutSuffix(rest, "/stats"); ok && app.accessLog != nil && id != "" && !strings.Contains(id, "/") {
+        app.chunkStats(w, r, id)
+        return
+    }
+    if id, ok := strings.CutSuffix(rest, "/share"); ok && id != "" && !strings.Contains(id, "/") {
+        app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            app.chunkShare(w, r, id)
+        })).ServeHTTP(w, r)
+        return
+    }
+    if id, ok := strings.CutSuffix(rest, "/favorite"); ok && app.favorites != nil && id != "" && !strings.Contains(id, "/") {
+        app.requireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            app.favoritePost(w, r, id)
+        })).ServeHTTP(w, r)
+        return
+    }
+    if rest != "" && !strings.Contains(rest, "/") {
+        app.streamChunk(w, r, rest, false)
+        return
+    }
+    app.notFound(w)
+}
+
+// setViewHeaders gives the times of the chunk shown on a view page in the
+// headers, for scripts and browser extensions which would otherwise have to
+// read them out of the HTML: X-Chunk-Created and X-Chunk-Expires, in
+// RFC 3339.
+func setViewHeaders(w http.ResponseWriter, chunk *models.Chunk) {
+    w.Header().Set("X-Chunk-Created", chunk.Created.UTC().Format(time.RFC3339))
+    w.Header().Set("X-Chunk-Expires", expiresHeader(chunk))
+}
+
+// expiresHeader is the X-Chunk-Expires header of a chunk, the same on every
+// response which has one: its expiry in RFC 3339, or "never" for a chunk
+// without one.
+func expiresHeader(chunk *models.Chunk) string {
+    if chunk.Expires.IsZero() {
+        return "never"
+    }
+    return chunk.Expires.UTC().Format(time.RFC3339)
+}
+
+// chunkFilename is the name a chunk's content is saved under. Chunks from
+// before slugs existed are named after their public ID.
+func chunkFilename(chunk *models.Chunk) string {
+    name := "chunk-" + chunk.PublicID
+    if chunk.Slug != "" {
+        name = chunk.Slug
+    }
+    return name + ".txt"
+}
+
+// chunkRaw serves the content of a chunk as plain text. The content is
+// streamed straight from the database to the client rather than loaded into
+// memory first. A HEAD request gets the same headers (including the size and
+// expiry of the chunk) without the content, so tools can check a chunk
+// without downloading it. With ?crlf=1 the line endings are converted to
+// CRLF for Windows tools, and with ?charset= the content is converted to
+// one of the charsets textnorm knows, for tools which can't read UTF-8.
+// With -strip-bom=raw a byte order mark is left out. The other files of a
+// multi-file chunk are served with ?file= and their name, and ?meta=1 adds
+// a footer with the chunk's URL and date (see rawMetaFooter).
+func (app *application) chunkRaw(w http.ResponseWriter, r *http.Request) {
+    app.streamChunk(w, r, r.URL.Query().Get("id"), false)
+}
+
+// chunkDownload is the same as chunkRaw, but asks the browser to save the
+// content as a file instead of displaying it. With ?zip=1 it sends all the
+// files of the chunk in a zip archive.
+func (app *application) chunkDownload(w http.ResponseWriter, r *http.Request) {
+    app.streamChunk(w, r, r.URL.Query().Get("id"), true)
+}
+
+// streamChunk serves the content of the chunk with the public ID, for
+// chunkRaw, chunkDownload and /chunk/{id}.
+func (app *application) streamChunk(w http.ResponseWriter, r *http.Request, id string, attachment bool) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+
+    if id == "" {
+        app.notFound(w)
+        return
+    }
+
+    // Check the chunk exists and may be viewed before streaming anything.
+    // GetMeta doesn't load the content, so this stays cheap for big chunks.
+    chunk, err := app.chunks.GetMetaByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if !app.canView(r, chunk) {
+        app.notFound(w)
+        return
+    }
+
+    // Another file of a multi-file chunk, or all of them in a zip, needs
+    // the whole chunk, as only Get loads the files.
+    var file *models.ChunkFile
+    var fileIndex int
+    name, zipped := r.URL.Query().Get("file"), attachment && r.URL.Query().Get("zip") == "1"
+    if name != "" || zipped {
+        full, err := app.chunks.GetByPublicID(id)
+        if err != nil {
+            if errors.Is(err, models.ErrNoRecord) {
+                app.notFound(w)
+            } else {
+                app.serverError(w, err)
+            }
+            return
+        }
+        if zipped {
+            app.writeChunkZip(w, r, full)
+            return
+        }
+        fileIndex = findFile(full, name)
+        if fileIndex < 0 {
+            app.notFound(w)
+            return
+        }
+        file = &full.Files[fileIndex]
+    }
+
+    charset := "utf-8"
+    if c := r.URL.Query().Get("charset"); c != "" {
+        var ok bool
+        charset, ok = textnorm.CanonicalCharset(c)
+        if !ok {
+            app.clientError(w, http.StatusBadRequest)
+            return
+        }
+    }
+
+    mediaType := chunkMediaType(chunk)
+    w.Header().Set("Content-Type", mediaType+"; charset="+charset)
+    if attachment {
+        // Downloads are attachments unless -inline-types says the browser
+        // may display the type. The filename is kept either way, for when
+        // the user saves it.
+        filename := chunkFilename(chunk)
+        if file != nil {
+            filename = file.Filename
+        }
+        w.Header().Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, app.inlineTypes.disposition(mediaType), filename))
+    }
+    setChunkHeaders(w, chunk)
+    var variants []string
+    if file != nil {
+        w.Header().Set("Content-Length", strconv.Itoa(len(file.Content)))
+        w.Header().Set("X-Content-SHA256", models.ContentSHA256(file.Content))
+        variants = append(variants, "file"+strconv.Itoa(fileIndex+1))
+        w.Header().Set("ETag", chunkETag(chunk, variants[0]))
+    }
+
+    // Each conversion changes the content's size by an amount we only know
+    // once it has been sent, so there is no Content-Length then, and each
+    // gives the content an ETag of its own. The bytes sent then aren't the
+    // stored ones, so they have no X-Content-SHA256 either.
+    crlf := r.URL.Query().Get("crlf") == "1"
+    stripBOM := app.stripBOM == stripBOMRaw
+    meta := !attachment && r.URL.Query().Get("meta") == "1"
+    conversions := len(variants)
+    if crlf {
+        variants = append(variants, "crlf")
+    }
+    if charset != "utf-8" {
+        variants = append(variants, charset)
+    }
+    if stripBOM {
+        variants = append(variants, "nobom")
+    }
+    if meta {
+        variants = append(variants, "meta")
+    }
+    if len(variants) > conversions {
+        w.Header().Del("Content-Length")
+        w.Header().Del("X-Content-SHA256")
+        w.Header().Set("ETag", chunkETag(chunk, strings.Join(variants, "-")))
+    }
+
+    if r.Method == http.MethodHead {
+        return
+    }
+    if attachment {
+        app.logAccess(r, chunk, accessDownload)
+    } else {
+        app.logAccess(r, chunk, accessRaw)
+    }
+
+    // The conversions are applied in turn: the BOM is dropped from the
+    // content as it was saved, then the line endings are converted, then
+    // the charset.
+    cw := &countingWriter{w: w}
+    var dst io.Writer = cw
+    if charset != "utf-8" {
+        // CanonicalCharset already accepted the charset.
+        dst, _ = textnorm.NewEncodingWriter(dst, charset)
+    }
+    if crlf {
+        dst = textnorm.NewCRLFWriter(dst)
+    }
+    if stripBOM {
+        dst = textnorm.NewBOMStripWriter(dst)
+    }
+    // The footer goes through the conversions too, after the content.
+    var lw *lastByteWriter
+    language := chunk.Language
+    if meta {
+        lw = &lastByteWriter{w: dst}
+        dst = lw
+        if file != nil {
+            language = file.Language
+        }
+    }
+    if file != nil {
+        _, err = io.WriteString(dst, file.Content)
+    } else {
+        err = app.chunks.StreamContent(r.Context(), chunk.ID, dst)
+    }
+    if err == nil && meta {
+        err = lw.writeFooter(app.rawMetaFooter(r, chunk, language))
+    }
+    if err != nil {
+        switch {
+        case cw.n > 0:
+            // The status code and part of the body have already been sent,
+            // so all we can do is log the error and stop.
+            app.errorLog.Printf("streaming chunk %d: %v", chunk.ID, err)
+        case errors.Is(err, models.ErrNoRecord):
+            clearChunkHeaders(w)
+            app.notFound(w)
+        default:
+            clearChunkHeaders(w)
+            app.serverError(w, err)
+        }
+    }
+}
+
+// setChunkHeaders describes a chunk (loaded with GetMeta) in the response
+// headers. The ETag has the time of the last change, to the microsecond, so
+// it changes whenever the chunk is edited.
+//
+// X-Content-SHA256 is the hex SHA-256 of the content exactly as stored and
+// served, before any highlighting, so a client can check it got every byte:
+// hash the body it received (with sha256sum, say) and compare. The API's
+// JSON has the same hash in a "sha256" field.
+func setChunkHeaders(w http.ResponseWriter, chunk *models.Chunk) {
+    w.Header().Set("Content-Length", strconv.FormatInt(chunk.Size, 10))
+    w.Header().Set("X-Content-SHA256", chunk.SHA256)
+    w.Header().Set("ETag", chunkETag(chunk, ""))
+    w.Header().Set("Last-Modified", chunk.Modified().UTC().Format(http.TimeFormat))
+    w.Header().Set("X-Chunk-Expires", expiresHeader(chunk))
+}
+
+// chunkETag returns the ETag of a chunk's content, for a variant of it (like
+// "crlf") or the content as it was saved.
+func chunkETag(chunk *models.Chunk, variant string) string {
+    tag := fmt.Sprintf("%s-%d", chunk.PublicID, chunk.Modified().UnixMicro())
+    if variant != "" {
+        tag += "-" + variant
+    }
+    return `"` + tag + `"`
+}
+
+// clearChunkHeaders removes the headers describing the chunk again, when an
+// error response is sent instead of its content.
+func clearChunkHeaders(w http.ResponseWriter) {
+    for _, h := range []string{"Content-Disposition", "Content-Length", "ETag", "Last-Modified", "X-Chunk-Expires", "X-Content-SHA256"} {
+        w.Header().Del(h)
+    }
+}
 
+// Define a chunkCreateForm struct to represent the form data and validation
+// errors for the form fields. Embedding the Validator gives us the Valid(),
+// CheckField() and AddFieldError() methods.
+type chunkCreateForm struct {
+    Title     string
+    Content   string
+    Expires   int
+    Language  string
+    Private   bool
+    KeepAlive bool
+    // Tags is the tags field as it was typed, comma-separated.
+    Tags      string
+    // Files are the other files of a multi-file chunk (-max-files).
+    Files     []models.ChunkFile
+    // Duplicate is the user's chunk with the same title, when they are
+    // asked whether they meant to create another (-warn-duplicate-title).
+    Duplicate *models.Chunk
+    // DuplicateContent is the creator's chunk with the same content, when
+    // the new one is refused for it (-unique-per-user=reject).
+    DuplicateContent *models.Chunk
+    // Secret is the warning about a secret in the content, when the creator
+    // is asked to confirm (-secret-action=warn), and SecretConfirmed whether
+    // they have.
+    Secret          string
+    SecretConfirmed bool
+    FormToken string
+    validator.Validator
 }
 
 func (app *application)chunkCreate(w http.ResponseWriter, r *http.Request){
-    // Use r.Method to check whether the request is using POST or not.
-    if r.Method != http.MethodPost {
-        // Use the Header().Set() method to add an 'Allow: POST' header to the
-        // response header map. The first parameter is the header name, and
-        // the second parameter is the header value.
-        w.Header().Set("Allow", http.MethodPost)
-        app.clientError(w, http.StatusMethodNotAllowed) // Use the clientError() helper.
-        return
-    }
-    // Create some variables holding dummy data. We'll remove these later on
-    // during the build.
-    title := "On BhagvadGita"
-    content := "The soul who meditates on the Self is content to serve the Self and rests satisfied within the Self; \n there remains nothing more for him to accomplish. \n- Bhagavad Gita 3.17"
-    expires := 7
+    // On a login-only instance (-allow-anonymous=false) send anonymous
+    // visitors to the login page, with a flash message explaining why.
+    if !app.allowAnonymous && !app.isAuthenticated(r) {
+        app.sessionManager.Put(r.Context(), "flash", "You need to log in to create chunks on this site.")
+        http.Redirect(w, r, app.loginURL(app.url("/chunkbox/create")), http.StatusSeeOther)
+        return
+    }
+
+    // Use r.Method to check whether the request is a GET for the form or a
+    // POST submitting it.
+    switch r.Method {
+    case http.MethodGet:
+        // Initialize a new chunkCreateForm instance and pass it to the
+        // template, so the default expiry radio button is checked.
+        app.renderCreate(w, r, http.StatusOK, chunkCreateForm{Expires: app.expiryPolicy(r).formDefault(), Language: app.defaultLanguage(), FormToken: app.newFormToken()})
+    case http.MethodPost:
+        app.chunkCreatePost(w, r)
+    default:
+        // Use the methodNotAllowed() helper to add the 'Allow' header and
+        // send a 405 response.
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+func (app *application) chunkCreatePost(w http.ResponseWriter, r *http.Request) {
+    // First we call r.ParseForm() which adds any data in POST request bodies
+    // to the r.PostForm map.
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    // Drop submissions that filled in the honeypot field or came back faster
+    // than a human could fill in the form. We respond with a normal 200 and
+    // a fresh form so bots can't tell they have been caught. "Add file"
+    // only shows the form again, so it can come as quickly as it likes.
+    addFile := r.PostForm.Get("add_file") != ""
+    if !addFile && app.isSpamSubmission(r) {
+        app.infoLog.Printf("spam: dropped create form submission from %s", r.RemoteAddr)
+        app.recordAbuse(r, abuseSpamPoints)
+        app.renderCreate(w, r, http.StatusOK, chunkCreateForm{Expires: app.expiryPolicy(r).formDefault(), Language: app.defaultLanguage(), FormToken: app.newFormToken()})
+        return
+    }
+
+    // The r.PostForm.Get() method always returns the form data as a *string*.
+    // However, we're expecting our expires value to be a number, so we
+    // manually convert the form data to an integer using strconv.Atoi().
+    expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    // Convert the title and content to UTF-8 and normalize the line endings
+    // (see -non-utf8 and -normalize-newlines). Content we can't read is
+    // reported like any other invalid field.
+    charset := submittedCharset(r)
+    title, err := app.decodeText(r.PostForm.Get("title"), charset)
+    var content string
+    var normalized bool
+    if err == nil {
+        content, normalized, err = app.normalizeContent(r.PostForm.Get("content"), charset)
+    }
+    files := app.fileFields(r.PostForm, addFile)
+    for i := range files {
+        var fileNormalized bool
+        if err == nil {
+            files[i].Filename, err = app.decodeText(files[i].Filename, charset)
+        }
+        if err == nil {
+            files[i].Content, fileNormalized, err = app.normalizeContent(files[i].Content, charset)
+        }
+        normalized = normalized || fileNormalized
+    }
+
+    form := chunkCreateForm{
+        Title:     title,
+        Content:   content,
+        Expires:   expires,
+        Language:  app.normalizeLanguage(r.PostForm.Get("language")),
+        // Keep the submitted form token, so re-displaying the form after a
+        // validation error doesn't restart the minimum fill time.
+        FormToken: r.PostForm.Get("form_token"),
+        // Only logged-in users can make a chunk private. An anonymous
+        // private chunk would have no owner able to see it.
+        Private:   r.PostForm.Get("private") != "" && app.isAuthenticated(r),
+        KeepAlive: r.PostForm.Get("keep_alive") != "" && app.extendOnView > 0,
+        Tags:      r.PostForm.Get("tags"),
+        Files:     files,
+    }
+    tags := parseTags(form.Tags)
+
+    // "Add file" shows the form again with one more file, as it was typed.
+    if addFile && err == nil {
+        if len(form.Files)+1 < app.maxFiles {
+            form.Files = append(form.Files, models.ChunkFile{Language: app.defaultLanguage()})
+        }
+        app.renderCreate(w, r, http.StatusOK, form)
+        return
+    }
+
+    switch {
+    case errors.Is(err, textnorm.ErrUnknownCharset):
+        form.AddNonFieldError(fmt.Sprintf("Text in the %q charset can't be accepted, please submit UTF-8.", charset))
+    case errors.Is(err, textnorm.ErrInvalidUTF8):
+        form.AddNonFieldError("Your chunk isn't valid UTF-8 text.")
+    case err != nil:
+        app.serverError(w, err)
+        return
+    default:
+        app.validateChunk(&form.Validator, form.Title, form.Content, form.Expires, form.Language, app.expiryPolicy(r))
+        app.validateTags(&form.Validator, tags)
+        app.validateFiles(&form.Validator, form.Content, form.Files)
+    }
+
+    // Check the title, content and tags against the spam blocklist. The error
+    // message is deliberately generic, so spammers can't use it to work out
+    // which pattern they tripped.
+    if form.Valid() && app.blocklist.Load().Matches(append(append([]string{form.Title, form.Content}, tags...), filesContent(form.Files)...)...) {
+        app.infoLog.Printf("blocklist: rejected chunk from %s: title=%q content=%q",
+            r.RemoteAddr, truncate(form.Title, 100), truncate(form.Content, 200))
+        form.AddNonFieldError("Your chunk could not be saved. Please check its content and try again.")
+        app.recordAbuse(r, abuseSpamPoints)
+    }
+
+    // Secrets pasted by mistake (-scan-secrets) are refused or, with
+    // -secret-action=warn, the form is shown again for the creator to
+    // confirm. The confirmation is kept if the form is shown again later.
+    form.SecretConfirmed = r.PostForm.Get("confirm_secret") != ""
+    if form.Valid() {
+        form.Secret = app.checkSecret(&form.Validator, r, form.Content, form.Files, form.SecretConfirmed)
+        if form.Secret != "" {
+            app.renderCreate(w, r, http.StatusOK, form)
+            return
+        }
+    }
+
+    // Anonymous visitors must also pass the CAPTCHA, if one is configured.
+    // Only ask the provider once the rest of the form is valid, so we don't
+    // spend a verification on a submission we'd reject anyway.
+    if form.Valid() && app.captcha != nil && !app.isAuthenticated(r) {
+        ok, err := app.captcha.Verify(r.Context(), r.PostForm.Get(app.captcha.ResponseField()), app.realIP(r))
+        if err != nil {
+            app.errorLog.Printf("captcha: %v", err)
+        }
+        if !ok {
+            form.AddFieldError("captcha", "Please complete the CAPTCHA")
+        }
+    }
+
+    // If there are any validation errors re-display the create.html template,
+    // passing in the chunkCreateForm instance as dynamic data in the Form
+    // field. Note that we use the HTTP status code 422 Unprocessable Entity
+    // when sending the response to indicate that there was a validation error.
+    if !form.Valid() {
+        app.renderCreate(w, r, http.StatusUnprocessableEntity, form)
+        return
+    }
+
+    // A logged-in user reusing the title of one of their chunks may be
+    // pasting the same thing again, so with -warn-duplicate-title they are
+    // shown the form again with a link to it. This is only a warning:
+    // "Create anyway" sends the form with confirm_duplicate set.
+    if app.warnDuplicateTitle && app.isAuthenticated(r) && r.PostForm.Get("confirm_duplicate") == "" {
+        existing, err := app.chunks.ExistsTitleForUser(app.authenticatedUserID(r), form.Title)
+        switch {
+        case err == nil:
+            form.Duplicate = existing
+            app.renderCreate(w, r, http.StatusOK, form)
+            return
+        case !errors.Is(err, models.ErrNoRecord):
+            app.serverError(w, err)
+            return
+        }
+    }
+
+    // Work out the language if the user asked us to. The flash message tells
+    // them what we picked, so they can correct it. The form keeps "auto", in
+    // case it has to be shown again.
+    flash := "Chunk successfully created!"
+    language := form.Language
+    if language == autoLanguage {
+        lang, ok := app.detectLanguage(form.Content)
+        language = lang.Name
+        if ok {
+            flash += " Detected language: " + lang.Label + "."
+        } else {
+            flash += " The language couldn't be detected, so it was saved as " + lang.Label + "."
+        }
+    }
+
+    // "Default for the Language" leaves the expiry at 0 until the language
+    // is known.
+    expires = app.expiryPolicy(r).resolve(form.Expires, language)
+
+    // The content transformers need the language, so they run last.
+    content, transformed := app.transformContent(&form.Validator, language, form.Content)
+    files, filesTransformed := app.prepareFiles(&form.Validator, append([]models.ChunkFile(nil), form.Files...))
+    if !form.Valid() {
+        app.renderCreate(w, r, http.StatusUnprocessableEntity, form)
+        return
+    }
+
+    // With -unique-per-user, content the creator has saved already is
+    // refused with a link to the chunk, or they are sent to it.
+    existing, err := app.existingContent(r, content)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    if existing != nil && app.uniquePerUser == uniqueReject {
+        form.DuplicateContent = existing
+        app.renderCreate(w, r, http.StatusConflict, form)
+        return
+    }
+    if existing != nil {
+        if app.postCreateDestination(r) == postCreateRaw {
+            http.Redirect(w, r, app.url("/chunkbox/raw?id="+existing.PublicID), http.StatusSeeOther)
+            return
+        }
+        app.sessionManager.Put(r.Context(), "flash", "You already have a chunk with this content, so it wasn't saved again.")
+        http.Redirect(w, r, app.url("/chunkbox/view?id="+existing.PublicID), http.StatusSeeOther)
+        return
+    }
+
+    // Anonymous visitors can't create chunks once the server is full (see
+    // -max-chunks), unless old chunks are evicted to make room.
+    if !app.isAuthenticated(r) {
+        ok, err := app.allowChunks(r, 1)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if !ok {
+            form.AddNonFieldError("This server is full and isn't accepting new chunks right now. Please try again later.")
+            app.renderCreate(w, r, http.StatusServiceUnavailable, form)
+            return
+        }
+    }
+
+    // Users can't store more than the -user-byte-quota.
+    ok, err := app.withinByteQuota(app.authenticatedUserID(r), int64(len(content))+filesSize(files))
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    if !ok {
+        form.AddNonFieldError(app.byteQuotaMessage())
+        app.renderCreate(w, r, http.StatusForbidden, form)
+        return
+    }
+
     // Pass the data to the ChunkModel.Insert() method, receiving the
-    // ID of the new record back.
-    id, err := app.chunks.Insert(title, content, expires)
+    // ID of the new record back. Chunks created while logged in are owned
+    // by that user; authenticatedUserID returns 0 for anonymous visitors.
+    id, err := app.chunks.Insert(form.Title, content, expires, language, app.authenticatedUserID(r), form.Private, form.KeepAlive, normalized || transformed || filesTransformed, tags, files, app.realIP(r))
     if err != nil {
         app.serverError(w, err)
         return
     }
+    app.quota.added(1)
+    app.notifyCreated(r, id, form.Title, language, form.Private)
+
+    // Anonymous creators get a secret link to edit or delete the chunk
+    // later, shown once on the chunk's page. The session can only hold
+    // plain types, so the expiry is kept as a Unix time.
+    if link, expires := app.issueEditToken(r, id, app.authenticatedUserID(r), expires); link != "" {
+        app.sessionManager.Put(r.Context(), editLinkKey(id), link)
+        app.sessionManager.Put(r.Context(), editLinkKey(id)+":expires", int(expires.Unix()))
+    }
+
+    // Scripts can ask to be sent to the raw content instead of the view
+    // page. They get no flash message, and the edit link waits in the
+    // session until the chunk's view page is shown.
+    if app.postCreateDestination(r) == postCreateRaw {
+        http.Redirect(w, r, app.url("/chunkbox/raw?id="+id), http.StatusSeeOther)
+        return
+    }
+
+    // Use the Put() method to add the flash message and the corresponding
+    // key ("flash") to the session data.
+    app.sessionManager.Put(r.Context(), "flash", flash)
+
     // Redirect the user to the relevant page for the chunk.
-    http.Redirect(w, r, fmt.Sprintf("/chunkbox/view?id=%d", id), http.StatusSeeOther)
+    http.Redirect(w, r, app.url("/chunkbox/view?id="+id), http.StatusSeeOther)
+}
+
+// Where the create form sends the browser once the chunk is saved: its
+// view page, or its raw content.
+const (
+    postCreateView = "view"
+    postCreateRaw  = "raw"
+)
+
+// postCreateDestination returns where to send the creator of a chunk. The
+// "redirect" form field wins, then an X-Post-Create header, then an Accept
+// header asking for plain text rather than HTML, as CLI tools may send;
+// otherwise it is the -post-create-redirect default.
+func (app *application) postCreateDestination(r *http.Request) string {
+    for _, choice := range []string{r.PostForm.Get("redirect"), r.Header.Get("X-Post-Create")} {
+        choice = strings.ToLower(strings.TrimSpace(choice))
+        if choice == postCreateView || choice == postCreateRaw {
+            return choice
+        }
+    }
+    accept := r.Header.Get("Accept")
+    if strings.Contains(accept, "text/plain") && !strings.Contains(accept, "text/html") {
+        return postCreateRaw
+    }
+    return app.postCreateRedirect
+}
+
+// renderCreate displays the create form. The CAPTCHA widget is only added for
+// anonymous visitors, as authenticated users are exempt from it.
+func (app *application) renderCreate(w http.ResponseWriter, r *http.Request, status int, form chunkCreateForm) {
+    data := app.newTemplateData(r)
+    data.Form = form
+    data.Languages = app.languages
+    data.TagsEnabled = app.maxTags > 0
+    data.KeepAliveEnabled = app.extendOnView > 0
+    data.MaxOtherFiles = app.maxFiles - 1
+    data.ExpiryOptions = app.expiryPolicy(r).options()
+    if app.captcha != nil && !data.IsAuthenticated {
+        data.Captcha = app.captcha.Widget()
+    }
+    app.render(w, status, "create.html", data)
+}
+
+// Define a userSignupForm struct to represent and hold the form data and
+// validation errors for the signup form. The struct embeds a Validator type,
+// so it "inherits" the Valid(), CheckField() and AddFieldError() methods.
+type userSignupForm struct {
+    Name     string
+    Email    string
+    Password string
+    // Next is the page to go back to after logging in (see safeNext).
+    Next     string
+    validator.Validator
+}
+
+// userSignup dispatches on the request method: GET displays the signup form
+// and POST creates the new user.
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        data := app.newTemplateData(r)
+        data.Form = userSignupForm{Next: app.safeNext(r.URL.Query().Get("next"))}
+        app.render(w, http.StatusOK, "signup.html", data)
+    case http.MethodPost:
+        app.userSignupPost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+    // Call r.ParseForm() which adds any data in POST request bodies to the
+    // r.PostForm map.
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    form := userSignupForm{
+        Name:     strings.TrimSpace(r.PostForm.Get("name")),
+        Email:    strings.TrimSpace(r.PostForm.Get("email")),
+        Password: r.PostForm.Get("password"),
+        Next:     app.safeNext(r.PostForm.Get("next")),
+    }
+
+    // Validate the form contents using our helper functions.
+    form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+    form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+    form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+    form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+    app.checkPassword(&form.Validator, "password", form.Password)
+
+    // If there are any errors, redisplay the signup form along with a 422
+    // status code.
+    if !form.Valid() {
+        data := app.newTemplateData(r)
+        data.Form = form
+        app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+        return
+    }
+
+    // Try to create a new user record in the database. If the email already
+    // exists then add an error message to the form and re-display it.
+    err = app.users.Insert(form.Name, form.Email, form.Password)
+    if err != nil {
+        if errors.Is(err, models.ErrDuplicateEmail) {
+            form.AddFieldError("email", "Email address is already in use")
+
+            data := app.newTemplateData(r)
+            data.Form = form
+            app.render(w, http.StatusUnprocessableEntity, "signup.html", data)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    app.audit(r, 0, auditSignup, form.Email)
+
+    // Otherwise add a confirmation flash message to the session confirming that
+    // their signup worked.
+    app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+
+    // And redirect the user to the login page.
+    http.Redirect(w, r, app.loginURL(form.Next), http.StatusSeeOther)
+}
+
+// Create a new userLoginForm struct.
+type userLoginForm struct {
+    Email    string
+    Password string
+    // Next is the page to go back to after logging in (see safeNext).
+    Next     string
+    validator.Validator
+}
+
+// userLogin dispatches on the request method: GET displays the login form
+// and POST authenticates the user.
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        data := app.newTemplateData(r)
+        data.Form = userLoginForm{Next: app.safeNext(r.URL.Query().Get("next"))}
+        data.OAuthProviders = app.oauthProviderNames()
+        app.render(w, http.StatusOK, "login.html", data)
+    case http.MethodPost:
+        app.userLoginPost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    form := userLoginForm{
+        Email:    strings.TrimSpace(r.PostForm.Get("email")),
+        Password: r.PostForm.Get("password"),
+        Next:     app.safeNext(r.PostForm.Get("next")),
+    }
+
+    // Do some validation checks on the form. We check that both email and
+    // password are provided, and also check the format of the email address as
+    // a UX-nicety (in case the user makes a typo).
+    form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+    form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+    form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+    if !form.Valid() {
+        data := app.newTemplateData(r)
+        data.Form = form
+        data.OAuthProviders = app.oauthProviderNames()
+        app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+        return
+    }
+
+    // Check whether the credentials are valid. If they're not, add a generic
+    // non-field error message and re-display the login page.
+    id, err := app.users.Authenticate(form.Email, form.Password)
+    if err != nil {
+        if errors.Is(err, models.ErrInvalidCredentials) {
+            app.audit(r, 0, auditLoginFailed, form.Email)
+            form.AddNonFieldError("Email or password is incorrect")
+
+            data := app.newTemplateData(r)
+            data.Form = form
+            data.OAuthProviders = app.oauthProviderNames()
+            app.render(w, http.StatusUnprocessableEntity, "login.html", data)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+
+    // Use the RenewToken() method on the current session to change the session
+    // ID. It's good practice to generate a new session ID when the
+    // authentication state or privilege levels changes for the user (e.g. login
+    // and logout operations).
+    err = app.sessionManager.RenewToken(r.Context())
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // Add the ID of the current user to the session, so that they are now
+    // 'logged in'.
+    app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+    app.audit(r, id, auditLogin, userTarget(id))
+
+    // Send the user back to the page which asked them to log in, or to the
+    // home page.
+    app.safeRedirect(w, r, form.Next)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    userID := app.authenticatedUserID(r)
+
+    // Use the RenewToken() method on the current session to change the session
+    // ID again.
+    err := app.sessionManager.RenewToken(r.Context())
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // Remove the authenticatedUserID from the session data so that the user is
+    // 'logged out'.
+    app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+    app.audit(r, userID, auditLogout, userTarget(userID))
+
+    // Add a flash message to the session to confirm to the user that they've been
+    // logged out.
+    app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+
+    // Redirect the user to the application home page.
+    http.Redirect(w, r, app.url("/"), http.StatusSeeOther)
+}
+
+// accountView shows the details of the logged-in user along with the links
+// to update them and the form to delete the account.
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+    user, err := app.users.Get(app.authenticatedUserID(r))
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            http.Redirect(w, r, app.url("/user/login"), http.StatusSeeOther)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+
+    storage, err := app.storageUsage(user.ID)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    data := app.newTemplateData(r)
+    data.User = user
+    data.Storage = storage
+    data.Form = accountDeleteForm{}
+    app.render(w, http.StatusOK, "account.html", data)
+}
+
+// The accountUpdateForm holds the editable profile fields. CurrentPassword
+// is only required when the email address is being changed, so that someone
+// with access to an unlocked session can't take over the account by pointing
+// it at a new address. Users who signed up through an OAuth provider have
+// to set a password first. The new address is only used once it is
+// confirmed, see email.go.
+type accountUpdateForm struct {
+    Name            string
+    Email           string
+    CurrentPassword string
+    validator.Validator
+}
+
+// accountUpdate dispatches on the request method: GET displays the profile
+// form pre-filled with the current values and POST saves the changes.
+func (app *application) accountUpdate(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        user, err := app.users.Get(app.authenticatedUserID(r))
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+
+        data := app.newTemplateData(r)
+        data.User = user
+        data.Form = accountUpdateForm{Name: user.Name, Email: user.Email}
+        app.render(w, http.StatusOK, "account_update.html", data)
+    case http.MethodPost:
+        app.accountUpdatePost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+func (app *application) accountUpdatePost(w http.ResponseWriter, r *http.Request) {
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    userID := app.authenticatedUserID(r)
+    user, err := app.users.Get(userID)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    form := accountUpdateForm{
+        Name:            strings.TrimSpace(r.PostForm.Get("name")),
+        Email:           strings.TrimSpace(r.PostForm.Get("email")),
+        CurrentPassword: r.PostForm.Get("currentPassword"),
+    }
+    emailChanged := form.Email != user.Email
+
+    form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+    form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+    form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+    form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+    if emailChanged {
+        form.CheckField(app.mailer != nil, "email", "This server can't send email, so your email address can't be changed")
+        form.CheckField(user.HasPassword, "email", "Set a password first to change your email address")
+        if user.HasPassword {
+            form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "Enter your current password to change your email address")
+        }
+    }
+
+    if !form.Valid() {
+        app.renderAccountUpdate(w, r, user, form)
+        return
+    }
+
+    // Re-verify the user with their current password before accepting a new
+    // email address.
+    if emailChanged {
+        err = app.users.CheckPassword(userID, form.CurrentPassword)
+        if err != nil {
+            if errors.Is(err, models.ErrInvalidCredentials) {
+                form.AddFieldError("currentPassword", "Current password is incorrect")
+                app.renderAccountUpdate(w, r, user, form)
+            } else {
+                app.serverError(w, err)
+            }
+            return
+        }
+    }
+
+    // An address which is taken already is refused straight away. The
+    // address is checked again when it is confirmed, as another account
+    // may have taken it by then.
+    if emailChanged {
+        otherID, err := app.users.IDByEmail(form.Email)
+        if err == nil && otherID != userID {
+            form.AddFieldError("email", "Email address is already in use")
+            app.renderAccountUpdate(w, r, user, form)
+            return
+        }
+        if err != nil && !errors.Is(err, models.ErrNoRecord) {
+            app.serverError(w, err)
+            return
+        }
+    }
+
+    err = app.users.UpdateName(userID, form.Name)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // The new address is kept as pending until the user follows the link
+    // sent to it, so an address they don't own is never theirs.
+    flash := "Your account details have been updated."
+    if emailChanged {
+        err = app.users.SetPendingEmail(userID, form.Email)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        app.sendEmailConfirmation(r, userID, form.Email)
+        flash += " We've sent a link to " + form.Email + ": your email address will change once you follow it."
+    }
+
+    app.sessionManager.Put(r.Context(), "flash", flash)
+    http.Redirect(w, r, app.url("/account/view"), http.StatusSeeOther)
+}
+
+// renderAccountUpdate re-displays the profile form with its validation errors.
+// The password is never echoed back into the page.
+func (app *application) renderAccountUpdate(w http.ResponseWriter, r *http.Request, user *models.User, form accountUpdateForm) {
+    form.CurrentPassword = ""
+    data := app.newTemplateData(r)
+    data.User = user
+    data.Form = form
+    app.render(w, http.StatusUnprocessableEntity, "account_update.html", data)
+}
+
+// The passwordUpdateForm holds the fields of the password change form.
+type passwordUpdateForm struct {
+    CurrentPassword         string
+    NewPassword             string
+    NewPasswordConfirmation string
+    validator.Validator
+}
+
+// accountPasswordUpdate dispatches on the request method: GET displays the
+// password change form and POST changes the password.
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        user, err := app.users.Get(app.authenticatedUserID(r))
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        app.renderPasswordUpdate(w, r, http.StatusOK, user, passwordUpdateForm{})
+    case http.MethodPost:
+        app.accountPasswordUpdatePost(w, r)
+    default:
+        app.methodNotAllowed(w, http.MethodGet, http.MethodPost)
+    }
+}
+
+// renderPasswordUpdate renders the password change form. Users who signed up
+// through an OAuth provider have no password yet, so they aren't asked for
+// their current one.
+func (app *application) renderPasswordUpdate(w http.ResponseWriter, r *http.Request, status int, user *models.User, form passwordUpdateForm) {
+    data := app.newTemplateData(r)
+    data.User = user
+    data.Form = form
+    app.render(w, status, "password.html", data)
+}
+
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    user, err := app.users.Get(app.authenticatedUserID(r))
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    form := passwordUpdateForm{
+        CurrentPassword:         r.PostForm.Get("currentPassword"),
+        NewPassword:             r.PostForm.Get("newPassword"),
+        NewPasswordConfirmation: r.PostForm.Get("newPasswordConfirmation"),
+    }
+
+    if user.HasPassword {
+        form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+    }
+    app.checkPassword(&form.Validator, "newPassword", form.NewPassword)
+    form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "This field cannot be blank")
+    form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+
+    if !form.Valid() {
+        app.renderPasswordUpdate(w, r, http.StatusUnprocessableEntity, user, passwordUpdateForm{Validator: form.Validator})
+        return
+    }
+
+    if user.HasPassword {
+        err = app.users.PasswordUpdate(user.ID, form.CurrentPassword, form.NewPassword)
+    } else {
+        err = app.users.SetPassword(user.ID, form.NewPassword)
+    }
+    if err != nil {
+        if errors.Is(err, models.ErrInvalidCredentials) {
+            form.AddFieldError("currentPassword", "Current password is incorrect")
+            app.renderPasswordUpdate(w, r, http.StatusUnprocessableEntity, user, passwordUpdateForm{Validator: form.Validator})
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    app.audit(r, user.ID, auditPasswordUpdate, userTarget(user.ID))
+
+    app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+    http.Redirect(w, r, app.url("/account/view"), http.StatusSeeOther)
+}
+
+// The accountDeleteForm holds the password confirmation for deleting an
+// account.
+type accountDeleteForm struct {
+    Password string
+    validator.Validator
+}
+
+// accountDeletePost deletes the logged-in user after checking their password.
+// Users who signed up through an OAuth provider have to set a password
+// first. Depending on the -delete-chunks-with-user flag their chunks are
+// either deleted too or kept and reassigned to anonymous.
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.methodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    err := r.ParseForm()
+    if err != nil {
+        app.clientError(w, http.StatusBadRequest)
+        return
+    }
+
+    userID := app.authenticatedUserID(r)
+    user, err := app.users.Get(userID)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    form := accountDeleteForm{Password: r.PostForm.Get("password")}
+
+    if user.HasPassword {
+        form.CheckField(validator.NotBlank(form.Password), "password", "Enter your password to delete your account")
+    } else {
+        form.AddFieldError("password", "Set a password first to delete your account")
+    }
+    if form.Valid() {
+        err = app.users.CheckPassword(userID, form.Password)
+        if err != nil {
+            if !errors.Is(err, models.ErrInvalidCredentials) {
+                app.serverError(w, err)
+                return
+            }
+            form.AddFieldError("password", "Password is incorrect")
+        }
+    }
+
+    if !form.Valid() {
+        data := app.newTemplateData(r)
+        data.User = user
+        data.Form = accountDeleteForm{Validator: form.Validator}
+        app.render(w, http.StatusUnprocessableEntity, "account.html", data)
+        return
+    }
+
+    err = app.users.Delete(userID, app.deleteChunksWithUser)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    target := userTarget(userID)
+    if app.deleteChunksWithUser {
+        target += " (with chunks)"
+    }
+    app.audit(r, userID, auditAccountDelete, target)
+
+    // The user no longer exists, so change the session ID and log them out
+    // in the same way as userLogoutPost.
+    err = app.sessionManager.RenewToken(r.Context())
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+    app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted.")
 
+    http.Redirect(w, r, app.url("/"), http.StatusSeeOther)
 }
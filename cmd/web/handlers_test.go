@@ -0,0 +1,425 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "io"
+    "log"
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/textnorm"
+)
+
+// A deletedMidStream store acts as if each chunk is deleted while its
+// content is being streamed, after the first piece of it is written, or
+// before anything is if first is empty.
+type deletedMidStream struct {
+    models.ChunkStore
+    first string
+}
+
+func (s *deletedMidStream) StreamContent(ctx context.Context, id int, w io.Writer) error {
+    if s.first != "" {
+        if _, err := io.WriteString(w, s.first); err != nil {
+            return err
+        }
+    }
+    return models.ErrNoRecord
+}
+
+func TestChunkRawDeletedMidStream(t *testing.T) {
+    app := newTestApplication(t)
+    var errorLog bytes.Buffer
+    app.errorLog = log.New(&errorLog, "", 0)
+    id := insertChunk(t, app, "Streamed", "first piece, second piece")
+    app.chunks = &deletedMidStream{ChunkStore: app.chunks, first: "first piece, "}
+    ts := newTestServer(t, app.routes())
+
+    resp, err := ts.Client().Get(ts.URL + "/chunkbox/raw?id=" + id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+
+    // The headers went out with the first piece, so the status can't
+    // change any more; the body just stops short of its Content-Length.
+    if resp.StatusCode != http.StatusOK {
+        t.Errorf("status %d, want %d", resp.StatusCode, http.StatusOK)
+    }
+    if resp.Header.Get("X-Content-SHA256") != models.ContentSHA256("first piece, second piece") {
+        t.Errorf("X-Content-SHA256 = %q", resp.Header.Get("X-Content-SHA256"))
+    }
+    body, err := io.ReadAll(resp.Body)
+    if err != io.ErrUnexpectedEOF {
+        t.Errorf("reading the body: %v, want %v", err, io.ErrUnexpectedEOF)
+    }
+    if string(body) != "first piece, " {
+        t.Errorf("body %q, want the first piece", body)
+    }
+    if !strings.Contains(errorLog.String(), "streaming chunk") {
+        t.Errorf("error not logged: %q", errorLog.String())
+    }
+}
+
+func TestChunkRawDeletedBeforeStream(t *testing.T) {
+    app := newTestApplication(t)
+    id := insertChunk(t, app, "Streamed", "content")
+    app.chunks = &deletedMidStream{ChunkStore: app.chunks}
+    ts := newTestServer(t, app.routes())
+
+    resp, body := ts.get(t, "/chunkbox/raw?id="+id)
+    if resp.StatusCode != http.StatusNotFound {
+        t.Errorf("status %d, want %d", resp.StatusCode, http.StatusNotFound)
+    }
+    // None of the chunk's headers are left on the error.
+    for _, h := range []string{"ETag", "X-Content-SHA256", "X-Chunk-Expires"} {
+        if v := resp.Header.Get(h); v != "" {
+            t.Errorf("%s: %q on the error", h, v)
+        }
+    }
+    if strings.Contains(body, "content") {
+        t.Errorf("body %q has the content", body)
+    }
+}
+
+func TestChunkCreateCharsetAndNewlines(t *testing.T) {
+    // "Café" and "naïve" in Latin-1, with Windows line endings.
+    const latin1 = "Caf\xe9\r\nna\xefve\r\n"
+
+    t.Run("transcoded", func(t *testing.T) {
+        app := newTestApplication(t)
+        app.transcode = true
+        ts := newTestServer(t, app.routes())
+
+        form := createForm("Latin-1", latin1)
+        form.Set("_charset_", "ISO-8859-1")
+        resp, _ := ts.postForm(t, "/chunkbox/create", form)
+        id := chunkIDFrom(t, resp)
+
+        chunk, err := app.chunks.GetByPublicID(id)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if chunk.Content != "Café\nnaïve\n" || !chunk.Normalized {
+            t.Errorf("stored %q, normalized %t", chunk.Content, chunk.Normalized)
+        }
+
+        _, raw := ts.get(t, "/chunkbox/raw?id="+id)
+        if raw != "Café\nnaïve\n" {
+            t.Errorf("raw %q", raw)
+        }
+        _, raw = ts.get(t, "/chunkbox/raw?id="+id+"&crlf=1")
+        if raw != "Café\r\nnaïve\r\n" {
+            t.Errorf("raw with crlf=1 %q", raw)
+        }
+    })
+
+    t.Run("rejected", func(t *testing.T) {
+        app := newTestApplication(t)
+        ts := newTestServer(t, app.routes())
+
+        form := createForm("Latin-1", latin1)
+        form.Set("_charset_", "ISO-8859-1")
+        resp, _ := ts.postForm(t, "/chunkbox/create", form)
+        if resp.StatusCode != http.StatusUnprocessableEntity {
+            t.Errorf("status %d, want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+        }
+        if n, _ := app.chunks.Count(); n != 0 {
+            t.Errorf("%d chunks stored", n)
+        }
+    })
+
+    t.Run("newlines kept", func(t *testing.T) {
+        app := newTestApplication(t)
+        app.normalizeNewlines = false
+        ts := newTestServer(t, app.routes())
+
+        resp, _ := ts.postForm(t, "/chunkbox/create", createForm("CRLF", "one\r\ntwo\r\n"))
+        chunk, err := app.chunks.GetByPublicID(chunkIDFrom(t, resp))
+        if err != nil {
+            t.Fatal(err)
+        }
+        if chunk.Content != "one\r\ntwo\r\n" || chunk.Normalized {
+            t.Errorf("stored %q, normalized %t", chunk.Content, chunk.Normalized)
+        }
+    })
+}
+
+func TestChunkRawBOM(t *testing.T) {
+    const withBOM = textnorm.BOM + "content"
+
+    tests := []struct {
+        stripBOM   string
+        wantStored string
+        wantRaw    string
+    }{
+        {stripBOMInsert, "content", "content"},
+        {stripBOMRaw, withBOM, "content"},
+        {stripBOMOff, withBOM, withBOM},
+    }
+    for _, tt := range tests {
+        t.Run(tt.stripBOM, func(t *testing.T) {
+            app := newTestApplication(t)
+            app.stripBOM = tt.stripBOM
+            ts := newTestServer(t, app.routes())
+
+            resp, _ := ts.postForm(t, "/chunkbox/create", createForm("BOM", withBOM))
+            id := chunkIDFrom(t, resp)
+            chunk, err := app.chunks.GetByPublicID(id)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if chunk.Content != tt.wantStored {
+                t.Errorf("stored %q, want %q", chunk.Content, tt.wantStored)
+            }
+
+            resp, raw := ts.get(t, "/chunkbox/raw?id="+id)
+            if raw != tt.wantRaw {
+                t.Errorf("raw %q, want %q", raw, tt.wantRaw)
+            }
+            if ct := resp.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+                t.Errorf("Content-Type %q", ct)
+            }
+            // Once the BOM is stripped on the way out, the bytes aren't the
+            // stored ones.
+            stripped := tt.stripBOM == stripBOMRaw
+            if sum := resp.Header.Get("X-Content-SHA256"); (sum == "") != stripped {
+                t.Errorf("X-Content-SHA256 %q", sum)
+            }
+        })
+    }
+}
+
+func TestChunkRawCharset(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Quotes", textnorm.BOM+"“café” €")
+
+    tests := []struct {
+        charset     string
+        status      int
+        contentType string
+        body        string
+    }{
+        {"", http.StatusOK, "text/plain; charset=utf-8", textnorm.BOM + "“café” €"},
+        {"UTF-8", http.StatusOK, "text/plain; charset=utf-8", textnorm.BOM + "“café” €"},
+        {"cp1252", http.StatusOK, "text/plain; charset=windows-1252", "?\x93caf\xe9\x94 \x80"},
+        {"latin1", http.StatusOK, "text/plain; charset=iso-8859-1", "??caf\xe9? ?"},
+        {"koi8-r", http.StatusBadRequest, "", ""},
+    }
+    for _, tt := range tests {
+        resp, body := ts.get(t, "/chunkbox/raw?id="+id+"&charset="+tt.charset)
+        if resp.StatusCode != tt.status {
+            t.Errorf("charset %q: status %d, want %d", tt.charset, resp.StatusCode, tt.status)
+            continue
+        }
+        if tt.status != http.StatusOK {
+            continue
+        }
+        if ct := resp.Header.Get("Content-Type"); ct != tt.contentType {
+            t.Errorf("charset %q: Content-Type %q, want %q", tt.charset, ct, tt.contentType)
+        }
+        if body != tt.body {
+            t.Errorf("charset %q: body %q, want %q", tt.charset, body, tt.body)
+        }
+    }
+}
+
+// TestChunkExpiresHeader checks X-Chunk-Expires is in the same format on
+// every response which has it.
+func TestChunkExpiresHeader(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Expiring", "content")
+    chunk, err := app.chunks.GetMetaByPublicID(id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want := chunk.Expires.UTC().Format(time.RFC3339)
+
+    for _, path := range []string{
+        "/chunkbox/view?id=" + id,
+        "/chunkbox/raw?id=" + id,
+        "/chunkbox/download?id=" + id,
+        "/chunkbox/download?zip=1&id=" + id,
+    } {
+        for _, method := range []string{http.MethodGet, http.MethodHead} {
+            resp, _ := ts.do(t, ts.request(t, method, path, nil))
+            if resp.StatusCode != http.StatusOK {
+                t.Errorf("%s %s: status %d", method, path, resp.StatusCode)
+                continue
+            }
+            if got := resp.Header.Get("X-Chunk-Expires"); got != want {
+                t.Errorf("%s %s: X-Chunk-Expires %q, want %q", method, path, got, want)
+            }
+        }
+    }
+
+    if got := expiresHeader(&models.Chunk{}); got != "never" {
+        t.Errorf("X-Chunk-Expires of a chunk without an expiry %q", got)
+    }
+}
+
+// sha256Hex is the X-Content-SHA256 a body should have.
+func sha256Hex(body string) string {
+    sum := sha256.Sum256([]byte(body))
+    return hex.EncodeToString(sum[:])
+}
+
+func TestContentSHA256Header(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+    id, err := app.chunks.Insert("Hashed", "first file\n", 7, highlight.PlainText, 0, false, false, false, nil,
+        []models.ChunkFile{{Filename: "second.txt", Language: highlight.PlainText, Content: "second file\n"}}, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    for _, path := range []string{
+        "/chunkbox/raw?id=" + id,
+        "/chunkbox/download?id=" + id,
+        "/chunkbox/raw?id=" + id + "&file=second.txt",
+    } {
+        resp, body := ts.get(t, path)
+        if got, want := resp.Header.Get("X-Content-SHA256"), sha256Hex(body); got != want {
+            t.Errorf("%s: X-Content-SHA256 %q, want %q", path, got, want)
+        }
+        // HEAD has the same header, without the body.
+        resp, _ = ts.do(t, ts.request(t, http.MethodHead, path, nil))
+        if got, want := resp.Header.Get("X-Content-SHA256"), sha256Hex(body); got != want {
+            t.Errorf("HEAD %s: X-Content-SHA256 %q, want %q", path, got, want)
+        }
+    }
+
+    // Converted content isn't the stored bytes, so it has no hash.
+    resp, _ := ts.get(t, "/chunkbox/raw?id="+id+"&crlf=1")
+    if got := resp.Header.Get("X-Content-SHA256"); got != "" {
+        t.Errorf("X-Content-SHA256 %q on converted content", got)
+    }
+}
+
+func TestContentSHA256API(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+
+    // The line endings are normalized, and the hash is of what is stored.
+    resp, body := ts.postJSON(t, "/api/v1/chunks/batch", `[{"title": "Hashed", "content": "one\r\ntwo\r\n"}]`)
+    if resp.StatusCode != http.StatusCreated {
+        t.Fatalf("status %d: %s", resp.StatusCode, body)
+    }
+    var created struct {
+        Chunks []struct {
+            ID     string `json:"id"`
+            SHA256 string `json:"sha256"`
+        } `json:"chunks"`
+    }
+    if err := json.Unmarshal([]byte(body), &created); err != nil {
+        t.Fatal(err)
+    }
+    if len(created.Chunks) != 1 {
+        t.Fatalf("created %s", body)
+    }
+
+    resp, raw := ts.get(t, "/chunkbox/raw?id="+created.Chunks[0].ID)
+    if raw != "one\ntwo\n" {
+        t.Errorf("raw %q", raw)
+    }
+    if want := sha256Hex(raw); created.Chunks[0].SHA256 != want || resp.Header.Get("X-Content-SHA256") != want {
+        t.Errorf("sha256 %q in the JSON and %q in the header, want %q", created.Chunks[0].SHA256, resp.Header.Get("X-Content-SHA256"), want)
+    }
+}
+
+// A noListing store fails any query for the chunks of a listing, to check
+// HEAD doesn't run one.
+type noListing struct {
+    models.ChunkStore
+}
+
+func (s *noListing) ListAfter(afterID, limit, previewChars int) ([]*models.Chunk, error) {
+    return nil, errors.New("listing chunks for HEAD")
+}
+
+func (s *noListing) Search(query string, limit int) ([]*models.Chunk, error) {
+    return nil, errors.New("searching chunks for HEAD")
+}
+
+func TestListingHead(t *testing.T) {
+    app := newTestApplication(t)
+    insertChunk(t, app, "Listed", "content")
+    store := app.chunks
+    ts := newTestServer(t, app.routes())
+    // Only pages for logged-out visitors without a flash have validators,
+    // and the session cookie would be one.
+    ts.Client().Jar = nil
+
+    for _, path := range []string{"/", "/chunkbox/search?q=content"} {
+        app.chunks = store
+        get, _ := ts.get(t, path)
+        if get.StatusCode != http.StatusOK {
+            t.Fatalf("GET %s: status %d", path, get.StatusCode)
+        }
+
+        app.chunks = &noListing{store}
+        head, body := ts.do(t, ts.request(t, http.MethodHead, path, nil))
+        if head.StatusCode != http.StatusOK {
+            t.Errorf("HEAD %s: status %d", path, head.StatusCode)
+            continue
+        }
+        if body != "" {
+            t.Errorf("HEAD %s: body %q", path, body)
+        }
+        for _, name := range []string{"Content-Type", "Last-Modified", "ETag"} {
+            if got, want := head.Header.Get(name), get.Header.Get(name); got == "" || got != want {
+                t.Errorf("HEAD %s: %s %q, GET has %q", path, name, got, want)
+            }
+        }
+
+        req := ts.request(t, http.MethodHead, path, nil)
+        req.Header.Set("If-None-Match", get.Header.Get("ETag"))
+        if resp, _ := ts.do(t, req); resp.StatusCode != http.StatusNotModified {
+            t.Errorf("HEAD %s with a matching ETag: status %d", path, resp.StatusCode)
+        }
+    }
+}
+
+func TestChunkPathRaw(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Raw", "content\n")
+    private, err := app.chunks.Insert("Private", "secret\n", 7, highlight.PlainText, 1, true, false, false, nil, nil, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    raw, want := ts.get(t, "/chunkbox/raw?id="+id)
+    get, body := ts.get(t, "/chunk/"+id)
+    if get.StatusCode != http.StatusOK || body != want {
+        t.Fatalf("GET: status %d, body %q; want %q", get.StatusCode, body, want)
+    }
+    head, body := ts.do(t, ts.request(t, http.MethodHead, "/chunk/"+id, nil))
+    if head.StatusCode != http.StatusOK || body != "" {
+        t.Fatalf("HEAD: status %d, body %q", head.StatusCode, body)
+    }
+    for _, name := range []string{"Content-Type", "Content-Length", "ETag", "Last-Modified", "X-Chunk-Expires", "X-Content-SHA256"} {
+        if got, want := head.Header.Get(name), raw.Header.Get(name); got == "" || got != want || get.Header.Get(name) != want {
+            t.Errorf("%s: HEAD %q, GET %q, raw %q", name, got, get.Header.Get(name), want)
+        }
+    }
+
+    for _, path := range []string{"/chunk/" + private, "/chunk/nosuchid", "/chunk/" + id + "/nothing"} {
+        for _, method := range []string{http.MethodGet, http.MethodHead} {
+            if resp, _ := ts.do(t, ts.request(t, method, path, nil)); resp.StatusCode != http.StatusNotFound {
+                t.Errorf("%s %s: status %d, want 404", method, path, resp.StatusCode)
+            }
+        }
+    }
+}
@@ -0,0 +1,123 @@
+/*-----------------------------------------------------------
+ @Filename:         health.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// readinessTimeout bounds how long /readyz waits for all the health checks.
+// The checks run concurrently, so this is also the slowest a single
+// dependency is allowed to be.
+const readinessTimeout = 2 * time.Second
+
+// The HealthChecker interface is implemented by every external dependency
+// the application talks to (the database, and later things like object
+// storage or SMTP). CheckHealth should return a non-nil error if the
+// dependency can't currently be used.
+type HealthChecker interface {
+    CheckHealth(ctx context.Context) error
+}
+
+// The healthCheckFunc type is an adapter to allow the use of ordinary
+// functions as health checkers, in the same way http.HandlerFunc does for
+// handlers. For example healthCheckFunc(db.PingContext).
+type healthCheckFunc func(ctx context.Context) error
+
+func (f healthCheckFunc) CheckHealth(ctx context.Context) error {
+    return f(ctx)
+}
+
+// A dependency is a named HealthChecker registered in main(). If required
+// is false a failing check is still reported, but doesn't make the
+// application unready.
+type dependency struct {
+    name     string
+    checker  HealthChecker
+    required bool
+}
+
+// dependencyStatus is the JSON representation of a single check result.
+type dependencyStatus struct {
+    Status   string `json:"status"`
+    Required bool   `json:"required"`
+    Error    string `json:"error,omitempty"`
+}
+
+// readyz runs every registered health check concurrently with a short
+// timeout and responds with a JSON object containing the status of each
+// dependency. The response code is 200 if all the required dependencies are
+// up, otherwise 503 Service Unavailable.
+func (app *application) readyz(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+    defer cancel()
+
+    var (
+        mu     sync.Mutex
+        wg     sync.WaitGroup
+        ready  = true
+        checks = make(map[string]dependencyStatus, len(app.dependencies))
+    )
+
+    for _, dep := range app.dependencies {
+        wg.Add(1)
+        go func(dep dependency) {
+            defer wg.Done()
+
+            status := dependencyStatus{Status: "up", Required: dep.required}
+            if err := dep.checker.CheckHealth(ctx); err != nil {
+                status.Status = "down"
+                status.Error = err.Error()
+            }
+
+            mu.Lock()
+            defer mu.Unlock()
+            checks[dep.name] = status
+            if status.Status != "up" && dep.required {
+                ready = false
+            }
+        }(dep)
+    }
+    wg.Wait()
+
+    response := struct {
+        Status string                      `json:"status"`
+        Checks map[string]dependencyStatus `json:"checks"`
+    }{
+        Status: "ready",
+        Checks: checks,
+    }
+    code := http.StatusOK
+    if !ready {
+        response.Status = "unavailable"
+        code = http.StatusServiceUnavailable
+    }
+
+    js, err := json.Marshal(response)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // Readiness must always reflect the current state, never a cached one.
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(code)
+    w.Write(js)
+}
+
+// healthz is the liveness check: it only says the process is up and serving
+// requests, without looking at any dependency, so an orchestrator doesn't
+// restart chunkbox because the database is down. Use /readyz for that.
+func (app *application) healthz(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+    w.Write([]byte("OK\n"))
+}
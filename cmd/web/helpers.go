@@ -6,9 +6,23 @@
 package main
 
 import (
+    "bytes"
     "fmt"
+    "io"
+    "mime"
+    "net"
     "net/http"
+    "net/netip"
     "runtime/debug"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/textnorm"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+    "github.com/justinas/nosurf"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
@@ -34,4 +48,475 @@ func (app *application) clientError(w http.ResponseWriter, status int) {
 // the user.
 func (app *application) notFound(w http.ResponseWriter) {
     app.clientError(w, http.StatusNotFound)
-}
\ No newline at end of file
+}
+
+// The methodNotAllowed helper sets the Allow header to the list of permitted
+// methods and sends a 405 Method Not Allowed response.
+func (app *application) methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+    for _, method := range allowed {
+        w.Header().Add("Allow", method)
+    }
+    app.clientError(w, http.StatusMethodNotAllowed)
+}
+
+// The render helper retrieves the appropriate template set from the cache
+// based on the page name (like 'home.html'), executes it and writes the
+// result with the given HTTP status code.
+func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+    // Retrieve the appropriate template set from the cache based on the page
+    // name (like 'home.html'). If no entry exists in the cache with the
+    // provided name, then create a new error and call the serverError() helper
+    // method that we made earlier and return.
+    cache := app.templateCache
+    if app.reloadTemplates != nil {
+        var err error
+        cache, err = app.reloadTemplates()
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+    }
+    ts, ok := cache[page]
+    if !ok {
+        err := fmt.Errorf("the template %s does not exist", page)
+        app.serverError(w, err)
+        return
+    }
+
+    // Initialize a new buffer and write the template to the buffer, instead
+    // of straight to the http.ResponseWriter. If there's an error, call our
+    // serverError() helper and then return.
+    buf := new(bytes.Buffer)
+
+    err := ts.ExecuteTemplate(buf, "base", data)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    // If the template is written to the buffer without any errors, we are safe
+    // to go ahead and write the HTTP status code to http.ResponseWriter. As
+    // we already have the whole page we can also set the Content-Type and
+    // Content-Length ourselves, rather than having net/http sniff the first
+    // bytes of the body.
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+    w.WriteHeader(status)
+
+    // Write the contents of the buffer to the http.ResponseWriter. At this
+    // point the status has been sent, so all we can do with an error (most
+    // likely the client went away) is log it.
+    _, err = buf.WriteTo(w)
+    if err != nil {
+        app.errorLog.Printf("render %s: %v", page, err)
+    }
+}
+
+// Create a newTemplateData() helper, which returns a pointer to a templateData
+// struct initialized with the data every page needs: the site branding, the
+// current year, any
+// flash message from the session, the authentication status and the CSRF
+// token for the forms.
+func (app *application) newTemplateData(r *http.Request) *templateData {
+    _, _, hasTerms, hasPrivacy := app.legal.get()
+    return &templateData{
+        SiteName:        app.branding.siteName,
+        SiteLogoURL:     app.branding.siteLogoURL,
+        CurrentYear:     time.Now().Year(),
+        TermsEnabled:    hasTerms,
+        PrivacyEnabled:  hasPrivacy,
+        Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+        IsAuthenticated: app.isAuthenticated(r),
+        AllowAnonymous:  app.allowAnonymous,
+        AccountsEnabled: app.users != nil,
+        CSRFToken:       nosurf.Token(r),
+        Banner:          app.pageBanner(r),
+    }
+}
+
+// Return true if the current request is from an authenticated user, otherwise
+// return false. The value is set in the request context by the authenticate
+// middleware once it has checked that the user still exists.
+func (app *application) isAuthenticated(r *http.Request) bool {
+    isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+    if !ok {
+        return false
+    }
+    return isAuthenticated
+}
+
+// The authenticatedUserID helper returns the ID of the logged-in user, or 0
+// if the request is from an anonymous visitor.
+func (app *application) authenticatedUserID(r *http.Request) int {
+    if !app.isAuthenticated(r) {
+        return 0
+    }
+    return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// The realIP helper returns the IP address of the client, without the port.
+// Requests from one of the -trusted-proxies carry the client's address in
+// the X-Forwarded-For header instead. Each proxy appends the address it got
+// the request from, so we walk the header from the right, skipping our own
+// proxies, and stop at the first address we don't trust: anything left of
+// it could have been made up by the client.
+func (app *application) realIP(r *http.Request) string {
+    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        ip = r.RemoteAddr
+    }
+    if !app.trustedProxies.contains(ip) {
+        return ip
+    }
+
+    hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop := strings.TrimSpace(hops[i])
+        if hop == "" {
+            continue
+        }
+        if _, err := netip.ParseAddr(hop); err != nil {
+            // A garbled entry means we can't tell who is behind it, so the
+            // last proxy is as far as we can go.
+            return ip
+        }
+        ip = hop
+        if !app.trustedProxies.contains(ip) {
+            break
+        }
+    }
+    return ip
+}
+
+// The isHTTPS helper reports whether the client reached us over HTTPS:
+// either we terminated TLS ourselves, or one of the -trusted-proxies did
+// and says so in X-Forwarded-Proto. Anyone else's header is ignored.
+func (app *application) isHTTPS(r *http.Request) bool {
+    if r.TLS != nil {
+        return true
+    }
+    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        ip = r.RemoteAddr
+    }
+    if !app.trustedProxies.contains(ip) {
+        return false
+    }
+    // The last proxy to add a value is the one in front of us.
+    protos := strings.Split(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","), ",")
+    return strings.EqualFold(strings.TrimSpace(protos[len(protos)-1]), "https")
+}
+
+// The checkPassword helper applies the password policy to a new password and
+// records any problem against the given form field, so the signup and
+// password change forms can highlight the right input.
+func (app *application) checkPassword(v *validator.Validator, key, password string) {
+    v.CheckField(validator.NotBlank(password), key, "This field cannot be blank")
+    v.CheckField(validator.MinChars(password, app.minPasswordLength), key,
+        fmt.Sprintf("This field must be at least %d characters long", app.minPasswordLength))
+    v.CheckField(validator.NotCommonPassword(password), key, "This password is too common, please choose another")
+}
+
+// submittedCharset returns the charset a form was submitted in. Browsers
+// fill in a field called _charset_ with it, and other clients can name it in
+// the Content-Type header. It is empty if neither says.
+func submittedCharset(r *http.Request) string {
+    if charset := r.PostForm.Get("_charset_"); charset != "" {
+        return charset
+    }
+    _, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if err != nil {
+        return ""
+    }
+    return params["charset"]
+}
+
+// decodeText converts submitted text to UTF-8. Text in another charset is
+// only converted with -non-utf8=transcode, otherwise it has to be valid
+// UTF-8 already.
+func (app *application) decodeText(s, charset string) (string, error) {
+    if app.transcode && !textnorm.IsUTF8(charset) {
+        return textnorm.ToUTF8(s, charset)
+    }
+    return textnorm.ToUTF8(s, "")
+}
+
+// The -strip-bom choices.
+const (
+    stripBOMInsert = "insert"
+    stripBOMRaw    = "raw"
+    stripBOMOff    = "off"
+)
+
+// normalizeContent gets the content of a new chunk ready to store: it is
+// converted to UTF-8 by decodeText, a byte order mark is removed with
+// -strip-bom=insert and, with -normalize-newlines, its Windows line endings
+// become LF. It also reports whether the content was changed.
+func (app *application) normalizeContent(content, charset string) (string, bool, error) {
+    decoded, err := app.decodeText(content, charset)
+    if err != nil {
+        return "", false, err
+    }
+    normalized := decoded != content
+    if app.stripBOM == stripBOMInsert {
+        var stripped bool
+        decoded, stripped = textnorm.StripBOM(decoded)
+        normalized = normalized || stripped
+    }
+    if app.normalizeNewlines {
+        var changed bool
+        decoded, changed = textnorm.NormalizeNewlines(decoded)
+        normalized = normalized || changed
+    }
+    return decoded, normalized, nil
+}
+
+// transformContent runs the content transformers (-trim-trailing-whitespace,
+// -tabs-to-spaces) over the content of a new or edited chunk, once its
+// language is known. A transformer's error, or a result which is now over
+// the size limit, is added to v as an error on the content field. It also
+// reports whether the content changed.
+func (app *application) transformContent(v *validator.Validator, language, content string) (string, bool) {
+    if len(app.transformers) == 0 {
+        return content, false
+    }
+    transformed, err := app.transformers.Transform(language, content)
+    if err != nil {
+        v.AddFieldError("content", err.Error())
+        return content, false
+    }
+    if limit := app.maxContentBytes(language); !validator.MaxBytes(transformed, limit) {
+        v.AddFieldError("content", app.contentLimitMessage(language, limit))
+        return content, false
+    }
+    return transformed, transformed != content
+}
+
+// The validateChunk helper checks the fields of a new chunk. It is shared by
+// the HTML create form and the JSON API so both apply the same rules. The
+// expiry must be one of the choices of the creator's expiry policy.
+func (app *application) validateChunk(v *validator.Validator, title, content string, expires int, language string, policy expiryPolicy) {
+    app.validateChunkText(v, title, content, language)
+    // 0 leaves the expiry to the policy, see expiryPolicy.resolve.
+    v.CheckField(expires == 0 || validator.PermittedInt(expires, policy.choices()...), "expires", policy.message())
+}
+
+// The validateChunkText helper checks the fields which can be changed when a
+// chunk is edited: everything but the expiry.
+func (app *application) validateChunkText(v *validator.Validator, title, content, language string) {
+    v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+    v.CheckField(validator.MaxChars(title, app.maxTitleLength), "title", fmt.Sprintf("This field cannot be more than %d characters long", app.maxTitleLength))
+    v.CheckField(validator.NotBlank(content), "content", "This field cannot be blank")
+    v.CheckField(validator.MinChars(strings.TrimSpace(content), app.minChunkChars), "content", fmt.Sprintf("This field must be at least %d characters long", app.minChunkChars))
+    if limit := app.maxContentBytes(language); !validator.MaxBytes(content, limit) {
+        v.AddFieldError("content", app.contentLimitMessage(language, limit))
+    }
+    v.CheckField(language == autoLanguage || app.languageAllowed(language), "language", "This language is not supported")
+}
+
+// The normalizeLanguage helper turns a submitted language into the name we
+// store: a blank value means the default language and any alias the
+// highlighter knows ("golang") becomes its canonical name ("go"). Anything
+// else is returned unchanged, for validateChunk to reject.
+func (app *application) normalizeLanguage(language string) string {
+    if strings.TrimSpace(language) == "" {
+        return app.defaultLanguage()
+    }
+    if lang, ok := highlight.Lookup(language); ok {
+        return lang.Name
+    }
+    return language
+}
+
+// autoLanguage is the language value asking us to detect the language from
+// the content.
+const autoLanguage = "auto"
+
+// The detectLanguage helper resolves the "auto" language for some content.
+// It returns the detected language when the highlighter is confident enough
+// (see -language-detect-threshold), or the default language otherwise. The
+// second result reports whether anything was detected.
+func (app *application) detectLanguage(content string) (highlight.Language, bool) {
+    lang, confidence := highlight.Detect(content, app.languages)
+    if confidence > 0 && confidence >= app.detectThreshold {
+        return lang, true
+    }
+    lang, _ = highlight.Lookup(app.defaultLanguage())
+    return lang, false
+}
+
+// The defaultLanguage is plain text, unless -languages leaves it out, in
+// which case it's the first language listed.
+func (app *application) defaultLanguage() string {
+    if app.languageAllowed(highlight.PlainText) {
+        return highlight.PlainText
+    }
+    return app.languages[0].Name
+}
+
+// The languageAllowed helper reports whether chunks may be created in the
+// language with the given (canonical) name.
+func (app *application) languageAllowed(name string) bool {
+    for _, lang := range app.languages {
+        if lang.Name == name {
+            return true
+        }
+    }
+    return false
+}
+
+// The absoluteURL helper turns an application path into a full URL using the
+// scheme and host of the current request, for links handed out to be used
+// elsewhere (share links, API responses).
+func (app *application) absoluteURL(r *http.Request, path string) string {
+    scheme := "http"
+    if app.isHTTPS(r) {
+        scheme = "https"
+    }
+    return scheme + "://" + r.Host + app.url(path)
+}
+
+// The notModified helper sets the Last-Modified and ETag headers of a
+// listing page and answers a matching If-None-Match or If-Modified-Since
+// request with 304 Not Modified. It returns true when it has written the
+// 304, in which case the caller is done. Cache-Control: no-cache lets
+// caches store the page but makes them revalidate it every time, which is
+// cheap thanks to the 304.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+    if modified.IsZero() {
+        return false
+    }
+    // The ETag is weak, as the page is only the same where it counts: the
+    // chunks listed. It has the microseconds HTTP dates don't.
+    etag := fmt.Sprintf(`W/"%x"`, modified.UnixMicro())
+    // HTTP dates only have second precision.
+    modified = modified.UTC().Truncate(time.Second)
+
+    w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+    w.Header().Set("ETag", etag)
+    w.Header().Set("Cache-Control", "no-cache")
+
+    // If-None-Match wins over If-Modified-Since when a client sends both.
+    if match := r.Header.Get("If-None-Match"); match != "" {
+        if !etagMatches(match, etag) {
+            return false
+        }
+    } else {
+        since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+        if err != nil || modified.After(since) {
+            return false
+        }
+    }
+    w.WriteHeader(http.StatusNotModified)
+    return true
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag,
+// comparing weakly (W/"x" matches "x") as RFC 9110 has it for GET and HEAD.
+func etagMatches(header, etag string) bool {
+    etag = strings.TrimPrefix(etag, "W/")
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+            return true
+        }
+    }
+    return false
+}
+
+// The listingCacheable helper reports whether a listing page (the home
+// page, the search results) is the same for every visitor, so it can be
+// revalidated against LatestModified. Pages for logged-in users or with a
+// pending flash message differ from visitor to visitor, and pages with a
+// banner change without any chunk changing.
+func (app *application) listingCacheable(r *http.Request) bool {
+    return !app.isAuthenticated(r) && !app.sessionManager.Exists(r.Context(), "flash") && app.banner.get().Message == ""
+}
+
+// The headOnly helper answers a HEAD request for a page without rendering
+// it: the headers set so far (like notModified's), the Content-Type of a
+// page and a 200. It returns false for other methods, which the caller
+// then renders as usual. Monitoring tools use HEAD to check a page is up.
+func (app *application) headOnly(w http.ResponseWriter, r *http.Request) bool {
+    if r.Method != http.MethodHead {
+        return false
+    }
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+    return true
+}
+
+// The url helper prepends the -base-path prefix to an application path, so
+// redirects keep working when chunkbox is mounted under a sub-path.
+func (app *application) url(path string) string {
+    return app.basePath + path
+}
+
+// pageNumber returns the page of a paginated listing asked for in the "page"
+// query parameter, counting from 1. It defaults to the first page. If the
+// parameter isn't a valid page number it sends a 400, and for pages past
+// -max-page a 404, and reports false.
+//
+// Page numbers are an OFFSET, which the database can only serve by reading
+// and throwing away every earlier row, so a crawler asking for page 99999999
+// makes for a slow query; hence the cap. The home page pages with a cursor
+// instead (see ChunkStore.ListAfter), which costs the same at any depth but
+// can't jump to an arbitrary page.
+func (app *application) pageNumber(w http.ResponseWriter, r *http.Request) (int, bool) {
+    s := r.URL.Query().Get("page")
+    if s == "" {
+        return 1, true
+    }
+    n, err := strconv.Atoi(s)
+    if err != nil || n < 1 {
+        app.clientError(w, http.StatusBadRequest)
+        return 0, false
+    }
+    if app.maxPage > 0 && n > app.maxPage {
+        app.notFound(w)
+        return 0, false
+    }
+    return n, true
+}
+
+// nextPage returns the number of the page after page, or 0 when there is
+// no next page or it is past -max-page.
+func (app *application) nextPage(page int, hasNext bool) int {
+    if !hasNext || (app.maxPage > 0 && page >= app.maxPage) {
+        return 0
+    }
+    return page + 1
+}
+
+// The canView helper reports whether the current user may see a chunk.
+// Public chunks are visible to everyone, private ones only to their owner.
+func (app *application) canView(r *http.Request, chunk *models.Chunk) bool {
+    if !chunk.Private {
+        return true
+    }
+    return chunk.UserID != 0 && chunk.UserID == app.authenticatedUserID(r)
+}
+
+// countingWriter wraps an io.Writer and counts the bytes written through it.
+// Handlers that stream a response use it to tell whether an error happened
+// before or after the response body was started.
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+    n, err := cw.w.Write(p)
+    cw.n += int64(n)
+    return n, err
+}
+
+// The truncate helper shortens s to at most n characters, adding "..." when
+// anything was cut off. It is used to keep user content in log lines short.
+func truncate(s string, n int) string {
+    runes := []rune(s)
+    if len(runes) <= n {
+        return s
+    }
+    return string(runes[:n]) + "..."
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+    "errors"
+    "html/template"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+func TestRenderTemplateError(t *testing.T) {
+    app := newTestApplication(t)
+    // The page is half written when fail stops it.
+    ts := template.Must(template.New("base").Funcs(template.FuncMap{
+        "fail": func() (string, error) { return "", errors.New("template failed") },
+    }).Parse(`<html><body><h1>Partial page</h1>{{fail}}</body></html>`))
+    app.templateCache = map[string]*template.Template{"broken.html": ts}
+
+    rr := httptest.NewRecorder()
+    app.render(rr, http.StatusOK, "broken.html", &templateData{})
+
+    if rr.Code != http.StatusInternalServerError {
+        t.Errorf("status %d, want %d", rr.Code, http.StatusInternalServerError)
+    }
+    if body := rr.Body.String(); strings.Contains(body, "Partial page") {
+        t.Errorf("body has the partial page: %q", body)
+    }
+    if ct := rr.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/html") {
+        t.Errorf("Content-Type %q of the page", ct)
+    }
+}
+
+func TestValidateChunkTitleLength(t *testing.T) {
+    app := newTestApplication(t)
+    app.maxTitleLength = 10
+
+    tests := []struct {
+        title string
+        valid bool
+    }{
+        {"short", true},
+        {strings.Repeat("x", 10), true},
+        {strings.Repeat("x", 11), false},
+        // The limit is in characters, not bytes.
+        {strings.Repeat("é", 10), true},
+        {strings.Repeat("日", 10), true},
+        {strings.Repeat("日", 11), false},
+        {strings.Repeat("🙂", 10), true},
+    }
+    for _, tt := range tests {
+        var v validator.Validator
+        app.validateChunkText(&v, tt.title, "content", highlight.PlainText)
+        if _, invalid := v.FieldErrors["title"]; invalid == tt.valid {
+            t.Errorf("title %q: valid = %t, want %t", tt.title, !invalid, tt.valid)
+        }
+    }
+}
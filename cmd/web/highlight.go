@@ -0,0 +1,107 @@
+/*-----------------------------------------------------------
+ @Filename:         highlight.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "html/template"
+    "net/http"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// The highlight stylesheet is generated at startup, so its modification time
+// for conditional requests is when the process started.
+var highlightCSSTime = time.Now()
+
+// highlightCSS serves the stylesheet for the -highlight-theme.
+func (app *application) highlightCSS(w http.ResponseWriter, r *http.Request) {
+    http.ServeContent(w, r, "highlight.css", highlightCSSTime, bytes.NewReader(app.highlighter.CSS()))
+}
+
+// highlightChunk returns the syntax highlighted content of a chunk, or an
+// empty string if it should be shown as plain text. A highlighting failure
+// is logged and the chunk falls back to plain text, rather than failing the
+// whole page. Rendered chunks are kept in the -highlight-cache-size cache,
+// plain text needs no rendering so it isn't cached. The variant tells apart
+// chunks displayed differently, such as with their lines cut short; it is
+// empty for the content as it was saved.
+//
+// At most -max-highlight-concurrency chunks are rendered at once. A request
+// waits up to -highlight-wait for its turn (less if it is cancelled), and
+// otherwise gets plain text, which isn't cached, so a later view can still
+// be highlighted.
+func (app *application) highlightChunk(ctx context.Context, chunk *models.Chunk, variant string) template.HTML {
+    if chunk.Language == "" || chunk.Language == highlight.PlainText {
+        return ""
+    }
+
+    key := app.highlightCacheKey(chunk, variant)
+    if html, ok := app.highlightCache.Get(key); ok {
+        return html
+    }
+
+    if !app.acquireHighlight(ctx) {
+        app.metrics.highlightFallbacks.Add(1)
+        return ""
+    }
+    defer app.highlightJobs.Release(1)
+    app.metrics.highlightJobs.Add(1)
+
+    html, err := app.highlighter.HTML(chunk.Language, chunk.Content)
+    if err != nil {
+        app.errorLog.Printf("highlight chunk %d (%s): %v", chunk.ID, chunk.Language, err)
+        return ""
+    }
+    app.highlightCache.Add(key, html)
+    return html
+}
+
+// acquireHighlight takes one of the -max-highlight-concurrency slots for
+// rendering a chunk, waiting at most -highlight-wait. It reports whether it
+// got one; the caller must release it.
+func (app *application) acquireHighlight(ctx context.Context) bool {
+    if app.highlightJobs.TryAcquire(1) {
+        return true
+    }
+    if app.highlightWait <= 0 {
+        return false
+    }
+    ctx, cancel := context.WithTimeout(ctx, app.highlightWait)
+    defer cancel()
+    return app.highlightJobs.Acquire(ctx, 1) == nil
+}
+
+// highlightCacheKey is the key of a chunk's rendered HTML in the highlight
+// cache. Anything which changes the content must remove it from the cache,
+// with every variant.
+func (app *application) highlightCacheKey(chunk *models.Chunk, variant string) string {
+    return fmt.Sprintf("%d:%s:%s:%s", chunk.ID, chunk.Language, app.highlighter.Theme(), variant)
+}
+
+// forgetHighlight removes every cached rendering of a chunk, for when its
+// content or language changes or it is deleted.
+func (app *application) forgetHighlight(chunk *models.Chunk) {
+    app.highlightCache.Remove(app.highlightCacheKey(chunk, ""))
+    app.highlightCache.Remove(app.highlightCacheKey(chunk, app.truncateVariant()))
+    app.highlightCache.Remove(app.highlightCacheKey(chunk, prettyVariant))
+    app.highlightCache.Remove(app.highlightCacheKey(chunk, prettyVariant+"-"+app.truncateVariant()))
+}
+
+// prettyVariant is the highlight cache variant of chunks displayed pretty
+// printed. Truncated as well, it is followed by "-" and the
+// truncateVariant, like the variants of the files.
+const prettyVariant = "pretty"
+
+// truncateVariant is the highlight cache variant of chunks displayed with
+// -wrap=truncate.
+func (app *application) truncateVariant() string {
+    return fmt.Sprintf("truncate%d", app.wrapWidth)
+}
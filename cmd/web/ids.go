@@ -0,0 +1,198 @@
+/*-----------------------------------------------------------
+ @Filename:         ids.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+
+    "github.com/cpucortexm/chunkbox/internal/hashids"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// The -id-scheme choices: how chunks are identified in URLs.
+const (
+    // idSchemePlain uses the random public ID stored with each chunk.
+    idSchemePlain = "plain"
+    // idSchemeHashids uses the database id, obfuscated with Hashids and
+    // the -hashids-salt.
+    idSchemeHashids = "hashids"
+)
+
+// hashidsMinLength is the shortest id -id-scheme=hashids gives out, so the
+// first chunks don't get one or two character ids.
+const hashidsMinLength = 6
+
+// A hashidChunks is a ChunkStore whose chunks are identified by the Hashids
+// of their database id (-id-scheme=hashids) rather than their random public
+// ID. It rewrites the PublicID of every chunk it returns, so the handlers,
+// templates and links keep using PublicID and never know the difference,
+// and there is no column to add or backfill. Public IDs which don't decode
+// are looked up as they are, so links handed out before the switch keep
+// working.
+type hashidChunks struct {
+    models.ChunkStore
+    ids *hashids.Hashids
+}
+
+func (s *hashidChunks) encode(chunks ...*models.Chunk) {
+    for _, c := range chunks {
+        c.PublicID = s.ids.Encode(int64(c.ID))
+    }
+}
+
+// id returns the database id a hashid encodes.
+func (s *hashidChunks) id(publicID string) (int, bool) {
+    id, ok := s.ids.Decode(publicID)
+    if !ok || id > int64(^uint(0)>>1) {
+        return 0, false
+    }
+    return int(id), true
+}
+
+// lookup gets a chunk by its hashid with get, or by its old public ID with
+// getByPublicID if it isn't one.
+func (s *hashidChunks) lookup(publicID string, get func(int) (*models.Chunk, error), getByPublicID func(string) (*models.Chunk, error)) (*models.Chunk, error) {
+    var c *models.Chunk
+    err := models.ErrNoRecord
+    if id, ok := s.id(publicID); ok {
+        c, err = get(id)
+    }
+    if errors.Is(err, models.ErrNoRecord) {
+        c, err = getByPublicID(publicID)
+    }
+    if err != nil {
+        return nil, err
+    }
+    s.encode(c)
+    return c, nil
+}
+
+func (s *hashidChunks) Insert(title string, content string, expires int, language string, userID int, private bool, keepAlive bool, normalized bool, tags []string, files []models.ChunkFile, ip string) (string, error) {
+    publicID, err := s.ChunkStore.Insert(title, content, expires, language, userID, private, keepAlive, normalized, tags, files, ip)
+    if err != nil {
+        return "", err
+    }
+    return s.hashid(publicID)
+}
+
+func (s *hashidChunks) InsertBatch(inputs []models.ChunkInput) ([]string, error) {
+    publicIDs, err := s.ChunkStore.InsertBatch(inputs)
+    if err != nil {
+        return nil, err
+    }
+    for i, publicID := range publicIDs {
+        publicIDs[i], err = s.hashid(publicID)
+        if err != nil {
+            return nil, err
+        }
+    }
+    return publicIDs, nil
+}
+
+// hashid returns the hashid of the chunk just inserted with a public ID.
+// Insert only returns the public ID, so this costs a lookup.
+func (s *hashidChunks) hashid(publicID string) (string, error) {
+    c, err := s.ChunkStore.GetMetaByPublicID(publicID)
+    if err != nil {
+        return "", err
+    }
+    return s.ids.Encode(int64(c.ID)), nil
+}
+
+func (s *hashidChunks) Get(id int) (*models.Chunk, error) {
+    c, err := s.ChunkStore.Get(id)
+    if err != nil {
+        return nil, err
+    }
+    s.encode(c)
+    return c, nil
+}
+
+func (s *hashidChunks) GetByPublicID(publicID string) (*models.Chunk, error) {
+    return s.lookup(publicID, s.ChunkStore.Get, s.ChunkStore.GetByPublicID)
+}
+
+func (s *hashidChunks) GetMeta(id int) (*models.Chunk, error) {
+    c, err := s.ChunkStore.GetMeta(id)
+    if err != nil {
+        return nil, err
+    }
+    s.encode(c)
+    return c, nil
+}
+
+func (s *hashidChunks) GetMetaByPublicID(publicID string) (*models.Chunk, error) {
+    return s.lookup(publicID, s.ChunkStore.GetMeta, s.ChunkStore.GetMetaByPublicID)
+}
+
+func (s *hashidChunks) Latest(previewChars int) ([]*models.Chunk, error) {
+    chunks, err := s.ChunkStore.Latest(previewChars)
+    s.encode(chunks...)
+    return chunks, err
+}
+
+func (s *hashidChunks) ListAfter(afterID, limit, previewChars int) ([]*models.Chunk, error) {
+    chunks, err := s.ChunkStore.ListAfter(afterID, limit, previewChars)
+    s.encode(chunks...)
+    return chunks, err
+}
+
+func (s *hashidChunks) Search(query string, limit int) ([]*models.Chunk, error) {
+    chunks, err := s.ChunkStore.Search(query, limit)
+    s.encode(chunks...)
+    return chunks, err
+}
+
+func (s *hashidChunks) SearchRanked(query string, minScore float64, limit int) ([]*models.Chunk, error) {
+    chunks, err := s.ChunkStore.SearchRanked(query, minScore, limit)
+    s.encode(chunks...)
+    return chunks, err
+}
+
+func (s *hashidChunks) Related(chunkID int, limit int) ([]*models.Chunk, error) {
+    chunks, err := s.ChunkStore.Related(chunkID, limit)
+    s.encode(chunks...)
+    return chunks, err
+}
+
+func (s *hashidChunks) ExistsTitleForUser(userID int, title string) (*models.Chunk, error) {
+    chunk, err := s.ChunkStore.ExistsTitleForUser(userID, title)
+    if err == nil {
+        s.encode(chunk)
+    }
+    return chunk, err
+}
+
+func (s *hashidChunks) ExistsContent(userID int, ip, sha256 string) (*models.Chunk, error) {
+    chunk, err := s.ChunkStore.ExistsContent(userID, ip, sha256)
+    if err == nil {
+        s.encode(chunk)
+    }
+    return chunk, err
+}
+
+// DeleteMatching matches the hashids in the filter by database id. The
+// public IDs which aren't hashids stay, for chunks from before the switch.
+func (s *hashidChunks) DeleteMatching(filter models.ChunkFilter) (int, error) {
+    publicIDs := filter.PublicIDs
+    filter.PublicIDs = nil
+    for _, publicID := range publicIDs {
+        if id, ok := s.id(publicID); ok {
+            filter.IDs = append(filter.IDs, id)
+        } else {
+            filter.PublicIDs = append(filter.PublicIDs, publicID)
+        }
+    }
+    return s.ChunkStore.DeleteMatching(filter)
+}
+
+// encodePublicIDs gives chunks loaded outside the ChunkStore, like the
+// favorites, the ids of the -id-scheme.
+func (app *application) encodePublicIDs(chunks ...*models.Chunk) {
+    if s, ok := app.chunks.(*hashidChunks); ok {
+        s.encode(chunks...)
+    }
+}
@@ -0,0 +1,234 @@
+/*-----------------------------------------------------------
+ @Filename:         inbound.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/mail"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/inbound"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// With -enable-inbound-email, chunks can be created by email: the inbound
+// parse webhook of Mailgun or SendGrid (-inbound-email-provider) posts the
+// emails sent to an address of the instance to /api/v1/inbound/email. The
+// subject becomes the title of the chunk, and the first attachment its
+// content, or the text of the email if there is none; the other attachments
+// become its other files (-max-files). With -smtp-addr the sender gets a
+// reply with the URL of the chunk, or with what was wrong with the email.
+//
+// Only the senders of -inbound-email-senders are accepted, as the chunks
+// are anonymous and the replies go to whoever the From header names. The
+// provider checks SPF and DKIM, but doesn't refuse the emails failing them,
+// so the allowlist is a safeguard against mistakes and spam rather than
+// authentication.
+const (
+    // inboundMaxBytes is the largest request taken from the provider, about
+    // the largest email the common mail servers let through.
+    inboundMaxBytes = 25 << 20
+    // inboundDefaultTitle is the title of a chunk from an email without a
+    // subject or attachments.
+    inboundDefaultTitle = "Chunk by email"
+)
+
+// inboundEmail creates a chunk from an email posted by the provider. Its
+// signature is checked first. An email which can't be taken, because of its
+// sender or its content, is still answered 200, as anything else has the
+// provider retry it for days.
+func (app *application) inboundEmail(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        app.apiMethodNotAllowed(w, http.MethodPost)
+        return
+    }
+
+    email, err := app.inbound.Parse(w, r, inboundMaxBytes)
+    if err != nil {
+        var maxBytesError *http.MaxBytesError
+        switch {
+        case errors.Is(err, inbound.ErrInvalidSignature):
+            app.infoLog.Printf("inbound email: %s sent a request which isn't signed by the provider", app.realIP(r))
+            app.apiError(w, http.StatusUnauthorized, "invalid_signature", "the request isn't signed by the email provider")
+        case errors.As(err, &maxBytesError):
+            app.apiError(w, http.StatusRequestEntityTooLarge, "too_large",
+                fmt.Sprintf("the email is larger than %s", formatBytes(inboundMaxBytes)))
+        default:
+            app.apiError(w, http.StatusBadRequest, "bad_request", err.Error())
+        }
+        return
+    }
+
+    if !app.inboundSenderAllowed(email.From) {
+        app.infoLog.Printf("inbound email: refused an email from %s, who isn't in -inbound-email-senders", email.From)
+        app.writeJSON(w, http.StatusOK, envelope{"accepted": false, "reason": "the sender isn't allowed to create chunks"})
+        return
+    }
+
+    chunk, fieldErrors, err := app.prepareAPIChunk(r, app.inboundChunk(email), "", app.expiryPolicy(r))
+    if err != nil {
+        app.apiServerError(w, err)
+        return
+    }
+    if fieldErrors != nil {
+        app.replyInbound(email, inboundRefusal(fieldErrors))
+        app.writeJSON(w, http.StatusOK, envelope{"accepted": false, "reason": "the email is not a valid chunk", "errors": fieldErrors})
+        return
+    }
+
+    created := app.createAPIChunks(w, r, []models.ChunkInput{chunk})
+    if created == nil {
+        return
+    }
+    app.infoLog.Printf("inbound email: created chunk %s from an email by %s", created[0]["id"], email.From)
+    app.replyInbound(email, inboundCreated(chunk.Title, created[0]))
+
+    app.writeJSON(w, createdStatus(created), envelope{"accepted": true, "chunk": created[0]})
+}
+
+// inboundSenderAllowed reports whether the address is in the allowlist of
+// -inbound-email-senders, itself or by its domain.
+func (app *application) inboundSenderAllowed(address string) bool {
+    domain := address[strings.LastIndex(address, "@")+1:]
+    for _, allowed := range app.inboundSenders {
+        if allowed == address || allowed == "@"+domain {
+            return true
+        }
+    }
+    return false
+}
+
+// parseSenderList parses -inbound-email-senders: addresses, or domains
+// written @example.com, separated by commas.
+func parseSenderList(s string) ([]string, error) {
+    var senders []string
+    for _, entry := range strings.Split(s, ",") {
+        entry = strings.ToLower(strings.TrimSpace(entry))
+        if entry == "" {
+            continue
+        }
+        if strings.HasPrefix(entry, "@") {
+            if len(entry) == 1 || strings.Contains(entry[1:], "@") {
+                return nil, fmt.Errorf("%q is not a domain", entry)
+            }
+        } else if address, err := mail.ParseAddress(entry); err != nil || address.Address != entry {
+            return nil, fmt.Errorf("%q is not an email address", entry)
+        }
+        senders = append(senders, entry)
+    }
+    return senders, nil
+}
+
+// inboundChunk turns an email into the fields of a chunk. The language is
+// the one the file name of the first attachment points to, and detected
+// from the content otherwise.
+func (app *application) inboundChunk(email *inbound.Email) apiChunkInput {
+    item := apiChunkInput{Title: email.Subject, Language: autoLanguage}
+    if len(email.Attachments) == 0 {
+        item.Content = stripSignature(email.Text)
+    } else {
+        first := email.Attachments[0]
+        item.Content = string(first.Content)
+        item.Language = app.filenameLanguage(first.Filename, autoLanguage)
+        for _, a := range email.Attachments[1:] {
+            item.Files = append(item.Files, apiChunkFile{
+                Filename: a.Filename,
+                Language: app.filenameLanguage(a.Filename, ""),
+                Content:  string(a.Content),
+            })
+        }
+        if item.Title == "" {
+            item.Title = first.Filename
+        }
+    }
+    if item.Title == "" {
+        item.Title = inboundDefaultTitle
+    }
+    if runes := []rune(item.Title); len(runes) > app.maxTitleLength {
+        item.Title = string(runes[:app.maxTitleLength])
+    }
+    return item
+}
+
+// filenameLanguage returns the language a file name points to, if it is
+// allowed here, or else fallback.
+func (app *application) filenameLanguage(filename, fallback string) string {
+    if lang, ok := highlight.ForFilename(filename); ok && app.languageAllowed(lang.Name) {
+        return lang.Name
+    }
+    return fallback
+}
+
+// stripSignature cuts the signature off the text of an email, from the
+// "-- " line mail clients put before it.
+func stripSignature(text string) string {
+    text = strings.ReplaceAll(text, "\r\n", "\n")
+    if strings.HasPrefix(text, "-- \n") {
+        return ""
+    }
+    if i := strings.Index(text, "\n-- \n"); i >= 0 {
+        text = text[:i+1]
+    }
+    return text
+}
+
+// inboundCreated is the reply to an email a chunk was created from, or
+// which had the content of one already (-unique-per-user=redirect).
+func inboundCreated(title string, created envelope) string {
+    var b strings.Builder
+    if created["existing"] == true {
+        fmt.Fprintf(&b, "You already have a chunk with this content, so it wasn't created again:\n\n    %s\n", created["url"])
+        return b.String()
+    }
+    fmt.Fprintf(&b, "Your chunk %q was created:\n\n    %s\n", title, created["url"])
+    if link, ok := created["edit_url"].(string); ok {
+        expires, _ := created["edit_expires"].(time.Time)
+        fmt.Fprintf(&b, "\nIt can be edited or deleted until %s with this secret link:\n\n    %s\n",
+            expires.Format("2 Jan 2006 15:04 UTC"), link)
+    }
+    return b.String()
+}
+
+// inboundRefusal is the reply to an email which isn't a valid chunk.
+func inboundRefusal(fieldErrors map[string]string) string {
+    fields := make([]string, 0, len(fieldErrors))
+    for field := range fieldErrors {
+        fields = append(fields, field)
+    }
+    sort.Strings(fields)
+
+    var b strings.Builder
+    b.WriteString("No chunk could be created from your email:\n\n")
+    for _, field := range fields {
+        fmt.Fprintf(&b, "    %s: %s\n", field, fieldErrors[field])
+    }
+    return b.String()
+}
+
+// replyInbound answers an email in the background, if -smtp-addr is set.
+func (app *application) replyInbound(email *inbound.Email, body string) {
+    if app.mailer == nil {
+        return
+    }
+    subject := "Re: " + email.Subject
+    if email.Subject == "" {
+        subject = inboundDefaultTitle
+    }
+    started := app.background.Go(func(context.Context) {
+        if err := app.mailer.Send(email.From, subject, body, email.MessageID); err != nil {
+            app.errorLog.Printf("inbound email: replying to %s: %v", email.From, err)
+        }
+    })
+    if !started {
+        app.errorLog.Printf("inbound email: no reply sent to %s, shutting down", email.From)
+    }
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/inbound"
+)
+
+// postInbound posts an email from the sender as Mailgun would, signed with
+// the key.
+func postInbound(t *testing.T, ts *testServer, key, from string) (*http.Response, string) {
+    t.Helper()
+
+    timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+    mac := hmac.New(sha256.New, []byte(key))
+    mac.Write([]byte(timestamp + "token"))
+    form := url.Values{
+        "from":       {from},
+        "subject":    {"By email"},
+        "body-plain": {"sent by email\n"},
+        "timestamp":  {timestamp},
+        "token":      {"token"},
+        "signature":  {hex.EncodeToString(mac.Sum(nil))},
+    }
+    req := ts.request(t, http.MethodPost, "/api/v1/inbound/email", strings.NewReader(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return ts.do(t, req)
+}
+
+func TestInboundEmailSenders(t *testing.T) {
+    app := newTestApplication(t)
+    receiver, err := inbound.New("mailgun", "signing-key")
+    if err != nil {
+        t.Fatal(err)
+    }
+    app.inbound = receiver
+    app.inboundSenders, err = parseSenderList("alice@example.com, @team.example.com")
+    if err != nil {
+        t.Fatal(err)
+    }
+    ts := newTestServer(t, app.routes())
+
+    tests := []struct {
+        name     string
+        key      string
+        from     string
+        status   int
+        accepted bool
+    }{
+        {"allowed address", "signing-key", "Alice <alice@example.com>", http.StatusCreated, true},
+        {"allowed domain", "signing-key", "bob@team.example.com", http.StatusCreated, true},
+        {"not allowed", "signing-key", "mallory@example.com", http.StatusOK, false},
+        {"subdomain of an allowed domain", "signing-key", "eve@evil.team.example.com", http.StatusOK, false},
+        {"wrong key", "other-key", "alice@example.com", http.StatusUnauthorized, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            resp, body := postInbound(t, ts, tt.key, tt.from)
+            if resp.StatusCode != tt.status {
+                t.Fatalf("status %d, want %d: %s", resp.StatusCode, tt.status, body)
+            }
+            if accepted := strings.Contains(body, `"accepted":true`); accepted != tt.accepted {
+                t.Errorf("accepted %t, want %t: %s", accepted, tt.accepted, body)
+            }
+        })
+    }
+
+    chunks, err := app.chunks.Latest(10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(chunks) != 2 {
+        t.Errorf("%d chunks created, want 2", len(chunks))
+    }
+}
@@ -0,0 +1,107 @@
+/*-----------------------------------------------------------
+ @Filename:         legal.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "html/template"
+    "net/http"
+    "os"
+    "sync"
+
+    "github.com/yuin/goldmark"
+)
+
+// legalPages are the terms of service and privacy policy of an instance,
+// written in Markdown in the -terms-file and -privacy-file files. A page
+// whose file isn't given doesn't exist: it is a 404 and the footer doesn't
+// link it. The files are read at startup and again on SIGHUP (see
+// reload.go), so the pages can be updated without a restart.
+type legalPages struct {
+    mu          sync.RWMutex
+    termsFile   string
+    privacyFile string
+    terms       template.HTML
+    privacy     template.HTML
+}
+
+// load reads the pages from the files, replacing the ones shown. If either
+// can't be read, both stay as they were.
+func (p *legalPages) load(termsFile, privacyFile string) error {
+    terms, err := renderMarkdownFile(termsFile)
+    if err != nil {
+        return fmt.Errorf("-terms-file: %w", err)
+    }
+    privacy, err := renderMarkdownFile(privacyFile)
+    if err != nil {
+        return fmt.Errorf("-privacy-file: %w", err)
+    }
+
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    p.termsFile, p.privacyFile = termsFile, privacyFile
+    p.terms, p.privacy = terms, privacy
+    return nil
+}
+
+// get returns the pages, and whether each is configured.
+func (p *legalPages) get() (terms, privacy template.HTML, hasTerms, hasPrivacy bool) {
+    p.mu.RLock()
+    defer p.mu.RUnlock()
+    return p.terms, p.privacy, p.termsFile != "", p.privacyFile != ""
+}
+
+// renderMarkdownFile converts the Markdown file at path to HTML, or gives
+// nothing for no path. goldmark's defaults are what makes the HTML safe to
+// show: raw HTML in the file is left out, and so are links and images with
+// javascript: and other dangerous URLs.
+func renderMarkdownFile(path string) (template.HTML, error) {
+    if path == "" {
+        return "", nil
+    }
+    source, err := os.ReadFile(path)
+    if err != nil {
+        return "", err
+    }
+    var buf bytes.Buffer
+    if err := goldmark.Convert(source, &buf); err != nil {
+        return "", err
+    }
+    return template.HTML(buf.String()), nil
+}
+
+// A legalPage is the data for the terms or privacy page.
+type legalPage struct {
+    Title   string
+    Content template.HTML
+}
+
+// termsPage shows the -terms-file.
+func (app *application) termsPage(w http.ResponseWriter, r *http.Request) {
+    terms, _, ok, _ := app.legal.get()
+    app.renderLegalPage(w, r, ok, &legalPage{Title: "Terms of Service", Content: terms})
+}
+
+// privacyPage shows the -privacy-file.
+func (app *application) privacyPage(w http.ResponseWriter, r *http.Request) {
+    _, privacy, _, ok := app.legal.get()
+    app.renderLegalPage(w, r, ok, &legalPage{Title: "Privacy Policy", Content: privacy})
+}
+
+func (app *application) renderLegalPage(w http.ResponseWriter, r *http.Request, configured bool, page *legalPage) {
+    if !configured {
+        app.notFound(w)
+        return
+    }
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+    data := app.newTemplateData(r)
+    data.Legal = page
+    app.render(w, http.StatusOK, "legal.html", data)
+}
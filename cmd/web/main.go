@@ -10,22 +10,319 @@
 package main
 
 import (
+    "context"
+    "crypto/tls"
     "database/sql"
+    "html/template"
+    "io"
     "log"
     "net/http"
+    "net/url"
     "flag"
+    "fmt"
     "os"
+    "os/signal"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "syscall"
+    "time"
     // Import the models package from internal/models.
     "github.com/cpucortexm/chunkbox/internal/models"
-    _ "github.com/go-sql-driver/mysql" //we need the driver’s init() function to run so that it can register itself with the database/sql package.
+    "github.com/cpucortexm/chunkbox/internal/blocklist"
+    "github.com/cpucortexm/chunkbox/internal/captcha"
+    "github.com/cpucortexm/chunkbox/internal/fetch"
+    "github.com/cpucortexm/chunkbox/internal/hashids"
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/inbound"
+    "github.com/cpucortexm/chunkbox/internal/mailer"
+    "github.com/cpucortexm/chunkbox/internal/oauth"
+    "github.com/cpucortexm/chunkbox/internal/secrets"
+    "github.com/cpucortexm/chunkbox/internal/signing"
+    "github.com/cpucortexm/chunkbox/internal/transform"
+    "github.com/cpucortexm/chunkbox/internal/webhook"
+    "github.com/alexedwards/scs/mysqlstore"
+    "github.com/alexedwards/scs/redisstore"
+    "github.com/alexedwards/scs/v2/memstore"
+    "github.com/gomodule/redigo/redis"
+    "github.com/alexedwards/scs/v2"
+    "golang.org/x/crypto/bcrypt"
+    "golang.org/x/sync/semaphore"
+    "github.com/go-sql-driver/mysql"
 )
 
 // Define an application struct to hold the application-wide dependencies for the
 // web application. For now we'll only include fields for the two custom loggers.
 type application struct {
-    errorLog *log.Logger
-    infoLog  *log.Logger
-    chunks   *models.ChunkModel
+    errorLog       *log.Logger
+    infoLog        *log.Logger
+    chunks         models.ChunkStore
+    // users is nil when running without a database (-db-driver=memory), in
+    // which case the account pages are not available.
+    users          models.UserStore
+    templateCache  map[string]*template.Template
+    // reloadTemplates is set in -dev mode, where render parses the
+    // templates again for every page instead of using templateCache.
+    reloadTemplates func() (map[string]*template.Template, error)
+    sessionManager *scs.SessionManager
+    // deleteChunksWithUser controls whether deleting an account also deletes
+    // the user's chunks, or keeps them as anonymous chunks.
+    deleteChunksWithUser bool
+    // minPasswordLength is the shortest password accepted on signup and
+    // password change.
+    minPasswordLength int
+    // dependencies are the external services checked by /readyz.
+    dependencies []dependency
+    // branding holds the site name, logo and favicon of this instance.
+    branding *branding
+    // legal holds the terms and privacy pages (-terms-file, -privacy-file).
+    legal *legalPages
+    // blocklist holds the spam patterns new chunks are checked against. It
+    // is nil when no -blocklist-file is configured. A SIGHUP swaps in a new
+    // one (see reload.go).
+    blocklist atomic.Pointer[blocklist.Blocklist]
+    // requestLog is the -access-log, or nil when requests are logged to the
+    // info log.
+    requestLog *requestLog
+    // config rereads the -config file on SIGHUP.
+    config *configReloader
+    // signer signs the tokens we hand out to clients (like the create form
+    // timestamp) with the server secret.
+    signer *signing.Signer
+    // minFillTime is how long the create form must have been displayed
+    // before a submission is accepted.
+    minFillTime time.Duration
+    // captcha verifies CAPTCHA responses on anonymous chunk creation. It is
+    // nil unless -captcha-provider is set.
+    captcha *captcha.Verifier
+    // shareLinkTTL is how long a generated share link stays valid.
+    shareLinkTTL time.Duration
+    // editWindow is how long the edit link of an anonymous chunk works, or
+    // 0 if anonymous chunks can't be edited.
+    editWindow time.Duration
+    // lowercasePaths and trailingSlash are how request paths are
+    // normalized (-lowercase-paths and -trailing-slash), see normalizePaths.
+    lowercasePaths bool
+    trailingSlash  string
+    // basePath is the sub-path chunkbox is mounted under, without a trailing
+    // slash. It is empty when mounted at the root.
+    basePath string
+    // maxBatchSize is the most chunks accepted in one batch API request.
+    maxBatchSize int
+    // debugRequestsEnabled turns on the debugRequests middleware
+    // (-debug-requests).
+    debugRequestsEnabled bool
+    // problemJSON sends API errors as application/problem+json rather than
+    // in the error envelope (-api-errors).
+    problemJSON bool
+    // gistJSON serves chunks as GitHub Gist JSON on /chunk/{id}.json
+    // (-gist-json).
+    gistJSON bool
+    // ogImages caches the link preview images of chunks, drawn when they
+    // are first asked for. It is nil when -open-graph is off, and the view
+    // pages then have no preview tags.
+    ogImages *ogImageCache
+    // allowAnonymous controls whether visitors who aren't logged in may
+    // create chunks.
+    allowAnonymous bool
+    // oauthProviders are the configured social login providers, keyed by
+    // name ("github", "google").
+    oauthProviders map[string]*oauth.Provider
+    // previewChars is how many characters of content are shown under each
+    // title on the listing pages.
+    previewChars int
+    // maxPage is the deepest page of the paginated listings, 0 for no
+    // limit (-max-page).
+    maxPage int
+    // searchSnippetChars is how much content is shown around the match in
+    // search results.
+    searchSnippetChars int
+    // searchFulltext is whether searches go through the FULLTEXT index,
+    // with boolean-mode queries ranked by relevance, rather than matching
+    // the query literally (-search-fulltext).
+    searchFulltext bool
+    // searchMinScore is the lowest relevance a ranked search result can
+    // have (-search-min-score).
+    searchMinScore float64
+    // maxSearchLen is the most characters a search query may have, and
+    // searchTooLong whether a longer one is rejected or truncated.
+    maxSearchLen  int
+    searchTooLong string
+    // maxTitleLength is the most characters a chunk title may have.
+    maxTitleLength int
+    // languages are the languages chunks may be created in, from the
+    // -languages flag, in the order they are offered in the dropdown.
+    languages []highlight.Language
+    // maxChunkBytes is the most bytes of content a chunk may have, unless
+    // its language has its own limit in sizeLimits (-language-size-limits).
+    maxChunkBytes int
+    sizeLimits    map[string]int
+    // minChunkChars is the fewest characters the content of a chunk must
+    // have, not counting whitespace around it (-min-chunk-chars).
+    minChunkChars int
+    // maxTags is the most tags a chunk may have, 0 when tags are turned
+    // off, and maxTagLength the most characters in a tag.
+    maxTags      int
+    maxTagLength int
+    // maxFiles is the most files a chunk may have, 1 when multi-file
+    // chunks are turned off (-max-files).
+    maxFiles int
+    // inlineTypes are the media types downloads are displayed inline for,
+    // rather than saved (-inline-types).
+    inlineTypes inlinePolicy
+    // quota limits the number of chunks anonymous visitors can fill the
+    // server up to (-max-chunks). It is nil when there is no limit.
+    quota *chunkQuota
+    // userByteQuota is the most bytes of content a user's non-expired
+    // chunks may hold together (-user-byte-quota), 0 for no limit.
+    userByteQuota int64
+    // anonExpiry and userExpiry are the default and longest expiry of
+    // chunks created by anonymous visitors and by logged-in users.
+    anonExpiry expiryPolicy
+    userExpiry expiryPolicy
+    // extendOnView is how far a view pushes back the expiry of a chunk
+    // created with "keep alive while viewed", 0 when that is off
+    // (-extend-on-view). keepAliveMaxLifetime caps how long after its
+    // creation such a chunk can last.
+    extendOnView         time.Duration
+    keepAliveMaxLifetime time.Duration
+    // secretScanner looks for credentials in the content of chunks being
+    // saved, and secretAction is what is done about them: warn or block. It
+    // is nil unless -scan-secrets is set.
+    secretScanner *secrets.Scanner
+    secretAction  string
+    // warnDuplicateTitle is whether logged-in users are warned before they
+    // create a chunk with the title of one of theirs (-warn-duplicate-title).
+    warnDuplicateTitle bool
+    // uniquePerUser is what is done about a chunk with the same content as
+    // one its creator has already: off, reject or redirect. uniqueAnonymous
+    // is whether anonymous chunks are exempt or compared by IP (see
+    // unique.go).
+    uniquePerUser   string
+    uniqueAnonymous string
+    // abuse scores misbehaving client IPs and blocks repeat offenders from
+    // creating chunks (-abuse-threshold). It is nil when that is off.
+    abuse *abuseTracker
+    // related finds the chunks suggested beside a chunk on its view page
+    // (-related-chunks). It is nil when there are no suggestions.
+    related *relatedChunks
+    // auditLog records security-relevant events for /admin/audit.
+    auditLog *models.AuditModel
+    // background runs the goroutines which outlive a request, so shutdown
+    // can wait for them.
+    background *runGroup
+    // views collects the view counts until they are flushed to the
+    // database. It is nil with a -view-flush-interval of 0, when each view
+    // is written straight away.
+    views *viewCounter
+    // createQueue holds the requests creating chunks which are over the
+    // rate limit until it lets them through. It is nil with a
+    // -create-queue-depth of 0, when they get 429 straight away.
+    createQueue *createQueue
+    // webhook sends the chunk.created webhook to -webhook-url. It is nil
+    // when no URL is set.
+    webhook *webhook.Sender
+    // fetcher downloads the URLs chunks are created from with
+    // POST /api/v1/fetch. It is nil unless -fetch-urls is set.
+    fetcher *fetch.Client
+    // inbound reads the emails posted by the provider's inbound parse
+    // webhook, and inboundSenders are the addresses and @domains they may
+    // come from. inbound is nil unless -enable-inbound-email is set.
+    inbound        *inbound.Receiver
+    inboundSenders []string
+    // mailer sends the replies to those emails, and the links confirming a
+    // new email address (see email.go). It is nil unless -smtp-addr is set.
+    mailer emailSender
+    // prettyPrint is whether JSON and XML chunks can be shown pretty
+    // printed on the view page (-pretty-print).
+    prettyPrint bool
+    // highlighter renders chunks with syntax highlighting, and
+    // highlightCache keeps the results (nil when -highlight-cache-size is 0).
+    highlighter    *highlight.Highlighter
+    highlightCache *highlight.Cache
+    // highlightJobs limits how many chunks are highlighted at once
+    // (-max-highlight-concurrency), and highlightWait is how long a request
+    // waits for its turn before showing plain text.
+    highlightJobs *semaphore.Weighted
+    highlightWait time.Duration
+    // maxRenderBytes is the size past which chunks are shown as plain text
+    // rather than highlighted (-max-render-bytes, 0 for no limit).
+    maxRenderBytes int
+    // responseCache keeps the view pages rendered for anonymous visitors
+    // (nil when -response-cache-size is 0).
+    responseCache *responseCache
+    // metrics are the counters served on /metrics to clients in
+    // metricsAllowlist.
+    metrics          *appMetrics
+    metricsAllowlist ipList
+    // detectThreshold is the minimum confidence for an auto-detected
+    // language, below which chunks are saved as the default language.
+    detectThreshold float32
+    // limiter rate limits the requests which change something, keyed by
+    // client IP. It is nil when -rate-limit is 0. A SIGHUP swaps in a new
+    // one.
+    limiter            atomic.Pointer[rateLimiter]
+    // rateLimitWarned is the unix time of the last warning about the
+    // limiter failing, so an outage doesn't flood the log.
+    rateLimitWarned    atomic.Int64
+    // comments holds the comments posted under chunks. It is nil when
+    // comments are switched off (-enable-comments=false) or there are no
+    // user accounts.
+    comments *models.CommentModel
+    // favorites holds the chunks users have starred. Like the accounts, it
+    // is nil with -db-driver=memory.
+    favorites *models.FavoriteModel
+    // accessLog records the accesses to the chunks whose owners turned it
+    // on, for /chunk/{id}/stats, and accessLogDays is how long they are
+    // kept. It is nil with -access-log-days=0 or -db-driver=memory.
+    accessLog     *models.AccessModel
+    accessLogDays int
+    // emptyMessage is shown on the home page while there are no chunks.
+    emptyMessage string
+    // transcode lets forms declare a charset other than UTF-8 to be converted
+    // from (-non-utf8), and normalizeNewlines turns CRLF line endings into
+    // LF when chunks are created.
+    transcode         bool
+    normalizeNewlines bool
+    // transformers post-process the content of new and edited chunks, in
+    // order. The pipeline is empty unless a transformer flag is set.
+    transformers transform.Pipeline
+    // postCreateRedirect is where the create form sends the browser by
+    // default, postCreateView or postCreateRaw (-post-create-redirect).
+    postCreateRedirect string
+    // stripBOM is where a byte order mark at the start of the content is
+    // removed (-strip-bom): when chunks are saved, on the raw output, or
+    // nowhere.
+    stripBOM string
+    // wrap is how long lines are displayed by default, and wrapWidth where
+    // they are cut in the truncate mode.
+    wrap      string
+    wrapWidth int
+    // createAllowlist and readAllowlist restrict creating and reading
+    // chunks to the listed networks. A nil list means no restriction.
+    createAllowlist ipList
+    readAllowlist   ipList
+    // canonicalHostName is the host every request is redirected to
+    // (-canonical-host), empty for none, except the hosts in
+    // canonicalHostExempt.
+    canonicalHostName   string
+    canonicalHostExempt map[string]bool
+    // redirectAllowlist are the path prefixes, below basePath, which the
+    // ?next= of the login pages may go to. Empty allows any local path.
+    redirectAllowlist []string
+    // maxCookieBytes is the most bytes of cookies a request may send
+    // (-max-cookie-bytes), or 0 for no limit of their own.
+    maxCookieBytes int
+    // trustedProxies are the reverse proxies whose X-Forwarded-For header
+    // we believe, see realIP.
+    trustedProxies ipList
+    // banner is the notice shown at the top of every page, from -banner or
+    // saved on /admin/banner.
+    banner *bannerState
+    // hsts is the Strict-Transport-Security header of HTTPS responses, or
+    // empty for none (-hsts-max-age and friends).
+    hsts string
 }
 
 // We dont use DefaultServeMux because it is a global variable, 
@@ -43,8 +340,264 @@ func main() {
     // and some short help text explaining what the flag controls. The value of the
     // flag will be stored in the addr variable at runtime.
     addr := flag.String("addr", ":3001", "HTTP network address")
+    // Flags can also be kept in a file, of which a SIGHUP applies the
+    // reloadable ones (see reload.go).
+    configFile := flag.String("config", "", "Path to a file of flag settings, one name = value per line; flags given on the command line win")
+    // With a certificate and key the server speaks HTTPS instead of HTTP.
+    tlsCert := flag.String("tls-cert", "", "Path to a TLS certificate file, to serve HTTPS")
+    tlsKey := flag.String("tls-key", "", "Path to the TLS private key file for -tls-cert")
+    // A notice at the top of every page, such as a maintenance warning.
+    // With a database administrators can change it on /admin/banner, which
+    // overrides these.
+    bannerMessage := flag.String("banner", "", "Notice shown at the top of every page until the visitor dismisses it (empty for none)")
+    bannerLevel := flag.String("banner-level", bannerInfo, "How the -banner is styled: info or warning")
+    // Strict-Transport-Security, sent on HTTPS responses only (served with
+    // -tls-cert or -autotls-hosts, or from a -trusted-proxies proxy saying
+    // X-Forwarded-Proto: https). Browsers then refuse plain HTTP for the
+    // site until max-age runs out, so only raise it once HTTPS works, and
+    // mind that includeSubDomains covers every subdomain too. Preloading
+    // goes further: once the domain is submitted to hstspreload.org and
+    // shipped in browsers, it is HTTPS-only even on a first visit, and
+    // taking it off the list takes months.
+    hstsMaxAge := flag.Duration("hsts-max-age", 365*24*time.Hour, "max-age of the Strict-Transport-Security header on HTTPS responses (0 to send none)")
+    hstsSubdomains := flag.Bool("hsts-include-subdomains", false, "Add includeSubDomains to Strict-Transport-Security, so every subdomain must use HTTPS too")
+    hstsPreload := flag.Bool("hsts-preload", false, "Add preload to Strict-Transport-Security, for submitting the domain to the browsers' HSTS preload list (hard to undo)")
+    // A second, plain HTTP listener which redirects everything to HTTPS.
+    httpRedirectAddr := flag.String("http-redirect-addr", "", "HTTP network address to redirect to HTTPS from, e.g. :80 (needs -tls-cert or -autotls-hosts)")
+    // The one host the site should be reached on, and hosts which are
+    // served without being redirected to it.
+    canonicalHostFlag := flag.String("canonical-host", "", "Host (with an optional port) to redirect requests for any other host to, e.g. example.com")
+    canonicalHostExempt := flag.String("canonical-host-exempt", "", "Comma-separated hosts which are not redirected to -canonical-host")
+    // After logging in, users go back to the page which sent them to the
+    // login page (?next=), as long as it is on this site.
+    redirectAllowlist := flag.String("redirect-allowlist", "", "Comma-separated path prefixes the page after login (?next=) must start with, e.g. /chunkbox/,/account/ (empty allows any page of the site)")
+    // Instead of -tls-cert and -tls-key, certificates can be obtained from
+    // Let's Encrypt. Its HTTP-01 challenges are answered on
+    // -http-redirect-addr, or on :80 if that isn't set.
+    autoTLSHosts := flag.String("autotls-hosts", "", "Comma-separated host names to get Let's Encrypt certificates for")
+    autoTLSCache := flag.String("autotls-cache", "certs", "Directory to keep Let's Encrypt certificates and the account key in")
+    // Connection tuning. HTTP/2 is only ever negotiated over TLS, so -http2
+    // has no effect without -tls-cert. -idle-timeout is how long a kept-alive
+    // connection (or an HTTP/2 connection, which is always kept alive) may
+    // sit unused before it is closed; with -keep-alives=false every HTTP/1.1
+    // connection is closed after one response and the idle timeout never
+    // comes into play for them.
+    enableHTTP2 := flag.Bool("http2", true, "Offer HTTP/2 to clients when serving over TLS")
+    keepAlives := flag.Bool("keep-alives", true, "Keep HTTP/1.1 connections open between requests")
+    idleTimeout := flag.Duration("idle-timeout", 2*time.Minute, "How long an idle kept-alive connection stays open")
+    maxHeaderBytes := flag.Int("max-header-bytes", http.DefaultMaxHeaderBytes, "Maximum size of request headers in bytes")
+    // The log of HTTP requests can go apart from the application's logs,
+    // in a format log analysers read. Not to be confused with the access
+    // logs of chunks (-access-log-days).
+    accessLogDest := flag.String("access-log", "", "Where to write the log of HTTP requests: a file (reopened on SIGHUP), stdout, stderr or off (default the info log)")
+    accessLogFormat := flag.String("access-log-format", requestLogCombined, "Format of the -access-log: common, combined or json")
+    maxCookieBytes := flag.Int("max-cookie-bytes", 8192, "Maximum size of the Cookie headers of a request in bytes, above which it gets 431 (0 means only -max-header-bytes applies)")
+    debugLogging := flag.Bool("debug-log", false, "Log debug messages, such as chunks whose highlighting fell back to -default-lexer")
+    // On SIGINT or SIGTERM the server stops accepting connections and gets
+    // this long to finish the requests and background work in progress.
+    shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for requests and background work to finish when shutting down")
+    // Only for debugging: logs the headers and body of requests made from
+    // the local machine.
+    debugRequests := flag.Bool("debug-requests", false, "Log the headers and body of requests from localhost (for debugging only)")
     // Define a new command-line flag for the MySQL DSN string.
     dsn := flag.String("dsn", "web:pass@/chunkbox?parseTime=true", "MySQL data source name")
+    // Queries which take at least this long are logged, by the model method
+    // which ran them, and counted on /metrics. 0 turns this off.
+    slowQueryThreshold := flag.Duration("slow-query-threshold", 0, "Log MySQL queries which take at least this long (0 to turn off)")
+    dbDriver := flag.String("db-driver", "mysql", "Where to store data: mysql, or memory for a throwaway demo instance")
+    // By default a deleted account leaves its chunks behind as anonymous chunks.
+    deleteChunksWithUser := flag.Bool("delete-chunks-with-user", false, "Delete a user's chunks when their account is deleted")
+    // Password policy: the minimum length and the bcrypt cost factor used to
+    // hash passwords. Raising the cost makes hashes slower to brute-force
+    // (and logins slower to check).
+    minPasswordLength := flag.Int("min-password-length", 8, "Minimum password length")
+    bcryptCost := flag.Int("bcrypt-cost", 12, "bcrypt cost factor for password hashes")
+    // Branding flags, so an instance can be rebranded without editing the
+    // templates.
+    siteName := flag.String("site-name", "Chunkbox", "Site name shown in page titles and the header")
+    siteLogoURL := flag.String("site-logo-url", "", "URL or absolute path of a custom logo image")
+    emptyMessage := flag.String("empty-message", "No chunks yet. Why not create the first one?", "Message shown on the home page while there are no chunks")
+    faviconPath := flag.String("favicon-path", "", "Path to a custom favicon file (default: embedded icon)")
+    // Markdown files for the /terms and /privacy pages, reread on SIGHUP.
+    termsFile := flag.String("terms-file", "", "Path to a Markdown file shown as the terms of service on /terms")
+    privacyFile := flag.String("privacy-file", "", "Path to a Markdown file shown as the privacy policy on /privacy")
+    // A file of newline-separated spam patterns (substrings, or /regexes/).
+    blocklistFile := flag.String("blocklist-file", "", "Path to a file of blocked content patterns")
+    // The secret used to sign tokens (form tokens and share links). Without
+    // one a random secret is used, which means tokens issued before a restart
+    // stop working. To rotate the secret put the new one first and keep the
+    // old one after it until the tokens signed with it have expired; removing
+    // a secret invalidates everything signed with it straight away. The
+    // session cookie itself is an opaque token looked up in MySQL, so it
+    // doesn't depend on the secret.
+    secret := flag.String("secret", os.Getenv("CHUNKBOX_SECRET"), "Comma-separated secret keys for signing tokens, newest first (at least 32 characters each)")
+    // In production a missing -secret is an error rather than a warning.
+    production := flag.Bool("production", false, "Refuse to start without a -secret")
+    minFillTime := flag.Duration("min-fill-time", 3*time.Second, "Minimum time between displaying and submitting the create form")
+    // Optional CAPTCHA on anonymous chunk creation ("hcaptcha" or "recaptcha").
+    captchaProvider := flag.String("captcha-provider", "", "CAPTCHA provider for anonymous chunk creation: hcaptcha or recaptcha (anonymous API requests are then refused)")
+    captchaSecret := flag.String("captcha-secret", "", "CAPTCHA provider secret key")
+    captchaSiteKey := flag.String("captcha-sitekey", "", "CAPTCHA provider site key")
+    // Creating chunks from a URL makes the server connect wherever users
+    // ask, so it is off by default, and private addresses are refused
+    // unless -fetch-allow-private is set.
+    fetchURLs := flag.Bool("fetch-urls", false, "Allow creating chunks from the content at a URL with POST /api/v1/fetch")
+    fetchAllowPrivate := flag.Bool("fetch-allow-private", false, "Let -fetch-urls fetch from loopback, private and link-local addresses")
+    fetchTimeout := flag.Duration("fetch-timeout", 10*time.Second, "How long -fetch-urls waits for a URL to download")
+    // Creating chunks by email. The chunks are anonymous and the replies go
+    // to the sender, so an allowlist of senders is required.
+    enableInboundEmail := flag.Bool("enable-inbound-email", false, "Allow creating chunks by email with the inbound parse webhook of -inbound-email-provider, at POST /api/v1/inbound/email")
+    inboundEmailProvider := flag.String("inbound-email-provider", "mailgun", "Email provider posting to the inbound webhook: mailgun or sendgrid")
+    inboundEmailSecret := flag.String("inbound-email-secret", os.Getenv("CHUNKBOX_INBOUND_EMAIL_SECRET"), "Mailgun webhook signing key, or the basic auth password in the URL given to SendGrid")
+    inboundEmailSenders := flag.String("inbound-email-senders", "", "Comma-separated email addresses, or @domains, allowed to create chunks by email")
+    smtpAddr := flag.String("smtp-addr", "", "SMTP server (host:port) to send emails through: the replies to emailed chunks and the links confirming new email addresses")
+    smtpUsername := flag.String("smtp-username", "", "Username for the SMTP server, if it needs one")
+    smtpPassword := flag.String("smtp-password", os.Getenv("CHUNKBOX_SMTP_PASSWORD"), "Password for the SMTP server")
+    smtpFrom := flag.String("smtp-from", "", "From address of the emails sent through -smtp-addr")
+    webhookURL := flag.String("webhook-url", "", "URL to POST a chunk.created webhook to for every new chunk")
+    webhookSecret := flag.String("webhook-secret", os.Getenv("CHUNKBOX_WEBHOOK_SECRET"), "Shared secret for signing webhooks in the X-Chunkbox-Signature header")
+    shareLinkTTL := flag.Duration("share-link-ttl", 7*24*time.Hour, "How long generated share links stay valid")
+    editWindow := flag.Duration("edit-window", 24*time.Hour, "How long anonymous creators can edit or delete their chunk with its secret edit link (0 disables it)")
+    // The path prefix chunkbox is served under, e.g. /paste/ behind a shared
+    // reverse proxy.
+    basePathFlag := flag.String("base-path", "/", "URL path prefix the application is mounted under")
+    // Redirecting to canonical paths is opt-in.
+    lowercasePaths := flag.Bool("lowercase-paths", false, "Redirect requests for paths with capital letters to the lowercase path (share tokens and static files excepted)")
+    trailingSlash := flag.String("trailing-slash", trailingSlashKeep, "Redirect requests to paths without (strip) or with (add) a trailing slash (default: leave paths as they are)")
+    maxBatchSize := flag.Int("max-batch-size", 100, "Maximum number of chunks in one batch API request")
+    apiErrors := flag.String("api-errors", "envelope", "Format of JSON API errors: envelope, or problem for application/problem+json (RFC 7807)")
+    maxTitleLength := flag.Int("max-title-length", 100, "Maximum number of characters in a chunk title (above 100 the title column must be widened)")
+    // Chunk URLs carry a random public ID by default. Hashids are shorter,
+    // but anyone who learns the salt can work out the database ids.
+    idScheme := flag.String("id-scheme", idSchemePlain, "How chunks are identified in URLs: plain (random public IDs) or hashids (the obfuscated database id)")
+    hashidsSalt := flag.String("hashids-salt", os.Getenv("CHUNKBOX_HASHIDS_SALT"), "Salt for -id-scheme=hashids; changing it changes every chunk's URL")
+    gistJSON := flag.Bool("gist-json", true, "Serve chunks as GitHub Gist JSON on /chunk/{id}.json, for tools which read gists")
+    openGraph := flag.Bool("open-graph", true, "Add Open Graph and Twitter card tags to public chunk pages and serve their preview images on /chunk/{id}/og.png")
+    allowAnonymous := flag.Bool("allow-anonymous", true, "Allow visitors who are not logged in to create chunks")
+    enableComments := flag.Bool("enable-comments", true, "Let logged-in users comment on chunks")
+    maxPage := flag.Int("max-page", 100, "Deepest page number of the paginated listings; later pages are a 404 (0 means no limit)")
+    previewChars := flag.Int("preview-chars", 120, "Number of content characters to preview on listing pages")
+    searchSnippetChars := flag.Int("search-snippet-chars", 160, "Number of content characters shown around the match in search results")
+    // Ranked search needs the FULLTEXT index described in
+    // internal/models/fulltext.go, so it is off until the index is added.
+    searchFulltext := flag.Bool("search-fulltext", false, "Search with the FULLTEXT index, taking +required, -excluded, \"phrase\" and prefix* terms and ranking results by relevance")
+    maxSearchLen := flag.Int("max-search-len", 256, "Maximum number of characters in a search query")
+    searchTooLong := flag.String("search-too-long", searchTooLongReject, "What is done about a search query longer than -max-search-len: reject, or truncate it")
+    searchMinScore := flag.Float64("search-min-score", 0, "Lowest relevance score of the results of -search-fulltext searches")
+    maxChunks := flag.Int("max-chunks", 0, "Maximum number of non-expired chunks, after which anonymous creation is refused (0 means no limit)")
+    // How long new chunks last, in days, by default and at most. Anonymous
+    // visitors and logged-in users each get their own pair.
+    anonDefaultExpiry := flag.Int("anon-default-expiry", 365, "Default expiry in days of chunks created anonymously")
+    anonMaxExpiry := flag.Int("anon-max-expiry", 365, "Longest expiry in days anonymous visitors can choose")
+    userDefaultExpiry := flag.Int("user-default-expiry", 365, "Default expiry in days of chunks created by logged-in users")
+    userMaxExpiry := flag.Int("user-max-expiry", 365, "Longest expiry in days logged-in users can choose")
+    // Chunks in some languages can have their own default, used when the
+    // creator doesn't choose an expiry, and still capped at the -*-max-expiry.
+    languageExpiry := flag.String("language-expiry", "", "Comma-separated language=days default expiries, overriding -anon-default-expiry and -user-default-expiry for chunks in those languages, e.g. bash=1,yaml=365")
+    // Chunks created with "keep alive while viewed" have their expiry pushed
+    // back to -extend-on-view from now each time they are viewed, but never
+    // past -keep-alive-max-days after they were created. 0 turns that off.
+    extendOnView := flag.Duration("extend-on-view", 0, "How far a view extends the expiry of chunks created to be kept alive while viewed (0 turns this off)")
+    keepAliveMaxDays := flag.Int("keep-alive-max-days", 365, "Longest a chunk kept alive by views can last, in days after it was created")
+    // View counts are kept in memory and added to the database in batches,
+    // so viewing a chunk doesn't wait for a write.
+    viewFlushInterval := flag.Duration("view-flush-interval", 10*time.Second, "How often view counts are written to the database (0 writes each view straight away)")
+    // Owners can have the accesses to a chunk recorded, for its stats page.
+    // Old entries are pruned, and each chunk keeps only the latest ones.
+    accessLogDays := flag.Int("access-log-days", 30, "Days the access logs owners turn on for their chunks are kept (0 turns the access log off)")
+    accessLogMax := flag.Int("access-log-max", 1000, "Most access log entries kept for each chunk")
+    // Checking pasted content for credentials: AWS keys, private keys, JWTs
+    // and the like.
+    scanSecrets := flag.Bool("scan-secrets", false, "Check the content of chunks being saved for secrets like AWS keys, private keys and JWTs")
+    secretAction := flag.String("secret-action", secretBlock, "What -scan-secrets does about a chunk with a secret: warn (ask the creator to confirm) or block")
+    uniquePerUser := flag.String("unique-per-user", uniqueOff, "What is done about a chunk with the same content as one its creator has already: off, reject, or redirect (to the existing chunk)")
+    uniqueAnonymous := flag.String("unique-anonymous", uniqueAnonymousExempt, "Whether -unique-per-user leaves anonymous chunks alone (exempt) or compares them with the anonymous chunks from the same address (ip)")
+    warnDuplicateTitle := flag.Bool("warn-duplicate-title", false, "Warn logged-in users creating a chunk with the same title as one of their chunks, in case it is a re-paste")
+    userByteQuota := flag.Int64("user-byte-quota", 0, "Maximum total bytes of content in a user's non-expired chunks (0 means no limit)")
+    evictOldest := flag.Bool("evict-oldest", false, "When -max-chunks is reached, delete the chunks closest to expiring instead of refusing new ones")
+    highlightTheme := flag.String("highlight-theme", "github", "Chroma style for syntax highlighting, or \"auto\" to follow the browser's dark mode")
+    // Chunks in a language chroma has no lexer for, or whose lexer fails,
+    // are highlighted as this language instead.
+    defaultLexer := flag.String("default-lexer", highlight.PlainText, "Language to highlight chunks as when their own lexer is missing or fails (text for plain text)")
+    nonUTF8 := flag.String("non-utf8", "reject", "What to do with content in another charset: reject, or transcode it if the form declares the charset")
+    // The built-in content transformers, which run in this order.
+    trimTrailingWhitespace := flag.Bool("trim-trailing-whitespace", false, "Remove trailing spaces and tabs from every line of new and edited chunks")
+    tabsToSpaces := flag.Int("tabs-to-spaces", 0, "Expand tabs to spaces at this tab width in new and edited chunks, except Makefiles (0 keeps tabs)")
+    normalizeNewlines := flag.Bool("normalize-newlines", true, "Convert CRLF line endings to LF when chunks are created")
+    stripBOM := flag.String("strip-bom", stripBOMInsert, "Where to remove a UTF-8 byte order mark from the start of chunks: insert (when saving), raw (from raw and download output) or off")
+    // Where the create form sends the browser after saving a chunk. Each
+    // request can ask otherwise (see postCreateDestination).
+    postCreateRedirect := flag.String("post-create-redirect", postCreateView, "Where to send the browser after the create form: view (the chunk's page) or raw (its content)")
+    wrap := flag.String("wrap", wrapSoft, "How to display long lines: soft (wrap), truncate or none (scroll)")
+    wrapWidth := flag.Int("wrap-width", 200, "Number of characters after which -wrap=truncate cuts lines")
+    prettyPrint := flag.Bool("pretty-print", true, "Offer to show JSON and XML chunks pretty printed on the view page (?pretty=1)")
+    highlightCacheSize := flag.Int("highlight-cache-size", 32<<20, "Bytes of highlighted HTML to keep in memory (0 disables the cache)")
+    // Highlighting big chunks is CPU heavy, so only so many are rendered at
+    // once; views over the limit wait a little, then get plain text.
+    maxHighlightConcurrency := flag.Int("max-highlight-concurrency", runtime.GOMAXPROCS(0), "Maximum number of chunks syntax highlighted at the same time")
+    highlightWait := flag.Duration("highlight-wait", 2*time.Second, "How long a view waits for a highlighting slot before showing plain text (0 doesn't wait)")
+    // Highlighting takes memory in proportion to the content, so really big
+    // chunks are shown as plain text.
+    maxRenderBytes := flag.Int("max-render-bytes", 1<<20, "Chunks bigger than this many bytes are shown as plain text instead of highlighted (0 for no limit)")
+    // Whole view pages can be cached for anonymous visitors too, for chunks
+    // which get linked from somewhere busy.
+    responseCacheSize := flag.Int("response-cache-size", 0, "Bytes of chunk view pages to cache for anonymous visitors (0 disables the cache)")
+    responseCacheTTL := flag.Duration("response-cache-ttl", 10*time.Second, "How long a cached chunk view page is served for")
+    detectThreshold := flag.Float64("language-detect-threshold", 0.5, "Minimum confidence (0 to 1) for an auto-detected language")
+    rateLimit := flag.Float64("rate-limit", 60, "Requests per minute each IP may make to create chunks, log in, etc. (0 disables rate limiting)")
+    rateBurst := flag.Int("rate-burst", 10, "Number of requests an IP may make in a burst above -rate-limit")
+    createQueueDepth := flag.Int("create-queue-depth", 0, "Number of requests creating chunks over -rate-limit which wait for their turn instead of getting 429 (0 refuses them straight away)")
+    // Redis is optional. When it is set the rate limiter keeps its counters
+    // there, so all instances behind a load balancer share them, and it can
+    // hold the sessions too.
+    redisAddr := flag.String("redis-addr", "", "Redis address (host:port) for state shared between instances")
+    sessionStoreName := flag.String("session-store", "", "Where to keep sessions: redis (needs -redis-addr), or the database by default")
+    // Networks allowed to create or read chunks, for an internal-only
+    // instance, and the reverse proxies in front of chunkbox.
+    createAllowlistFlag := flag.String("create-allowlist", "", "Comma-separated CIDRs of clients allowed to create chunks (default anyone)")
+    readAllowlistFlag := flag.String("read-allowlist", "", "Comma-separated CIDRs of clients allowed to use the site at all (default anyone)")
+    trustedProxiesFlag := flag.String("trusted-proxies", "", "Comma-separated CIDRs of reverse proxies whose X-Forwarded-For header is trusted")
+    metricsAllowlistFlag := flag.String("metrics-allowlist", "127.0.0.0/8,::1/128", "Comma-separated CIDRs of clients allowed to read /metrics (empty allows anyone)")
+    languageList := flag.String("languages", "", "Comma-separated list of the languages chunks may use (default all)")
+    // The size limit for chunk content, in bytes, and different limits for
+    // some languages. The default fits the TEXT content column.
+    maxChunkBytes := flag.Int("max-chunk-bytes", 65535, "Maximum size of a chunk's content in bytes")
+    // Content shorter than -min-chunk-chars is refused before the blocklist
+    // is checked, so it doesn't count towards the -abuse-threshold.
+    minChunkChars := flag.Int("min-chunk-chars", 1, "Minimum number of characters in a chunk's content, not counting leading and trailing whitespace")
+    languageSizeLimits := flag.String("language-size-limits", "", "Comma-separated language=bytes limits overriding -max-chunk-bytes, e.g. json=1048576 (chunks with an auto-detected language get -max-chunk-bytes)")
+    maxTags := flag.Int("max-tags", 10, "Maximum number of tags per chunk (0 turns tags off)")
+    maxTagLength := flag.Int("max-tag-length", 30, "Maximum number of characters in a tag")
+    // Chunks can have several files, like a gist, which together must fit
+    // in -max-chunk-bytes.
+    maxFiles := flag.Int("max-files", 10, "Maximum number of files per chunk (1 turns multi-file chunks off)")
+    // Abuse scoring: IPs which trip the spam checks, the blocklist or the
+    // rate limit earn points, which decay over time, and are blocked from
+    // creating chunks for a while once they reach the threshold.
+    abuseThreshold := flag.Float64("abuse-threshold", 0, "Abuse score at which an IP is blocked from creating chunks; spam and blocklist hits score 5, rate limit violations 1 (0 turns scoring off)")
+    abuseDecay := flag.Duration("abuse-decay", 10*time.Minute, "Time for an IP's abuse score to drop by one point")
+    abuseBlock := flag.Duration("abuse-block", time.Hour, "How long an IP which reaches -abuse-threshold is blocked")
+    // Templates overriding the embedded ones, and whether a broken set
+    // stops startup or falls back to the embedded templates.
+    templatesDir := flag.String("templates-dir", "./ui/html", "Directory of templates overriding the built-in ones (empty uses the built-in templates)")
+    // For working on the templates: they are parsed again on every request,
+    // so edits show up on reload without restarting the server.
+    dev := flag.Bool("dev", false, "Development mode: re-read the -templates-dir templates on every request (slow, never use in production)")
+    strictTemplates := flag.Bool("strict-templates", true, "Fail to start when the -templates-dir templates don't load, instead of falling back to the built-in ones")
+    // How many related chunks the view page suggests, and how long the
+    // suggestions for a chunk are reused.
+    relatedLimit := flag.Int("related-chunks", 5, "Number of related chunks suggested on the view page (0 turns them off)")
+    relatedCacheTTL := flag.Duration("related-cache-ttl", time.Minute, "How long the related chunks of a chunk are cached (0 disables the cache)")
+    // Media types the download endpoint lets the browser display. By
+    // default every download is an attachment.
+    inlineTypesFlag := flag.String("inline-types", "", "Comma-separated media types downloads are served inline for, e.g. image/png or image/* (default none)")
+    // OAuth client credentials. They default to environment variables so the
+    // secrets don't have to appear on the command line. A provider is only
+    // enabled when both its id and secret are set.
+    githubClientID := flag.String("github-client-id", os.Getenv("CHUNKBOX_GITHUB_CLIENT_ID"), "GitHub OAuth client ID")
+    githubClientSecret := flag.String("github-client-secret", os.Getenv("CHUNKBOX_GITHUB_CLIENT_SECRET"), "GitHub OAuth client secret")
+    googleClientID := flag.String("google-client-id", os.Getenv("CHUNKBOX_GOOGLE_CLIENT_ID"), "Google OAuth client ID")
+    googleClientSecret := flag.String("google-client-secret", os.Getenv("CHUNKBOX_GOOGLE_CLIENT_SECRET"), "Google OAuth client secret")
     // Importantly, we use the flag.Parse() function to parse the command-line flag.
     // This reads in the command-line flag value and assigns it to the addr
     // variable. You need to call this *before* you use the addr variable
@@ -64,22 +617,734 @@ func main() {
     // file name and line number.
     errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-    // We pass openDB() the DSN from the command-line flag.
-    db, err := openDB(*dsn)
+    // The -config file fills in the flags which weren't given, before any
+    // of them are used.
+    config, err := newConfigReloader(*configFile)
     if err != nil {
         errorLog.Fatal(err)
     }
-    // We also defer a call to db.Close(), so that the connection pool is closed
-    // before the main() or program exits. It actually will never run
-    // in this scenario because of errorLog.Fatal() which terminates
-    // the program immediately.
-    defer db.Close()
+
+    // Debug messages are thrown away unless -debug-log is set.
+    debugLog := log.New(io.Discard, "DEBUG\t", log.Ldate|log.Ltime)
+    if *debugLogging {
+        debugLog.SetOutput(os.Stdout)
+    }
+
+    // Check the password policy flags before doing anything else, so a typo
+    // doesn't only show up on the first signup.
+    if *minPasswordLength < 1 {
+        errorLog.Fatal("-min-password-length must be at least 1")
+    }
+    if *bcryptCost < bcrypt.MinCost || *bcryptCost > bcrypt.MaxCost {
+        errorLog.Fatalf("-bcrypt-cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+    }
+
+    // Each chunk in a batch uses 10 placeholders, and MySQL allows at most
+    // 65535 in one statement.
+    if *maxBatchSize < 1 || *maxBatchSize > 6500 {
+        errorLog.Fatal("-max-batch-size must be between 1 and 6500")
+    }
+    // The title column is at most a VARCHAR(255); see models.Chunk.
+    if *maxTitleLength < 1 || *maxTitleLength > 255 {
+        errorLog.Fatal("-max-title-length must be between 1 and 255")
+    }
+    if *trailingSlash != trailingSlashKeep && *trailingSlash != trailingSlashStrip && *trailingSlash != trailingSlashAdd {
+        errorLog.Fatalf("unknown -trailing-slash %q (choose strip or add)", *trailingSlash)
+    }
+    if *apiErrors != "envelope" && *apiErrors != "problem" {
+        errorLog.Fatalf("-api-errors must be envelope or problem, not %q", *apiErrors)
+    }
+    if *maxPage < 0 {
+        errorLog.Fatal("-max-page cannot be negative")
+    }
+    if *previewChars < 0 || *previewChars > 1000 {
+        errorLog.Fatal("-preview-chars must be between 0 and 1000")
+    }
+    if *searchSnippetChars < 1 || *searchSnippetChars > 1000 {
+        errorLog.Fatal("-search-snippet-chars must be between 1 and 1000")
+    }
+    if *maxSearchLen < 1 {
+        errorLog.Fatal("-max-search-len must be at least 1")
+    }
+    if *searchTooLong != searchTooLongReject && *searchTooLong != searchTooLongTruncate {
+        errorLog.Fatalf("unknown -search-too-long %q (choose reject or truncate)", *searchTooLong)
+    }
+    if *searchMinScore < 0 {
+        errorLog.Fatal("-search-min-score cannot be negative")
+    }
+
+    if *maxChunks < 0 {
+        errorLog.Fatal("-max-chunks cannot be negative")
+    }
+    if *anonMaxExpiry < 1 || *userMaxExpiry < 1 {
+        errorLog.Fatal("-anon-max-expiry and -user-max-expiry must be at least 1 day")
+    }
+    if *anonDefaultExpiry < 1 || *anonDefaultExpiry > *anonMaxExpiry {
+        errorLog.Fatalf("-anon-default-expiry must be between 1 and -anon-max-expiry (%d)", *anonMaxExpiry)
+    }
+    if *userDefaultExpiry < 1 || *userDefaultExpiry > *userMaxExpiry {
+        errorLog.Fatalf("-user-default-expiry must be between 1 and -user-max-expiry (%d)", *userMaxExpiry)
+    }
+    languageExpiries, err := parseLanguageExpiries(*languageExpiry)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    // Allowed, but usually a mistake.
+    if *anonMaxExpiry > *userMaxExpiry {
+        infoLog.Printf("-anon-max-expiry (%d days) is longer than -user-max-expiry (%d days): anonymous chunks can outlive users' chunks", *anonMaxExpiry, *userMaxExpiry)
+    }
+    if *viewFlushInterval < 0 {
+        errorLog.Fatal("-view-flush-interval cannot be negative")
+    }
+    if *extendOnView < 0 {
+        errorLog.Fatal("-extend-on-view cannot be negative")
+    }
+    if *accessLogDays < 0 {
+        errorLog.Fatal("-access-log-days cannot be negative")
+    }
+    if *accessLogMax < 1 {
+        errorLog.Fatal("-access-log-max must be at least 1")
+    }
+    if *keepAliveMaxDays < 1 {
+        errorLog.Fatal("-keep-alive-max-days must be at least 1 day")
+    }
+    if *userByteQuota < 0 {
+        errorLog.Fatal("-user-byte-quota cannot be negative")
+    }
+
+    languages, err := highlight.Parse(*languageList)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    if len(languages) == 0 {
+        errorLog.Fatal("-languages must name at least one language")
+    }
+
+    // The size limits, overall and for particular languages.
+    if *maxChunkBytes < 1 || *maxChunkBytes > maxContentLimit {
+        errorLog.Fatalf("-max-chunk-bytes must be between 1 and %d", maxContentLimit)
+    }
+    if *minChunkChars < 1 || *minChunkChars > *maxChunkBytes {
+        errorLog.Fatal("-min-chunk-chars must be at least 1, and not more than -max-chunk-bytes")
+    }
+    sizeLimits, err := parseSizeLimits(*languageSizeLimits)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    if *tabsToSpaces < 0 || *tabsToSpaces > 16 {
+        errorLog.Fatal("-tabs-to-spaces must be between 0 and 16")
+    }
+    var transformers transform.Pipeline
+    if *trimTrailingWhitespace {
+        transformers = append(transformers, transform.TrimTrailingWhitespace{})
+    }
+    if *tabsToSpaces > 0 {
+        transformers = append(transformers, transform.TabsToSpaces{Width: *tabsToSpaces})
+    }
+    if *maxFiles < 1 {
+        errorLog.Fatal("-max-files must be at least 1")
+    }
+    if *maxTags < 0 {
+        errorLog.Fatal("-max-tags cannot be negative")
+    }
+    if *abuseThreshold < 0 {
+        errorLog.Fatal("-abuse-threshold cannot be negative")
+    }
+    if *abuseDecay <= 0 || *abuseBlock <= 0 {
+        errorLog.Fatal("-abuse-decay and -abuse-block must be positive")
+    }
+    if *relatedLimit < 0 {
+        errorLog.Fatal("-related-chunks cannot be negative")
+    }
+    if *relatedCacheTTL < 0 {
+        errorLog.Fatal("-related-cache-ttl cannot be negative")
+    }
+    if *maxTagLength < 1 || *maxTagLength > maxTagLimit {
+        errorLog.Fatalf("-max-tag-length must be between 1 and %d", maxTagLimit)
+    }
+    inlineTypes, err := parseInlinePolicy(*inlineTypesFlag)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+
+    createAllowlist, err := parseIPList(*createAllowlistFlag)
+    if err != nil {
+        errorLog.Fatalf("-create-allowlist: %v", err)
+    }
+    readAllowlist, err := parseIPList(*readAllowlistFlag)
+    if err != nil {
+        errorLog.Fatalf("-read-allowlist: %v", err)
+    }
+    metricsAllowlist, err := parseIPList(*metricsAllowlistFlag)
+    if err != nil {
+        errorLog.Fatalf("-metrics-allowlist: %v", err)
+    }
+    canonicalHostName, err := parseCanonicalHost(*canonicalHostFlag)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    trustedProxies, err := parseIPList(*trustedProxiesFlag)
+    if err != nil {
+        errorLog.Fatalf("-trusted-proxies: %v", err)
+    }
+
+    if *detectThreshold < 0 || *detectThreshold > 1 {
+        errorLog.Fatal("-language-detect-threshold must be between 0 and 1")
+    }
+
+    if *rateLimit < 0 {
+        errorLog.Fatal("-rate-limit cannot be negative")
+    }
+    if *rateBurst < 1 {
+        errorLog.Fatal("-rate-burst must be at least 1")
+    }
+    if *createQueueDepth < 0 {
+        errorLog.Fatal("-create-queue-depth cannot be negative")
+    }
+
+    highlighter, err := highlight.New(*highlightTheme, *defaultLexer)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    highlighter.Debugf = debugLog.Printf
+    if *nonUTF8 != "reject" && *nonUTF8 != "transcode" {
+        errorLog.Fatalf("unknown -non-utf8 %q (choose reject or transcode)", *nonUTF8)
+    }
+    if *stripBOM != stripBOMInsert && *stripBOM != stripBOMRaw && *stripBOM != stripBOMOff {
+        errorLog.Fatalf("unknown -strip-bom %q (choose insert, raw or off)", *stripBOM)
+    }
+    if *postCreateRedirect != postCreateView && *postCreateRedirect != postCreateRaw {
+        errorLog.Fatalf("unknown -post-create-redirect %q (choose view or raw)", *postCreateRedirect)
+    }
+    if !validWrapMode(*wrap) {
+        errorLog.Fatalf("unknown -wrap %q (choose soft, truncate or none)", *wrap)
+    }
+    if *wrapWidth < 1 {
+        errorLog.Fatal("-wrap-width must be at least 1")
+    }
+    if *highlightCacheSize < 0 {
+        errorLog.Fatal("-highlight-cache-size cannot be negative")
+    }
+    if *maxHighlightConcurrency < 1 {
+        errorLog.Fatal("-max-highlight-concurrency must be at least 1")
+    }
+    if *highlightWait < 0 {
+        errorLog.Fatal("-highlight-wait cannot be negative")
+    }
+    if *maxRenderBytes < 0 {
+        errorLog.Fatal("-max-render-bytes cannot be negative")
+    }
+    if *responseCacheSize < 0 {
+        errorLog.Fatal("-response-cache-size cannot be negative")
+    }
+    if *responseCacheSize > 0 && *responseCacheTTL <= 0 {
+        errorLog.Fatal("-response-cache-ttl must be positive")
+    }
+
+    // Validate the branding flags and load the favicon.
+    siteBranding, err := newBranding(*siteName, *siteLogoURL, *faviconPath)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    legal := &legalPages{}
+    if err := legal.load(*termsFile, *privacyFile); err != nil {
+        errorLog.Fatal(err)
+    }
+
+    // Set up the token signer from the -secret flag, or generate a random
+    // key if no secret was given.
+    var signingKeys [][]byte
+    for _, key := range strings.Split(*secret, ",") {
+        key = strings.TrimSpace(key)
+        if key == "" {
+            continue
+        }
+        if len(key) < 32 {
+            errorLog.Fatal("-secret keys must be at least 32 characters long")
+        }
+        signingKeys = append(signingKeys, []byte(key))
+    }
+    if len(signingKeys) == 0 {
+        if *production {
+            errorLog.Fatal("-secret (or CHUNKBOX_SECRET) is required with -production")
+        }
+        key, err := signing.RandomKey()
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        signingKeys = append(signingKeys, key)
+        infoLog.Print("No -secret given, using a random key: signed tokens will not survive a restart")
+    }
+
+    if (*tlsCert == "") != (*tlsKey == "") {
+        errorLog.Fatal("-tls-cert and -tls-key must be set together")
+    }
+    var autocertHosts []string
+    for _, host := range strings.Split(*autoTLSHosts, ",") {
+        if host = strings.TrimSpace(host); host != "" {
+            autocertHosts = append(autocertHosts, host)
+        }
+    }
+    if len(autocertHosts) > 0 && *tlsCert != "" {
+        errorLog.Fatal("-autotls-hosts can't be used together with -tls-cert and -tls-key")
+    }
+    if *httpRedirectAddr != "" && *tlsCert == "" && len(autocertHosts) == 0 {
+        errorLog.Fatal("-http-redirect-addr only works when serving HTTPS with -tls-cert or -autotls-hosts")
+    }
+    if *hstsMaxAge < 0 {
+        errorLog.Fatal("-hsts-max-age cannot be negative")
+    }
+    if *hstsPreload && (*hstsMaxAge < 365*24*time.Hour || !*hstsSubdomains) {
+        errorLog.Fatal("-hsts-preload needs -hsts-max-age of at least a year and -hsts-include-subdomains, as the preload list requires")
+    }
+    hsts := ""
+    if *hstsMaxAge > 0 {
+        hsts = "max-age=" + strconv.FormatInt(int64(hstsMaxAge.Seconds()), 10)
+        if *hstsSubdomains {
+            hsts += "; includeSubDomains"
+        }
+        if *hstsPreload {
+            hsts += "; preload"
+        }
+    }
+    if *debugRequests {
+        if *production {
+            errorLog.Fatal("-debug-requests can't be used with -production")
+        }
+        infoLog.Print("WARNING: -debug-requests is on, request headers and bodies from localhost are being logged. Do not use this in production")
+    }
+    if *editWindow < 0 {
+        errorLog.Fatal("-edit-window cannot be negative")
+    }
+    if *shutdownTimeout <= 0 {
+        errorLog.Fatal("-shutdown-timeout must be positive")
+    }
+    if *idleTimeout < 0 {
+        errorLog.Fatal("-idle-timeout cannot be negative")
+    }
+    // Below 4KB ordinary requests with a session cookie would be refused.
+    if *maxHeaderBytes < 4096 {
+        errorLog.Fatal("-max-header-bytes must be at least 4096")
+    }
+    reqLog, err := newRequestLog(*accessLogDest, *accessLogFormat)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    // Our own cookies need about 200 bytes.
+    if *maxCookieBytes != 0 && *maxCookieBytes < 1024 {
+        errorLog.Fatal("-max-cookie-bytes must be 0 or at least 1024")
+    }
+
+    // Set up the CAPTCHA verifier, if a provider has been configured.
+    var captchaVerifier *captcha.Verifier
+    if *captchaProvider != "" {
+        captchaVerifier, err = captcha.New(*captchaProvider, *captchaSecret, *captchaSiteKey)
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        infoLog.Print("-captcha-provider is set, only logged-in users can create chunks through the API")
+    }
+
+    // Set up the webhook sender, if a URL has been configured.
+    var webhookSender *webhook.Sender
+    if *webhookURL != "" {
+        u, err := url.Parse(*webhookURL)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            errorLog.Fatalf("-webhook-url %q is not an http or https URL", *webhookURL)
+        }
+        if *webhookSecret == "" {
+            infoLog.Print("no -webhook-secret set, webhooks will be sent unsigned")
+        }
+        webhookSender = webhook.New(*webhookURL, *webhookSecret)
+    }
+
+    // Set up the URL fetcher. It accepts up to the largest chunk any
+    // language may have; the limit for the fetched chunk's language is
+    // checked once it is known.
+    var fetcher *fetch.Client
+    if *fetchURLs {
+        if *fetchTimeout <= 0 {
+            errorLog.Fatal("-fetch-timeout must be positive")
+        }
+        maxFetchBytes := *maxChunkBytes
+        for _, limit := range sizeLimits {
+            if limit > maxFetchBytes {
+                maxFetchBytes = limit
+            }
+        }
+        if *fetchAllowPrivate {
+            infoLog.Print("-fetch-allow-private is set, chunks can be fetched from the local network")
+        }
+        fetcher = fetch.New(*fetchAllowPrivate, int64(maxFetchBytes), *fetchTimeout)
+    }
+
+    // Set up creating chunks by email, and the SMTP server to reply through.
+    var inboundReceiver *inbound.Receiver
+    var inboundSenders []string
+    if *enableInboundEmail {
+        if !*allowAnonymous {
+            errorLog.Fatal("-enable-inbound-email creates anonymous chunks, which -allow-anonymous=false doesn't allow")
+        }
+        inboundReceiver, err = inbound.New(*inboundEmailProvider, *inboundEmailSecret)
+        if err != nil {
+            errorLog.Fatalf("-enable-inbound-email: %v", err)
+        }
+        inboundSenders, err = parseSenderList(*inboundEmailSenders)
+        if err != nil {
+            errorLog.Fatalf("-inbound-email-senders: %v", err)
+        }
+        if len(inboundSenders) == 0 {
+            errorLog.Fatal("-enable-inbound-email needs -inbound-email-senders")
+        }
+        if *smtpAddr == "" {
+            infoLog.Print("no -smtp-addr set, emails creating chunks won't be answered")
+        }
+    }
+    // A nil *mailer.Mailer would make a non-nil emailSender.
+    var smtpMailer emailSender
+    if *smtpAddr != "" {
+        m, err := mailer.New(*smtpAddr, *smtpUsername, *smtpPassword, *smtpFrom)
+        if err != nil {
+            errorLog.Fatalf("-smtp-addr: %v", err)
+        }
+        smtpMailer = m
+    } else if *dbDriver == "mysql" {
+        infoLog.Print("no -smtp-addr set, users can't change their email address")
+    }
+
+    var ogImages *ogImageCache
+    if *openGraph {
+        ogImages = newOGImageCache(ogImageCacheSize)
+    }
+
+    // Set up the OAuth providers which have credentials configured.
+    oauthProviders := map[string]*oauth.Provider{}
+    if *githubClientID != "" && *githubClientSecret != "" {
+        oauthProviders["github"] = oauth.GitHub(*githubClientID, *githubClientSecret)
+    }
+    if *googleClientID != "" && *googleClientSecret != "" {
+        oauthProviders["google"] = oauth.Google(*googleClientID, *googleClientSecret)
+    }
+
+    if *uniquePerUser != uniqueOff && *uniquePerUser != uniqueReject && *uniquePerUser != uniqueRedirect {
+        errorLog.Fatalf("unknown -unique-per-user %q (choose off, reject or redirect)", *uniquePerUser)
+    }
+    if *uniqueAnonymous != uniqueAnonymousExempt && *uniqueAnonymous != uniqueAnonymousIP {
+        errorLog.Fatalf("unknown -unique-anonymous %q (choose exempt or ip)", *uniqueAnonymous)
+    }
+
+    var secretScanner *secrets.Scanner
+    if *scanSecrets {
+        if *secretAction != secretWarn && *secretAction != secretBlock {
+            errorLog.Fatalf("unknown -secret-action %q (choose warn or block)", *secretAction)
+        }
+        secretScanner = secrets.New(secretScanBudget)
+    }
+
+    // Load the content blocklist, if one has been configured.
+    var chunkBlocklist *blocklist.Blocklist
+    if *blocklistFile != "" {
+        chunkBlocklist, err = blocklist.Load(*blocklistFile)
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        infoLog.Printf("Loaded %d blocklist patterns from %s", chunkBlocklist.Len(), *blocklistFile)
+    }
+
+    if *slowQueryThreshold < 0 {
+        errorLog.Fatal("-slow-query-threshold can't be negative")
+    }
+    metrics := &appMetrics{}
+
+    // Set up the stores. With -db-driver=memory no database is needed and
+    // the chunks and sessions are kept in memory, which is handy for demos.
+    // User accounts (and with them the audit log, comments, favorites and
+    // access logs)
+    // only exist in MySQL, so they are switched off in that mode.
+    var (
+        chunks       models.ChunkStore
+        users        models.UserStore
+        auditLog     *models.AuditModel
+        comments     *models.CommentModel
+        favorites    *models.FavoriteModel
+        accessLog    *models.AccessModel
+        settings     *models.SettingModel
+        sessionStore scs.Store
+        dependencies []dependency
+    )
+    switch *dbDriver {
+    case "mysql":
+        // We pass openDB() the DSN from the command-line flag.
+        db, err := openDB(*dsn, *slowQueryThreshold, func(q models.SlowQuery) {
+            metrics.slowQueries.Add(1)
+            infoLog.Printf("Slow query %s took %s", q.Name, q.Duration.Round(time.Millisecond))
+        })
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        // We also defer a call to db.Close(), so that the connection pool is closed
+        // before the main() or program exits. It actually will never run
+        // in this scenario because of errorLog.Fatal() which terminates
+        // the program immediately.
+        defer db.Close()
+
+        chunkModel := &models.ChunkModel{DB: db}
+        // Chunks from before public IDs existed get one now, so every chunk
+        // can be reached by URL.
+        backfilled, err := chunkModel.BackfillPublicIDs()
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        if backfilled > 0 {
+            infoLog.Printf("Gave %d existing chunks a public ID", backfilled)
+        }
+        chunks = chunkModel
+        users = &models.UserModel{DB: db, BcryptCost: *bcryptCost}
+        auditLog = &models.AuditModel{DB: db}
+        favorites = &models.FavoriteModel{DB: db}
+        if *accessLogDays > 0 {
+            accessLog = &models.AccessModel{DB: db, Max: *accessLogMax}
+        }
+        settings = &models.SettingModel{DB: db}
+        if *enableComments {
+            comments = &models.CommentModel{DB: db}
+        }
+        sessionStore = mysqlstore.New(db)
+        // Register the health checks for /readyz. The application can't do
+        // anything useful without its database, so it is required.
+        dependencies = []dependency{
+            {name: "database", checker: healthCheckFunc(db.PingContext), required: true},
+        }
+    case "memory":
+        if !*allowAnonymous {
+            errorLog.Fatal("-allow-anonymous=false needs user accounts, which -db-driver=memory doesn't support")
+        }
+        chunks = models.NewMemoryChunkModel()
+        sessionStore = memstore.New()
+        infoLog.Print("Using the in-memory store: chunks are lost on restart and user accounts are disabled")
+    default:
+        errorLog.Fatalf("unknown -db-driver %q (choose mysql or memory)", *dbDriver)
+    }
+
+    // With -id-scheme=hashids chunks are identified by the obfuscated
+    // database id. The store does the translation, so everything using it
+    // sees the hashids.
+    switch *idScheme {
+    case idSchemePlain:
+    case idSchemeHashids:
+        if *hashidsSalt == "" {
+            errorLog.Fatal("-id-scheme=hashids needs a -hashids-salt")
+        }
+        ids, err := hashids.New(*hashidsSalt, hashidsMinLength)
+        if err != nil {
+            errorLog.Fatal(err)
+        }
+        chunks = &hashidChunks{ChunkStore: chunks, ids: ids}
+    default:
+        errorLog.Fatalf("unknown -id-scheme %q (choose plain or hashids)", *idScheme)
+    }
+
+    // Connect to Redis, if configured. The pool dials lazily, so an
+    // unreachable Redis doesn't stop the server from starting.
+    var redisPool *redis.Pool
+    if *redisAddr != "" {
+        redisPool = newRedisPool(*redisAddr)
+        defer redisPool.Close()
+    }
+
+    // Sessions live in the database unless they are to be kept in Redis,
+    // which lets several instances share them without hitting MySQL on every
+    // request. Redis then becomes a hard dependency.
+    switch *sessionStoreName {
+    case "", "mysql":
+    case "redis":
+        if redisPool == nil {
+            infoLog.Print("-session-store=redis needs -redis-addr, keeping sessions in the database")
+            break
+        }
+        sessionStore = redisstore.New(redisPool)
+        dependencies = append(dependencies, dependency{name: "redis", checker: redisHealthCheck(redisPool), required: true})
+    default:
+        errorLog.Fatalf("unknown -session-store %q (choose redis, or leave it empty for the database)", *sessionStoreName)
+    }
+
+    // Set up the rate limiter, in Redis if we have it and in memory
+    // otherwise. A reload makes its new one in the same place.
+    limiter := newRateLimiter(*rateLimit, *rateBurst, redisPool)
+    config.redisPool = redisPool
+
+    // Normalize the base path to "" for the root, or a prefix like "/paste"
+    // with a leading slash and no trailing slash.
+    basePath := strings.TrimRight(*basePathFlag, "/")
+    if basePath != "" && !strings.HasPrefix(basePath, "/") {
+        errorLog.Fatalf("-base-path %q must start with a /", *basePathFlag)
+    }
+
+    redirectPrefixes, err := parseRedirectAllowlist(*redirectAllowlist)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+
+    if *bannerLevel != bannerInfo && *bannerLevel != bannerWarning {
+        errorLog.Fatalf("unknown -banner-level %q (choose info or warning)", *bannerLevel)
+    }
+    siteBanner, err := newBannerState(banner{Message: strings.TrimSpace(*bannerMessage), Level: *bannerLevel}, settings)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+
+    // Initialize a new template cache, so every page template is parsed only
+    // once at startup.
+    templateCache, err := loadTemplates(*templatesDir, *strictTemplates, basePath, infoLog)
+    if err != nil {
+        errorLog.Fatal(err)
+    }
+    var reloadTemplates func() (map[string]*template.Template, error)
+    if *dev {
+        if *production {
+            errorLog.Fatal("-dev can't be used with -production")
+        }
+        if *templatesDir == "" {
+            errorLog.Fatal("-dev needs a -templates-dir to re-read the templates from")
+        }
+        infoLog.Printf("WARNING: -dev is on, the templates in %s are parsed again for every page. This is slow, and any change to them goes live at once. Do not use this in production", *templatesDir)
+        // A broken template is an error page rather than a silent fallback
+        // to the built-in templates, so mistakes show up while editing.
+        reloadTemplates = func() (map[string]*template.Template, error) {
+            return loadTemplates(*templatesDir, true, basePath, infoLog)
+        }
+    }
+
+    // Use the scs.New() function to initialize a new session manager. Then we
+    // configure it to use the session store chosen above, and set a
+    // lifetime of 12 hours (so that sessions automatically expire 12 hours
+    // after first being created). The store needs a sessions table:
+    //
+    //  CREATE TABLE sessions (
+    //      token CHAR(43) PRIMARY KEY,
+    //      data BLOB NOT NULL,
+    //      expiry TIMESTAMP(6) NOT NULL
+    //  );
+    //  CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+    sessionManager := scs.New()
+    sessionManager.Store = sessionStore
+    sessionManager.Lifetime = 12 * time.Hour
+    // Scope the session cookie to the base path, so other applications behind
+    // the same reverse proxy never receive it.
+    sessionManager.Cookie.Path = basePath + "/"
+
     // Initialize a new instance of our application struct, containing the
     // dependencies.
     app := &application{
         errorLog: errorLog,
         infoLog:  infoLog,
-        chunks: &models.ChunkModel{DB:db},
+        chunks: chunks,
+        users: users,
+        templateCache: templateCache,
+        reloadTemplates: reloadTemplates,
+        sessionManager: sessionManager,
+        deleteChunksWithUser: *deleteChunksWithUser,
+        minPasswordLength: *minPasswordLength,
+        dependencies: dependencies,
+        branding: siteBranding,
+        legal:    legal,
+        config:   config,
+        signer: signing.New(signingKeys...),
+        minFillTime: *minFillTime,
+        captcha: captchaVerifier,
+        shareLinkTTL: *shareLinkTTL,
+        editWindow:   *editWindow,
+        basePath: basePath,
+        lowercasePaths: *lowercasePaths,
+        trailingSlash:  *trailingSlash,
+        maxBatchSize: *maxBatchSize,
+        problemJSON:  *apiErrors == "problem",
+        debugRequestsEnabled: *debugRequests,
+        gistJSON:       *gistJSON,
+        ogImages:       ogImages,
+        allowAnonymous: *allowAnonymous,
+        oauthProviders: oauthProviders,
+        previewChars:   *previewChars,
+        maxPage:        *maxPage,
+        searchSnippetChars: *searchSnippetChars,
+        searchFulltext: *searchFulltext,
+        searchMinScore: *searchMinScore,
+        maxSearchLen:   *maxSearchLen,
+        searchTooLong:  *searchTooLong,
+        maxTitleLength: *maxTitleLength,
+        languages:      languages,
+        maxChunkBytes:  *maxChunkBytes,
+        minChunkChars:  *minChunkChars,
+        sizeLimits:     sizeLimits,
+        inlineTypes:    inlineTypes,
+        maxTags:        *maxTags,
+        maxFiles:       *maxFiles,
+        maxTagLength:   *maxTagLength,
+        quota:          newChunkQuota(chunks, *maxChunks, *evictOldest),
+        userByteQuota:  *userByteQuota,
+        anonExpiry:     expiryPolicy{Default: *anonDefaultExpiry, Max: *anonMaxExpiry, Languages: languageExpiries},
+        extendOnView:   *extendOnView,
+        warnDuplicateTitle: *warnDuplicateTitle,
+        uniquePerUser:   *uniquePerUser,
+        uniqueAnonymous: *uniqueAnonymous,
+        secretScanner:  secretScanner,
+        secretAction:   *secretAction,
+        keepAliveMaxLifetime: time.Duration(*keepAliveMaxDays) * 24 * time.Hour,
+        userExpiry:     expiryPolicy{Default: *userDefaultExpiry, Max: *userMaxExpiry, Languages: languageExpiries},
+        abuse:          newAbuseTracker(*abuseThreshold, *abuseDecay, *abuseBlock),
+        related:        newRelatedChunks(chunks, *relatedLimit, *relatedCacheTTL),
+        auditLog:       auditLog,
+        webhook:        webhookSender,
+        fetcher:        fetcher,
+        inbound:        inboundReceiver,
+        inboundSenders: inboundSenders,
+        mailer:         smtpMailer,
+        background:     newRunGroup(),
+        highlighter:    highlighter,
+        highlightCache: highlight.NewCache(*highlightCacheSize),
+        highlightJobs:  semaphore.NewWeighted(int64(*maxHighlightConcurrency)),
+        highlightWait:  *highlightWait,
+        maxRenderBytes: *maxRenderBytes,
+        responseCache:  newResponseCache(*responseCacheSize, *responseCacheTTL),
+        metrics:        metrics,
+        metricsAllowlist: metricsAllowlist,
+        detectThreshold: float32(*detectThreshold),
+        comments:       comments,
+        favorites:      favorites,
+        accessLog:      accessLog,
+        accessLogDays:  *accessLogDays,
+        emptyMessage:   *emptyMessage,
+        transcode:      *nonUTF8 == "transcode",
+        normalizeNewlines: *normalizeNewlines,
+        transformers:   transformers,
+        stripBOM:          *stripBOM,
+        postCreateRedirect: *postCreateRedirect,
+        wrap:           *wrap,
+        wrapWidth:      *wrapWidth,
+        prettyPrint:    *prettyPrint,
+        createAllowlist: createAllowlist,
+        readAllowlist:  readAllowlist,
+        canonicalHostName:   canonicalHostName,
+        canonicalHostExempt: parseHostList(*canonicalHostExempt),
+        maxCookieBytes: *maxCookieBytes,
+        requestLog:     reqLog,
+        redirectAllowlist: redirectPrefixes,
+        trustedProxies: trustedProxies,
+        hsts:           hsts,
+        banner:         siteBanner,
+    }
+    app.blocklist.Store(chunkBlocklist)
+    app.limiter.Store(limiter)
+    if *viewFlushInterval > 0 {
+        app.views = newViewCounter()
+        app.startViewFlusher(*viewFlushInterval)
+    }
+    if app.accessLog != nil {
+        app.startAccessPruner()
+    }
+    if *createQueueDepth > 0 {
+        app.createQueue = newCreateQueue(*createQueueDepth)
+        app.startCreateQueue()
     }
     // Initialize a new http.Server struct. We set the Addr and Handler fields so
     // that the server uses the same network address and routes as before, and set
@@ -90,32 +1355,165 @@ func main() {
         ErrorLog: errorLog,
         // call the new app.routes() method to get the servemux containing our routes.
         Handler:  app.routes(),
+        IdleTimeout:    *idleTimeout,
+        MaxHeaderBytes: *maxHeaderBytes,
+    }
+    // A non-nil, empty TLSNextProto map stops the server from setting up
+    // HTTP/2, so clients fall back to HTTP/1.1.
+    if !*enableHTTP2 {
+        srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
     }
+    srv.SetKeepAlivesEnabled(*keepAlives)
 
     // The value returned from the flag.String() function is a pointer to the flag
     // value, not the value itself. So we need to dereference the pointer (i.e.
     // prefix it with the * symbol) before using it. Note that we're using the
     // log.Printf() function to interpolate the address with the log message.
-    infoLog.Printf("Starting server on %s", *addr)
+    infoLog.Printf("Starting server on %s (version %s, commit %s, built %s)", *addr, version, commit, buildTime)
 
     // Instead of the default http.ListenAndServe(), we will use the newly created
     // http server struct. Call the ListenAndServe() method on our new http.Server struct. 
-    // err is already declared above.
-    err = srv.ListenAndServe()
-    errorLog.Fatal(err)
+    // The servers run in their own goroutines, so main can wait for a signal
+    // to shut down, and report back on serveErr if they fail.
+    serveErr := make(chan error, 2)
+    var redirectSrv *http.Server
+    if *tlsCert != "" || len(autocertHosts) > 0 {
+        // The plain HTTP listener redirects to HTTPS. With autotls it is
+        // always needed, and answers the ACME challenges before redirecting
+        // everything else.
+        redirectAddr := *httpRedirectAddr
+        var redirect http.Handler = app.httpsRedirect(*addr)
+        if len(autocertHosts) > 0 {
+            manager := newAutocertManager(autocertHosts, *autoTLSCache, infoLog)
+            srv.TLSConfig = autocertTLSConfig(manager, *enableHTTP2)
+            redirect = manager.HTTPHandler(redirect)
+            if redirectAddr == "" {
+                redirectAddr = ":80"
+            }
+            infoLog.Printf("autotls: serving Let's Encrypt certificates for %s", strings.Join(autocertHosts, ", "))
+        }
+        if redirectAddr != "" {
+            redirectSrv = &http.Server{
+                Addr:           redirectAddr,
+                ErrorLog:       errorLog,
+                Handler:        app.logRequest(redirect),
+                IdleTimeout:    *idleTimeout,
+                MaxHeaderBytes: *maxHeaderBytes,
+            }
+            go func() {
+                infoLog.Printf("Redirecting HTTP on %s to HTTPS", redirectAddr)
+                serveErr <- redirectSrv.ListenAndServe()
+            }()
+        }
+        // With autotls the certificates come from srv.TLSConfig, so no
+        // files are given.
+        go func() { serveErr <- srv.ListenAndServeTLS(*tlsCert, *tlsKey) }()
+    } else {
+        go func() { serveErr <- srv.ListenAndServe() }()
+    }
+
+    // SIGHUP reloads the configuration. A mistake in it is logged and
+    // leaves everything as it was.
+    hup := make(chan os.Signal, 1)
+    signal.Notify(hup, syscall.SIGHUP)
+    go func() {
+        for range hup {
+            // The -access-log is reopened whatever happens to the rest.
+            if app.requestLog != nil {
+                if err := app.requestLog.reopen(); err != nil {
+                    errorLog.Printf("reload: %v", err)
+                }
+            }
+            if err := app.reloadConfig(); err != nil {
+                errorLog.Printf("reload: %v (keeping the current configuration)", err)
+                continue
+            }
+            infoLog.Print("Reloaded the configuration")
+        }
+    }()
+
+    quit := make(chan os.Signal, 1)
+    signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+    select {
+    case err = <-serveErr:
+        errorLog.Fatal(err)
+    case sig := <-quit:
+        infoLog.Printf("Received %s, shutting down", sig)
+    }
+
+    // Stop taking requests and let the ones in progress finish, then drain
+    // the background work. All of it shares the one grace period. Returning
+    // from main afterwards runs the deferred Close calls on the database and
+    // Redis.
+    ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+    defer cancel()
+    if redirectSrv != nil {
+        if err := redirectSrv.Shutdown(ctx); err != nil {
+            errorLog.Printf("shutdown: redirect server: %v", err)
+        }
+    }
+    if err := srv.Shutdown(ctx); err != nil {
+        errorLog.Printf("shutdown: server: %v", err)
+    }
+    if err := app.background.Shutdown(ctx); err != nil {
+        errorLog.Printf("shutdown: gave up waiting for background work: %v", err)
+    }
+    infoLog.Print("Stopped")
 }
 
 
-// The openDB() function wraps sql.Open() and returns a sql.DB connection pool
-// for a given DSN.
-func openDB(dsn string) (*sql.DB, error) {
-    db, err := sql.Open("mysql", dsn)
+// newRedisPool returns a Redis connection pool for the server at addr. The
+// short timeouts stop a struggling Redis from holding up every request.
+func newRedisPool(addr string) *redis.Pool {
+    return &redis.Pool{
+        MaxIdle:     10,
+        IdleTimeout: 4 * time.Minute,
+        Dial: func() (redis.Conn, error) {
+            return redis.Dial("tcp", addr,
+                redis.DialConnectTimeout(time.Second),
+                redis.DialReadTimeout(500*time.Millisecond),
+                redis.DialWriteTimeout(500*time.Millisecond),
+            )
+        },
+    }
+}
+
+// redisHealthCheck returns a health check which pings Redis.
+func redisHealthCheck(pool *redis.Pool) healthCheckFunc {
+    return func(ctx context.Context) error {
+        conn, err := pool.GetContext(ctx)
+        if err != nil {
+            return err
+        }
+        defer conn.Close()
+
+        // The pool's read timeout bounds how long this can take.
+        _, err = conn.Do("PING")
+        return err
+    }
+}
+
+// The openDB() function returns a sql.DB connection pool for a given DSN,
+// which checkDSN checks first. With a slowQuery threshold, queries which
+// take at least that long are passed to reportSlow.
+func openDB(dsn string, slowQuery time.Duration, reportSlow func(models.SlowQuery)) (*sql.DB, error) {
+    cfg, err := checkDSN(dsn)
     if err != nil {
         return nil, err
     }
-    //create a connection and check for any errors.
-    if err = db.Ping(); err != nil {
+    connector, err := mysql.NewConnector(cfg)
+    if err != nil {
         return nil, err
     }
+    if slowQuery > 0 {
+        connector = models.SlowQueryConnector(connector, slowQuery, reportSlow)
+    }
+    db := sql.OpenDB(connector)
+    //create a connection and check for any errors. The error says where we
+    //tried to connect, as the driver's own often doesn't.
+    if err = db.Ping(); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("connecting to MySQL at %s (database %s): %w", cfg.Addr, cfg.DBName, err)
+    }
     return db, nil
 }
\ No newline at end of file
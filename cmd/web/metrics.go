@@ -0,0 +1,50 @@
+/*-----------------------------------------------------------
+ @Filename:         metrics.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "net/http"
+    "sync/atomic"
+)
+
+// appMetrics are the counters served on /metrics, in the Prometheus text
+// format. They count since the process started.
+type appMetrics struct {
+    // highlightJobs counts chunks rendered with syntax highlighting, and
+    // highlightFallbacks the ones shown as plain text instead because
+    // -max-highlight-concurrency jobs were already running for longer than
+    // -highlight-wait.
+    highlightJobs      atomic.Int64
+    highlightFallbacks atomic.Int64
+    // responseCacheHits and responseCacheMisses count the anonymous view
+    // pages served from the -response-cache-size cache and the ones which
+    // had to be rendered. Their ratio is the cache's hit ratio.
+    responseCacheHits   atomic.Int64
+    responseCacheMisses atomic.Int64
+    // slowQueries counts the MySQL queries which took at least
+    // -slow-query-threshold.
+    slowQueries atomic.Int64
+}
+
+// metricsHandler serves the metrics. It is limited to the -metrics-allowlist
+// in routes().
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+    var buf bytes.Buffer
+    counter := func(name, help string, value int64) {
+        fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+    }
+    counter("chunkbox_highlight_jobs_total", "Chunks rendered with syntax highlighting.", app.metrics.highlightJobs.Load())
+    counter("chunkbox_highlight_fallbacks_total", "Chunks shown as plain text because the highlighter was busy.", app.metrics.highlightFallbacks.Load())
+    counter("chunkbox_response_cache_hits_total", "Anonymous chunk views served from the response cache.", app.metrics.responseCacheHits.Load())
+    counter("chunkbox_response_cache_misses_total", "Anonymous chunk views which weren't in the response cache.", app.metrics.responseCacheMisses.Load())
+    counter("chunkbox_slow_queries_total", "MySQL queries which took at least -slow-query-threshold.", app.metrics.slowQueries.Load())
+
+    w.Header().Set("Cache-Control", "no-store")
+    w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+    w.Write(buf.Bytes())
+}
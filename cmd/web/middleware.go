@@ -0,0 +1,432 @@
+/*-----------------------------------------------------------
+ @Filename:         middleware.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "mime"
+    "net/http"
+    "net/netip"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/ratelimit"
+    "github.com/gomodule/redigo/redis"
+    "github.com/justinas/alice"
+    "github.com/justinas/nosurf"
+)
+
+// Define a custom contextKey type for the keys we store in the request
+// context, so they can't collide with keys set by third-party packages.
+type contextKey string
+
+const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// queueableContextKey marks the requests which may wait in the create queue.
+const queueableContextKey = contextKey("queueable")
+
+// secureHeaders sets a handful of security related headers on every response,
+// which instruct the user's web browser to implement some additional security
+// measures to help prevent XSS and Clickjacking attacks. If a remote site
+// logo or a CAPTCHA provider is configured their origins are added to the
+// Content-Security-Policy, otherwise the browser would refuse to load them.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
+    imgSrc := "'self'"
+    if origin := app.branding.logoOrigin(); origin != "" {
+        imgSrc += " " + origin
+    }
+    // The CAPTCHA widget loads its script, frame and styles from the
+    // provider, so allow those origins when it is enabled.
+    scriptSrc, frameSrc, styleSrc := "'self'", "'self'", "'self' fonts.googleapis.com"
+    if app.captcha != nil {
+        sources := strings.Join(app.captcha.CSPSources(), " ")
+        scriptSrc += " " + sources
+        frameSrc += " " + sources
+        styleSrc += " " + sources
+    }
+    csp := "default-src 'self'; style-src " + styleSrc + "; font-src fonts.gstatic.com; img-src " + imgSrc +
+        "; script-src " + scriptSrc + "; frame-src " + frameSrc
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Security-Policy", csp)
+        w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+        w.Header().Set("X-Content-Type-Options", "nosniff")
+        w.Header().Set("X-Frame-Options", "deny")
+        w.Header().Set("X-XSS-Protection", "0")
+        // Never over plain HTTP: browsers ignore it there anyway, and a
+        // proxy which forwards plain HTTP must not be able to turn it on.
+        if app.hsts != "" && app.isHTTPS(r) {
+            w.Header().Set("Strict-Transport-Security", app.hsts)
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// logRequest records the IP address of the user, and which URL and method
+// are being requested, using the information logger. With an -access-log
+// the request is written there instead, once the response is done, with
+// its status and size (see requestlog.go).
+func (app *application) logRequest(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch {
+        case app.requestLog == nil:
+            app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+        case app.requestLog.off:
+        default:
+            start := time.Now()
+            sw := &statusWriter{ResponseWriter: w}
+            done := false
+            // Deferred, so a request which panicked is logged too, with the
+            // 500 recoverPanic sends.
+            defer func() {
+                status := sw.status
+                if status == 0 {
+                    status = http.StatusOK
+                    if !done {
+                        status = http.StatusInternalServerError
+                    }
+                }
+                app.requestLog.log(r, app.realIP(r), status, sw.size, start)
+            }()
+            next.ServeHTTP(sw, r)
+            done = true
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// limitCookies refuses requests whose cookies add up to more than
+// -max-cookie-bytes with 431 Request Header Fields Too Large, before any
+// handler parses them. Our own cookies (the session and the CSRF token)
+// take less than 200 bytes, so only a broken or abusive client gets there.
+func (app *application) limitCookies(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if app.maxCookieBytes > 0 {
+            // HTTP/2 clients may send the cookies split over several
+            // headers, so count them all.
+            size := 0
+            for _, cookie := range r.Header.Values("Cookie") {
+                size += len(cookie)
+            }
+            if size > app.maxCookieBytes {
+                app.infoLog.Printf("%s - refused %d bytes of cookies", r.RemoteAddr, size)
+                app.clientError(w, http.StatusRequestHeaderFieldsTooLarge)
+                return
+            }
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// debugBodyBytes is how much of a request body debugRequests logs.
+const debugBodyBytes = 4096
+
+// debugRedactedHeaders and debugRedactedFields are never logged by
+// debugRequests, as they carry credentials.
+var (
+    debugRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Csrf-Token"}
+    debugRedactedFields  = []string{"password", "currentPassword", "newPassword", "newPasswordConfirmation", "csrf_token", "form_token"}
+)
+
+// debugRequests logs the headers and the start of the body of requests from
+// the local machine, for -debug-requests. Credentials are redacted, and the
+// body is put back together so the handler still reads all of it.
+func (app *application) debugRequests(next http.Handler) http.Handler {
+    if !app.debugRequestsEnabled {
+        return next
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        ip, err := netip.ParseAddr(app.realIP(r))
+        if err != nil || !ip.IsLoopback() {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        headers := r.Header.Clone()
+        for _, h := range debugRedactedHeaders {
+            if headers.Get(h) != "" {
+                headers.Set(h, "[redacted]")
+            }
+        }
+
+        var body []byte
+        if r.Body != nil {
+            body, err = io.ReadAll(io.LimitReader(r.Body, debugBodyBytes))
+            if err != nil {
+                app.serverError(w, err)
+                return
+            }
+            r.Body = struct {
+                io.Reader
+                io.Closer
+            }{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+        }
+
+        // Form bodies have their credential fields blanked out too.
+        snapshot := string(body)
+        mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+        if mediaType == "application/x-www-form-urlencoded" {
+            if form, err := url.ParseQuery(snapshot); err == nil {
+                for _, f := range debugRedactedFields {
+                    if form.Has(f) {
+                        form.Set(f, "[redacted]")
+                    }
+                }
+                snapshot = form.Encode()
+            }
+        }
+        if len(body) == debugBodyBytes {
+            snapshot += " [truncated]"
+        }
+
+        var b strings.Builder
+        headers.Write(&b)
+        app.infoLog.Printf("debug: %s %s\n%s\n%s", r.Method, r.URL.RequestURI(), strings.TrimSpace(b.String()), snapshot)
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// recoverPanic turns a panic in any handler further down the chain into a
+// logged error and a 500 Internal Server Error response, instead of the
+// connection just being closed by Go's HTTP server.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Create a deferred function (which will always be run in the event
+        // of a panic as Go unwinds the stack).
+        defer func() {
+            // Use the builtin recover function to check if there has been a
+            // panic or not. If there has...
+            if err := recover(); err != nil {
+                // Set a "Connection: close" header on the response.
+                w.Header().Set("Connection", "close")
+                // Call the app.serverError helper method to return a 500
+                // Internal Server response.
+                app.serverError(w, fmt.Errorf("%s", err))
+            }
+        }()
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// A rateLimiter is the -rate-limit limiter, with the Retry-After header
+// it sends: how long it takes for one request's worth of tokens to come
+// back, which is interval.
+type rateLimiter struct {
+    ratelimit.Limiter
+    retryAfter string
+    interval   time.Duration
+}
+
+// newRateLimiter returns a limiter allowing perMinute requests a minute in
+// bursts of burst, keeping its counters in Redis if there is a pool and in
+// memory otherwise. No limit (0) gives nil.
+func newRateLimiter(perMinute float64, burst int, pool *redis.Pool) *rateLimiter {
+    l := &rateLimiter{retryAfter: strconv.Itoa(int(math.Ceil(60 / perMinute))), interval: time.Duration(float64(time.Minute) / perMinute)}
+    switch {
+    case perMinute == 0:
+        return nil
+    case pool != nil:
+        l.Limiter = ratelimit.NewRedis(pool, "chunkbox:ratelimit:", perMinute/60, burst)
+    default:
+        l.Limiter = ratelimit.NewMemory(perMinute/60, burst)
+    }
+    return l
+}
+
+// rateLimit limits how often each client IP can make requests which change
+// something (creating chunks, logging in, signing up, ...). Reading pages is
+// cheap and isn't limited. When the limiter's storage (Redis) can't be
+// reached the request is let through, with a warning at most once a minute,
+// so an outage of Redis doesn't take the whole site down with it.
+func (app *application) rateLimit(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        limiter := app.limiter.Load()
+        if limiter == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+        switch r.Method {
+        case http.MethodGet, http.MethodHead, http.MethodOptions:
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        ok, err := limiter.Allow(r.Context(), app.realIP(r))
+        if err != nil {
+            now := time.Now().Unix()
+            if last := app.rateLimitWarned.Load(); now-last >= 60 && app.rateLimitWarned.CompareAndSwap(last, now) {
+                app.errorLog.Printf("rate limit: %v (letting requests through)", err)
+            }
+            ok = true
+        }
+        // Creating a chunk can wait its turn instead (-create-queue-depth).
+        if !ok && app.createQueue != nil && queueable(r) {
+            queued, err := app.createQueue.wait(r.Context(), app.realIP(r))
+            switch {
+            case errors.Is(err, errQueueStopped):
+                app.clientError(w, http.StatusServiceUnavailable)
+                return
+            case err != nil:
+                // The client has gone or given up waiting.
+                return
+            case queued:
+                ok = true
+            }
+        }
+        if !ok {
+            app.recordAbuse(r, abuseRateLimitPoints)
+            w.Header().Set("Retry-After", limiter.retryAfter)
+            if isAPIRequest(r) {
+                app.apiError(w, http.StatusTooManyRequests, "rate_limited", "too many requests, please slow down")
+                return
+            }
+            app.clientError(w, http.StatusTooManyRequests)
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// allowIPs returns middleware which only lets requests through from clients
+// in one of the networks on the list, and refuses everyone else with a 403
+// Forbidden. An empty list lets everyone through.
+func (app *application) allowIPs(list ipList) alice.Constructor {
+    return func(next http.Handler) http.Handler {
+        if list == nil {
+            return next
+        }
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if !list.contains(app.realIP(r)) {
+                if isAPIRequest(r) {
+                    app.apiError(w, http.StatusForbidden, "forbidden", "requests from your network are not allowed")
+                    return
+                }
+                app.clientError(w, http.StatusForbidden)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// requireAuthentication redirects unauthenticated users to the login page,
+// and stops pages that require authentication from being cached by the
+// user's browser (or other intermediary cache).
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // If the user is not authenticated, redirect them to the login page and
+        // return from the middleware chain so that no subsequent handlers in
+        // the chain are executed.
+        if !app.isAuthenticated(r) {
+            if isAPIRequest(r) {
+                app.apiError(w, http.StatusUnauthorized, "unauthorized", "you must be authenticated to use this endpoint")
+                return
+            }
+            // Pages which are read come back after the login.
+            next := ""
+            if r.Method == http.MethodGet {
+                next = app.url(r.URL.RequestURI())
+            }
+            http.Redirect(w, r, app.loginURL(next), http.StatusSeeOther)
+            return
+        }
+
+        // Otherwise set the "Cache-Control: no-store" header so that pages
+        // that require authentication are not stored in the users browser cache (or
+        // other intermediary cache).
+        w.Header().Add("Cache-Control", "no-store")
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// requireAdmin only lets administrators through. It must run after
+// requireAuthentication, so there is always a logged-in user to look up.
+// Everyone else gets a 403 Forbidden.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        user, err := app.users.Get(app.authenticatedUserID(r))
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if !user.IsAdmin {
+            if isAPIRequest(r) {
+                app.apiError(w, http.StatusForbidden, "forbidden", "only administrators can use this endpoint")
+                return
+            }
+            app.clientError(w, http.StatusForbidden)
+            return
+        }
+
+        next.ServeHTTP(w, r)
+    })
+}
+
+// Create a noSurf middleware function which uses a customized CSRF cookie with
+// the Secure, Path and HttpOnly attributes set.
+func noSurf(next http.Handler) http.Handler {
+    csrfHandler := nosurf.New(next)
+    csrfHandler.SetBaseCookie(http.Cookie{
+        HttpOnly: true,
+        Path:     "/",
+    })
+
+    return csrfHandler
+}
+
+// authenticate checks the session for an authenticatedUserID and, if the user
+// still exists in the database, flags the request as authenticated by adding
+// isAuthenticatedContextKey to the request context.
+func (app *application) authenticate(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Retrieve the authenticatedUserID value from the session using the
+        // GetInt() method. This will return the zero value for an int (0) if no
+        // "authenticatedUserID" value is in the session -- in which case we
+        // call the next handler in the chain as normal and return.
+        // Without user accounts (-db-driver=memory) nobody can be logged in.
+        id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+        if id == 0 || app.users == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        // Otherwise, we check to see if a user with that ID exists in our
+        // database.
+        exists, err := app.users.Exists(id)
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+
+        // If a matching user is found, we know that the request is
+        // coming from an authenticated user who exists in our database. We
+        // create a new copy of the request (with an isAuthenticatedContextKey
+        // value of true in the request context) and assign it to r.
+        if exists {
+            ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+            r = r.WithContext(ctx)
+        }
+
+        // Call the next handler in the chain.
+        next.ServeHTTP(w, r)
+    })
+}
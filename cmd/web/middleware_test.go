@@ -0,0 +1,75 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestLimitCookies(t *testing.T) {
+    app := newTestApplication(t)
+    app.maxCookieBytes = 1024
+    ts := newTestServer(t, app.routes())
+    // Only the cookies of each test are sent.
+    jar := ts.Client().Jar
+    ts.Client().Jar = nil
+
+    tests := []struct {
+        name    string
+        cookies []string
+        status  int
+    }{
+        {name: "none", status: http.StatusOK},
+        {name: "under the limit", cookies: []string{"a=" + strings.Repeat("x", 1000)}, status: http.StatusOK},
+        {name: "oversized", cookies: []string{"a=" + strings.Repeat("x", 1100)}, status: http.StatusRequestHeaderFieldsTooLarge},
+        // Cookies split over several headers add up.
+        {name: "split", cookies: []string{"a=" + strings.Repeat("x", 600), "b=" + strings.Repeat("y", 600)}, status: http.StatusRequestHeaderFieldsTooLarge},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            req := ts.request(t, http.MethodGet, "/", nil)
+            for _, cookie := range tt.cookies {
+                req.Header.Add("Cookie", cookie)
+            }
+            resp, _ := ts.do(t, req)
+            if resp.StatusCode != tt.status {
+                t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+            }
+        })
+    }
+
+    // Our own cookies are well under the limit.
+    ts.Client().Jar = jar
+    ts.get(t, "/chunkbox/create")
+    req := ts.request(t, http.MethodGet, "/chunkbox/create", nil)
+    for _, c := range jar.Cookies(req.URL) {
+        req.AddCookie(c)
+    }
+    if size := len(req.Header.Get("Cookie")); size == 0 || size > 200 {
+        t.Errorf("the session and CSRF cookies take %d bytes", size)
+    }
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+    app := newTestApplication(t)
+    app.maxCookieBytes = 0
+    ts := httptest.NewUnstartedServer(app.routes())
+    ts.Config.MaxHeaderBytes = 4096
+    ts.Start()
+    defer ts.Close()
+
+    req, err := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Header.Set("X-Padding", strings.Repeat("x", 16<<10))
+    resp, err := ts.Client().Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+        t.Errorf("status %d, want %d", resp.StatusCode, http.StatusRequestHeaderFieldsTooLarge)
+    }
+}
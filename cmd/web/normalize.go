@@ -0,0 +1,112 @@
+/*-----------------------------------------------------------
+ @Filename:         normalize.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "net/http"
+    "net/url"
+    "path"
+    "strings"
+)
+
+// The -trailing-slash choices. With trailingSlashKeep paths are left as
+// they are.
+const (
+    trailingSlashKeep  = ""
+    trailingSlashStrip = "strip"
+    trailingSlashAdd   = "add"
+)
+
+// caseSensitivePrefixes are the subtrees whose paths carry something that
+// mustn't be lowercased: share tokens, chunk ids, and the names of static
+// files. Only the prefix itself is lowercased.
+var caseSensitivePrefixes = []string{"/s/", "/chunk/", "/static/"}
+
+// subtreePatterns are the routes registered with a trailing slash. The mux
+// redirects them to their slashed form itself, so they are left alone, or
+// the two would redirect back and forth.
+var subtreePatterns = []string{"/", "/s/", "/chunk/", "/static/", "/api/", "/auth/"}
+
+// canonicalPath returns the canonical form of a request path under
+// -lowercase-paths and -trailing-slash, and the path the mux should see,
+// which never has a trailing slash added.
+func (app *application) canonicalPath(p string) (canonical, route string) {
+    canonical = p
+    if app.lowercasePaths {
+        canonical = lowercasePath(canonical)
+    }
+
+    // Static files have their own handling of slashes in the file server,
+    // and neither "/" nor the subtree roots can change.
+    if app.trailingSlash == trailingSlashKeep || strings.HasPrefix(canonical, "/static/") {
+        return canonical, canonical
+    }
+    for _, pattern := range subtreePatterns {
+        if canonical == pattern {
+            return canonical, canonical
+        }
+    }
+
+    trimmed := strings.TrimRight(canonical, "/")
+    if trimmed == "" {
+        return "/", "/"
+    }
+    if app.trailingSlash == trailingSlashStrip {
+        return trimmed, trimmed
+    }
+    // Files like /favicon.ico keep their name as it is.
+    route = trimmed
+    if !strings.Contains(path.Base(route), ".") {
+        canonical = route + "/"
+    }
+    return canonical, route
+}
+
+// lowercasePath lowercases a path, except what follows one of the
+// caseSensitivePrefixes.
+func lowercasePath(p string) string {
+    lower := strings.ToLower(p)
+    for _, prefix := range caseSensitivePrefixes {
+        if strings.HasPrefix(lower, prefix) {
+            return prefix + p[len(prefix):]
+        }
+    }
+    return lower
+}
+
+// normalizePaths redirects GET and HEAD requests to the canonical form of
+// their path, with a 301 that keeps the query string, when -lowercase-paths
+// or -trailing-slash is set. Other requests are never redirected, because
+// browsers don't resend a form body after a 301; they are only routed as if
+// their path were canonical. The path it sees has -base-path stripped.
+func (app *application) normalizePaths(next http.Handler) http.Handler {
+    if !app.lowercasePaths && app.trailingSlash == trailingSlashKeep {
+        return next
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        canonical, route := app.canonicalPath(r.URL.Path)
+        if canonical != r.URL.Path && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+            target := app.url(canonical)
+            if r.URL.RawQuery != "" {
+                target += "?" + r.URL.RawQuery
+            }
+            http.Redirect(w, r, target, http.StatusMovedPermanently)
+            return
+        }
+
+        if route != r.URL.Path {
+            r2 := new(http.Request)
+            *r2 = *r
+            r2.URL = new(url.URL)
+            *r2.URL = *r.URL
+            r2.URL.Path = route
+            r2.URL.RawPath = ""
+            r = r2
+        }
+        next.ServeHTTP(w, r)
+    })
+}
@@ -0,0 +1,202 @@
+/*-----------------------------------------------------------
+ @Filename:         oauth.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/base64"
+    "errors"
+    "net/http"
+    "sort"
+    "strings"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/oauth"
+)
+
+// oauthProviderNames returns the names of the configured OAuth providers in
+// a stable order, for the login page buttons.
+func (app *application) oauthProviderNames() []string {
+    names := make([]string, 0, len(app.oauthProviders))
+    for name := range app.oauthProviders {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// oauthAuth handles both halves of the OAuth login flow:
+//
+//  GET /auth/{provider}           redirects to the provider's consent page
+//  GET /auth/{provider}/callback  is where the provider sends the user back
+func (app *application) oauthAuth(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/auth/"), "/")
+    provider, ok := app.oauthProviders[name]
+    if !ok {
+        app.notFound(w)
+        return
+    }
+
+    redirectURL := app.absoluteURL(r, "/auth/"+name+"/callback")
+
+    switch rest {
+    case "":
+        app.oauthBegin(w, r, provider, redirectURL)
+    case "callback":
+        app.oauthCallback(w, r, provider, redirectURL)
+    default:
+        app.notFound(w)
+    }
+}
+
+// oauthBegin stores a random state token in the session and sends the user
+// to the provider. The provider passes the state back to the callback, which
+// only continues if it matches, so an attacker can't complete a login flow
+// they started in someone else's browser.
+func (app *application) oauthBegin(w http.ResponseWriter, r *http.Request, provider *oauth.Provider, redirectURL string) {
+    b := make([]byte, 32)
+    _, err := rand.Read(b)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    state := base64.RawURLEncoding.EncodeToString(b)
+
+    app.sessionManager.Put(r.Context(), "oauthState", provider.Name+":"+state)
+    // Where to go after the login, like the next field of the login form.
+    if next := app.safeNext(r.URL.Query().Get("next")); next != "" {
+        app.sessionManager.Put(r.Context(), "oauthNext", next)
+    }
+
+    http.Redirect(w, r, provider.AuthCodeURL(state, redirectURL), http.StatusSeeOther)
+}
+
+func (app *application) oauthCallback(w http.ResponseWriter, r *http.Request, provider *oauth.Provider, redirectURL string) {
+    // The state can only be used once.
+    expected := app.sessionManager.PopString(r.Context(), "oauthState")
+    next := app.sessionManager.PopString(r.Context(), "oauthNext")
+    state := provider.Name + ":" + r.URL.Query().Get("state")
+    if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(state)) != 1 {
+        app.clientError(w, http.StatusForbidden)
+        return
+    }
+
+    // The user declined on the provider's consent page.
+    if r.URL.Query().Get("error") != "" {
+        app.oauthFailed(w, r, "Login was cancelled.")
+        return
+    }
+
+    profile, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), redirectURL)
+    if err != nil {
+        app.errorLog.Printf("oauth %s: %v", provider.Name, err)
+        app.oauthFailed(w, r, "We couldn't log you in with that provider. Please try again.")
+        return
+    }
+
+    userID, err := app.oauthUserID(r, provider.Name, profile)
+    if err != nil {
+        switch {
+        case errors.Is(err, models.ErrDuplicateEmail):
+            app.oauthFailed(w, r, "An account with that email address already exists. Log in with your password first to link it.")
+        case errors.Is(err, errNoOAuthEmail):
+            app.oauthFailed(w, r, "Your account with that provider doesn't have an email address.")
+        default:
+            app.serverError(w, err)
+        }
+        return
+    }
+
+    // Log the user in, in exactly the same way as userLoginPost.
+    err = app.sessionManager.RenewToken(r.Context())
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+    app.sessionManager.Put(r.Context(), "authenticatedUserID", userID)
+    app.audit(r, userID, auditLogin, userTarget(userID)+" via "+provider.Name)
+
+    app.safeRedirect(w, r, next)
+}
+
+var errNoOAuthEmail = errors.New("oauth: profile has no email address")
+
+// oauthUserID finds or creates the user for a provider profile:
+//
+//  1. an account already linked to the provider account logs in;
+//  2. a logged-in user gets the provider account linked to them;
+//  3. a user whose email both they and the provider have verified gets it
+//     linked;
+//  4. otherwise a new user is created, unless the email is taken.
+func (app *application) oauthUserID(r *http.Request, provider string, profile *oauth.Profile) (int, error) {
+    id, err := app.users.IdentityUserID(provider, profile.Subject)
+    if err == nil {
+        return id, nil
+    }
+    if !errors.Is(err, models.ErrNoRecord) {
+        return 0, err
+    }
+
+    if id = app.authenticatedUserID(r); id != 0 {
+        return id, app.linkIdentity(r, id, provider, profile.Subject)
+    }
+
+    if profile.Email == "" {
+        return 0, errNoOAuthEmail
+    }
+
+    // Only trust the email address to link accounts if the provider has
+    // verified it, otherwise anyone could claim someone else's address. The
+    // local account has to have verified it too: anyone can sign up with an
+    // address which isn't theirs, and its owner logging in with the provider
+    // would then end up in an account someone else has the password of.
+    // Those users log in with their password first to link it.
+    if profile.EmailVerified {
+        id, err = app.users.IDByEmail(profile.Email)
+        if err == nil {
+            user, err := app.users.Get(id)
+            if err != nil {
+                return 0, err
+            }
+            if !user.EmailVerified {
+                return 0, models.ErrDuplicateEmail
+            }
+            return id, app.linkIdentity(r, id, provider, profile.Subject)
+        }
+        if !errors.Is(err, models.ErrNoRecord) {
+            return 0, err
+        }
+    }
+
+    name := strings.TrimSpace(profile.Name)
+    if name == "" {
+        name, _, _ = strings.Cut(profile.Email, "@")
+    }
+    return app.users.InsertWithIdentity(name, profile.Email, profile.EmailVerified, provider, profile.Subject)
+}
+
+// linkIdentity links a provider account to a user, and records it in the
+// audit log.
+func (app *application) linkIdentity(r *http.Request, userID int, provider, subject string) error {
+    err := app.users.LinkIdentity(userID, provider, subject)
+    if err != nil {
+        return err
+    }
+    app.audit(r, userID, auditOAuthLink, userTarget(userID)+" to "+provider)
+    return nil
+}
+
+// oauthFailed sends the user back to the login page with an explanation.
+func (app *application) oauthFailed(w http.ResponseWriter, r *http.Request, message string) {
+    app.sessionManager.Put(r.Context(), "flash", message)
+    http.Redirect(w, r, app.url("/user/login"), http.StatusSeeOther)
+}
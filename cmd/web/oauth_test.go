@@ -0,0 +1,66 @@
+package main
+
+import (
+    "errors"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/oauth"
+)
+
+func TestOAuthUserID(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    unverified := users.add(t, "Unverified", "unverified@example.com", "pa55word")
+    verified := users.add(t, "Verified", "verified@example.com", "pa55word")
+    users.users[verified].EmailVerified = true
+
+    tests := []struct {
+        name    string
+        profile oauth.Profile
+        wantID  int
+        wantErr error
+    }{
+        {"unverified local email", oauth.Profile{Subject: "1", Email: "unverified@example.com", EmailVerified: true}, unverified, models.ErrDuplicateEmail},
+        {"verified local email", oauth.Profile{Subject: "2", Email: "verified@example.com", EmailVerified: true}, verified, nil},
+        {"unverified provider email", oauth.Profile{Subject: "3", Email: "verified@example.com"}, verified, models.ErrDuplicateEmail},
+        {"no email", oauth.Profile{Subject: "4"}, 0, errNoOAuthEmail},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := httptest.NewRequest("GET", "/oauth/github/callback", nil)
+            id, err := app.oauthUserID(r, "github", &tt.profile)
+            if tt.wantErr != nil {
+                if !errors.Is(err, tt.wantErr) {
+                    t.Fatalf("got %d, %v; want %v", id, err, tt.wantErr)
+                }
+                if linked, err := users.IdentityUserID("github", tt.profile.Subject); err == nil {
+                    t.Errorf("provider account linked to user %d", linked)
+                }
+                return
+            }
+            if err != nil || id != tt.wantID {
+                t.Fatalf("got %d, %v; want %d", id, err, tt.wantID)
+            }
+            if linked, _ := users.IdentityUserID("github", tt.profile.Subject); linked != tt.wantID {
+                t.Errorf("provider account linked to user %d", linked)
+            }
+        })
+    }
+
+    // A new user gets the email address verified if the provider vouches for
+    // it, and logs in to the same account next time.
+    r := httptest.NewRequest("GET", "/oauth/github/callback", nil)
+    profile := &oauth.Profile{Subject: "5", Name: "New", Email: "new@example.com", EmailVerified: true}
+    id, err := app.oauthUserID(r, "github", profile)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if user, _ := users.Get(id); !user.EmailVerified || user.HasPassword {
+        t.Errorf("new user: email verified %t, has a password %t", user.EmailVerified, user.HasPassword)
+    }
+    if again, err := app.oauthUserID(r, "github", profile); err != nil || again != id {
+        t.Errorf("logging in again: got %d, %v; want %d", again, err, id)
+    }
+}
@@ -0,0 +1,136 @@
+/*-----------------------------------------------------------
+ @Filename:         og.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "sync"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/ogimage"
+)
+
+// ogDescriptionChars is how much of a chunk's content goes in the
+// description of its link previews.
+const ogDescriptionChars = 200
+
+// ogImageCacheSize is the most preview images kept in memory. A card is
+// a few tens of kilobytes, so this stays under about 20MB.
+const ogImageCacheSize = 500
+
+// openGraph is what the Open Graph and Twitter card tags of a view page
+// say, so links to a chunk unfurl with its title, the start of its content
+// and a preview image.
+type openGraph struct {
+    Title       string
+    Description string
+    URL         string
+    Image       string
+}
+
+// openGraphFor returns the link preview of a chunk, or nil if there
+// mustn't be one: previews are off (-open-graph), or the chunk is private.
+// Unfurlers cache what they fetch and show it to whoever the link is sent
+// to, so a private chunk never gets a preview, even on its owner's page.
+func (app *application) openGraphFor(r *http.Request, chunk *models.Chunk) *openGraph {
+    if app.ogImages == nil || chunk.Private {
+        return nil
+    }
+    description := strings.Join(strings.Fields(chunk.Content), " ")
+    return &openGraph{
+        Title:       chunk.Title,
+        Description: truncate(description, ogDescriptionChars),
+        URL:         app.absoluteURL(r, "/chunkbox/view?id="+chunk.PublicID),
+        // The version makes unfurlers fetch the image again after an edit.
+        Image: app.absoluteURL(r, "/chunk/"+chunk.PublicID+"/og.png?v="+strconv.FormatInt(chunk.Modified().Unix(), 10)),
+    }
+}
+
+// chunkOGImage serves GET /chunk/{id}/og.png, the preview image of a chunk
+// (-open-graph). Private chunks get a 404 whoever asks, like they get no
+// preview tags, and so do expired ones.
+func (app *application) chunkOGImage(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+    if chunk.Private {
+        app.notFound(w)
+        return
+    }
+
+    png, err := app.ogImages.get(chunk, app.branding.siteName)
+    if err != nil {
+        app.serverError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", "image/png")
+    w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+    w.Header().Set("Cache-Control", "public, max-age=86400")
+    if r.Method == http.MethodHead {
+        return
+    }
+    w.Write(png)
+}
+
+// An ogImageCache keeps the preview images already drawn, keyed by the
+// chunk id and the time it was last changed, so an edit draws a new one.
+// The images of older versions are left to be dropped when the cache
+// fills up, at which point it starts again empty.
+type ogImageCache struct {
+    mu     sync.Mutex
+    images map[string][]byte
+    max    int
+}
+
+func newOGImageCache(max int) *ogImageCache {
+    return &ogImageCache{images: make(map[string][]byte), max: max}
+}
+
+// get returns the preview image of a chunk, drawing it if it isn't cached.
+func (c *ogImageCache) get(chunk *models.Chunk, siteName string) ([]byte, error) {
+    key := fmt.Sprintf("%d:%d", chunk.ID, chunk.Modified().UnixNano())
+
+    c.mu.Lock()
+    png, ok := c.images[key]
+    c.mu.Unlock()
+    if ok {
+        return png, nil
+    }
+
+    footer := siteName
+    if lang, ok := highlight.Lookup(chunk.Language); ok {
+        footer += " · " + lang.Label
+    }
+    png, err := ogimage.Render(ogimage.Card{Title: chunk.Title, Content: chunk.Content, Footer: footer})
+    if err != nil {
+        return nil, err
+    }
+
+    c.mu.Lock()
+    if len(c.images) >= c.max {
+        c.images = make(map[string][]byte)
+    }
+    c.images[key] = png
+    c.mu.Unlock()
+    return png, nil
+}
@@ -0,0 +1,175 @@
+/*-----------------------------------------------------------
+ @Filename:         pretty.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "errors"
+    "io"
+    "net/http"
+    "strings"
+)
+
+// JSON and XML chunks can be shown pretty printed on the view page, with
+// the ?pretty=1 parameter the page links to (unless -pretty-print=false).
+// Like the wrap modes, only the page changes: the content is reformatted
+// for display, and the raw and download endpoints serve it byte for byte
+// as it was saved. Content which doesn't parse is shown as it is, with a
+// notice. Only the content of the chunk is reformatted, not its other
+// files.
+const prettyIndent = "  "
+
+// prettyPrinters reformat content in the languages which can be pretty
+// printed, by their canonical names.
+var prettyPrinters = map[string]func(string) (string, error){
+    "json": prettyJSON,
+    "xml":  prettyXML,
+}
+
+// prettyJSON indents a JSON document.
+func prettyJSON(s string) (string, error) {
+    var b bytes.Buffer
+    if err := json.Indent(&b, []byte(s), "", prettyIndent); err != nil {
+        return "", err
+    }
+    b.WriteByte('\n')
+    return b.String(), nil
+}
+
+// prettyXML indents an XML document. It is read twice: once to check it is
+// well-formed, which RawToken doesn't, then with RawToken to write it out
+// with the namespace prefixes it had, which Token resolves away. The
+// whitespace between elements is dropped for the indentation to replace,
+// and an element with nothing but text in it stays on one line.
+func prettyXML(s string) (string, error) {
+    d := xml.NewDecoder(strings.NewReader(s))
+    root := false
+    for {
+        tok, err := d.Token()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return "", err
+        }
+        if _, ok := tok.(xml.StartElement); ok {
+            root = true
+        }
+    }
+    if !root {
+        return "", errors.New("no root element")
+    }
+
+    var b bytes.Buffer
+    depth := 0
+    // inline is whether the element just started has only had text so far,
+    // so its end tag goes on the same line, and empty whether it hasn't
+    // even had that, so it can be written as <name/>.
+    inline, empty := false, false
+    newline := func() {
+        if b.Len() > 0 {
+            b.WriteByte('\n')
+        }
+        b.WriteString(strings.Repeat(prettyIndent, depth))
+    }
+
+    d = xml.NewDecoder(strings.NewReader(s))
+    for {
+        tok, err := d.RawToken()
+        if errors.Is(err, io.EOF) {
+            break
+        }
+        if err != nil {
+            return "", err
+        }
+        switch t := tok.(type) {
+        case xml.StartElement:
+            newline()
+            b.WriteString("<" + xmlName(t.Name))
+            for _, a := range t.Attr {
+                b.WriteString(" " + xmlName(a.Name) + `="` + attrEscaper.Replace(a.Value) + `"`)
+            }
+            b.WriteString(">")
+            depth++
+            inline, empty = true, true
+        case xml.EndElement:
+            depth--
+            if empty {
+                b.Truncate(b.Len() - 1)
+                b.WriteString("/>")
+                inline, empty = false, false
+                continue
+            }
+            if !inline {
+                newline()
+            }
+            b.WriteString("</" + xmlName(t.Name) + ">")
+            inline = false
+        case xml.CharData:
+            text := strings.TrimSpace(string(t))
+            if text == "" {
+                continue
+            }
+            if !inline {
+                newline()
+            }
+            b.WriteString(textEscaper.Replace(text))
+            empty = false
+        case xml.Comment:
+            newline()
+            b.WriteString("<!--" + string(t) + "-->")
+            inline, empty = false, false
+        case xml.ProcInst:
+            newline()
+            b.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+        case xml.Directive:
+            newline()
+            b.WriteString("<!" + string(t) + ">")
+        }
+    }
+    b.WriteByte('\n')
+    return b.String(), nil
+}
+
+// The characters escaped again in the text and the attribute values of a
+// pretty printed XML document. Only the ones which must be are, so the
+// text reads as it was written.
+var (
+    textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+    attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+)
+
+// xmlName writes a name RawToken read, with its prefix.
+func xmlName(n xml.Name) string {
+    if n.Space == "" {
+        return n.Local
+    }
+    return n.Space + ":" + n.Local
+}
+
+// prettyPrinter returns the printer for content in the language, or nil if
+// it can't be pretty printed here.
+func (app *application) prettyPrinter(language string) func(string) (string, error) {
+    if !app.prettyPrint {
+        return nil
+    }
+    return prettyPrinters[language]
+}
+
+// prettyURL is the link to the view page the other way round: pretty
+// printed if it isn't, and as saved if it is. It keeps the rest of the
+// query string, like the links to the wrap modes.
+func (app *application) prettyURL(r *http.Request, pretty bool) string {
+    query := r.URL.Query()
+    if pretty {
+        query.Del("pretty")
+    } else {
+        query.Set("pretty", "1")
+    }
+    return app.url(r.URL.Path + "?" + query.Encode())
+}
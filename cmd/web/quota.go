@@ -0,0 +1,106 @@
+/*-----------------------------------------------------------
+ @Filename:         quota.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// quotaCountTTL is how long the cached chunk count is trusted before it is
+// read from the database again. Chunks created through this process are
+// added to the cached count straight away, so the refresh mostly picks up
+// chunks which have expired in the meantime.
+const quotaCountTTL = time.Minute
+
+// A chunkQuota enforces the -max-chunks limit on the number of non-expired
+// chunks. A nil *chunkQuota means there is no limit.
+//
+// The check and the insert aren't atomic, so concurrent requests can push
+// the total slightly over the limit. That's fine for its purpose of keeping
+// a small server from filling its disk.
+type chunkQuota struct {
+    chunks models.ChunkStore
+    max    int
+    // evict makes room for new chunks by deleting the chunks which are
+    // closest to expiring, instead of refusing them (-evict-oldest).
+    evict  bool
+
+    mu      sync.Mutex
+    count   int
+    fetched time.Time
+}
+
+func newChunkQuota(chunks models.ChunkStore, max int, evict bool) *chunkQuota {
+    if max <= 0 {
+        return nil
+    }
+    return &chunkQuota{chunks: chunks, max: max, evict: evict}
+}
+
+// allow reports whether n more chunks fit within the quota, evicting old
+// chunks to make room for them if eviction is enabled. It also returns the
+// number of chunks evicted.
+func (q *chunkQuota) allow(n int) (bool, int, error) {
+    if q == nil {
+        return true, 0, nil
+    }
+
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    if time.Since(q.fetched) > quotaCountTTL {
+        count, err := q.chunks.Count()
+        if err != nil {
+            return false, 0, err
+        }
+        q.count, q.fetched = count, time.Now()
+    }
+
+    if q.count+n <= q.max {
+        return true, 0, nil
+    }
+    if !q.evict {
+        return false, 0, nil
+    }
+
+    deleted, err := q.chunks.DeleteOldest(q.count + n - q.max)
+    if err != nil {
+        return false, 0, err
+    }
+    q.count -= deleted
+    return q.count+n <= q.max, deleted, nil
+}
+
+// allowChunks checks the quota for n new chunks from this request and
+// records any evictions it caused in the audit log.
+func (app *application) allowChunks(r *http.Request, n int) (bool, error) {
+    ok, evicted, err := app.quota.allow(n)
+    if evicted > 0 {
+        app.audit(r, 0, auditChunksEvict, fmt.Sprintf("%d chunks", evicted))
+    }
+    return ok, err
+}
+
+// added records that n chunks have been created.
+func (q *chunkQuota) added(n int) {
+    if q == nil {
+        return
+    }
+
+    q.mu.Lock()
+    q.count += n
+    q.mu.Unlock()
+}
+
+// removed records that n chunks have been deleted.
+func (q *chunkQuota) removed(n int) {
+    q.added(-n)
+}
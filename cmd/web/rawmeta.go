@@ -0,0 +1,52 @@
+/*-----------------------------------------------------------
+ @Filename:         rawmeta.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "io"
+    "net/http"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// With ?meta=1 the raw content ends with a footer saying where it came
+// from, for scripts which keep what they download:
+//
+//  // https://example.com/chunkbox/view?id=abc (2024-01-01)
+//
+// It is a comment in the chunk's language (or the file's, for ?file=), see
+// highlight.CommentFor; plain text and languages it doesn't know get a "#"
+// comment. Without ?meta=1 the content is sent exactly as it was saved.
+func (app *application) rawMetaFooter(r *http.Request, chunk *models.Chunk, language string) string {
+    text := app.absoluteURL(r, "/chunkbox/view?id="+chunk.PublicID) + " (" + chunk.Created.UTC().Format("2006-01-02") + ")"
+    return highlight.CommentFor(language).Line(text) + "\n"
+}
+
+// A lastByteWriter remembers the last byte written through it, so the
+// footer can start on a line of its own.
+type lastByteWriter struct {
+    w    io.Writer
+    last byte
+}
+
+func (lw *lastByteWriter) Write(p []byte) (int, error) {
+    n, err := lw.w.Write(p)
+    if n > 0 {
+        lw.last = p[n-1]
+    }
+    return n, err
+}
+
+// writeFooter writes the footer after the content written so far, with a
+// line break before it unless the content ended with one.
+func (lw *lastByteWriter) writeFooter(footer string) error {
+    if lw.last != '\n' && lw.last != 0 {
+        footer = "\n" + footer
+    }
+    _, err := io.WriteString(lw.w, footer)
+    return err
+}
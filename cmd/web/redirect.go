@@ -0,0 +1,221 @@
+/*-----------------------------------------------------------
+ @Filename:         redirect.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "path"
+    "strings"
+    "unicode"
+)
+
+// acmeChallengePrefix is where ACME HTTP-01 challenges (Let's Encrypt) are
+// fetched from. They must be answered over plain HTTP, so they are never
+// redirected.
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// httpsRedirect returns the handler for the -http-redirect-addr listener. It
+// sends every request to the same host and path on the HTTPS listener at
+// httpsAddr with a 301 Moved Permanently.
+func (app *application) httpsRedirect(httpsAddr string) http.Handler {
+    // Keep the HTTPS port in the URL, unless it is the default one.
+    _, port, _ := net.SplitHostPort(httpsAddr)
+    if port == "443" {
+        port = ""
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // With -autotls-hosts autocert answers the challenges before they
+        // get here, so only unknown ones are left.
+        if strings.HasPrefix(r.URL.Path, acmeChallengePrefix) {
+            http.NotFound(w, r)
+            return
+        }
+
+        // Drop the port (and the brackets of an IPv6 address) from the Host.
+        host := strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+        if h, _, err := net.SplitHostPort(r.Host); err == nil {
+            host = h
+        }
+        if host == "" {
+            app.clientError(w, http.StatusBadRequest)
+            return
+        }
+        if port != "" {
+            host = net.JoinHostPort(host, port)
+        } else if strings.Contains(host, ":") {
+            // A bare IPv6 address needs its brackets back.
+            host = "[" + host + "]"
+        }
+
+        http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+    })
+}
+
+// canonicalHostExemptPaths are never redirected to the -canonical-host: the
+// monitoring endpoints are probed on whatever address reaches the server,
+// and ACME challenges must be answered on the host they were issued for.
+var canonicalHostExemptPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// canonicalHost redirects requests for any other host than the
+// -canonical-host to the same path and query there, so old domains and
+// www. all end up on one. GET and HEAD requests get a 301; other methods a
+// 308, which tells the client to repeat the request, body and all. Hosts in
+// -canonical-host-exempt are served as they are, on any port.
+func (app *application) canonicalHost(next http.Handler) http.Handler {
+    if app.canonicalHostName == "" {
+        return next
+    }
+
+    // Without a port in the flag, any port on the canonical host name is
+    // fine, so the redirect doesn't fight whatever proxy is in front.
+    _, _, err := net.SplitHostPort(app.canonicalHostName)
+    comparePort := err == nil
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        host := strings.ToLower(r.Host)
+        name := host
+        if h, _, err := net.SplitHostPort(host); err == nil {
+            name = h
+        }
+        name = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+        if !comparePort {
+            host = name
+        }
+        if host == app.canonicalHostName || app.canonicalHostExempt[name] || app.exemptFromCanonicalHost(r.URL.Path) {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        scheme := "http"
+        if r.TLS != nil {
+            scheme = "https"
+        }
+        status := http.StatusMovedPermanently
+        if r.Method != http.MethodGet && r.Method != http.MethodHead {
+            status = http.StatusPermanentRedirect
+        }
+        http.Redirect(w, r, scheme+"://"+app.canonicalHostName+r.URL.RequestURI(), status)
+    })
+}
+
+// exemptFromCanonicalHost reports whether a request path is one of the
+// canonicalHostExemptPaths (below -base-path) or an ACME challenge.
+func (app *application) exemptFromCanonicalHost(p string) bool {
+    if strings.HasPrefix(p, acmeChallengePrefix) {
+        return true
+    }
+    p = strings.TrimPrefix(p, app.basePath)
+    for _, exempt := range canonicalHostExemptPaths {
+        if p == exempt {
+            return true
+        }
+    }
+    return false
+}
+
+// parseCanonicalHost checks the -canonical-host flag, a host name with an
+// optional port, and returns it lowercased. A bare IPv6 address gets its
+// brackets.
+func parseCanonicalHost(s string) (string, error) {
+    s = strings.ToLower(strings.TrimSpace(s))
+    if s == "" {
+        return "", nil
+    }
+    if strings.Contains(s, "/") {
+        return "", fmt.Errorf("-canonical-host %q must be a host name, without a scheme or path", s)
+    }
+    if ip := net.ParseIP(s); ip != nil && strings.Contains(s, ":") {
+        return "[" + s + "]", nil
+    }
+    return s, nil
+}
+
+// parseHostList parses a comma-separated list of host names, as in
+// -canonical-host-exempt, into a set.
+func parseHostList(s string) map[string]bool {
+    hosts := make(map[string]bool)
+    for _, host := range strings.Split(s, ",") {
+        if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+            hosts[host] = true
+        }
+    }
+    return hosts
+}
+
+// localPath reports whether p is a path of this site which a redirect can
+// safely go to: below -base-path, and with nothing a browser could read as
+// another host ("//evil.com", "/\evil.com") or a scheme.
+func (app *application) localPath(p string) bool {
+    if !strings.HasPrefix(p, app.basePath+"/") || strings.HasPrefix(p, "//") || strings.Contains(p, "\\") {
+        return false
+    }
+    if strings.IndexFunc(p, unicode.IsControl) >= 0 {
+        return false
+    }
+    u, err := url.Parse(p)
+    return err == nil && u.Scheme == "" && u.Host == "" && u.User == nil
+}
+
+// safeNext returns next, the page to go back to after logging in (the
+// ?next= of the login and signup pages), if it is a local path and, with a
+// -redirect-allowlist, below one of its prefixes. Otherwise it returns "".
+// The prefixes are matched against the cleaned path, as browsers resolve
+// "/chunkbox/../admin" before following it.
+func (app *application) safeNext(next string) string {
+    if !app.localPath(next) {
+        return ""
+    }
+    if len(app.redirectAllowlist) == 0 {
+        return next
+    }
+    u, _ := url.Parse(next)
+    clean := path.Clean(strings.TrimPrefix(u.Path, app.basePath))
+    for _, prefix := range app.redirectAllowlist {
+        if strings.HasPrefix(clean+"/", prefix) {
+            return next
+        }
+    }
+    return ""
+}
+
+// safeRedirect sends the browser on to next if safeNext allows it, and to
+// the home page otherwise.
+func (app *application) safeRedirect(w http.ResponseWriter, r *http.Request, next string) {
+    target := app.safeNext(next)
+    if target == "" {
+        target = app.url("/")
+    }
+    http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// loginURL is the login page, coming back to next afterwards.
+func (app *application) loginURL(next string) string {
+    if next = app.safeNext(next); next == "" {
+        return app.url("/user/login")
+    }
+    return app.url("/user/login?next=" + url.QueryEscape(next))
+}
+
+// parseRedirectAllowlist checks the -redirect-allowlist flag, a
+// comma-separated list of path prefixes below -base-path.
+func parseRedirectAllowlist(s string) ([]string, error) {
+    var prefixes []string
+    for _, prefix := range strings.Split(s, ",") {
+        prefix = strings.TrimSpace(prefix)
+        if prefix == "" {
+            continue
+        }
+        if !strings.HasPrefix(prefix, "/") || strings.HasPrefix(prefix, "//") {
+            return nil, fmt.Errorf("-redirect-allowlist: %q must be a path starting with a single /", prefix)
+        }
+        prefixes = append(prefixes, prefix)
+    }
+    return prefixes, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestSafeNext(t *testing.T) {
+    tests := []struct {
+        name      string
+        basePath  string
+        allowlist []string
+        next      string
+        want      string
+    }{
+        {name: "local path", next: "/chunkbox/view?id=abc", want: "/chunkbox/view?id=abc"},
+        {name: "home", next: "/", want: "/"},
+        {name: "empty", next: ""},
+        {name: "protocol-relative", next: "//evil.com"},
+        {name: "protocol-relative with path", next: "//evil.com/chunkbox/"},
+        {name: "absolute", next: "https://evil.com"},
+        {name: "absolute to this site", next: "https://chunkbox.example/"},
+        {name: "backslash", next: `/\evil.com`},
+        {name: "backslashes", next: `\\evil.com`},
+        {name: "javascript", next: "javascript:alert(1)"},
+        {name: "relative", next: "evil.com"},
+        {name: "control character", next: "/\t/evil.com"},
+        {name: "newline", next: "/chunkbox/\r\nLocation: https://evil.com"},
+        {name: "userinfo", next: "/@evil.com", want: "/@evil.com"},
+        {name: "below base path", basePath: "/paste", next: "/paste/chunkbox/", want: "/paste/chunkbox/"},
+        {name: "outside base path", basePath: "/paste", next: "/chunkbox/"},
+        {name: "allowed prefix", allowlist: []string{"/chunkbox/", "/account/"}, next: "/account/view", want: "/account/view"},
+        {name: "not an allowed prefix", allowlist: []string{"/chunkbox/"}, next: "/admin/"},
+        {name: "dot-dot out of a prefix", allowlist: []string{"/chunkbox/"}, next: "/chunkbox/../admin/"},
+        {name: "allowed prefix below base path", basePath: "/paste", allowlist: []string{"/chunkbox/"}, next: "/paste/chunkbox/view", want: "/paste/chunkbox/view"},
+        {name: "unsafe with an allowlist", allowlist: []string{"/"}, next: "//evil.com"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            app := newTestApplication(t)
+            app.basePath = tt.basePath
+            app.redirectAllowlist = tt.allowlist
+            if got := app.safeNext(tt.next); got != tt.want {
+                t.Errorf("safeNext(%q) = %q, want %q", tt.next, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestSafeRedirect(t *testing.T) {
+    app := newTestApplication(t)
+    for next, want := range map[string]string{
+        "/chunkbox/view?id=abc": "/chunkbox/view?id=abc",
+        "//evil.com":            "/",
+        "https://evil.com":      "/",
+        `/\evil.com`:            "/",
+    } {
+        rr := httptest.NewRecorder()
+        app.safeRedirect(rr, httptest.NewRequest(http.MethodPost, "/user/login", nil), next)
+        if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != want {
+            t.Errorf("safeRedirect to %q: %d to %q, want %d to %q", next, rr.Code, rr.Header().Get("Location"), http.StatusSeeOther, want)
+        }
+    }
+
+    for next, want := range map[string]string{
+        "/chunkbox/view?id=abc": "/user/login?next=%2Fchunkbox%2Fview%3Fid%3Dabc",
+        "//evil.com":            "/user/login",
+    } {
+        if got := app.loginURL(next); got != want {
+            t.Errorf("loginURL(%q) = %q, want %q", next, got, want)
+        }
+    }
+}
+
+func TestParseRedirectAllowlist(t *testing.T) {
+    prefixes, err := parseRedirectAllowlist(" /chunkbox/, ,/account/ ")
+    if err != nil || len(prefixes) != 2 || prefixes[0] != "/chunkbox/" || prefixes[1] != "/account/" {
+        t.Errorf("got %q, %v", prefixes, err)
+    }
+    for _, s := range []string{"chunkbox/", "//evil.com/", "https://evil.com/"} {
+        if _, err := parseRedirectAllowlist(s); err == nil {
+            t.Errorf("no error for %q", s)
+        }
+    }
+}
@@ -0,0 +1,97 @@
+/*-----------------------------------------------------------
+ @Filename:         related.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "sync"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// relatedCacheMax is the number of chunks whose related chunks are kept
+// before expired entries are cleared out, so the cache of a busy server
+// doesn't keep growing until the entries expire.
+const relatedCacheMax = 10000
+
+// A relatedChunks finds the related chunks shown beside a chunk on its view
+// page (-related-chunks). The query joins the tags of every chunk, so its
+// results are reused for -related-cache-ttl: a list that is a minute old is
+// good enough for suggestions. A nil *relatedChunks shows none.
+type relatedChunks struct {
+    chunks models.ChunkStore
+    limit  int
+    ttl    time.Duration
+
+    mu      sync.Mutex
+    entries map[int]relatedEntry
+}
+
+type relatedEntry struct {
+    chunks  []*models.Chunk
+    expires time.Time
+}
+
+func newRelatedChunks(chunks models.ChunkStore, limit int, ttl time.Duration) *relatedChunks {
+    if limit <= 0 {
+        return nil
+    }
+    return &relatedChunks{chunks: chunks, limit: limit, ttl: ttl, entries: make(map[int]relatedEntry)}
+}
+
+// get returns the chunks related to a chunk, from the cache if it has them.
+func (r *relatedChunks) get(chunk *models.Chunk) ([]*models.Chunk, error) {
+    if r == nil {
+        return nil, nil
+    }
+
+    now := time.Now()
+    r.mu.Lock()
+    entry, ok := r.entries[chunk.ID]
+    r.mu.Unlock()
+    if ok && now.Before(entry.expires) {
+        return entry.chunks, nil
+    }
+
+    // The lock isn't held over the query, so chunks viewed at the same time
+    // don't wait for each other. Two views of the same chunk may both run
+    // it, which is harmless.
+    related, err := r.chunks.Related(chunk.ID, r.limit)
+    if err != nil {
+        return nil, err
+    }
+    if r.ttl <= 0 {
+        return related, nil
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if len(r.entries) >= relatedCacheMax {
+        for id, e := range r.entries {
+            if !now.Before(e.expires) {
+                delete(r.entries, id)
+            }
+        }
+        // Everything is still fresh: start over rather than grow.
+        if len(r.entries) >= relatedCacheMax {
+            r.entries = make(map[int]relatedEntry)
+        }
+    }
+    r.entries[chunk.ID] = relatedEntry{chunks: related, expires: now.Add(r.ttl)}
+    return related, nil
+}
+
+// forget drops the cached related chunks of a chunk, for when its tags or
+// language change or it is deleted. Other chunks may still list it until
+// their entries expire; a deleted one is then simply not found.
+func (r *relatedChunks) forget(chunk *models.Chunk) {
+    if r == nil {
+        return
+    }
+    r.mu.Lock()
+    delete(r.entries, chunk.ID)
+    r.mu.Unlock()
+}
@@ -0,0 +1,189 @@
+/*-----------------------------------------------------------
+ @Filename:         reload.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/cpucortexm/chunkbox/internal/blocklist"
+    "github.com/gomodule/redigo/redis"
+)
+
+// Flags can also be set in a -config file, one per line as
+//
+//  rate-limit = 30
+//  banner = Maintenance tonight from 22:00 UTC
+//
+// with blank lines and lines starting with # ignored. The command line wins
+// over the file. On SIGHUP the file is read again and the flags below are
+// changed in the running server; the others only take effect on the next
+// start. The files named by the reloadable flags (the blocklist and the
+// legal pages) are reread on SIGHUP too, with or without a -config file,
+// and the -access-log file is reopened.
+var reloadableFlags = map[string]bool{
+    "banner":         true,
+    "banner-level":   true,
+    "blocklist-file": true,
+    "rate-limit":     true,
+    "rate-burst":     true,
+    "terms-file":     true,
+    "privacy-file":   true,
+}
+
+// readConfigFile reads the flag values of a -config file. Unknown flags are
+// an error, so a typo doesn't go unnoticed.
+func readConfigFile(path string) (map[string]string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    values := map[string]string{}
+    scanner := bufio.NewScanner(f)
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        name, value, ok := strings.Cut(line, "=")
+        if !ok {
+            return nil, fmt.Errorf("%s:%d: expected name = value", path, n)
+        }
+        name = strings.TrimLeft(strings.TrimSpace(name), "-")
+        if name == "config" || flag.Lookup(name) == nil {
+            return nil, fmt.Errorf("%s:%d: unknown flag %q", path, n, name)
+        }
+        values[name] = strings.TrimSpace(value)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+    return values, nil
+}
+
+// A configReloader rereads the configuration on SIGHUP.
+type configReloader struct {
+    // path is the -config file, if there is one.
+    path string
+    // commandLine are the flags given on the command line, which the file
+    // doesn't change.
+    commandLine map[string]bool
+    // loaded are the values the file had at startup, to tell which of the
+    // flags which aren't reloadable someone changed.
+    loaded map[string]string
+    // redisPool is where the rate limiter keeps its counters, if anywhere.
+    redisPool *redis.Pool
+}
+
+// newConfigReloader applies the -config file, if there is one, to the
+// flags which weren't given on the command line. It must be called right
+// after flag.Parse. The redisPool is filled in once there is one.
+func newConfigReloader(path string) (*configReloader, error) {
+    c := &configReloader{path: path, commandLine: map[string]bool{}, loaded: map[string]string{}}
+    flag.Visit(func(f *flag.Flag) { c.commandLine[f.Name] = true })
+    if path == "" {
+        return c, nil
+    }
+
+    values, err := readConfigFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("-config: %w", err)
+    }
+    for name, value := range values {
+        if c.commandLine[name] {
+            continue
+        }
+        if err := flag.Set(name, value); err != nil {
+            return nil, fmt.Errorf("-config: -%s: %w", name, err)
+        }
+    }
+    c.loaded = values
+    return c, nil
+}
+
+// value returns what a reloadable flag is to be after a reload with the
+// file's values: the command line's, the file's or the default, in that
+// order.
+func (c *configReloader) value(values map[string]string, name string) string {
+    f := flag.Lookup(name)
+    if c.commandLine[name] {
+        return f.Value.String()
+    }
+    if v, ok := values[name]; ok {
+        return v
+    }
+    return f.DefValue
+}
+
+// reloadConfig rereads the -config file and the files it names and swaps
+// the reloadable settings in. Everything is checked before anything
+// changes, so a mistake anywhere leaves the server as it was.
+func (app *application) reloadConfig() error {
+    c := app.config
+    values := map[string]string{}
+    if c.path != "" {
+        var err error
+        values, err = readConfigFile(c.path)
+        if err != nil {
+            return fmt.Errorf("-config: %w", err)
+        }
+    }
+
+    rate, err := strconv.ParseFloat(c.value(values, "rate-limit"), 64)
+    if err != nil || rate < 0 {
+        return errors.New("-rate-limit must be a number, and not negative")
+    }
+    burst, err := strconv.Atoi(c.value(values, "rate-burst"))
+    if err != nil || burst < 1 {
+        return errors.New("-rate-burst must be a whole number, at least 1")
+    }
+    level := c.value(values, "banner-level")
+    if level != bannerInfo && level != bannerWarning {
+        return fmt.Errorf("unknown -banner-level %q (choose info or warning)", level)
+    }
+    var list *blocklist.Blocklist
+    if path := c.value(values, "blocklist-file"); path != "" {
+        if list, err = blocklist.Load(path); err != nil {
+            return err
+        }
+    }
+    // The legal pages go last, as loading them swaps them in.
+    if err := app.legal.load(c.value(values, "terms-file"), c.value(values, "privacy-file")); err != nil {
+        return err
+    }
+
+    app.blocklist.Store(list)
+    app.limiter.Store(newRateLimiter(rate, burst, c.redisPool))
+    app.banner.setDefaults(banner{Message: strings.TrimSpace(c.value(values, "banner")), Level: level})
+    // The cached pages have the old banner and footer.
+    app.responseCache.clear()
+
+    for name := range mergeKeys(values, c.loaded) {
+        if !reloadableFlags[name] && !c.commandLine[name] && values[name] != c.loaded[name] {
+            app.infoLog.Printf("-%s changed in %s, which needs a restart", name, c.path)
+        }
+    }
+    return nil
+}
+
+// mergeKeys returns the keys of both maps.
+func mergeKeys(a, b map[string]string) map[string]bool {
+    keys := make(map[string]bool, len(a)+len(b))
+    for k := range a {
+        keys[k] = true
+    }
+    for k := range b {
+        keys[k] = true
+    }
+    return keys
+}
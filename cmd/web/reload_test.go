@@ -0,0 +1,133 @@
+package main
+
+import (
+    "flag"
+    "os"
+    "path/filepath"
+    "sync"
+    "sync/atomic"
+    "testing"
+)
+
+var defineReloadableOnce sync.Once
+
+// defineReloadableFlags defines the reloadable flags, with the defaults of
+// main, which reloadConfig looks up.
+func defineReloadableFlags() {
+    defineReloadableOnce.Do(func() {
+        flag.String("banner", "", "")
+        flag.String("banner-level", bannerInfo, "")
+        flag.String("blocklist-file", "", "")
+        flag.Float64("rate-limit", 60, "")
+        flag.Int("rate-burst", 10, "")
+        flag.String("terms-file", "", "")
+        flag.String("privacy-file", "", "")
+    })
+}
+
+// writeFile writes a file in dir and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+    t.Helper()
+
+    path := filepath.Join(dir, name)
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestReloadConfigConcurrentReads(t *testing.T) {
+    defineReloadableFlags()
+    app := newTestApplication(t)
+    dir := t.TempDir()
+    spam := writeFile(t, dir, "spam.txt", "spam\n")
+    ham := writeFile(t, dir, "ham.txt", "ham\n")
+    configs := []string{
+        writeFile(t, dir, "spam.conf", "blocklist-file = "+spam+"\nbanner = Spam\nrate-limit = 30\n"),
+        writeFile(t, dir, "ham.conf", "blocklist-file = "+ham+"\nbanner = Ham\nrate-limit = 0\n"),
+    }
+    app.config = &configReloader{path: configs[0], commandLine: map[string]bool{}, loaded: map[string]string{}}
+    if err := app.reloadConfig(); err != nil {
+        t.Fatal(err)
+    }
+
+    // Readers check that what they see is always one whole configuration
+    // or the other, while it is swapped under them.
+    var stop atomic.Bool
+    var wg sync.WaitGroup
+    errs := make(chan string, 8)
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for !stop.Load() {
+                list := app.blocklist.Load()
+                if list == nil || list.Matches("spam") == list.Matches("ham") {
+                    errs <- "a blocklist matching neither or both"
+                    return
+                }
+                if b := app.banner.get(); b.Message != "Spam" && b.Message != "Ham" {
+                    errs <- "banner " + b.Message
+                    return
+                }
+                app.limiter.Load()
+            }
+        }()
+    }
+
+    for i := 0; i < 200; i++ {
+        app.config.path = configs[i%2]
+        if err := app.reloadConfig(); err != nil {
+            t.Error(err)
+            break
+        }
+    }
+    stop.Store(true)
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Error(err)
+    }
+
+    // The last reload was of ham.conf.
+    if list := app.blocklist.Load(); !list.Matches("ham") || list.Matches("spam") {
+        t.Error("the blocklist isn't ham.txt")
+    }
+    if b := app.banner.get(); b.Message != "Ham" {
+        t.Errorf("banner %q, want Ham", b.Message)
+    }
+    if app.limiter.Load() != nil {
+        t.Error("-rate-limit = 0 left a limiter")
+    }
+}
+
+func TestReloadConfigMistake(t *testing.T) {
+    defineReloadableFlags()
+    app := newTestApplication(t)
+    dir := t.TempDir()
+    spam := writeFile(t, dir, "spam.txt", "spam\n")
+    config := writeFile(t, dir, "chunkbox.conf", "blocklist-file = "+spam+"\nbanner = Before\n")
+    app.config = &configReloader{path: config, commandLine: map[string]bool{}, loaded: map[string]string{}}
+    if err := app.reloadConfig(); err != nil {
+        t.Fatal(err)
+    }
+
+    // A mistake anywhere leaves everything as it was.
+    for _, content := range []string{
+        "blocklist-file = " + filepath.Join(dir, "missing.txt") + "\nbanner = After\n",
+        "banner = After\nrate-limit = fast\n",
+        "banner = After\nbanner-level = loud\n",
+        "no-such-flag = 1\n",
+    } {
+        writeFile(t, dir, "chunkbox.conf", content)
+        if err := app.reloadConfig(); err == nil {
+            t.Errorf("no error reloading %q", content)
+        }
+        if b := app.banner.get(); b.Message != "Before" {
+            t.Errorf("banner %q after reloading %q", b.Message, content)
+        }
+        if list := app.blocklist.Load(); list == nil || !list.Matches("spam") {
+            t.Errorf("blocklist changed after reloading %q", content)
+        }
+    }
+}
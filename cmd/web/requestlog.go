@@ -0,0 +1,197 @@
+/*-----------------------------------------------------------
+ @Filename:         requestlog.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// The formats of the -access-log: the Common and Combined Log Formats of
+// Apache and nginx, or one JSON object per line.
+const (
+    requestLogCommon   = "common"
+    requestLogCombined = "combined"
+    requestLogJSON     = "json"
+)
+
+// A requestLog writes a line for every request to the -access-log, apart
+// from the info log. Without -access-log the requests are logged to the
+// info log as before, and with -access-log=off not at all. A file is
+// opened for appending and reopened on SIGHUP, so logrotate can move it
+// away (without copytruncate).
+type requestLog struct {
+    format string
+    off    bool
+    // path is the file written to, or "" for stdout and stderr.
+    path string
+
+    mu   sync.Mutex
+    out  io.Writer
+    file *os.File
+}
+
+// newRequestLog returns the log for the -access-log destination, or nil
+// when there is none and the info log is to be used.
+func newRequestLog(dest, format string) (*requestLog, error) {
+    switch format {
+    case requestLogCommon, requestLogCombined, requestLogJSON:
+    default:
+        return nil, fmt.Errorf("unknown -access-log-format %q (choose common, combined or json)", format)
+    }
+
+    l := &requestLog{format: format}
+    switch dest {
+    case "":
+        return nil, nil
+    case "off":
+        l.off = true
+    case "stdout":
+        l.out = os.Stdout
+    case "stderr":
+        l.out = os.Stderr
+    default:
+        l.path = dest
+        if err := l.reopen(); err != nil {
+            return nil, err
+        }
+    }
+    return l, nil
+}
+
+// reopen opens the file again, for after it was rotated. The old file is
+// only closed once the new one is open, so no line is lost.
+func (l *requestLog) reopen() error {
+    if l.path == "" {
+        return nil
+    }
+    f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+    if err != nil {
+        return fmt.Errorf("-access-log: %w", err)
+    }
+
+    l.mu.Lock()
+    old := l.file
+    l.file, l.out = f, f
+    l.mu.Unlock()
+
+    if old != nil {
+        return old.Close()
+    }
+    return nil
+}
+
+// log writes the line for a request which got the status and that many
+// bytes of body, after taking the time since start.
+func (l *requestLog) log(r *http.Request, remoteAddr string, status int, size int64, start time.Time) {
+    var line []byte
+    if l.format == requestLogJSON {
+        line, _ = json.Marshal(struct {
+            Time       string  `json:"time"`
+            RemoteAddr string  `json:"remote_addr"`
+            Method     string  `json:"method"`
+            URI        string  `json:"uri"`
+            Proto      string  `json:"proto"`
+            Status     int     `json:"status"`
+            Bytes      int64   `json:"bytes"`
+            DurationMS float64 `json:"duration_ms"`
+            Referer    string  `json:"referer"`
+            UserAgent  string  `json:"user_agent"`
+        }{
+            Time:       start.UTC().Format(time.RFC3339Nano),
+            RemoteAddr: remoteAddr,
+            Method:     r.Method,
+            URI:        r.URL.RequestURI(),
+            Proto:      r.Proto,
+            Status:     status,
+            Bytes:      size,
+            DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+            Referer:    r.Referer(),
+            UserAgent:  r.UserAgent(),
+        })
+    } else {
+        // host ident authuser [date] "request" status bytes, and then
+        // "referer" "user-agent" for the combined format.
+        bytes := "-"
+        if size > 0 {
+            bytes = strconv.FormatInt(size, 10)
+        }
+        s := fmt.Sprintf(`%s - - [%s] "%s" %d %s`, remoteAddr, start.Format("02/Jan/2006:15:04:05 -0700"),
+            logEscape(r.Method+" "+r.URL.RequestURI()+" "+r.Proto), status, bytes)
+        if l.format == requestLogCombined {
+            s += fmt.Sprintf(` "%s" "%s"`, logEscape(orDash(r.Referer())), logEscape(orDash(r.UserAgent())))
+        }
+        line = []byte(s)
+    }
+    line = append(line, '\n')
+
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.out.Write(line)
+}
+
+// logEscape escapes quotes, backslashes and unprintable bytes the way
+// Apache does, so a client can't break a line of the log apart.
+func logEscape(s string) string {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case c == '"' || c == '\\':
+            b.WriteByte('\\')
+            b.WriteByte(c)
+        case c < 0x20 || c >= 0x7f:
+            fmt.Fprintf(&b, `\x%02x`, c)
+        default:
+            b.WriteByte(c)
+        }
+    }
+    return b.String()
+}
+
+// orDash returns s, or "-" for an empty header, like Apache logs it.
+func orDash(s string) string {
+    if s == "" {
+        return "-"
+    }
+    return s
+}
+
+// A statusWriter notes the status and the size of the body of a response
+// for the request log.
+type statusWriter struct {
+    http.ResponseWriter
+    status int
+    size   int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+    if sw.status == 0 {
+        sw.status = status
+    }
+    sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+    if sw.status == 0 {
+        sw.status = http.StatusOK
+    }
+    n, err := sw.ResponseWriter.Write(b)
+    sw.size += int64(n)
+    return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection's writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+    return sw.ResponseWriter
+}
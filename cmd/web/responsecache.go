@@ -0,0 +1,272 @@
+/*-----------------------------------------------------------
+ @Filename:         responsecache.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "bytes"
+    "container/list"
+    "context"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// A responseCache keeps whole chunk view pages for anonymous visitors, so a
+// chunk linked from somewhere busy is rendered once every -response-cache-ttl
+// rather than on every view. Like the highlight cache it is bounded by the
+// size of the entries in bytes (-response-cache-size), evicting the least
+// recently used ones when full. Entries are indexed by the public ID of the
+// chunk shown, so editing or deleting the chunk drops its pages at once; the
+// TTL catches everything else which shows on the page, such as the related
+// chunks. A nil *responseCache (the default) caches nothing.
+type responseCache struct {
+    maxBytes int
+    ttl      time.Duration
+
+    mu   sync.Mutex
+    size int
+    // order holds the entries with the most recently used at the front.
+    order   *list.List
+    entries map[string]*list.Element
+    // byChunk holds the keys of the entries for each chunk.
+    byChunk map[string]map[string]bool
+}
+
+// csrfField is in the pages with a form, which can't be cached.
+var csrfField = []byte("name='csrf_token'")
+
+// viewedChunkContextKey holds where chunkView puts the database id of the
+// chunk it shows, so the views of its cached page can be counted.
+const viewedChunkContextKey = contextKey("viewedChunk")
+
+// A cachedResponse is one page in the responseCache. chunkID is the public
+// ID of the chunk shown, and viewID its database id, for counting views.
+type cachedResponse struct {
+    key     string
+    chunkID string
+    viewID  int
+    status  int
+    header  http.Header
+    body    []byte
+    expires time.Time
+}
+
+// size is the number of bytes an entry counts for: its key and body, and
+// its headers roughly.
+func (c *cachedResponse) size() int {
+    n := len(c.key) + len(c.body)
+    for name, values := range c.header {
+        for _, v := range values {
+            n += len(name) + len(v)
+        }
+    }
+    return n
+}
+
+func newResponseCache(maxBytes int, ttl time.Duration) *responseCache {
+    if maxBytes <= 0 || ttl <= 0 {
+        return nil
+    }
+    return &responseCache{
+        maxBytes: maxBytes,
+        ttl:      ttl,
+        order:    list.New(),
+        entries:  make(map[string]*list.Element),
+        byChunk:  make(map[string]map[string]bool),
+    }
+}
+
+// get returns the page cached under key, unless there is none or it has
+// expired.
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    e, ok := c.entries[key]
+    if !ok {
+        return nil, false
+    }
+    entry := e.Value.(*cachedResponse)
+    if !time.Now().Before(entry.expires) {
+        c.remove(e)
+        return nil, false
+    }
+    c.order.MoveToFront(e)
+    return entry, true
+}
+
+// add caches a page, evicting the least recently used entries to make room
+// for it. A page bigger than the whole cache isn't cached at all.
+func (c *responseCache) add(entry *cachedResponse) {
+    size := entry.size()
+    if size > c.maxBytes {
+        return
+    }
+    entry.expires = time.Now().Add(c.ttl)
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if e, ok := c.entries[entry.key]; ok {
+        c.remove(e)
+    }
+    c.entries[entry.key] = c.order.PushFront(entry)
+    if c.byChunk[entry.chunkID] == nil {
+        c.byChunk[entry.chunkID] = make(map[string]bool)
+    }
+    c.byChunk[entry.chunkID][entry.key] = true
+    c.size += size
+    for c.size > c.maxBytes {
+        c.remove(c.order.Back())
+    }
+}
+
+// forget drops the cached pages of a chunk, for when it is edited, deleted,
+// commented on or starred.
+func (c *responseCache) forget(publicID string) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    for key := range c.byChunk[publicID] {
+        c.remove(c.entries[key])
+    }
+}
+
+// clear drops every cached page, for changes which can't be pinned down to
+// some chunks, like a bulk delete.
+func (c *responseCache) clear() {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.order.Init()
+    c.entries = make(map[string]*list.Element)
+    c.byChunk = make(map[string]map[string]bool)
+    c.size = 0
+}
+
+// remove takes an entry out of the cache. The caller must hold c.mu.
+func (c *responseCache) remove(e *list.Element) {
+    entry := c.order.Remove(e).(*cachedResponse)
+    delete(c.entries, entry.key)
+    delete(c.byChunk[entry.chunkID], entry.key)
+    if len(c.byChunk[entry.chunkID]) == 0 {
+        delete(c.byChunk, entry.chunkID)
+    }
+    c.size -= entry.size()
+}
+
+// cacheResponses serves chunk view pages to anonymous visitors from the
+// responseCache, and caches the pages it renders for them. It runs before
+// the session is loaded, so a hit costs no database queries, other than
+// writing its view with a -view-flush-interval of 0.
+//
+// Only GET requests without a session cookie are cached: whatever is in a
+// session, a login, a flash message or the edit link of a chunk just
+// created, changes the page. Nor are pages with a form, like the Dismiss
+// button of the banner: the visitor's CSRF token is in them, and would be
+// wrong for everybody else. Pages are keyed by URL and Accept header,
+// and only 200 responses without "Cache-Control: no-store" are kept.
+// Set-Cookie headers are never replayed from the cache. A hit still counts
+// as a view of the chunk.
+func (app *application) cacheResponses(next http.Handler) http.Handler {
+    if app.responseCache == nil {
+        return next
+    }
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.URL.Query().Get("id")
+        if r.Method != http.MethodGet || id == "" || r.Header.Get("Authorization") != "" {
+            next.ServeHTTP(w, r)
+            return
+        }
+        // A visitor who dismissed the banner sees the page without it.
+        if _, err := r.Cookie(bannerCookie); err == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+        if _, err := r.Cookie(app.sessionManager.Cookie.Name); err == nil {
+            next.ServeHTTP(w, r)
+            return
+        }
+
+        key := r.URL.RequestURI() + "\n" + r.Header.Get("Accept")
+        if entry, ok := app.responseCache.get(key); ok {
+            app.metrics.responseCacheHits.Add(1)
+            if _, err := app.countView(entry.viewID); err != nil {
+                app.errorLog.Printf("counting a view of chunk %d: %v", entry.viewID, err)
+            }
+            for name, values := range entry.header {
+                w.Header()[name] = values
+            }
+            w.WriteHeader(entry.status)
+            w.Write(entry.body)
+            return
+        }
+        app.metrics.responseCacheMisses.Add(1)
+
+        // A page which didn't count a view isn't a chunk's, and isn't kept.
+        var viewID int
+        r = r.WithContext(context.WithValue(r.Context(), viewedChunkContextKey, &viewID))
+        rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK, limit: app.responseCache.maxBytes}
+        next.ServeHTTP(rec, r)
+        if rec.status != http.StatusOK || rec.overflow || viewID == 0 || hasNoStore(w.Header()) || bytes.Contains(rec.body.Bytes(), csrfField) {
+            return
+        }
+        header := w.Header().Clone()
+        header.Del("Set-Cookie")
+        app.responseCache.add(&cachedResponse{key: key, chunkID: id, viewID: viewID, status: rec.status, header: header, body: rec.body.Bytes()})
+    })
+}
+
+// hasNoStore reports whether a response may not be stored by caches.
+func hasNoStore(h http.Header) bool {
+    for _, v := range h.Values("Cache-Control") {
+        for _, directive := range strings.Split(v, ",") {
+            if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// A responseRecorder passes a response through to the client, and keeps a
+// copy of its status and up to limit bytes of its body for the cache.
+type responseRecorder struct {
+    http.ResponseWriter
+    status      int
+    wroteHeader bool
+    body        bytes.Buffer
+    limit       int
+    overflow    bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+    if !rec.wroteHeader {
+        rec.status = status
+        rec.wroteHeader = true
+    }
+    rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+    rec.wroteHeader = true
+    if !rec.overflow {
+        if rec.body.Len()+len(b) > rec.limit {
+            rec.overflow = true
+            rec.body = bytes.Buffer{}
+        } else {
+            rec.body.Write(b)
+        }
+    }
+    return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+)
+
+func TestResponseCacheTTL(t *testing.T) {
+    c := newResponseCache(1<<20, time.Minute)
+    entry := &cachedResponse{key: "a", chunkID: "x", status: http.StatusOK, body: []byte("page")}
+    c.add(entry)
+
+    if _, ok := c.get("a"); !ok {
+        t.Fatal("fresh entry not found")
+    }
+    entry.expires = time.Now().Add(-time.Second)
+    if _, ok := c.get("a"); ok {
+        t.Fatal("expired entry still served")
+    }
+    if c.size != 0 || len(c.entries) != 0 || len(c.byChunk) != 0 {
+        t.Errorf("expired entry not removed: size %d, %d entries, %d chunks", c.size, len(c.entries), len(c.byChunk))
+    }
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+    page := []byte(strings.Repeat("x", 100))
+    // Room for two entries of about 101 bytes, not three.
+    c := newResponseCache(250, time.Minute)
+    c.add(&cachedResponse{key: "a", chunkID: "x", body: page})
+    c.add(&cachedResponse{key: "b", chunkID: "y", body: page})
+    c.get("a")
+    c.add(&cachedResponse{key: "c", chunkID: "z", body: page})
+
+    for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+        if _, ok := c.get(key); ok != want {
+            t.Errorf("entry %s cached = %t, want %t", key, ok, want)
+        }
+    }
+
+    c.add(&cachedResponse{key: "big", chunkID: "x", body: make([]byte, 300)})
+    if _, ok := c.get("big"); ok {
+        t.Error("entry bigger than the cache was cached")
+    }
+}
+
+func TestResponseCacheInvalidation(t *testing.T) {
+    c := newResponseCache(1<<20, time.Minute)
+    c.add(&cachedResponse{key: "x1", chunkID: "x", body: []byte("page")})
+    c.add(&cachedResponse{key: "x2", chunkID: "x", body: []byte("page")})
+    c.add(&cachedResponse{key: "y1", chunkID: "y", body: []byte("page")})
+
+    c.forget("x")
+    for key, want := range map[string]bool{"x1": false, "x2": false, "y1": true} {
+        if _, ok := c.get(key); ok != want {
+            t.Errorf("after forget, entry %s cached = %t, want %t", key, ok, want)
+        }
+    }
+
+    c.clear()
+    if _, ok := c.get("y1"); ok || c.size != 0 {
+        t.Error("clear left entries behind")
+    }
+
+    // A nil cache is off, and forgetting in it does nothing.
+    var off *responseCache
+    off.forget("x")
+    off.clear()
+}
+
+func TestCacheResponses(t *testing.T) {
+    // cached views a chunk twice with the request changed by modify, and
+    // reports whether the second view was a cache hit.
+    cached := func(t *testing.T, keepAlive bool, setup func(app *application), modify func(r *http.Request)) bool {
+        app := newTestApplication(t)
+        app.responseCache = newResponseCache(1<<20, time.Hour)
+        if setup != nil {
+            setup(app)
+        }
+        ts := newTestServer(t, app.routes())
+        // The session cookie would keep every page out of the cache.
+        ts.Client().Jar = nil
+        id, err := app.chunks.Insert("Cached", "content", 7, highlight.PlainText, 0, false, keepAlive, false, nil, nil, "")
+        if err != nil {
+            t.Fatal(err)
+        }
+
+        for i := 0; i < 2; i++ {
+            req := ts.request(t, http.MethodGet, "/chunkbox/view?id="+id, nil)
+            if modify != nil {
+                modify(req)
+            }
+            resp, _ := ts.do(t, req)
+            if resp.StatusCode != http.StatusOK {
+                t.Fatalf("status %d", resp.StatusCode)
+            }
+        }
+        return app.metrics.responseCacheHits.Load() > 0
+    }
+
+    tests := []struct {
+        name      string
+        keepAlive bool
+        setup     func(app *application)
+        modify    func(r *http.Request)
+        want      bool
+    }{
+        {name: "anonymous", want: true},
+        {
+            name:   "session cookie",
+            modify: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: "token"}) },
+        },
+        {
+            name:   "banner dismissed",
+            modify: func(r *http.Request) { r.AddCookie(&http.Cookie{Name: bannerCookie, Value: "1"}) },
+        },
+        {
+            name:   "authorization",
+            modify: func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") },
+        },
+        {
+            // The Dismiss form has the first visitor's CSRF token.
+            name: "banner shown",
+            setup: func(app *application) {
+                app.banner.current = banner{Message: "Maintenance tonight", Level: bannerInfo}
+            },
+        },
+        {
+            // Every view has to extend it.
+            name:      "keep-alive chunk",
+            keepAlive: true,
+            setup:     func(app *application) { app.extendOnView = time.Hour },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := cached(t, tt.keepAlive, tt.setup, tt.modify); got != tt.want {
+                t.Errorf("cached = %t, want %t", got, tt.want)
+            }
+        })
+    }
+}
@@ -5,10 +5,16 @@
 -------------------------------------------------------------*/
 package main
 
-import "net/http"
-// The routes() method returns a servemux containing our application routes.
+import (
+    "net/http"
 
-func (app *application) routes() *http.ServeMux{
+    "github.com/justinas/alice"
+)
+
+// The routes() method returns a handler containing our application routes,
+// wrapped in the middleware that runs for every request.
+
+func (app *application) routes() http.Handler {
 
     // Initialise new server mux and register a home function
     // as handler for the "/" URL pattern
@@ -23,10 +29,135 @@ func (app *application) routes() *http.ServeMux{
     // all URL paths that start with "/static/". For matching paths, we strip the
     // "/static" prefix before the request reaches the file server.
     mux.Handle("/static/", http.StripPrefix("/static", fileServer))
+    // The favicon is served by its own handler so it can be replaced with the
+    // -favicon-path flag.
+    mux.HandleFunc("/favicon.ico", app.favicon)
+    // The highlighting stylesheet is generated from the -highlight-theme at
+    // startup. The more specific pattern wins over "/static/".
+    mux.HandleFunc("/static/highlight.css", app.highlightCSS)
+
+    // The middleware is composed into chains with alice. A chain runs its
+    // middleware in the order given, the first one outermost, so each one
+    // can rely on everything listed before it having run:
+    //
+    //   - dynamic is for the stateful application routes: the
+    //     -read-allowlist and rate limiting come first, so refused
+    //     requests never touch the session store; then the session is
+    //     loaded (and saved after the handler), which noSurf's CSRF check
+    //     and authenticate both need.
+    //   - protected adds requireAuthentication after dynamic, once the
+    //     authentication status is known; admin adds requireAdmin after
+    //     that.
+    //   - cachedView is dynamic with cacheResponses between the rate
+    //     limiting and the session, so anonymous views served from the
+    //     -response-cache-size cache don't touch the session store either.
+    //   - api is dynamic without the CSRF check: readJSON insists on a JSON
+    //     Content-Type, which a cross-site form can't send. It still loads
+    //     the session so logged-in users own the chunks they create, and
+    //     the admin endpoints add the same checks as admin.
+    //
+    // Static files, the favicon and the health and version endpoints are
+    // registered without any of these.
+    dynamic := alice.New(app.allowIPs(app.readAllowlist), app.rateLimit, app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+    cachedView := alice.New(app.allowIPs(app.readAllowlist), app.rateLimit, app.cacheResponses, app.sessionManager.LoadAndSave, noSurf, app.authenticate)
+    protected := dynamic.Append(app.requireAuthentication)
+    admin := protected.Append(app.requireAdmin)
+    api := alice.New(app.allowIPs(app.readAllowlist), app.rateLimit, app.sessionManager.LoadAndSave, app.authenticate)
+
+    // Routes which create chunks can further be limited to some networks
+    // (-create-allowlist). That check comes first, before the read one.
+    // IPs blocked for abuse (-abuse-threshold) are refused next. Requests
+    // over the rate limit may then wait in the create queue.
+    creating := alice.New(app.allowIPs(app.createAllowlist), app.blockAbusers, app.queueCreates)
+
+    mux.Handle("/", dynamic.ThenFunc(app.home))
+    mux.Handle("/chunkbox/view", cachedView.ThenFunc(app.chunkView))
+    mux.Handle("/chunkbox/search", dynamic.ThenFunc(app.chunkSearch))
+    // Editing and deleting are for the chunk's owner or whoever has its
+    // edit token, so they aren't limited to logged-in users.
+    mux.Handle("/chunkbox/edit", dynamic.ThenFunc(app.chunkEdit))
+    mux.Handle("/chunkbox/delete", dynamic.ThenFunc(app.chunkDeletePost))
+    mux.Handle("/chunkbox/create", creating.Extend(dynamic).ThenFunc(app.chunkCreate))
+    // The raw and download endpoints need the session to check whether the
+    // user may see a private chunk.
+    mux.Handle("/chunkbox/raw", dynamic.ThenFunc(app.chunkRaw))
+    mux.Handle("/chunkbox/download", dynamic.ThenFunc(app.chunkDownload))
+    // Chunks as gists, for tools which read GitHub's gist JSON, their link
+    // preview images and their badges. The mux can't match the suffixes, so
+    // chunkPath parses the path itself.
+    mux.Handle("/chunk/", dynamic.ThenFunc(app.chunkPath))
+    mux.Handle("/banner/dismiss", dynamic.ThenFunc(app.bannerDismissPost))
+    // The legal pages are a 404 until their file is configured, which a
+    // SIGHUP can do.
+    mux.Handle("/terms", dynamic.ThenFunc(app.termsPage))
+    mux.Handle("/privacy", dynamic.ThenFunc(app.privacyPage))
+    // Share links carry their own authorization in the signed token.
+    mux.Handle("/s/", dynamic.ThenFunc(app.shareView))
+
+    mux.Handle("/api/v1/chunks/batch", creating.Extend(api).ThenFunc(app.apiChunksBatch))
+    if app.fetcher != nil {
+        mux.Handle("/api/v1/fetch", creating.Extend(api).ThenFunc(app.apiFetch))
+    }
+    if app.inbound != nil {
+        mux.Handle("/api/v1/inbound/email", creating.Extend(api).ThenFunc(app.inboundEmail))
+    }
+    // Unknown API paths get a JSON 404 rather than the HTML one.
+    mux.Handle("/api/", api.ThenFunc(app.apiNotFound))
+
+    mux.HandleFunc("/healthz", app.healthz)
+    mux.HandleFunc("/readyz", app.readyz)
+    mux.HandleFunc("/version", app.versionHandler)
+    // The metrics are for monitoring, not the public, so by default only
+    // the local machine can read them.
+    mux.Handle("/metrics", alice.New(app.allowIPs(app.metricsAllowlist)).ThenFunc(app.metricsHandler))
+
+    // User accounts need the database, so there are no account routes when
+    // running with -db-driver=memory.
+    if app.users != nil {
+        mux.Handle("/user/signup", dynamic.ThenFunc(app.userSignup))
+        mux.Handle("/user/login", dynamic.ThenFunc(app.userLogin))
+        mux.Handle("/user/logout", protected.ThenFunc(app.userLogoutPost))
+        mux.Handle("/auth/", dynamic.ThenFunc(app.oauthAuth))
+        if app.comments != nil {
+            mux.Handle("/chunkbox/comment", protected.ThenFunc(app.commentPost))
+        }
+
+        mux.Handle("/account/view", protected.ThenFunc(app.accountView))
+        mux.Handle("/account/update", protected.ThenFunc(app.accountUpdate))
+        mux.Handle("/account/email/confirm", protected.ThenFunc(app.accountEmailConfirm))
+        mux.Handle("/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+        mux.Handle("/account/delete", protected.ThenFunc(app.accountDeletePost))
+        mux.Handle("/account/favorites", protected.ThenFunc(app.accountFavorites))
+
+        mux.Handle("/admin/audit", admin.ThenFunc(app.adminAudit))
+        mux.Handle("/admin/abuse", admin.ThenFunc(app.adminAbuse))
+        mux.Handle("/admin/banner", admin.ThenFunc(app.adminBanner))
+        mux.Handle("/admin/abuse/unblock", admin.ThenFunc(app.adminUnblockPost))
+        mux.Handle("/api/v1/admin/chunks", api.Append(app.requireAuthentication, app.requireAdmin).ThenFunc(app.apiAdminChunks))
+    }
 
-    mux.HandleFunc("/", app.home)
-    mux.HandleFunc("/chunkbox/view", app.chunkView)
-    mux.HandleFunc("/chunkbox/create", app.chunkCreate)
+    // When chunkbox is mounted under a sub-path (-base-path), the routes
+    // above are registered without the prefix and the prefix is stripped
+    // before the request reaches them. Requests outside the prefix get a
+    // 404, and the bare prefix redirects to the home page. Paths are
+    // normalized (-lowercase-paths, -trailing-slash) once the prefix is
+    // stripped, so the canonical path is the same wherever chunkbox is
+    // mounted.
+    var handler http.Handler = app.normalizePaths(mux)
+    if app.basePath != "" {
+        prefixed := http.NewServeMux()
+        prefixed.Handle(app.basePath+"/", http.StripPrefix(app.basePath, handler))
+        prefixed.Handle(app.basePath, http.RedirectHandler(app.basePath+"/", http.StatusMovedPermanently))
+        handler = prefixed
+    }
 
-    return mux
-}
\ No newline at end of file
+    // The standard chain runs for every request, static files included.
+    // recoverPanic is outermost so a panic anywhere, even in the logging
+    // middleware, becomes a 500 response; logRequest and debugRequests see
+    // every request before anything can refuse it; limitCookies turns away
+    // oversized cookies before they are parsed; canonicalHost redirects
+    // before any work is done for the wrong host; and secureHeaders sets
+    // its headers before any response is written.
+    standard := alice.New(app.recoverPanic, app.logRequest, app.debugRequests, app.limitCookies, app.canonicalHost, app.secureHeaders)
+    return standard.Then(handler)
+}
@@ -0,0 +1,72 @@
+/*-----------------------------------------------------------
+ @Filename:         rungroup.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "sync"
+)
+
+// A runGroup runs the application's background goroutines (webhook
+// deliveries and the like), so shutdown can wait for them before the
+// database is closed under them.
+//
+// Every goroutine gets the group's context, which is cancelled when
+// shutdown starts. Long-running workers should return when it is; one-off
+// tasks which ought to finish, like a webhook that is already on its way,
+// may carry on, because Shutdown only waits as long as the grace period.
+type runGroup struct {
+    ctx    context.Context
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+
+    mu      sync.Mutex
+    stopped bool
+}
+
+func newRunGroup() *runGroup {
+    ctx, cancel := context.WithCancel(context.Background())
+    return &runGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a new goroutine. Once shutdown has started nothing new is
+// started, and Go reports false.
+func (g *runGroup) Go(fn func(ctx context.Context)) bool {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if g.stopped {
+        return false
+    }
+
+    g.wg.Add(1)
+    go func() {
+        defer g.wg.Done()
+        fn(g.ctx)
+    }()
+    return true
+}
+
+// Shutdown cancels the group's context and waits for its goroutines to
+// return, or for ctx to be done. It returns ctx.Err() if it gave up waiting.
+func (g *runGroup) Shutdown(ctx context.Context) error {
+    g.mu.Lock()
+    g.stopped = true
+    g.mu.Unlock()
+    g.cancel()
+
+    done := make(chan struct{})
+    go func() {
+        g.wg.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
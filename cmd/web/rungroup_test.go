@@ -0,0 +1,57 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestRunGroupShutdown(t *testing.T) {
+    g := newRunGroup()
+
+    // The fake worker runs until it is signalled, then takes a moment to
+    // finish, which Shutdown has to wait for.
+    started := make(chan struct{})
+    var finished bool
+    g.Go(func(ctx context.Context) {
+        close(started)
+        <-ctx.Done()
+        time.Sleep(50 * time.Millisecond)
+        finished = true
+    })
+    <-started
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := g.Shutdown(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if !finished {
+        t.Error("Shutdown returned before the worker finished")
+    }
+
+    // Nothing new starts once shutdown has.
+    if g.Go(func(ctx context.Context) { t.Error("started after shutdown") }) {
+        t.Error("Go reported true after shutdown")
+    }
+}
+
+func TestRunGroupShutdownTimeout(t *testing.T) {
+    g := newRunGroup()
+
+    // This worker ignores the signal, so the grace period runs out.
+    release := make(chan struct{})
+    defer close(release)
+    g.Go(func(ctx context.Context) { <-release })
+
+    ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+    defer cancel()
+    start := time.Now()
+    if err := g.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Shutdown returned %v, want %v", err, context.DeadlineExceeded)
+    }
+    if waited := time.Since(start); waited > time.Second {
+        t.Errorf("Shutdown waited %v past the grace period", waited)
+    }
+}
@@ -0,0 +1,255 @@
+/*-----------------------------------------------------------
+ @Filename:         search.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "html/template"
+    "net/http"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+const (
+    // searchResultsLimit is the most chunks shown for one search.
+    searchResultsLimit = 20
+)
+
+// What is done about a query longer than -max-search-len
+// (-search-too-long): refuse it, or search for its beginning.
+const (
+    searchTooLongReject   = "reject"
+    searchTooLongTruncate = "truncate"
+)
+
+// searchPage is the data for the search page. Message explains an empty or
+// refused query, and Notice a query which was cut short. Ranked is set for
+// -search-fulltext searches, whose results have a relevance score.
+// MaxChars is the longest query accepted.
+type searchPage struct {
+    Query    string
+    Message  string
+    Notice   string
+    Ranked   bool
+    MaxChars int
+    Results  []searchResult
+}
+
+// A searchResult is a matching chunk with a Snippet of its content around
+// the first match, with the matches marked.
+type searchResult struct {
+    Chunk   *models.Chunk
+    Snippet template.HTML
+}
+
+// chunkSearch finds public chunks whose title or content contains the q
+// query parameter. With -search-fulltext, q is a boolean-mode query instead
+// and the chunks come most relevant first.
+func (app *application) chunkSearch(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+
+    // The results only change with the chunks, so they can be revalidated
+    // like the home page, and HEAD doesn't run the search at all.
+    if app.listingCacheable(r) {
+        modified, err := app.chunks.LatestModified()
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        if app.notModified(w, r, modified) {
+            return
+        }
+    }
+    if app.headOnly(w, r) {
+        return
+    }
+
+    page := &searchPage{Query: strings.TrimSpace(r.URL.Query().Get("q")), Ranked: app.searchFulltext, MaxChars: app.maxSearchLen}
+    // Long queries make for slow searches, so they are refused or cut short
+    // (-max-search-len), and so are the ones which would match about
+    // everything, before they get to the database.
+    if utf8.RuneCountInString(page.Query) > app.maxSearchLen && app.searchTooLong == searchTooLongTruncate {
+        page.Query = truncateQuery(page.Query, app.maxSearchLen)
+        page.Notice = fmt.Sprintf("Your search was cut to its first %d characters.", app.maxSearchLen)
+    }
+    switch {
+    case page.Query == "":
+        page.Message = "Enter a word or phrase to search the chunks for."
+    case utf8.RuneCountInString(page.Query) > app.maxSearchLen:
+        page.Message = fmt.Sprintf("Your search is too long. Searches can be up to %d characters.", app.maxSearchLen)
+    case !app.searchable(page.Query):
+        page.Message = "Your search only has common words like \"the\" or punctuation in it. Please add a word which is more specific."
+    default:
+        var (
+            chunks []*models.Chunk
+            err    error
+        )
+        if page.Ranked {
+            chunks, err = app.chunks.SearchRanked(page.Query, app.searchMinScore, searchResultsLimit)
+        } else {
+            chunks, err = app.chunks.Search(page.Query, searchResultsLimit)
+        }
+        if err != nil {
+            app.serverError(w, err)
+            return
+        }
+        for _, chunk := range chunks {
+            term := page.Query
+            if page.Ranked {
+                term = snippetTerm(chunk.Content, models.SearchTerms(page.Query))
+            }
+            page.Results = append(page.Results, searchResult{
+                Chunk:   chunk,
+                Snippet: searchSnippet(chunk.Content, term, app.searchSnippetChars),
+            })
+        }
+        if len(page.Results) == 0 {
+            page.Message = "No chunks match your search."
+        }
+    }
+
+    data := app.newTemplateData(r)
+    data.Search = page
+    app.render(w, http.StatusOK, "search.html", data)
+}
+
+// truncateQuery cuts a query to at most max characters, at the last space
+// before the limit if there is one, so the last word isn't cut in half.
+func truncateQuery(query string, max int) string {
+    runes := []rune(query)
+    if len(runes) <= max {
+        return query
+    }
+    cut := string(runes[:max])
+    if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+        cut = cut[:i]
+    }
+    return strings.TrimSpace(cut)
+}
+
+// searchable reports whether a query has a word to look for which isn't a
+// stopword. The excluded terms of a ranked search don't count, as they
+// can't find anything on their own.
+func (app *application) searchable(query string) bool {
+    words := models.QueryWords(query)
+    if app.searchFulltext {
+        words = nil
+        for _, term := range models.SearchTerms(query) {
+            words = append(words, strings.Fields(term)...)
+        }
+    }
+    for _, word := range words {
+        if !models.IsStopword(word) {
+            return true
+        }
+    }
+    return false
+}
+
+// searchSnippet returns about width characters of content around the first
+// match of query, HTML-escaped, with every match in it wrapped in <mark>.
+// Content which doesn't contain the query (the title matched) gives its
+// beginning instead, without any marks.
+//
+// Matching is done on the plain text and each piece is escaped on its own,
+// so a query can never match inside the escaping ("amp" doesn't find the
+// &amp; made from a "&") or split a tag or an entity in two.
+func searchSnippet(content, query string, width int) template.HTML {
+    text := []rune(content)
+    matches := findMatches(text, []rune(query))
+    qlen := utf8.RuneCountInString(query)
+    // A match longer than the snippet is shown in full.
+    if qlen > width {
+        width = qlen
+    }
+
+    // Center the window on the first match, or start at the top.
+    start := 0
+    if len(matches) > 0 {
+        start = matches[0] - (width-qlen)/2
+        if start < 0 {
+            start = 0
+        }
+    }
+    end := start + width
+    if end > len(text) {
+        end = len(text)
+        // Use the whole width at the end of the content as well.
+        start = end - width
+        if start < 0 {
+            start = 0
+        }
+    }
+
+    var b strings.Builder
+    if start > 0 {
+        b.WriteString("…")
+    }
+    pos := start
+    for _, m := range matches {
+        // Only marks which fit into the window in full.
+        if m < pos || m+qlen > end {
+            continue
+        }
+        b.WriteString(template.HTMLEscapeString(string(text[pos:m])))
+        b.WriteString("<mark>")
+        b.WriteString(template.HTMLEscapeString(string(text[m : m+qlen])))
+        b.WriteString("</mark>")
+        pos = m + qlen
+    }
+    b.WriteString(template.HTMLEscapeString(string(text[pos:end])))
+    if end < len(text) {
+        b.WriteString("…")
+    }
+    return template.HTML(b.String())
+}
+
+// snippetTerm picks which of the terms of a ranked search the snippet of
+// the content is centered on and marks: the first one found in it, in the
+// order of the query. Content with none of them gets the first, which
+// marks nothing.
+func snippetTerm(content string, terms []string) string {
+    text := []rune(content)
+    for _, t := range terms {
+        if len(findMatches(text, []rune(t))) > 0 {
+            return t
+        }
+    }
+    if len(terms) == 0 {
+        return ""
+    }
+    return terms[0]
+}
+
+// findMatches returns the positions of the non-overlapping, case-insensitive
+// matches of query in text. Runes are compared one by one after lowering, so
+// the positions are the same in the original text.
+func findMatches(text, query []rune) []int {
+    if len(query) == 0 {
+        return nil
+    }
+    var matches []int
+    for i := 0; i+len(query) <= len(text); i++ {
+        match := true
+        for j, q := range query {
+            if unicode.ToLower(text[i+j]) != unicode.ToLower(q) {
+                match = false
+                break
+            }
+        }
+        if match {
+            matches = append(matches, i)
+            i += len(query) - 1
+        }
+    }
+    return matches
+}
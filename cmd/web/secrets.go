@@ -0,0 +1,78 @@
+/*-----------------------------------------------------------
+ @Filename:         secrets.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/secrets"
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// With -scan-secrets, the content of chunks being saved is checked for
+// credentials pasted by mistake: AWS keys, private keys, JWTs and the like
+// (see internal/secrets). With -secret-action=block such a chunk is refused
+// with an error on its content; with warn the creator is asked to confirm,
+// and API clients get a warning in the response. The secret itself is never
+// shown back, and the operator's log only has it redacted.
+const (
+    secretWarn  = "warn"
+    secretBlock = "block"
+    // secretScanBudget bounds the scan of one chunk. A chunk which takes
+    // longer is let through, as the scan is a safety net, not a control.
+    secretScanBudget = 100 * time.Millisecond
+)
+
+// findSecret returns the first secret in the content of a chunk or its
+// files, or nil, logging what it found.
+func (app *application) findSecret(r *http.Request, content string, files []models.ChunkFile) *secrets.Finding {
+    texts := []string{content}
+    for _, f := range files {
+        texts = append(texts, f.Content)
+    }
+    finding, err := app.secretScanner.Scan(texts...)
+    if errors.Is(err, secrets.ErrBudget) {
+        app.infoLog.Printf("secrets: gave up scanning a chunk from %s after %s", app.realIP(r), secretScanBudget)
+        return nil
+    }
+    if finding != nil {
+        app.infoLog.Printf("secrets: found a secret (%s: %s) on line %d of file %d of a chunk from %s (-secret-action=%s)",
+            finding.Kind, finding.Redacted, finding.Line, finding.Part+1, app.realIP(r), app.secretAction)
+    }
+    return finding
+}
+
+// secretMessage tells the creator what was found, and where, without the
+// secret itself.
+func secretMessage(f *secrets.Finding) string {
+    where := fmt.Sprintf("line %d", f.Line)
+    if f.Part > 0 {
+        where += fmt.Sprintf(" of file %d", f.Part+1)
+    }
+    return fmt.Sprintf("This looks like it contains a secret (%s) on %s.", f.Kind, where)
+}
+
+// checkSecret applies -secret-action to a chunk being saved. With block, a
+// secret is a validation error on the content. With warn, the warning to
+// show the creator is returned, unless they have confirmed already.
+func (app *application) checkSecret(v *validator.Validator, r *http.Request, content string, files []models.ChunkFile, confirmed bool) string {
+    if app.secretScanner == nil || (app.secretAction == secretWarn && confirmed) {
+        return ""
+    }
+    finding := app.findSecret(r, content, files)
+    if finding == nil {
+        return ""
+    }
+    if app.secretAction == secretBlock {
+        v.AddFieldError("content", secretMessage(finding)+" Please remove it before saving.")
+        return ""
+    }
+    return secretMessage(finding)
+}
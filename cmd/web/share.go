@@ -0,0 +1,117 @@
+/*-----------------------------------------------------------
+ @Filename:         share.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// shareToken returns the signature for a share link to the chunk with the
+// public ID id which expires at the unix time exp. Both values are covered by
+// the HMAC, so neither can be changed without invalidating the link.
+func (app *application) shareToken(id string, exp int64) string {
+    return app.signer.Sign("share", id, strconv.FormatInt(exp, 10))
+}
+
+// chunkShare generates a time-limited share link for one of the current
+// user's chunks, at /chunk/{id}/share. Anyone with the link can see the
+// chunk until it expires, even if the chunk is private.
+func (app *application) chunkShare(w http.ResponseWriter, r *http.Request, id string) {
+    if r.Method != http.MethodGet {
+        app.methodNotAllowed(w, http.MethodGet)
+        return
+    }
+
+    chunk, err := app.chunks.GetMetaByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+
+    // Only the owner may share a chunk. As with private chunks, we respond
+    // with a 404 rather than revealing the chunk exists.
+    if chunk.UserID == 0 || chunk.UserID != app.authenticatedUserID(r) {
+        app.notFound(w)
+        return
+    }
+
+    expires := time.Now().Add(app.shareLinkTTL)
+    // There's no point in a link outliving the chunk itself.
+    if chunk.Expires.Before(expires) {
+        expires = chunk.Expires
+    }
+    exp := expires.Unix()
+
+    query := url.Values{}
+    query.Set("t", app.shareToken(chunk.PublicID, exp))
+    query.Set("exp", strconv.FormatInt(exp, 10))
+
+    data := app.newTemplateData(r)
+    data.Chunk = chunk
+    data.ShareURL = app.absoluteURL(r, fmt.Sprintf("/s/%s?%s", chunk.PublicID, query.Encode()))
+    data.ShareExpires = expires
+    app.render(w, http.StatusOK, "share.html", data)
+}
+
+// shareView serves a chunk through a share link of the form
+// /s/{public id}?t=<token>&exp=<unix time>. The link is checked for a valid
+// signature and expiry, and if it passes the chunk is shown whatever its
+// visibility. Tampered or expired links get a 403 Forbidden.
+func (app *application) shareView(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+
+    id := strings.TrimPrefix(r.URL.Path, "/s/")
+    if id == "" {
+        app.notFound(w)
+        return
+    }
+
+    // Recompute the token from the id and expiry in the URL. If either has
+    // been changed (or the token itself) they won't match.
+    exp, err := strconv.ParseInt(r.URL.Query().Get("exp"), 10, 64)
+    if err != nil || !app.signer.Verify(r.URL.Query().Get("t"), "share", id, strconv.FormatInt(exp, 10)) {
+        app.clientError(w, http.StatusForbidden)
+        return
+    }
+    if time.Now().Unix() >= exp {
+        app.clientError(w, http.StatusForbidden)
+        return
+    }
+
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        if errors.Is(err, models.ErrNoRecord) {
+            app.notFound(w)
+        } else {
+            app.serverError(w, err)
+        }
+        return
+    }
+
+    // Share links are meant for one person, so keep them out of shared
+    // caches and search engines.
+    w.Header().Set("Cache-Control", "private, no-store")
+    w.Header().Set("X-Robots-Tag", "noindex")
+
+    data := app.newTemplateData(r)
+    app.displayChunk(r, data, chunk)
+    app.render(w, http.StatusOK, "view.html", data)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+    "html"
+    "io"
+    "net/http"
+    "net/url"
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+)
+
+var shareURLRX = regexp.MustCompile(`value='([^']*/s/[^']*)' readonly`)
+
+func TestChunkShare(t *testing.T) {
+    app := newTestApplication(t)
+    users := withUsers(app)
+    owner := users.add(t, "Owner", "owner@example.com", "pa55word")
+    users.add(t, "Other", "other@example.com", "pa55word")
+    ts := newTestServer(t, app.routes())
+    id, err := app.chunks.Insert("Private", "private content", 7, highlight.PlainText, owner, true, false, false, nil, nil, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    sharePath := "/chunk/" + id + "/share"
+
+    // Anonymous visitors are sent to log in, and other users don't get to
+    // know the chunk exists.
+    resp, _ := ts.get(t, sharePath)
+    if loc := resp.Header.Get("Location"); resp.StatusCode != http.StatusSeeOther || !strings.HasPrefix(loc, "/user/login") {
+        t.Errorf("anonymous: status %d, redirect to %q", resp.StatusCode, loc)
+    }
+    other := newTestServer(t, app.routes())
+    other.login(t, "other@example.com", "pa55word")
+    if resp, _ := other.get(t, sharePath); resp.StatusCode != http.StatusNotFound {
+        t.Errorf("another user: status %d, want 404", resp.StatusCode)
+    }
+
+    ts.login(t, "owner@example.com", "pa55word")
+    resp, page := ts.get(t, sharePath)
+    if resp.StatusCode != http.StatusOK {
+        t.Fatalf("owner: status %d", resp.StatusCode)
+    }
+    m := shareURLRX.FindStringSubmatch(page)
+    if m == nil {
+        t.Fatal("no share link in the page")
+    }
+    link, err := url.Parse(html.UnescapeString(m[1]))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // view gets a share link the way someone it was sent to would, without
+    // the owner's session.
+    view := func(query url.Values) (int, string) {
+        t.Helper()
+
+        resp, err := http.Get(ts.URL + link.Path + "?" + query.Encode())
+        if err != nil {
+            t.Fatal(err)
+        }
+        defer resp.Body.Close()
+        body, err := io.ReadAll(resp.Body)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return resp.StatusCode, string(body)
+    }
+
+    if status, body := view(link.Query()); status != http.StatusOK || !strings.Contains(body, "private content") {
+        t.Errorf("share link: status %d, content shown %t", status, strings.Contains(body, "private content"))
+    }
+
+    tampered := link.Query()
+    tampered.Set("t", strings.Repeat("0", len(tampered.Get("t"))))
+    if status, _ := view(tampered); status != http.StatusForbidden {
+        t.Errorf("tampered token: status %d, want 403", status)
+    }
+
+    later := link.Query()
+    exp, _ := strconv.ParseInt(later.Get("exp"), 10, 64)
+    later.Set("exp", strconv.FormatInt(exp+3600, 10))
+    if status, _ := view(later); status != http.StatusForbidden {
+        t.Errorf("longer expiry with the same token: status %d, want 403", status)
+    }
+
+    // A link signed properly, but which has expired.
+    past := time.Now().Add(-time.Minute).Unix()
+    expired := url.Values{"t": {app.shareToken(id, past)}, "exp": {strconv.FormatInt(past, 10)}}
+    if status, _ := view(expired); status != http.StatusForbidden {
+        t.Errorf("expired link: status %d, want 403", status)
+    }
+}
@@ -0,0 +1,80 @@
+/*-----------------------------------------------------------
+ @Filename:         sizelimits.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+)
+
+// maxContentLimit is the highest size limit that can be configured. Go reads
+// at most 10MB of a form body, and the API reads at most maxAPIBodyBytes, so
+// larger chunks could never arrive anyway.
+const maxContentLimit = 10 << 20
+
+// parseSizeLimits parses the -language-size-limits flag, a comma-separated
+// list of language=bytes pairs. The languages are stored under their
+// canonical names, so aliases like "golang" work too.
+func parseSizeLimits(s string) (map[string]int, error) {
+    limits := map[string]int{}
+    for _, pair := range strings.Split(s, ",") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        name, value, ok := strings.Cut(pair, "=")
+        if !ok {
+            return nil, fmt.Errorf("-language-size-limits: %q is not language=bytes", strings.TrimSpace(pair))
+        }
+        lang, ok := highlight.Lookup(name)
+        if !ok {
+            return nil, fmt.Errorf("-language-size-limits: unknown language %q", strings.TrimSpace(name))
+        }
+        n, err := strconv.Atoi(strings.TrimSpace(value))
+        if err != nil || n < 1 || n > maxContentLimit {
+            return nil, fmt.Errorf("-language-size-limits: the limit for %s must be between 1 and %d bytes", lang.Name, maxContentLimit)
+        }
+        limits[lang.Name] = n
+    }
+    return limits, nil
+}
+
+// maxContentBytes returns the size limit for content in the language: its
+// own limit if it has one, otherwise -max-chunk-bytes. The language isn't
+// known yet for "auto", so that gets the global limit too.
+func (app *application) maxContentBytes(language string) int {
+    if limit, ok := app.sizeLimits[language]; ok {
+        return limit
+    }
+    return app.maxChunkBytes
+}
+
+// contentLimitMessage is the validation error for content over the limit.
+// It names the language when the limit is the language's own.
+func (app *application) contentLimitMessage(language string, limit int) string {
+    if _, ok := app.sizeLimits[language]; ok {
+        label := language
+        if lang, ok := highlight.Lookup(language); ok {
+            label = lang.Label
+        }
+        return fmt.Sprintf("This field cannot be larger than %s for %s chunks", formatBytes(limit), label)
+    }
+    return fmt.Sprintf("This field cannot be larger than %s", formatBytes(limit))
+}
+
+// formatBytes formats a size in bytes for people, as in "64 KB". Sizes
+// which aren't a whole number of KB or MB are given exactly in bytes.
+func formatBytes(n int) string {
+    switch {
+    case n >= 1<<20 && n%(1<<20) == 0:
+        return strconv.Itoa(n>>20) + " MB"
+    case n >= 1<<10 && n%(1<<10) == 0:
+        return strconv.Itoa(n>>10) + " KB"
+    }
+    return strconv.Itoa(n) + " bytes"
+}
@@ -0,0 +1,49 @@
+/*-----------------------------------------------------------
+ @Filename:         spam.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// The honeypot field is rendered in the create form but hidden from people
+// with CSS. Humans leave it empty, while naive bots fill in every field they
+// find.
+const honeypotField = "website"
+
+// newFormToken returns a signed token recording when the create form was
+// displayed, in the form "<unix time>.<signature>".
+func (app *application) newFormToken() string {
+    ts := strconv.FormatInt(time.Now().Unix(), 10)
+    return ts + "." + app.signer.Sign("create-form", ts)
+}
+
+// checkFormToken verifies the signature of a form token and that the form was
+// displayed at least minFillTime ago. Because the timestamp is signed a bot
+// can't simply submit a token with an old time in it.
+func (app *application) checkFormToken(token string) bool {
+    ts, sig, ok := strings.Cut(token, ".")
+    if !ok || !app.signer.Verify(sig, "create-form", ts) {
+        return false
+    }
+    unix, err := strconv.ParseInt(ts, 10, 64)
+    if err != nil {
+        return false
+    }
+    return time.Since(time.Unix(unix, 0)) >= app.minFillTime
+}
+
+// isSpamSubmission applies the honeypot and minimum fill time checks to a
+// parsed create form submission.
+func (app *application) isSpamSubmission(r *http.Request) bool {
+    if r.PostForm.Get(honeypotField) != "" {
+        return true
+    }
+    return !app.checkFormToken(r.PostForm.Get("form_token"))
+}
@@ -0,0 +1,89 @@
+/*-----------------------------------------------------------
+ @Filename:         tags.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+// maxTagLimit is the longest -max-tag-length can be, the width of the tag
+// column.
+const maxTagLimit = 64
+
+// tagPunctuation are the characters besides letters and digits a tag may
+// contain, so tags like "c++", "c#" and "node.js" work.
+const tagPunctuation = "-_.+#"
+
+// normalizeTags cleans up submitted tags: they are trimmed and lowercased,
+// empty ones are dropped, and repeats are removed, so "go, Go, go" is the
+// single tag "go". The order of the first occurrences is kept.
+func normalizeTags(tags []string) []string {
+    var result []string
+    seen := make(map[string]bool, len(tags))
+    for _, tag := range tags {
+        tag = strings.ToLower(strings.TrimSpace(tag))
+        if tag == "" || seen[tag] {
+            continue
+        }
+        seen[tag] = true
+        result = append(result, tag)
+    }
+    return result
+}
+
+// parseTags splits the comma-separated tags field of the forms into
+// normalized tags.
+func parseTags(s string) []string {
+    return normalizeTags(strings.Split(s, ","))
+}
+
+// validTagChars reports whether a tag only contains letters, digits and
+// tagPunctuation.
+func validTagChars(tag string) bool {
+    for _, r := range tag {
+        if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(tagPunctuation, r) {
+            return false
+        }
+    }
+    return true
+}
+
+// validateTags checks normalized tags against -max-tags and -max-tag-length.
+// The errors name the tags at fault, so the user knows which ones to fix.
+func (app *application) validateTags(v *validator.Validator, tags []string) {
+    if len(tags) == 0 {
+        return
+    }
+    if app.maxTags == 0 {
+        v.AddFieldError("tags", "Tags are not enabled on this site")
+        return
+    }
+    if len(tags) > app.maxTags {
+        v.AddFieldError("tags", fmt.Sprintf("A chunk cannot have more than %d tags", app.maxTags))
+        return
+    }
+
+    var tooLong, invalid []string
+    for _, tag := range tags {
+        switch {
+        case utf8.RuneCountInString(tag) > app.maxTagLength:
+            tooLong = append(tooLong, tag)
+        case !validTagChars(tag):
+            invalid = append(invalid, tag)
+        }
+    }
+    switch {
+    case len(tooLong) > 0:
+        v.AddFieldError("tags", fmt.Sprintf("Tags cannot be more than %d characters long: %s", app.maxTagLength, strings.Join(tooLong, ", ")))
+    case len(invalid) > 0:
+        v.AddFieldError("tags", fmt.Sprintf("Tags can only contain letters, digits and %s: %s", strings.Join(strings.Split(tagPunctuation, ""), " "), strings.Join(invalid, ", ")))
+    }
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "net/http"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/cpucortexm/chunkbox/internal/validator"
+)
+
+func TestParseTags(t *testing.T) {
+    tests := []struct {
+        in   string
+        want []string
+    }{
+        {"", nil},
+        {"go", []string{"go"}},
+        {"go, Go, go", []string{"go"}},
+        {" Go ,  web,GO , , web ", []string{"go", "web"}},
+        {"Ünïcode, ÜNÏCODE", []string{"ünïcode"}},
+        {",,,", nil},
+    }
+    for _, tt := range tests {
+        if got := parseTags(tt.in); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("parseTags(%q) = %q, want %q", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestValidateTags(t *testing.T) {
+    tests := []struct {
+        name     string
+        tags     string
+        disabled bool
+        want     string
+    }{
+        {name: "none", tags: ""},
+        {name: "at the limits", tags: "a, b, abcde"},
+        // The duplicates collapse before they are counted.
+        {name: "duplicates", tags: "go, Go, GO, web, web, db"},
+        {name: "too many", tags: "a, b, c, d", want: "more than 3 tags"},
+        {name: "too long", tags: "short, toolong, alsotoolong", want: "5 characters long: toolong, alsotoolong"},
+        // The limit is in characters.
+        {name: "unicode", tags: "ünïcö"},
+        {name: "invalid", tags: "a b", want: "can only contain"},
+        {name: "disabled", tags: "go", disabled: true, want: "not enabled"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            app := newTestApplication(t)
+            app.maxTags = 3
+            if tt.disabled {
+                app.maxTags = 0
+            }
+            app.maxTagLength = 5
+            var v validator.Validator
+            app.validateTags(&v, parseTags(tt.tags))
+            got := v.FieldErrors["tags"]
+            if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+                t.Errorf("error %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestChunkCreateTags(t *testing.T) {
+    app := newTestApplication(t)
+    ts := newTestServer(t, app.routes())
+
+    form := createForm("Tagged", "content")
+    form.Set("tags", "Go, go, web")
+    resp, _ := ts.postForm(t, "/chunkbox/create", form)
+    chunk, err := app.chunks.GetByPublicID(chunkIDFrom(t, resp))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(chunk.Tags, []string{"go", "web"}) {
+        t.Errorf("tags %q", chunk.Tags)
+    }
+
+    form.Set("tags", strings.Repeat("x", app.maxTagLength+1))
+    resp, body := ts.postForm(t, "/chunkbox/create", form)
+    if resp.StatusCode != http.StatusUnprocessableEntity {
+        t.Errorf("status %d with a tag too long, want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+    }
+    if !strings.Contains(body, strings.Repeat("x", app.maxTagLength+1)) {
+        t.Error("the error doesn't name the tag")
+    }
+}
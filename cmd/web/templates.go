@@ -0,0 +1,255 @@
+/*-----------------------------------------------------------
+ @Filename:         templates.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "html/template"
+    "io/fs"
+    "log"
+    "os"
+    "path"
+    "strings"
+    "time"
+    "unicode"
+
+    "github.com/cpucortexm/chunkbox/internal/captcha"
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/ui"
+)
+
+// Define a templateData type to act as the holding structure for
+// any dynamic data that we want to pass to our HTML templates.
+// Go's html/template package only allows a single item of dynamic data
+// to be passed in when rendering, so we collect everything in here.
+type templateData struct {
+    SiteName        string
+    SiteLogoURL     string
+    CurrentYear     int
+    // TermsEnabled and PrivacyEnabled are true when the footer links to
+    // the /terms and /privacy pages (-terms-file, -privacy-file).
+    TermsEnabled    bool
+    PrivacyEnabled  bool
+    Chunk           *models.Chunk
+    Chunks          []*models.Chunk
+    User            *models.User
+    Form            any
+    Flash           string
+    IsAuthenticated bool
+    // Banner is the notice at the top of the page, nil for none.
+    Banner          *banner
+    // AllowAnonymous mirrors the -allow-anonymous flag, so the pages can
+    // tell anonymous visitors they need to log in to create chunks.
+    AllowAnonymous  bool
+    // EmptyMessage is the welcome text shown on the home page of an instance
+    // without any chunks.
+    EmptyMessage    string
+    // OlderCursor is the "after" parameter of the home page's link to older
+    // chunks, empty on the last page. Paged is true past the first page.
+    OlderCursor     string
+    Paged           bool
+    // Highlighted is the syntax highlighted content of the Chunk, or empty
+    // if it is shown as plain text.
+    Highlighted     template.HTML
+    // RenderedPlain is true when the Chunk is bigger than -max-render-bytes,
+    // so it wasn't highlighted.
+    RenderedPlain   bool
+    // Wrap is how long lines of the Chunk are displayed (see wrap.go), and
+    // WrapOptions the links to switch to the other ways. TruncatedLines is
+    // the number of lines cut at WrapWidth characters.
+    Wrap            string
+    WrapWidth       int
+    WrapOptions     []wrapOption
+    TruncatedLines  int
+    // PrettyURL links to the Chunk pretty printed, or back to it as saved
+    // when Pretty (see pretty.go). It is empty for languages which can't be
+    // pretty printed. PrettyFailed is true when the content didn't parse.
+    Pretty          bool
+    PrettyURL       string
+    PrettyFailed    bool
+    // AccountsEnabled is false when running without a database, so the
+    // signup and login links are hidden.
+    AccountsEnabled bool
+    // Comments are shown under the Chunk on the view page, when
+    // CommentsEnabled.
+    Comments        []*models.Comment
+    CommentsEnabled bool
+    // FavoritesEnabled is true when users can star chunks. FavoriteCount is
+    // the number of users who starred the Chunk, and IsFavorite whether the
+    // current user did.
+    FavoritesEnabled bool
+    FavoriteCount   int
+    IsFavorite      bool
+    // Favorites is the data for the /account/favorites page.
+    Favorites       *favoritesPage
+    // StatsEnabled is true when the owner of the Chunk can see its access
+    // log (-access-log-days), and Stats is the data for that page.
+    StatsEnabled    bool
+    Stats           *statsPage
+    // AbuseEnabled is true when IPs are scored for abuse (-abuse-threshold),
+    // and Blocked lists the ones currently blocked, on /admin/abuse.
+    AbuseEnabled    bool
+    Blocked         []blockedIP
+    // Storage is the space the user's chunks take up, on the account page.
+    Storage         *storageUsage
+    // OpenGraph is the link preview of the Chunk on the view page, or nil
+    // if it mustn't have one.
+    OpenGraph       *openGraph
+    // Related are the chunks suggested beside the Chunk being displayed.
+    Related         []*models.Chunk
+    // IsOwner is true when the current user owns the Chunk being displayed.
+    IsOwner         bool
+    // EditURL is the secret edit link of a chunk just created anonymously,
+    // which works until EditExpires. It is only shown once.
+    EditURL         string
+    EditExpires     time.Time
+    CSRFToken       string
+    // Captcha is set when the CAPTCHA widget should be shown on the form.
+    Captcha         *captcha.Widget
+    // Languages are the choices for the create form's language dropdown.
+    Languages       []highlight.Language
+    // ExpiryOptions are the expiry choices on the create form.
+    ExpiryOptions   []expiryOption
+    // TagsEnabled is true when the forms offer the tags field (-max-tags).
+    TagsEnabled     bool
+    // Files are the tabs of a multi-file chunk's view page, the first file
+    // included, and empty for other chunks.
+    Files           []chunkFileView
+    // MaxOtherFiles is how many files the create form offers besides the
+    // first one (-max-files).
+    MaxOtherFiles   int
+    // KeepAliveEnabled is true when the create form offers to keep the
+    // chunk alive while it is viewed (-extend-on-view).
+    KeepAliveEnabled bool
+    // OAuthProviders are the names of the social login providers to offer.
+    OAuthProviders  []string
+    // Legal is the data for the terms and privacy pages.
+    Legal           *legalPage
+    // Search is the data for the search page.
+    Search          *searchPage
+    // Audit holds the audit log page for administrators.
+    Audit           *auditPage
+    // ShareURL and ShareExpires describe a freshly generated share link.
+    ShareURL        string
+    ShareExpires    time.Time
+}
+
+// Create a humanDate function which returns a nicely formatted string
+// representation of a time.Time object.
+func humanDate(t time.Time) string {
+    if t.IsZero() {
+        return ""
+    }
+    return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+// preview returns the preview text of a chunk for the listing pages. Control
+// characters are dropped (line breaks and tabs become spaces) so the preview
+// fits on one line, and "…" marks a preview that was cut short.
+func preview(c *models.Chunk) string {
+    s := strings.Map(func(r rune) rune {
+        switch {
+        case r == '\n' || r == '\r' || r == '\t':
+            return ' '
+        case unicode.IsControl(r):
+            return -1
+        }
+        return r
+    }, c.Preview)
+    s = strings.TrimSpace(s)
+    if c.Truncated {
+        s += "…"
+    }
+    return s
+}
+
+// Initialize a template.FuncMap object and store it in a global variable. This is
+// essentially a string-keyed map which acts as a lookup between the names of our
+// custom template functions and the functions themselves.
+var functions = template.FuncMap{
+    "humanDate": humanDate,
+    "preview":   preview,
+}
+
+// newTemplateCache parses every page template once at startup, together with
+// the base layout and partials, and stores the resulting template sets in a
+// map keyed by the page name (e.g. 'home.html'). Handlers then only need to
+// look the page up instead of reading and parsing files from disk on every
+// request. The templates are read from fsys, laid out like ui/html. The
+// basePath is prepended to links by the "url" template function.
+func newTemplateCache(fsys fs.FS, basePath string) (map[string]*template.Template, error) {
+    cache := map[string]*template.Template{}
+
+    // The url function depends on the -base-path flag, so it can't live in
+    // the global functions map.
+    urlFuncs := template.FuncMap{
+        "url": func(path string) string {
+            return basePath + path
+        },
+    }
+
+    // Use the fs.Glob() function to get a slice of all filepaths that
+    // match the pattern "pages/*.html".
+    pages, err := fs.Glob(fsys, "pages/*.html")
+    if err != nil {
+        return nil, err
+    }
+    // A missing or empty directory would leave every page broken.
+    if len(pages) == 0 {
+        return nil, errors.New("no page templates found")
+    }
+
+    for _, page := range pages {
+        // Extract the file name (like 'home.html') from the full filepath
+        // and assign it to the name variable.
+        name := path.Base(page)
+
+        // The template.FuncMap must be registered with the template set before
+        // you call the ParseFS() method. This means we have to use
+        // template.New() to create an empty template set, use the Funcs() method
+        // to register the template.FuncMap, and then parse the files as normal.
+        // The base layout, any partials and the page template all go into
+        // one set.
+        ts, err := template.New(name).Funcs(functions).Funcs(urlFuncs).ParseFS(fsys, "base.html", "partials/*.html", page)
+        if err != nil {
+            return nil, err
+        }
+
+        // Add the template set to the map, using the name of the page
+        // (like 'home.html') as the key.
+        cache[name] = ts
+    }
+
+    return cache, nil
+}
+
+// loadTemplates builds the template cache from the -templates-dir, which
+// overrides the templates embedded in the binary, or from the embedded ones
+// when it is empty. If the directory's templates fail to load, startup fails,
+// unless strict is false (-strict-templates=false): then the embedded
+// templates are used and a warning is logged, so a broken customization
+// doesn't take the site down.
+func loadTemplates(dir string, strict bool, basePath string, infoLog *log.Logger) (map[string]*template.Template, error) {
+    embedded, err := fs.Sub(ui.Files, "html")
+    if err != nil {
+        return nil, err
+    }
+    if dir == "" {
+        return newTemplateCache(embedded, basePath)
+    }
+
+    cache, err := newTemplateCache(os.DirFS(dir), basePath)
+    if err == nil {
+        return cache, nil
+    }
+    if strict {
+        return nil, fmt.Errorf("templates in %s: %w", dir, err)
+    }
+    infoLog.Printf("WARNING: templates in %s failed to load, using the built-in ones: %v", dir, err)
+    return newTemplateCache(embedded, basePath)
+}
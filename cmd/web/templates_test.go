@@ -0,0 +1,116 @@
+package main
+
+import (
+    "bytes"
+    "io/fs"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/cpucortexm/chunkbox/ui"
+)
+
+// templatesDir copies the embedded templates to a directory for -templates-dir,
+// with the files in override written over them.
+func templatesDir(t *testing.T, override map[string]string) string {
+    t.Helper()
+
+    dir := t.TempDir()
+    err := fs.WalkDir(ui.Files, "html", func(path string, d fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        target := filepath.Join(dir, strings.TrimPrefix(path, "html"))
+        if d.IsDir() {
+            return os.MkdirAll(target, 0o755)
+        }
+        b, err := fs.ReadFile(ui.Files, path)
+        if err != nil {
+            return err
+        }
+        return os.WriteFile(target, b, 0o644)
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    for name, content := range override {
+        if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    return dir
+}
+
+func TestLoadTemplates(t *testing.T) {
+    const broken = `{{define "title"}}Home{{end}} {{if}}`
+
+    tests := []struct {
+        name     string
+        dir      func(t *testing.T) string
+        strict   bool
+        wantErr  bool
+        wantWarn bool
+        // wantHome is in the home page when the templates load.
+        wantHome string
+    }{
+        {name: "embedded", dir: func(t *testing.T) string { return "" }, strict: true, wantHome: "Chunkbox"},
+        {
+            name:     "override",
+            dir:      func(t *testing.T) string { return templatesDir(t, map[string]string{"pages/home.html": `{{define "title"}}Home{{end}}{{define "main"}}Custom home{{end}}`}) },
+            strict:   true,
+            wantHome: "Custom home",
+        },
+        {
+            name:    "parse error, strict",
+            dir:     func(t *testing.T) string { return templatesDir(t, map[string]string{"pages/home.html": broken}) },
+            strict:  true,
+            wantErr: true,
+        },
+        {
+            name:     "parse error, fallback",
+            dir:      func(t *testing.T) string { return templatesDir(t, map[string]string{"pages/home.html": broken}) },
+            wantWarn: true,
+            wantHome: "Chunkbox",
+        },
+        {
+            name:    "missing directory, strict",
+            dir:     func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+            strict:  true,
+            wantErr: true,
+        },
+        {
+            name:     "missing directory, fallback",
+            dir:      func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") },
+            wantWarn: true,
+            wantHome: "Chunkbox",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var logged bytes.Buffer
+            cache, err := loadTemplates(tt.dir(t), tt.strict, "", log.New(&logged, "", 0))
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatal("no error")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+            if warned := strings.Contains(logged.String(), "WARNING"); warned != tt.wantWarn {
+                t.Errorf("warned = %t, want %t: %q", warned, tt.wantWarn, logged.String())
+            }
+
+            app := newTestApplication(t)
+            app.templateCache = cache
+            ts := newTestServer(t, app.routes())
+            _, body := ts.get(t, "/")
+            if !strings.Contains(body, tt.wantHome) {
+                t.Errorf("home page without %q", tt.wantHome)
+            }
+        })
+    }
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+    "bytes"
+    "html"
+    "io"
+    "log"
+    "net/http"
+    "net/http/cookiejar"
+    "net/http/httptest"
+    "net/url"
+    "regexp"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/alexedwards/scs/v2"
+    "github.com/alexedwards/scs/v2/memstore"
+    "github.com/cpucortexm/chunkbox/internal/highlight"
+    "github.com/cpucortexm/chunkbox/internal/models"
+    "github.com/cpucortexm/chunkbox/internal/signing"
+    "golang.org/x/sync/semaphore"
+)
+
+// newTestApplication returns an application like main() sets up with the
+// default flags and -db-driver=memory, with the logs thrown away. Tests
+// change the fields they are about before calling routes().
+func newTestApplication(t *testing.T) *application {
+    t.Helper()
+
+    templateCache, err := loadTemplates("", true, "", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    highlighter, err := highlight.New("github", highlight.PlainText)
+    if err != nil {
+        t.Fatal(err)
+    }
+    languages, err := highlight.Parse("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    siteBranding, err := newBranding("Chunkbox", "", "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    siteBanner, err := newBannerState(banner{Level: bannerInfo}, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    key, err := signing.RandomKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sessionManager := scs.New()
+    sessionManager.Store = memstore.New()
+    sessionManager.Lifetime = 12 * time.Hour
+    sessionManager.Cookie.Path = "/"
+
+    chunks := models.NewMemoryChunkModel()
+    return &application{
+        errorLog:           log.New(io.Discard, "", 0),
+        infoLog:            log.New(io.Discard, "", 0),
+        chunks:             chunks,
+        templateCache:      templateCache,
+        sessionManager:     sessionManager,
+        branding:           siteBranding,
+        legal:              &legalPages{},
+        signer:             signing.New(key),
+        editWindow:         24 * time.Hour,
+        shareLinkTTL:       7 * 24 * time.Hour,
+        maxBatchSize:       100,
+        allowAnonymous:     true,
+        previewChars:       120,
+        maxPage:            100,
+        searchSnippetChars: 160,
+        maxSearchLen:       256,
+        searchTooLong:      searchTooLongReject,
+        maxTitleLength:     100,
+        languages:          languages,
+        maxChunkBytes:      65535,
+        minChunkChars:      1,
+        maxTags:            10,
+        maxTagLength:       30,
+        maxFiles:           10,
+        quota:              newChunkQuota(chunks, 0, false),
+        anonExpiry:         expiryPolicy{Default: 365, Max: 365},
+        userExpiry:         expiryPolicy{Default: 365, Max: 365},
+        keepAliveMaxLifetime: 365 * 24 * time.Hour,
+        secretAction:       secretBlock,
+        uniquePerUser:      uniqueOff,
+        uniqueAnonymous:    uniqueAnonymousExempt,
+        background:         newRunGroup(),
+        highlighter:        highlighter,
+        highlightCache:     highlight.NewCache(32 << 20),
+        highlightJobs:      semaphore.NewWeighted(4),
+        highlightWait:      2 * time.Second,
+        maxRenderBytes:     1 << 20,
+        metrics:            &appMetrics{},
+        detectThreshold:    0.5,
+        normalizeNewlines:  true,
+        stripBOM:           stripBOMInsert,
+        postCreateRedirect: postCreateView,
+        wrap:               wrapSoft,
+        wrapWidth:          200,
+        prettyPrint:        true,
+        canonicalHostExempt: map[string]bool{},
+        maxCookieBytes:     8192,
+        banner:             siteBanner,
+    }
+}
+
+// A testServer runs the application's routes for a test. Its client keeps
+// cookies, like a browser, and doesn't follow redirects, so they can be
+// checked.
+type testServer struct {
+    *httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+    t.Helper()
+
+    ts := httptest.NewServer(h)
+    t.Cleanup(ts.Close)
+
+    jar, err := cookiejar.New(nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    ts.Client().Jar = jar
+    ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+        return http.ErrUseLastResponse
+    }
+    return &testServer{ts}
+}
+
+// do sends a request to the server and returns the response, with its body
+// read into a string.
+func (ts *testServer) do(t *testing.T, req *http.Request) (*http.Response, string) {
+    t.Helper()
+
+    resp, err := ts.Client().Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return resp, string(body)
+}
+
+// request builds a request for the path of the server.
+func (ts *testServer) request(t *testing.T, method, path string, body io.Reader) *http.Request {
+    t.Helper()
+
+    req, err := http.NewRequest(method, ts.URL+path, body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return req
+}
+
+func (ts *testServer) get(t *testing.T, path string) (*http.Response, string) {
+    t.Helper()
+    return ts.do(t, ts.request(t, http.MethodGet, path, nil))
+}
+
+// postForm posts a form, with the CSRF token of the page it is on added,
+// and its form token if it has one.
+func (ts *testServer) postForm(t *testing.T, path string, form url.Values) (*http.Response, string) {
+    t.Helper()
+
+    return ts.postFormFrom(t, path, path, form)
+}
+
+// postFormFrom is like postForm for a form on the page at pagePath, which
+// posts to another path.
+func (ts *testServer) postFormFrom(t *testing.T, pagePath, path string, form url.Values) (*http.Response, string) {
+    t.Helper()
+
+    _, page := ts.get(t, pagePath)
+    form.Set("csrf_token", extractField(t, page, "csrf_token"))
+    if token, ok := findField(page, "form_token"); ok && form.Get("form_token") == "" {
+        form.Set("form_token", token)
+    }
+    req := ts.request(t, http.MethodPost, path, bytes.NewBufferString(form.Encode()))
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return ts.do(t, req)
+}
+
+// postJSON posts a JSON body to an API endpoint.
+func (ts *testServer) postJSON(t *testing.T, path, body string) (*http.Response, string) {
+    t.Helper()
+
+    req := ts.request(t, http.MethodPost, path, bytes.NewBufferString(body))
+    req.Header.Set("Content-Type", "application/json")
+    return ts.do(t, req)
+}
+
+// extractField returns the value of the hidden form field with the name in
+// a page.
+func extractField(t *testing.T, page, name string) string {
+    t.Helper()
+
+    value, ok := findField(page, name)
+    if !ok {
+        t.Fatalf("no %s field in the page", name)
+    }
+    return value
+}
+
+// findField is extractField for a field the page may not have.
+func findField(page, name string) (string, bool) {
+    rx := regexp.MustCompile(`name='` + regexp.QuoteMeta(name) + `' value='([^']*)'`)
+    m := rx.FindStringSubmatch(page)
+    if m == nil {
+        return "", false
+    }
+    return html.UnescapeString(m[1]), true
+}
+
+// insertChunk adds a chunk straight to the store, and returns its public ID.
+func insertChunk(t *testing.T, app *application, title, content string) string {
+    t.Helper()
+
+    id, err := app.chunks.Insert(title, content, 7, highlight.PlainText, 0, false, false, false, nil, nil, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return id
+}
+
+// createForm returns the fields of the create form for a chunk with the
+// title and content, as a browser would post them.
+func createForm(title, content string) url.Values {
+    return url.Values{
+        "title":    {title},
+        "content":  {content},
+        "expires":  {"7"},
+        "language": {highlight.PlainText},
+    }
+}
+
+// chunkIDFrom returns the public ID of the chunk a response redirects to.
+func chunkIDFrom(t *testing.T, resp *http.Response) string {
+    t.Helper()
+
+    loc, err := resp.Location()
+    if err != nil {
+        t.Fatalf("status %d with no redirect: %v", resp.StatusCode, err)
+    }
+    id := loc.Query().Get("id")
+    if id == "" {
+        t.Fatalf("redirected to %s, not a chunk", loc)
+    }
+    return id
+}
+
+// memoryUsers is a models.UserStore for tests, which keeps the accounts in
+// memory with their passwords in the clear. A user without a password
+// signed up through an OAuth provider.
+type memoryUsers struct {
+    mu         sync.Mutex
+    users      map[int]*models.User
+    passwords  map[int]string
+    identities map[string]int
+    nextID     int
+}
+
+// withUsers gives the application accounts, kept by a memoryUsers, before
+// routes() is called, and returns the store.
+func withUsers(app *application) *memoryUsers {
+    users := &memoryUsers{users: map[int]*models.User{}, passwords: map[int]string{}, identities: map[string]int{}}
+    app.users = users
+    return users
+}
+
+// add creates a user, with no password for an OAuth-only one, and returns
+// their ID.
+func (m *memoryUsers) add(t *testing.T, name, email, password string) int {
+    t.Helper()
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    id, err := m.insert(name, email, password)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return id
+}
+
+func (m *memoryUsers) insert(name, email, password string) (int, error) {
+    for _, u := range m.users {
+        if u.Email == email {
+            return 0, models.ErrDuplicateEmail
+        }
+    }
+    m.nextID++
+    m.users[m.nextID] = &models.User{ID: m.nextID, Name: name, Email: email, Created: time.Now(), HasPassword: password != ""}
+    m.passwords[m.nextID] = password
+    return m.nextID, nil
+}
+
+func (m *memoryUsers) Insert(name, email, password string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _, err := m.insert(name, email, password)
+    return err
+}
+
+func (m *memoryUsers) Authenticate(email, password string) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for id, u := range m.users {
+        if u.Email == email && m.passwords[id] != "" && m.passwords[id] == password {
+            return id, nil
+        }
+    }
+    return 0, models.ErrInvalidCredentials
+}
+
+func (m *memoryUsers) Exists(id int) (bool, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _, ok := m.users[id]
+    return ok, nil
+}
+
+func (m *memoryUsers) Get(id int) (*models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    u, ok := m.users[id]
+    if !ok {
+        return nil, models.ErrNoRecord
+    }
+    user := *u
+    return &user, nil
+}
+
+func (m *memoryUsers) CheckPassword(id int, password string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if _, ok := m.users[id]; !ok {
+        return models.ErrNoRecord
+    }
+    if m.passwords[id] == "" || m.passwords[id] != password {
+        return models.ErrInvalidCredentials
+    }
+    return nil
+}
+
+func (m *memoryUsers) UpdateName(id int, name string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.users[id].Name = name
+    return nil
+}
+
+func (m *memoryUsers) SetPendingEmail(id int, email string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.users[id].PendingEmail = email
+    return nil
+}
+
+func (m *memoryUsers) ConfirmEmail(id int, email string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    u := m.users[id]
+    if u.PendingEmail == "" || u.PendingEmail != email {
+        return models.ErrNoRecord
+    }
+    for other, o := range m.users {
+        if other != id && o.Email == email {
+            return models.ErrDuplicateEmail
+        }
+    }
+    u.Email, u.PendingEmail, u.EmailVerified = email, "", true
+    return nil
+}
+
+func (m *memoryUsers) PasswordUpdate(id int, currentPassword, newPassword string) error {
+    if err := m.CheckPassword(id, currentPassword); err != nil {
+        return err
+    }
+    return m.SetPassword(id, newPassword)
+}
+
+func (m *memoryUsers) SetPassword(id int, newPassword string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.passwords[id] = newPassword
+    m.users[id].HasPassword = true
+    return nil
+}
+
+func (m *memoryUsers) IdentityUserID(provider, subject string) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    id, ok := m.identities[provider+"/"+subject]
+    if !ok {
+        return 0, models.ErrNoRecord
+    }
+    return id, nil
+}
+
+func (m *memoryUsers) IDByEmail(email string) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for id, u := range m.users {
+        if u.Email == email {
+            return id, nil
+        }
+    }
+    return 0, models.ErrNoRecord
+}
+
+func (m *memoryUsers) LinkIdentity(userID int, provider, subject string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.identities[provider+"/"+subject] = userID
+    return nil
+}
+
+func (m *memoryUsers) InsertWithIdentity(name, email string, emailVerified bool, provider, subject string) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    id, err := m.insert(name, email, "")
+    if err != nil {
+        return 0, err
+    }
+    m.users[id].EmailVerified = emailVerified
+    m.identities[provider+"/"+subject] = id
+    return id, nil
+}
+
+func (m *memoryUsers) Delete(id int, deleteChunks bool) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if _, ok := m.users[id]; !ok {
+        return models.ErrNoRecord
+    }
+    delete(m.users, id)
+    delete(m.passwords, id)
+    return nil
+}
+
+// login logs the client of the server in with the email and password.
+func (ts *testServer) login(t *testing.T, email, password string) {
+    t.Helper()
+
+    resp, body := ts.postForm(t, "/user/login", url.Values{"email": {email}, "password": {password}})
+    if resp.StatusCode != http.StatusSeeOther {
+        t.Fatalf("logging in as %s: status %d: %s", email, resp.StatusCode, body)
+    }
+}
@@ -0,0 +1,135 @@
+/*-----------------------------------------------------------
+ @Filename:         unique.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// With -unique-per-user, a user can't have two chunks with the same content
+// at a time: content is compared by its SHA-256 (the content_sha256 column)
+// with that of their chunks which haven't expired. With reject, a chunk
+// they have already is refused with a link to it; with redirect, they are
+// sent to it as if it had just been created, and API clients get it in the
+// response in place of a new one. Anonymous chunks are exempt, unless
+// -unique-anonymous=ip, which compares them with the anonymous chunks from
+// the same address.
+//
+// Only the content of a chunk is compared, not its title or its other
+// files. Two requests with the same content at the same moment can both
+// get through, as nothing stops them in the database.
+const (
+    uniqueOff      = "off"
+    uniqueReject   = "reject"
+    uniqueRedirect = "redirect"
+
+    uniqueAnonymousExempt = "exempt"
+    uniqueAnonymousIP     = "ip"
+)
+
+// uniqueApplies reports whether -unique-per-user applies to the creator of
+// a chunk.
+func (app *application) uniqueApplies(r *http.Request) bool {
+    if app.uniquePerUser == uniqueOff {
+        return false
+    }
+    return app.isAuthenticated(r) || app.uniqueAnonymous == uniqueAnonymousIP
+}
+
+// existingContent returns the creator's chunk with the content, or nil if
+// there is none or -unique-per-user doesn't apply to them.
+func (app *application) existingContent(r *http.Request, content string) (*models.Chunk, error) {
+    if !app.uniqueApplies(r) {
+        return nil, nil
+    }
+    existing, err := app.chunks.ExistsContent(app.authenticatedUserID(r), app.realIP(r), models.ContentSHA256(content))
+    if errors.Is(err, models.ErrNoRecord) {
+        return nil, nil
+    }
+    return existing, err
+}
+
+// existingContents is existingContent for the chunks of an API request, by
+// index. The chunks which repeat the content of an earlier one in the
+// request are in repeats instead, with the index of the first. Both are nil
+// if -unique-per-user doesn't apply.
+func (app *application) existingContents(r *http.Request, inputs []models.ChunkInput) (existing []*models.Chunk, repeats map[int]int, err error) {
+    if !app.uniqueApplies(r) {
+        return nil, nil, nil
+    }
+    existing = make([]*models.Chunk, len(inputs))
+    first := make(map[string]int, len(inputs))
+    for i, in := range inputs {
+        existing[i], err = app.existingContent(r, in.Content)
+        if err != nil {
+            return nil, nil, err
+        }
+        if existing[i] != nil {
+            continue
+        }
+        sum := models.ContentSHA256(in.Content)
+        if j, ok := first[sum]; ok {
+            if repeats == nil {
+                repeats = make(map[int]int)
+            }
+            repeats[i] = j
+            continue
+        }
+        first[sum] = i
+    }
+    return existing, repeats, nil
+}
+
+// duplicateErrors are the item errors of an API request refused with
+// -unique-per-user=reject, with the URL of the chunk each item duplicates.
+func (app *application) duplicateErrors(r *http.Request, existing []*models.Chunk, repeats map[int]int) []apiItemError {
+    var items []apiItemError
+    for i := range existing {
+        switch j, repeat := repeats[i]; {
+        case existing[i] != nil:
+            items = append(items, apiItemError{
+                Index:       i,
+                Errors:      map[string]string{"content": "You already have a chunk with this content"},
+                ExistingURL: app.absoluteURL(r, "/chunkbox/view?id="+existing[i].PublicID),
+            })
+        case repeat:
+            items = append(items, apiItemError{
+                Index:  i,
+                Errors: map[string]string{"content": fmt.Sprintf("This is the same content as item %d of the request", j)},
+            })
+        }
+    }
+    return items
+}
+
+// existingEnvelope describes an existing chunk returned by the API in place
+// of a new one, like createAPIChunks does a new chunk. It has no edit link:
+// that was only given out when the chunk was created.
+func (app *application) existingEnvelope(r *http.Request, chunk *models.Chunk, content string) envelope {
+    return envelope{
+        "id":       chunk.PublicID,
+        "url":      app.absoluteURL(r, "/chunkbox/view?id="+chunk.PublicID),
+        "raw_url":  app.absoluteURL(r, "/chunkbox/raw?id="+chunk.PublicID),
+        "language": chunk.Language,
+        "sha256":   models.ContentSHA256(content),
+        "existing": true,
+    }
+}
+
+// createdStatus is the status of a response with the chunks createAPIChunks
+// returned: 201 Created, or 200 OK if they all existed already.
+func createdStatus(created []envelope) int {
+    for _, c := range created {
+        if c["existing"] != true {
+            return http.StatusCreated
+        }
+    }
+    return http.StatusOK
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "encoding/json"
+    "html"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+// uniqueServer returns a server with -unique-per-user set to mode, for
+// anonymous chunks too, which are all from the test's address.
+func uniqueServer(t *testing.T, mode string) *testServer {
+    t.Helper()
+
+    app := newTestApplication(t)
+    app.uniquePerUser = mode
+    app.uniqueAnonymous = uniqueAnonymousIP
+    return newTestServer(t, app.routes())
+}
+
+func TestUniqueContentForm(t *testing.T) {
+    create := func(t *testing.T, ts *testServer, title, content string) (*http.Response, string) {
+        t.Helper()
+        return ts.postForm(t, "/chunkbox/create", createForm(title, content))
+    }
+
+    t.Run("off", func(t *testing.T) {
+        ts := uniqueServer(t, uniqueOff)
+        resp, _ := create(t, ts, "First", "same content")
+        first := chunkIDFrom(t, resp)
+        resp, _ = create(t, ts, "Second", "same content")
+        if second := chunkIDFrom(t, resp); second == first {
+            t.Errorf("the same content wasn't saved again")
+        }
+    })
+
+    t.Run("exempt", func(t *testing.T) {
+        app := newTestApplication(t)
+        app.uniquePerUser = uniqueReject
+        ts := newTestServer(t, app.routes())
+        resp, _ := create(t, ts, "First", "same content")
+        first := chunkIDFrom(t, resp)
+        resp, _ = create(t, ts, "Second", "same content")
+        if second := chunkIDFrom(t, resp); second == first {
+            t.Errorf("an anonymous chunk wasn't exempt")
+        }
+    })
+
+    t.Run("reject", func(t *testing.T) {
+        ts := uniqueServer(t, uniqueReject)
+        resp, _ := create(t, ts, "First", "same content")
+        first := chunkIDFrom(t, resp)
+
+        // Only the content counts, not the title.
+        resp, body := create(t, ts, "Second", "same content")
+        if resp.StatusCode != http.StatusConflict {
+            t.Fatalf("status %d", resp.StatusCode)
+        }
+        if !strings.Contains(html.UnescapeString(body), "/chunkbox/view?id="+first) {
+            t.Errorf("no link to the existing chunk in the page")
+        }
+
+        resp, _ = create(t, ts, "Third", "other content")
+        if id := chunkIDFrom(t, resp); id == first {
+            t.Errorf("other content sent to the existing chunk")
+        }
+    })
+
+    t.Run("redirect", func(t *testing.T) {
+        ts := uniqueServer(t, uniqueRedirect)
+        resp, _ := create(t, ts, "First", "same content")
+        first := chunkIDFrom(t, resp)
+
+        resp, _ = create(t, ts, "Second", "same content")
+        if resp.StatusCode != http.StatusSeeOther {
+            t.Fatalf("status %d", resp.StatusCode)
+        }
+        if id := chunkIDFrom(t, resp); id != first {
+            t.Errorf("redirected to %s, want the existing chunk %s", id, first)
+        }
+        _, page := ts.get(t, "/chunkbox/view?id="+first)
+        if !strings.Contains(html.UnescapeString(page), "wasn't saved again") {
+            t.Errorf("no flash about the existing chunk")
+        }
+
+        form := createForm("Third", "same content")
+        form.Set("redirect", postCreateRaw)
+        resp, _ = ts.postForm(t, "/chunkbox/create", form)
+        if loc := resp.Header.Get("Location"); loc != "/chunkbox/raw?id="+first {
+            t.Errorf("raw redirect to %q", loc)
+        }
+    })
+}
+
+// batchResult is the response of the batch API, for a success or an error.
+type batchResult struct {
+    Chunks []struct {
+        ID       string `json:"id"`
+        URL      string `json:"url"`
+        Existing bool   `json:"existing"`
+    } `json:"chunks"`
+    Error struct {
+        Code  string         `json:"code"`
+        Items []apiItemError `json:"items"`
+    } `json:"error"`
+}
+
+func postBatch(t *testing.T, ts *testServer, body string) (int, batchResult) {
+    t.Helper()
+
+    resp, js := ts.postJSON(t, "/api/v1/chunks/batch", body)
+    var result batchResult
+    if err := json.Unmarshal([]byte(js), &result); err != nil {
+        t.Fatalf("%s: %v", js, err)
+    }
+    return resp.StatusCode, result
+}
+
+func TestUniqueContentAPI(t *testing.T) {
+    t.Run("reject", func(t *testing.T) {
+        ts := uniqueServer(t, uniqueReject)
+        status, first := postBatch(t, ts, `[{"title": "First", "content": "same content"}]`)
+        if status != http.StatusCreated {
+            t.Fatalf("status %d", status)
+        }
+
+        status, result := postBatch(t, ts, `[{"title": "New", "content": "new content"}, {"title": "Second", "content": "same content"}, {"title": "Again", "content": "new content"}]`)
+        if status != http.StatusConflict || result.Error.Code != "duplicate_content" {
+            t.Fatalf("status %d, code %q", status, result.Error.Code)
+        }
+        items := result.Error.Items
+        if len(items) != 2 {
+            t.Fatalf("items %+v", items)
+        }
+        if items[0].Index != 1 || items[0].ExistingURL != first.Chunks[0].URL {
+            t.Errorf("item %+v, want index 1 linking to %s", items[0], first.Chunks[0].URL)
+        }
+        if items[1].Index != 2 || !strings.Contains(items[1].Errors["content"], "item 0") {
+            t.Errorf("item %+v, want index 2 repeating item 0", items[1])
+        }
+
+        // Nothing was created, so the new content is still new.
+        if status, _ := postBatch(t, ts, `[{"title": "New", "content": "new content"}]`); status != http.StatusCreated {
+            t.Errorf("status %d after the rejected request", status)
+        }
+    })
+
+    t.Run("redirect", func(t *testing.T) {
+        ts := uniqueServer(t, uniqueRedirect)
+        _, first := postBatch(t, ts, `[{"title": "First", "content": "same content"}]`)
+
+        status, result := postBatch(t, ts, `[{"title": "New", "content": "new content"}, {"title": "Second", "content": "same content"}, {"title": "Again", "content": "new content"}]`)
+        if status != http.StatusCreated || len(result.Chunks) != 3 {
+            t.Fatalf("status %d, %d chunks", status, len(result.Chunks))
+        }
+        chunks := result.Chunks
+        if chunks[0].Existing || chunks[0].ID == first.Chunks[0].ID {
+            t.Errorf("new content returned as %+v", chunks[0])
+        }
+        if !chunks[1].Existing || chunks[1].ID != first.Chunks[0].ID {
+            t.Errorf("existing content returned as %+v, want %s", chunks[1], first.Chunks[0].ID)
+        }
+        if !chunks[2].Existing || chunks[2].ID != chunks[0].ID {
+            t.Errorf("repeat returned as %+v, want %s", chunks[2], chunks[0].ID)
+        }
+
+        // Nothing new, so nothing was created.
+        status, result = postBatch(t, ts, `[{"title": "Again", "content": "same content"}]`)
+        if status != http.StatusOK || len(result.Chunks) != 1 || result.Chunks[0].ID != first.Chunks[0].ID {
+            t.Errorf("status %d, chunks %+v", status, result.Chunks)
+        }
+    })
+}
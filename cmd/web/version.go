@@ -0,0 +1,54 @@
+/*-----------------------------------------------------------
+ @Filename:         version.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "net/http"
+    "runtime/debug"
+)
+
+// Build metadata, set at build time with the linker:
+//
+//  go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse HEAD) \
+//      -X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/web
+//
+// A plain go build from a git checkout still reports the commit, and the
+// commit's time in place of the build time, from the VCS information Go
+// embeds; see init.
+var (
+    version   = "dev"
+    commit    = "unknown"
+    buildTime = "unknown"
+)
+
+func init() {
+    info, ok := debug.ReadBuildInfo()
+    if !ok {
+        return
+    }
+    for _, s := range info.Settings {
+        switch {
+        case s.Key == "vcs.revision" && commit == "unknown":
+            commit = s.Value
+        case s.Key == "vcs.time" && buildTime == "unknown":
+            buildTime = s.Value
+        }
+    }
+}
+
+// versionHandler reports which build is running. It is not behind any
+// middleware, so deploy scripts can poll it without a session.
+func (app *application) versionHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet && r.Method != http.MethodHead {
+        app.methodNotAllowed(w, http.MethodGet, http.MethodHead)
+        return
+    }
+    app.writeJSON(w, http.StatusOK, envelope{
+        "version":    version,
+        "commit":     commit,
+        "build_time": buildTime,
+    })
+}
@@ -0,0 +1,96 @@
+/*-----------------------------------------------------------
+ @Filename:         views.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// A viewCounter collects the views of chunks in memory, so showing a chunk
+// doesn't have to write to the database. Every -view-flush-interval the
+// counts are added to the database in one go (ChunkStore.AddViews), and
+// once more on shutdown. Counts which haven't been flushed yet are lost if
+// the server crashes. Views served from the response cache are counted too.
+type viewCounter struct {
+    mu      sync.Mutex
+    pending map[int]int64
+}
+
+func newViewCounter() *viewCounter {
+    return &viewCounter{pending: make(map[int]int64)}
+}
+
+// add counts a view of the chunk with the id and returns how many of its
+// views are waiting to be flushed, this one included.
+func (c *viewCounter) add(id int) int64 {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.pending[id]++
+    return c.pending[id]
+}
+
+// take returns the counts waiting to be flushed and starts again from none.
+func (c *viewCounter) take() map[int]int64 {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    counts := c.pending
+    c.pending = make(map[int]int64, len(counts))
+    return counts
+}
+
+// putBack adds counts which couldn't be flushed back, for the next flush.
+func (c *viewCounter) putBack(counts map[int]int64) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for id, n := range counts {
+        c.pending[id] += n
+    }
+}
+
+// countView counts a view of the chunk with the id and returns how many
+// views to show on top of the ones it was loaded with. With a
+// -view-flush-interval of 0 the view is written straight away.
+func (app *application) countView(id int) (int64, error) {
+    if app.views == nil {
+        return 1, app.chunks.AddViews(map[int]int64{id: 1})
+    }
+    return app.views.add(id), nil
+}
+
+// flushViews writes the pending view counts to the database. If that
+// fails they are kept for the next try.
+func (app *application) flushViews() error {
+    counts := app.views.take()
+    if err := app.chunks.AddViews(counts); err != nil {
+        app.views.putBack(counts)
+        return err
+    }
+    return nil
+}
+
+// startViewFlusher flushes the view counts every interval until shutdown,
+// and a last time then, before the database is closed.
+func (app *application) startViewFlusher(interval time.Duration) {
+    app.background.Go(func(ctx context.Context) {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                if err := app.flushViews(); err != nil {
+                    app.errorLog.Printf("flushing view counts: %v", err)
+                }
+            case <-ctx.Done():
+                if err := app.flushViews(); err != nil {
+                    app.errorLog.Printf("flushing view counts: %v (they are lost)", err)
+                }
+                return
+            }
+        }
+    })
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+)
+
+// viewsOf returns the views of a chunk saved in the store.
+func viewsOf(t *testing.T, app *application, id string) int64 {
+    t.Helper()
+
+    chunk, err := app.chunks.GetByPublicID(id)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return chunk.Views
+}
+
+// viewConcurrently views the chunk n times, from as many goroutines.
+func viewConcurrently(t *testing.T, ts *testServer, id string, n int) {
+    t.Helper()
+
+    var wg sync.WaitGroup
+    errs := make(chan error, n)
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            resp, err := http.Get(ts.URL + "/chunkbox/view?id=" + id)
+            if err != nil {
+                errs <- err
+                return
+            }
+            resp.Body.Close()
+            if resp.StatusCode != http.StatusOK {
+                errs <- &statusError{resp.StatusCode}
+            }
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Fatal(err)
+    }
+}
+
+type statusError struct{ status int }
+
+func (e *statusError) Error() string { return http.StatusText(e.status) }
+
+func TestViewCountsFlushed(t *testing.T) {
+    app := newTestApplication(t)
+    app.views = newViewCounter()
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Viewed", "content")
+
+    const views = 200
+    viewConcurrently(t, ts, id, views)
+
+    // Nothing is written until the flush.
+    if got := viewsOf(t, app, id); got != 0 {
+        t.Fatalf("views before the flush = %d, want 0", got)
+    }
+    if err := app.flushViews(); err != nil {
+        t.Fatal(err)
+    }
+    if got := viewsOf(t, app, id); got != views {
+        t.Errorf("views after the flush = %d, want %d", got, views)
+    }
+}
+
+func TestViewCountsFlushedOnShutdown(t *testing.T) {
+    app := newTestApplication(t)
+    app.views = newViewCounter()
+    // The ticker never fires during the test, so only shutdown flushes.
+    app.startViewFlusher(time.Hour)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Viewed", "content")
+
+    const views = 50
+    viewConcurrently(t, ts, id, views)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    if err := app.background.Shutdown(ctx); err != nil {
+        t.Fatal(err)
+    }
+    if got := viewsOf(t, app, id); got != views {
+        t.Errorf("views after shutdown = %d, want %d", got, views)
+    }
+}
+
+func TestViewCountsWithResponseCache(t *testing.T) {
+    app := newTestApplication(t)
+    app.views = newViewCounter()
+    app.responseCache = newResponseCache(1<<20, time.Hour)
+    ts := newTestServer(t, app.routes())
+    id := insertChunk(t, app, "Viewed", "content")
+
+    const views = 20
+    for i := 0; i < views; i++ {
+        resp, _ := ts.get(t, "/chunkbox/view?id="+id)
+        if resp.StatusCode != http.StatusOK {
+            t.Fatalf("view %d: status %d", i, resp.StatusCode)
+        }
+        // The session cookie would keep the page out of the cache.
+        ts.Client().Jar = nil
+    }
+    if hits := app.metrics.responseCacheHits.Load(); hits != views-1 {
+        t.Fatalf("cache hits = %d, want %d", hits, views-1)
+    }
+    if err := app.flushViews(); err != nil {
+        t.Fatal(err)
+    }
+    if got := viewsOf(t, app, id); got != views {
+        t.Errorf("views = %d, want %d", got, views)
+    }
+}
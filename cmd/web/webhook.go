@@ -0,0 +1,60 @@
+/*-----------------------------------------------------------
+ @Filename:         webhook.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+)
+
+// chunkCreatedEvent is the body of the chunk.created webhook, sent to
+// -webhook-url whenever a chunk is created. See internal/webhook for how it
+// is signed.
+type chunkCreatedEvent struct {
+    Event    string    `json:"event"`
+    ID       string    `json:"id"`
+    URL      string    `json:"url"`
+    Title    string    `json:"title"`
+    Language string    `json:"language"`
+    Private  bool      `json:"private"`
+    Created  time.Time `json:"created"`
+}
+
+// notifyCreated sends the chunk.created webhook for a new chunk, if one is
+// configured. It is sent in the background so the consumer can't slow down
+// the response, and a failed delivery is only logged. A delivery which has
+// started is finished during shutdown (within the grace period), so it
+// doesn't use the group's context.
+func (app *application) notifyCreated(r *http.Request, id, title, language string, private bool) {
+    if app.webhook == nil {
+        return
+    }
+
+    payload, err := json.Marshal(chunkCreatedEvent{
+        Event:    "chunk.created",
+        ID:       id,
+        URL:      app.absoluteURL(r, "/chunkbox/view?id="+id),
+        Title:    title,
+        Language: language,
+        Private:  private,
+        Created:  time.Now().UTC().Truncate(time.Second),
+    })
+    if err != nil {
+        app.errorLog.Printf("webhook: %v", err)
+        return
+    }
+
+    started := app.background.Go(func(context.Context) {
+        if err := app.webhook.Send(context.Background(), payload); err != nil {
+            app.errorLog.Printf("webhook: chunk %s: %v", id, err)
+        }
+    })
+    if !started {
+        app.errorLog.Printf("webhook: chunk %s: not sent, shutting down", id)
+    }
+}
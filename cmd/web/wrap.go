@@ -0,0 +1,142 @@
+/*-----------------------------------------------------------
+ @Filename:         wrap.go
+ @Copyright Author: Yogesh K
+ @Date:             14/10/2026
+-------------------------------------------------------------*/
+package main
+
+import (
+    "net/http"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/cpucortexm/chunkbox/internal/models"
+)
+
+// How long lines are displayed on the view page. The default comes from the
+// -wrap flag, and visitors can pick another one with the "wrap" query
+// parameter. Only the page changes: the raw and download endpoints always
+// serve the content as it was saved.
+const (
+    // wrapSoft wraps long lines at the edge of the page.
+    wrapSoft = "soft"
+    // wrapTruncate cuts lines after -wrap-width characters.
+    wrapTruncate = "truncate"
+    // wrapNone leaves lines alone, so the box scrolls sideways.
+    wrapNone = "none"
+)
+
+// wrapModes are the choices for -wrap and the ?wrap= parameter, in the order
+// they are offered on the page, with their link text.
+var wrapModes = []struct{ Name, Label string }{
+    {wrapSoft, "wrap"},
+    {wrapTruncate, "cut"},
+    {wrapNone, "scroll"},
+}
+
+// validWrapMode reports whether mode is one of the wrapModes.
+func validWrapMode(mode string) bool {
+    for _, m := range wrapModes {
+        if m.Name == mode {
+            return true
+        }
+    }
+    return false
+}
+
+// A wrapOption is a link on the view page to switch to another wrap mode.
+type wrapOption struct {
+    Label   string
+    URL     string
+    Current bool
+}
+
+// wrapMode returns the wrap mode for this request: the one in the query
+// string if it is valid, otherwise the -wrap default.
+func (app *application) wrapMode(r *http.Request) string {
+    if mode := r.URL.Query().Get("wrap"); validWrapMode(mode) {
+        return mode
+    }
+    return app.wrap
+}
+
+// displayChunk adds a chunk to the template data for the view page, wrapped
+// and pretty printed the way this request asked for. The links to the other modes keep the
+// rest of the query string, so they work for share links too.
+func (app *application) displayChunk(r *http.Request, data *templateData, chunk *models.Chunk) {
+    mode := app.wrapMode(r)
+
+    // Pretty printing and truncating work on a copy, so the cached
+    // highlighting of the chunk as saved isn't mixed up with theirs.
+    display, variant := chunk, ""
+    if printer := app.prettyPrinter(chunk.Language); printer != nil {
+        pretty := r.URL.Query().Get("pretty") == "1"
+        if pretty {
+            content, err := printer(chunk.Content)
+            if err != nil {
+                data.PrettyFailed = true
+                pretty = false
+            } else {
+                c := *chunk
+                c.Content = content
+                display = &c
+                variant = prettyVariant
+            }
+        }
+        data.Pretty = pretty
+        data.PrettyURL = app.prettyURL(r, pretty)
+    }
+    if mode == wrapTruncate {
+        content, cut := truncateLines(display.Content, app.wrapWidth)
+        if cut > 0 {
+            c := *display
+            c.Content = content
+            display = &c
+            if variant != "" {
+                variant += "-"
+            }
+            variant += app.truncateVariant()
+            data.TruncatedLines = cut
+        }
+    }
+
+    data.Chunk = display
+    // Rendering a huge chunk takes a lot of memory, so past
+    // -max-render-bytes it is shown as plain text, with a notice.
+    if app.maxRenderBytes > 0 && len(display.Content) > app.maxRenderBytes {
+        data.RenderedPlain = true
+    } else {
+        data.Highlighted = app.highlightChunk(r.Context(), display, variant)
+    }
+    data.Wrap = mode
+    data.WrapWidth = app.wrapWidth
+
+    query := r.URL.Query()
+    for _, m := range wrapModes {
+        query.Set("wrap", m.Name)
+        data.WrapOptions = append(data.WrapOptions, wrapOption{
+            Label:   m.Label,
+            URL:     app.url(r.URL.Path + "?" + query.Encode()),
+            Current: m.Name == mode,
+        })
+    }
+}
+
+// truncateLines cuts every line of s which is longer than width characters
+// down to width, marking the cut with "…". It returns the result and the
+// number of lines it cut.
+func truncateLines(s string, width int) (string, int) {
+    lines := strings.Split(s, "\n")
+    cut := 0
+    for i, line := range lines {
+        if utf8.RuneCountInString(line) <= width {
+            continue
+        }
+        lines[i] = string([]rune(line)[:width]) + "…"
+        cut++
+    }
+    if cut == 0 {
+        return s, 0
+    }
+    return strings.Join(lines, "\n"), cut
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func TestMaxRenderBytes(t *testing.T) {
+    const notice = "Rendered as plain text (too large)."
+    content := "package main\n\nfunc main() { println(\"<hi>\") }\n"
+
+    tests := []struct {
+        name           string
+        maxRenderBytes int
+        wantPlain      bool
+    }{
+        {"under the limit", len(content), false},
+        {"over the limit", len(content) - 1, true},
+        {"no limit", 0, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            app := newTestApplication(t)
+            app.maxRenderBytes = tt.maxRenderBytes
+            ts := newTestServer(t, app.routes())
+            id, err := app.chunks.Insert("Go", content, 7, "go", 0, false, false, false, nil, nil, "")
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            resp, page := ts.get(t, "/chunkbox/view?id="+id)
+            if resp.StatusCode != http.StatusOK {
+                t.Fatalf("status %d", resp.StatusCode)
+            }
+            if plain := strings.Contains(page, notice); plain != tt.wantPlain {
+                t.Errorf("notice shown = %t, want %t", plain, tt.wantPlain)
+            }
+            plainText := "<pre><code>package main\n\nfunc main() { println(&#34;&lt;hi&gt;&#34;) }\n</code></pre>"
+            if strings.Contains(page, plainText) != tt.wantPlain {
+                t.Errorf("content shown as plain text = %t, want %t", !tt.wantPlain, tt.wantPlain)
+            }
+
+            // The raw content is the same either way.
+            if _, raw := ts.get(t, "/chunkbox/raw?id="+id); raw != content {
+                t.Errorf("raw %q", raw)
+            }
+        })
+    }
+}
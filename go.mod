@@ -2,4 +2,27 @@ module github.com/cpucortexm/chunkbox
 
 go 1.20
 
-require github.com/go-sql-driver/mysql v1.7.0 // indirect
+require (
+	github.com/alecthomas/chroma/v2 v2.10.0
+	github.com/alexedwards/scs/mysqlstore v0.0.0-20230327161757-10d4299e3b24
+	github.com/alexedwards/scs/redisstore v0.0.0-20230327161757-10d4299e3b24
+	github.com/alexedwards/scs/v2 v2.5.1
+	github.com/go-sql-driver/mysql v1.7.0
+	github.com/gomodule/redigo v1.8.0
+	github.com/justinas/alice v1.2.0
+	github.com/justinas/nosurf v1.1.1
+	github.com/yuin/goldmark v1.5.6
+	golang.org/x/crypto v0.14.0
+	golang.org/x/image v0.13.0
+	golang.org/x/oauth2 v0.13.0
+	golang.org/x/sync v0.4.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.16.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
+	google.golang.org/appengine v1.6.7 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
@@ -0,0 +1,100 @@
+package blocklist
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strings"
+)
+
+// A Blocklist matches text against a list of patterns loaded from a file.
+// Each non-blank line of the file is a pattern; lines starting with # are
+// comments. A pattern wrapped in slashes (like /fr[e3]e\s+money/) is a
+// regular expression, anything else is matched as a plain substring. All
+// matching is case-insensitive.
+//
+// Rather than looping over the patterns for every check, they are all
+// compiled into a single alternation. Go's regexp package guarantees
+// linear time matching, so a check costs one pass over the text no matter
+// how long the list is.
+type Blocklist struct {
+    rx    *regexp.Regexp
+    count int
+}
+
+// Load reads the patterns from the file at path.
+func Load(path string) (*Blocklist, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    return Parse(f)
+}
+
+// Parse reads newline-separated patterns from r.
+func Parse(r io.Reader) (*Blocklist, error) {
+    var alternatives []string
+
+    scanner := bufio.NewScanner(r)
+    line := 0
+    for scanner.Scan() {
+        line++
+        pattern := strings.TrimSpace(scanner.Text())
+        if pattern == "" || strings.HasPrefix(pattern, "#") {
+            continue
+        }
+
+        if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+            expr := pattern[1 : len(pattern)-1]
+            // Compile each regular expression on its own first, so a bad
+            // pattern is reported with its line number.
+            if _, err := regexp.Compile(expr); err != nil {
+                return nil, fmt.Errorf("blocklist line %d: %w", line, err)
+            }
+            alternatives = append(alternatives, "(?:"+expr+")")
+        } else {
+            alternatives = append(alternatives, regexp.QuoteMeta(pattern))
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, err
+    }
+
+    b := &Blocklist{count: len(alternatives)}
+    if len(alternatives) > 0 {
+        rx, err := regexp.Compile("(?i)" + strings.Join(alternatives, "|"))
+        if err != nil {
+            return nil, err
+        }
+        b.rx = rx
+    }
+
+    return b, nil
+}
+
+// Len returns the number of patterns in the blocklist.
+func (b *Blocklist) Len() int {
+    if b == nil {
+        return 0
+    }
+    return b.count
+}
+
+// Matches reports whether any of the values contains a blocked pattern. A nil
+// Blocklist never matches, so callers don't need to check whether one was
+// configured.
+func (b *Blocklist) Matches(values ...string) bool {
+    if b == nil || b.rx == nil {
+        return false
+    }
+    for _, value := range values {
+        if b.rx.MatchString(value) {
+            return true
+        }
+    }
+    return false
+}
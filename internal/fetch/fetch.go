@@ -0,0 +1,212 @@
+package fetch
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net"
+    "net/http"
+    "net/netip"
+    "net/url"
+    "syscall"
+    "time"
+)
+
+var (
+    // ErrInvalidURL is returned for URLs which can't be parsed, or have no
+    // host.
+    ErrInvalidURL = errors.New("the URL is invalid")
+    // ErrScheme is returned for URLs which aren't http or https.
+    ErrScheme = errors.New("only http and https URLs can be fetched")
+    // ErrForbiddenAddress is returned when the host of a URL, or of a
+    // redirect, resolves to an address the Client may not connect to.
+    ErrForbiddenAddress = errors.New("the URL points to an address which can't be fetched")
+    // ErrTooLarge is returned for bodies bigger than the Client's MaxBytes.
+    ErrTooLarge = errors.New("the resource is too large")
+    // ErrTooManyRedirects is returned after maxRedirects redirects.
+    ErrTooManyRedirects = errors.New("the URL redirects too many times")
+)
+
+// maxRedirects is the number of redirects followed before giving up.
+const maxRedirects = 5
+
+// A StatusError is returned when the server answers with a status other
+// than 200 OK.
+type StatusError struct {
+    Status int
+}
+
+func (e *StatusError) Error() string {
+    return fmt.Sprintf("the server answered %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// forbiddenPrefixes are the special-purpose networks, besides the ones the
+// netip.Addr methods know about, which a Client doesn't connect to unless
+// it allows private addresses: they are either not routable on the internet
+// or lead back into the local network.
+var forbiddenPrefixes = []netip.Prefix{
+    netip.MustParsePrefix("0.0.0.0/8"),      // "this network"
+    netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+    netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+    netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+    netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+    netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, which can reach any IPv4 address
+    netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+    netip.MustParsePrefix("2002::/16"),      // 6to4, which embeds an IPv4 address
+    netip.MustParsePrefix("fec0::/10"),      // deprecated site-local
+}
+
+// Forbidden reports whether addr is a loopback, private, link-local,
+// multicast or other special-purpose address, which fetching a user's URL
+// must never reach. IPv4 addresses mapped into IPv6 are checked as IPv4.
+func Forbidden(addr netip.Addr) bool {
+    addr = addr.Unmap()
+    if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+        addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+        addr.IsMulticast() {
+        return true
+    }
+    for _, p := range forbiddenPrefixes {
+        if p.Contains(addr) {
+            return true
+        }
+    }
+    return false
+}
+
+// A Client downloads resources from user-supplied URLs without letting them
+// reach the server's own network. The address is checked when each
+// connection is made, after the name has been resolved, so a host which
+// resolves to a public address when the URL is checked and a private one
+// when it is fetched (DNS rebinding) is still refused, and so are redirects
+// to private addresses. Proxies from the environment aren't used, as the
+// check would then only see the proxy's address.
+type Client struct {
+    // MaxBytes is the largest body accepted, after any gzip encoding is
+    // undone.
+    MaxBytes int64
+    client   *http.Client
+}
+
+// A Response is a fetched resource.
+type Response struct {
+    Body []byte
+    // MediaType and Charset come from the Content-Type header, and are
+    // empty if it doesn't give them.
+    MediaType string
+    Charset   string
+    // URL is where the resource was found, after any redirects.
+    URL *url.URL
+}
+
+// New returns a Client which gives up on a fetch after timeout. With
+// allowPrivate it connects to any address, for servers whose users are
+// trusted to fetch from the local network.
+func New(allowPrivate bool, maxBytes int64, timeout time.Duration) *Client {
+    dialer := &net.Dialer{Timeout: timeout}
+    if !allowPrivate {
+        dialer.Control = func(network, address string, _ syscall.RawConn) error {
+            addrPort, err := netip.ParseAddrPort(address)
+            if err != nil || Forbidden(addrPort.Addr()) {
+                return ErrForbiddenAddress
+            }
+            return nil
+        }
+    }
+
+    transport := &http.Transport{
+        Proxy:                  nil,
+        DialContext:            dialer.DialContext,
+        TLSHandshakeTimeout:    timeout,
+        ResponseHeaderTimeout:  timeout,
+        MaxResponseHeaderBytes: 64 << 10,
+        MaxIdleConns:           10,
+        IdleConnTimeout:        90 * time.Second,
+    }
+    return &Client{
+        MaxBytes: maxBytes,
+        client: &http.Client{
+            Transport: transport,
+            Timeout:   timeout,
+            CheckRedirect: func(req *http.Request, via []*http.Request) error {
+                if len(via) >= maxRedirects {
+                    return ErrTooManyRedirects
+                }
+                return checkScheme(req.URL)
+            },
+        },
+    }
+}
+
+// Get fetches rawURL. The errors other than the ones of this package come
+// from the network, and their messages can be shown to the user.
+func (c *Client) Get(ctx context.Context, rawURL string) (*Response, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, ErrInvalidURL
+    }
+    // The scheme is checked first, as file:///etc/passwd has no host either.
+    if err := checkScheme(u); err != nil {
+        return nil, err
+    }
+    if u.Hostname() == "" {
+        return nil, ErrInvalidURL
+    }
+
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", "chunkbox")
+    req.Header.Set("Accept", "text/*, application/json, application/xml;q=0.9, */*;q=0.5")
+
+    resp, err := c.client.Do(req)
+    if err != nil {
+        // The client wraps the errors of CheckRedirect and the dialer in a
+        // *url.Error, whose message repeats the URL.
+        for _, sentinel := range []error{ErrScheme, ErrForbiddenAddress, ErrTooManyRedirects} {
+            if errors.Is(err, sentinel) {
+                return nil, sentinel
+            }
+        }
+        var uerr *url.Error
+        if errors.As(err, &uerr) {
+            if uerr.Timeout() {
+                return nil, errors.New("the server took too long to answer")
+            }
+            return nil, uerr.Err
+        }
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, &StatusError{Status: resp.StatusCode}
+    }
+    if resp.ContentLength > c.MaxBytes {
+        return nil, ErrTooLarge
+    }
+    body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxBytes+1))
+    if err != nil {
+        return nil, err
+    }
+    if int64(len(body)) > c.MaxBytes {
+        return nil, ErrTooLarge
+    }
+
+    fetched := &Response{Body: body, URL: resp.Request.URL}
+    if mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+        fetched.MediaType = mediaType
+        fetched.Charset = params["charset"]
+    }
+    return fetched, nil
+}
+
+func checkScheme(u *url.URL) error {
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return ErrScheme
+    }
+    return nil
+}
@@ -0,0 +1,132 @@
+package fetch
+
+import (
+    "context"
+    "errors"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/netip"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestForbidden(t *testing.T) {
+    tests := []struct {
+        addr string
+        want bool
+    }{
+        {"127.0.0.1", true},
+        {"127.1.2.3", true},
+        {"10.0.0.1", true},
+        {"10.255.255.255", true},
+        {"172.16.0.1", true},
+        {"192.168.1.1", true},
+        {"169.254.169.254", true},
+        {"100.64.0.1", true},
+        {"0.0.0.0", true},
+        {"224.0.0.1", true},
+        {"255.255.255.255", true},
+        {"::1", true},
+        {"::", true},
+        {"::ffff:10.0.0.1", true},
+        {"::ffff:127.0.0.1", true},
+        {"64:ff9b::a00:1", true},
+        {"2002:a00:1::", true},
+        {"fe80::1", true},
+        {"fc00::1", true},
+        {"ff02::1", true},
+        {"93.184.216.34", false},
+        {"8.8.8.8", false},
+        {"::ffff:8.8.8.8", false},
+        {"2606:4700:4700::1111", false},
+    }
+    for _, tt := range tests {
+        if got := Forbidden(netip.MustParseAddr(tt.addr)); got != tt.want {
+            t.Errorf("Forbidden(%s) = %t, want %t", tt.addr, got, tt.want)
+        }
+    }
+}
+
+func TestGetForbiddenAddress(t *testing.T) {
+    target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write([]byte("internal"))
+    }))
+    defer target.Close()
+    redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, target.URL+"/secret", http.StatusFound)
+    }))
+    defer redirector.Close()
+
+    c := New(false, 1<<20, 5*time.Second)
+    // public.example stands for a public server: it is dialled without the
+    // address check, and redirects to the loopback server.
+    transport := c.client.Transport.(*http.Transport)
+    dial := transport.DialContext
+    transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+        if addr == "public.example:80" {
+            var d net.Dialer
+            return d.DialContext(ctx, network, redirector.Listener.Addr().String())
+        }
+        return dial(ctx, network, addr)
+    }
+
+    for _, rawURL := range []string{target.URL, "http://public.example/"} {
+        if _, err := c.Get(context.Background(), rawURL); !errors.Is(err, ErrForbiddenAddress) {
+            t.Errorf("Get(%s) error %v, want %v", rawURL, err, ErrForbiddenAddress)
+        }
+    }
+
+    // With private addresses allowed the redirect is followed.
+    resp, err := New(true, 1<<20, 5*time.Second).Get(context.Background(), redirector.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if string(resp.Body) != "internal" || resp.URL.Path != "/secret" {
+        t.Errorf("got %q from %s", resp.Body, resp.URL)
+    }
+}
+
+func TestGetTooLarge(t *testing.T) {
+    body := strings.Repeat("x", 100)
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Without a Content-Length the size is only known from the body.
+        if r.URL.Path == "/chunked" {
+            w.(http.Flusher).Flush()
+        }
+        w.Write([]byte(body))
+    }))
+    defer ts.Close()
+
+    for _, path := range []string{"/", "/chunked"} {
+        if _, err := New(true, 99, 5*time.Second).Get(context.Background(), ts.URL+path); !errors.Is(err, ErrTooLarge) {
+            t.Errorf("%s: error %v, want %v", path, err, ErrTooLarge)
+        }
+        resp, err := New(true, 100, 5*time.Second).Get(context.Background(), ts.URL+path)
+        if err != nil || string(resp.Body) != body {
+            t.Errorf("%s at the limit: error %v", path, err)
+        }
+    }
+}
+
+func TestGetScheme(t *testing.T) {
+    c := New(true, 1<<20, 5*time.Second)
+    for _, rawURL := range []string{"file:///etc/passwd", "file://localhost/etc/passwd", "gopher://example.com/", "ftp://example.com/file"} {
+        if _, err := c.Get(context.Background(), rawURL); !errors.Is(err, ErrScheme) {
+            t.Errorf("Get(%s) error %v, want %v", rawURL, err, ErrScheme)
+        }
+    }
+    if _, err := c.Get(context.Background(), "http:///path"); !errors.Is(err, ErrInvalidURL) {
+        t.Errorf("no host: error %v, want %v", err, ErrInvalidURL)
+    }
+
+    // Redirects can't switch to another scheme either.
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Redirect(w, r, "gopher://example.com/", http.StatusFound)
+    }))
+    defer ts.Close()
+    if _, err := c.Get(context.Background(), ts.URL); !errors.Is(err, ErrScheme) {
+        t.Errorf("redirect: error %v, want %v", err, ErrScheme)
+    }
+}
@@ -0,0 +1,183 @@
+package hashids
+
+import (
+    "errors"
+    "math"
+    "strings"
+)
+
+// The defaults of the Hashids algorithm (https://hashids.org). Keeping them
+// means the ids are the same as those of other Hashids implementations given
+// the same salt and minimum length.
+const (
+    defaultAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+    defaultSeps     = "cfhistuCFHISTU"
+    sepDiv          = 3.5
+    guardDiv        = 12
+)
+
+// A Hashids turns non-negative numbers into short strings which don't look
+// sequential, and back. It is obfuscation, not encryption: anyone who learns
+// the salt can decode the ids, so it mustn't protect anything secret.
+type Hashids struct {
+    salt      string
+    minLength int
+    alphabet  string
+    seps      string
+    guards    string
+}
+
+// New returns a Hashids for the salt. Ids shorter than minLength are padded.
+func New(salt string, minLength int) (*Hashids, error) {
+    if minLength < 0 {
+        return nil, errors.New("hashids: the minimum length cannot be negative")
+    }
+
+    alphabet := defaultAlphabet
+    // The separators are the default ones which are in the alphabet; the
+    // alphabet itself keeps the rest.
+    var seps, rest strings.Builder
+    for _, c := range defaultSeps {
+        if strings.ContainsRune(alphabet, c) {
+            seps.WriteRune(c)
+        }
+    }
+    for _, c := range alphabet {
+        if !strings.ContainsRune(defaultSeps, c) {
+            rest.WriteRune(c)
+        }
+    }
+    h := &Hashids{salt: salt, minLength: minLength}
+    h.alphabet, h.seps = rest.String(), shuffle(seps.String(), salt)
+
+    if len(h.seps) == 0 || float64(len(h.alphabet))/float64(len(h.seps)) > sepDiv {
+        n := int(math.Ceil(float64(len(h.alphabet)) / sepDiv))
+        if n == 1 {
+            n++
+        }
+        if n > len(h.seps) {
+            diff := n - len(h.seps)
+            h.seps += h.alphabet[:diff]
+            h.alphabet = h.alphabet[diff:]
+        } else {
+            h.seps = h.seps[:n]
+        }
+    }
+    h.alphabet = shuffle(h.alphabet, salt)
+
+    n := int(math.Ceil(float64(len(h.alphabet)) / guardDiv))
+    if len(h.alphabet) < 3 {
+        h.guards, h.seps = h.seps[:n], h.seps[n:]
+    } else {
+        h.guards, h.alphabet = h.alphabet[:n], h.alphabet[n:]
+    }
+    return h, nil
+}
+
+// Encode returns the id of n, which must not be negative.
+func (h *Hashids) Encode(n int64) string {
+    // With a single number, the "numbers id" of the algorithm is n % 100.
+    numbersID := n % 100
+    alphabet := h.alphabet
+    lottery := alphabet[numbersID%int64(len(alphabet))]
+
+    buffer := string(lottery) + h.salt + alphabet
+    alphabet = shuffle(alphabet, buffer[:len(alphabet)])
+    id := string(lottery) + hash(n, alphabet)
+
+    if len(id) < h.minLength {
+        id = string(h.guards[(numbersID+int64(id[0]))%int64(len(h.guards))]) + id
+        if len(id) < h.minLength {
+            id += string(h.guards[(numbersID+int64(id[2]))%int64(len(h.guards))])
+        }
+    }
+    half := len(alphabet) / 2
+    for len(id) < h.minLength {
+        alphabet = shuffle(alphabet, alphabet)
+        id = alphabet[half:] + id + alphabet[:half]
+        if excess := len(id) - h.minLength; excess > 0 {
+            id = id[excess/2 : excess/2+h.minLength]
+        }
+    }
+    return id
+}
+
+// Decode returns the number an id encodes. It reports false for strings
+// which aren't ids of this Hashids, including ids of several numbers.
+func (h *Hashids) Decode(id string) (int64, bool) {
+    if id == "" {
+        return 0, false
+    }
+    // The guards pad an id at the start, and maybe the end too, so the
+    // number is in the second part if there are any.
+    parts := strings.Split(strings.Map(func(r rune) rune {
+        if strings.ContainsRune(h.guards, r) {
+            return ' '
+        }
+        return r
+    }, id), " ")
+    breakdown := parts[0]
+    if len(parts) == 2 || len(parts) == 3 {
+        breakdown = parts[1]
+    }
+    if len(breakdown) < 2 || strings.ContainsAny(breakdown, h.seps) {
+        return 0, false
+    }
+
+    lottery, hashed := breakdown[0], breakdown[1:]
+    buffer := string(lottery) + h.salt + h.alphabet
+    alphabet := shuffle(h.alphabet, buffer[:len(h.alphabet)])
+    n, ok := unhash(hashed, alphabet)
+    // Only an id Encode would have produced is valid, which also rules out
+    // ones mangled or padded differently.
+    if !ok || h.Encode(n) != id {
+        return 0, false
+    }
+    return n, true
+}
+
+// shuffle is the consistent shuffle of the algorithm: a Fisher-Yates
+// shuffle driven by the salt instead of random numbers.
+func shuffle(alphabet, salt string) string {
+    if salt == "" {
+        return alphabet
+    }
+    b := []byte(alphabet)
+    for i, v, p := len(b)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+        v %= len(salt)
+        c := int(salt[v])
+        p += c
+        j := (c + v + p) % i
+        b[i], b[j] = b[j], b[i]
+    }
+    return string(b)
+}
+
+// hash writes n in the base of the alphabet's length, with its characters
+// as digits.
+func hash(n int64, alphabet string) string {
+    base := int64(len(alphabet))
+    var digits []byte
+    for {
+        digits = append([]byte{alphabet[n%base]}, digits...)
+        n /= base
+        if n == 0 {
+            return string(digits)
+        }
+    }
+}
+
+// unhash is the reverse of hash. It reports false for characters which
+// aren't in the alphabet, or a number too big for an int64.
+func unhash(s, alphabet string) (int64, bool) {
+    base := int64(len(alphabet))
+    var n int64
+    for i := 0; i < len(s); i++ {
+        digit := strings.IndexByte(alphabet, s[i])
+        if digit < 0 || n > (math.MaxInt64-int64(digit))/base {
+            return 0, false
+        }
+        n = n*base + int64(digit)
+    }
+    return n, true
+}
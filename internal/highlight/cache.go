@@ -0,0 +1,128 @@
+package highlight
+
+import (
+    "container/list"
+    "html/template"
+    "sync"
+)
+
+// A Cache keeps recently rendered HTML, so popular chunks aren't highlighted
+// again on every view. It is bounded by the total size of the entries in
+// bytes rather than their number, as a single huge chunk can take more room
+// than thousands of small ones. When it is full the least recently used
+// entries are evicted. A nil *Cache caches nothing.
+type Cache struct {
+    mu       sync.Mutex
+    maxBytes int
+    size     int
+    // order holds the entries with the most recently used at the front.
+    order    *list.List
+    entries  map[string]*list.Element
+    hits     uint64
+    misses   uint64
+}
+
+// A cacheEntry is one rendered chunk in the Cache.
+type cacheEntry struct {
+    key  string
+    html template.HTML
+}
+
+// CacheStats are the counters of a Cache, for tuning its size.
+type CacheStats struct {
+    Hits    uint64
+    Misses  uint64
+    Entries int
+    Bytes   int
+}
+
+// NewCache returns a Cache holding up to maxBytes of HTML, or nil (no
+// caching) if maxBytes is 0.
+func NewCache(maxBytes int) *Cache {
+    if maxBytes <= 0 {
+        return nil
+    }
+    return &Cache{
+        maxBytes: maxBytes,
+        order:    list.New(),
+        entries:  make(map[string]*list.Element),
+    }
+}
+
+// Get returns the HTML cached under key, and whether there was any.
+func (c *Cache) Get(key string) (template.HTML, bool) {
+    if c == nil {
+        return "", false
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    e, ok := c.entries[key]
+    if !ok {
+        c.misses++
+        return "", false
+    }
+    c.hits++
+    c.order.MoveToFront(e)
+    return e.Value.(*cacheEntry).html, true
+}
+
+// Add caches html under key, evicting the least recently used entries to
+// make room for it. HTML bigger than the whole cache isn't cached at all.
+func (c *Cache) Add(key string, html template.HTML) {
+    if c == nil {
+        return
+    }
+    size := entrySize(key, html)
+    if size > c.maxBytes {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if e, ok := c.entries[key]; ok {
+        c.remove(e)
+    }
+    c.entries[key] = c.order.PushFront(&cacheEntry{key: key, html: html})
+    c.size += size
+    for c.size > c.maxBytes {
+        c.remove(c.order.Back())
+    }
+}
+
+// Remove drops the HTML cached under key, if there is any.
+func (c *Cache) Remove(key string) {
+    if c == nil {
+        return
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if e, ok := c.entries[key]; ok {
+        c.remove(e)
+    }
+}
+
+// Stats returns the hit and miss counts and the current size of the cache.
+func (c *Cache) Stats() CacheStats {
+    if c == nil {
+        return CacheStats{}
+    }
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    return CacheStats{Hits: c.hits, Misses: c.misses, Entries: len(c.entries), Bytes: c.size}
+}
+
+// remove takes an entry out of the cache. The caller must hold c.mu.
+func (c *Cache) remove(e *list.Element) {
+    entry := c.order.Remove(e).(*cacheEntry)
+    delete(c.entries, entry.key)
+    c.size -= entrySize(entry.key, entry.html)
+}
+
+// entrySize is the number of bytes an entry counts for. The bookkeeping
+// overhead is small next to any real chunk, so only the strings count.
+func entrySize(key string, html template.HTML) int {
+    return len(key) + len(html)
+}
@@ -0,0 +1,94 @@
+package highlight
+
+// A Comment is how a one line comment is written in a language: Start, the
+// text, then End for languages which only have block comments (like HTML).
+type Comment struct {
+    Start string
+    End   string
+}
+
+// Line returns text as a comment.
+func (c Comment) Line(text string) string {
+    if c.End == "" {
+        return c.Start + " " + text
+    }
+    return c.Start + " " + text + " " + c.End
+}
+
+// FallbackComment is the comment syntax of plain text and of the languages
+// which aren't in comments. "#" starts a comment in shells, Python, Ruby,
+// Perl, R, YAML, TOML, Makefiles, Dockerfiles and most configuration
+// formats, so it's the most likely to be harmless where we don't know.
+// Languages without comments at all, like JSON, get it too.
+var FallbackComment = Comment{Start: "#"}
+
+// comments are the comment syntaxes of the languages which don't use "#",
+// by the name Lookup gives them.
+var comments = map[string]Comment{
+    // The C family.
+    "c":           {Start: "//"},
+    "cpp":         {Start: "//"},
+    "csharp":      {Start: "//"},
+    "objective-c": {Start: "//"},
+    "d":           {Start: "//"},
+    "go":          {Start: "//"},
+    "java":        {Start: "//"},
+    "kotlin":      {Start: "//"},
+    "scala":       {Start: "//"},
+    "groovy":      {Start: "//"},
+    "dart":        {Start: "//"},
+    "js":          {Start: "//"},
+    "jsx":         {Start: "//"},
+    "ts":          {Start: "//"},
+    "rust":        {Start: "//"},
+    "swift":       {Start: "//"},
+    "zig":         {Start: "//"},
+    "php":         {Start: "//"},
+    "sol":         {Start: "//"},
+    "protobuf":    {Start: "//"},
+    "verilog":     {Start: "//"},
+    "fsharp":      {Start: "//"},
+    "scss":        {Start: "//"},
+    "sass":        {Start: "//"},
+    "css":         {Start: "/*", End: "*/"},
+
+    "sql":        {Start: "--"},
+    "mysql":      {Start: "--"},
+    "postgresql": {Start: "--"},
+    "lua":        {Start: "--"},
+    "haskell":    {Start: "--"},
+    "elm":        {Start: "--"},
+    "ada":        {Start: "--"},
+    "vhdl":       {Start: "--"},
+
+    "tex":    {Start: "%"},
+    "erlang": {Start: "%"},
+    "matlab": {Start: "%"},
+
+    "common-lisp": {Start: ";"},
+    "clojure":     {Start: ";"},
+    "scheme":      {Start: ";"},
+    "racket":      {Start: ";"},
+    "ini":         {Start: ";"},
+
+    "html":    {Start: "<!--", End: "-->"},
+    "xml":     {Start: "<!--", End: "-->"},
+    "md":      {Start: "<!--", End: "-->"},
+    "vue":     {Start: "<!--", End: "-->"},
+    "svelte":  {Start: "<!--", End: "-->"},
+    "ocaml":   {Start: "(*", End: "*)"},
+    "fortran": {Start: "!"},
+    "vim":     {Start: "\""},
+    "bat":     {Start: "REM"},
+}
+
+// CommentFor returns the comment syntax of a language, or FallbackComment
+// for plain text and languages it doesn't know.
+func CommentFor(language string) Comment {
+    if lang, ok := Lookup(language); ok {
+        if c, ok := comments[lang.Name]; ok {
+            return c
+        }
+    }
+    return FallbackComment
+}
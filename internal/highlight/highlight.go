@@ -0,0 +1,173 @@
+package highlight
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/alecthomas/chroma/v2"
+    "github.com/alecthomas/chroma/v2/lexers"
+)
+
+// A Language is one of the languages the chroma highlighter knows. Name is
+// the short identifier stored with a chunk (like "go" or "js") and Label is
+// the human readable name shown in the dropdown (like "Go" or "JavaScript").
+type Language struct {
+    Name  string
+    Label string
+}
+
+// PlainText is the language for chunks which aren't highlighted.
+const PlainText = "text"
+
+// commonAliases are names people commonly give languages, mapped to one chroma
+// knows. Chroma has some of them already, but not all, and it knows others
+// only by file extension, which Lookup doesn't go by.
+var commonAliases = map[string]string{
+    "golang": "go",
+    "sh":     "bash",
+    "shell":  "bash",
+    "js":     "javascript",
+    "node":   "javascript",
+    "ts":     "typescript",
+    "py":     "python",
+    "rb":     "ruby",
+    "yml":    "yaml",
+    "cs":     "csharp",
+    "txt":    PlainText,
+}
+
+// Canonical returns the name a language is looked up by: lowercase and
+// trimmed, with the common aliases resolved.
+func Canonical(name string) string {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if alias, ok := commonAliases[name]; ok {
+        return alias
+    }
+    return name
+}
+
+// The languages list and the lookup index (by name and by every alias) are
+// built once from chroma's lexer registry.
+var languages, byAlias = index()
+
+func index() ([]Language, map[string]Language) {
+    var all []Language
+    aliases := map[string]Language{}
+
+    for _, lexer := range lexers.GlobalLexerRegistry.Lexers {
+        config := lexer.Config()
+        // Use the first alias as the identifier where there is one, as it's
+        // a short, lowercase and space-free name ("cpp" rather than "C++").
+        lang := Language{Name: strings.ToLower(config.Name), Label: config.Name}
+        if len(config.Aliases) > 0 {
+            lang.Name = strings.ToLower(config.Aliases[0])
+        }
+        all = append(all, lang)
+
+        aliases[strings.ToLower(config.Name)] = lang
+        for _, alias := range config.Aliases {
+            aliases[strings.ToLower(alias)] = lang
+        }
+    }
+
+    sort.Slice(all, func(i, j int) bool {
+        return strings.ToLower(all[i].Label) < strings.ToLower(all[j].Label)
+    })
+    return all, aliases
+}
+
+// Languages returns every language the highlighter knows, sorted by label.
+func Languages() []Language {
+    return append([]Language(nil), languages...)
+}
+
+// Lookup finds a language by its name, label or any of chroma's aliases for
+// it, or a common alias, ignoring case. So "golang", "Go" and "go" all
+// return the Go language.
+func Lookup(name string) (Language, bool) {
+    lang, ok := byAlias[Canonical(name)]
+    return lang, ok
+}
+
+// ForMediaType finds the language of content served with a media type,
+// like "text/x-python". Generic types such as text/plain name no language.
+func ForMediaType(mediaType string) (Language, bool) {
+    return fromLexer(lexers.MatchMimeType(strings.ToLower(mediaType)))
+}
+
+// ForFilename finds the language of a file from its name, mostly by its
+// extension.
+func ForFilename(name string) (Language, bool) {
+    return fromLexer(lexers.Match(name))
+}
+
+// fromLexer returns the language of a lexer. The plain text lexer doesn't
+// count, as it only says that nothing better was found.
+func fromLexer(lexer chroma.Lexer) (Language, bool) {
+    if lexer == nil {
+        return Language{}, false
+    }
+    lang, ok := byAlias[strings.ToLower(lexer.Config().Name)]
+    if !ok || lang.Name == PlainText {
+        return Language{}, false
+    }
+    return lang, true
+}
+
+// Detect guesses the language of content with chroma's analysers, only
+// considering the given candidates. It returns the best match and how
+// confident the analyser is, from 0 to 1. A confidence of 0 means nothing
+// recognised the content.
+//
+// The analysers mostly look for tell-tale signs like a shebang line, so
+// the confidence is often low even for a correct guess.
+func Detect(content string, candidates []Language) (Language, float32) {
+    allowed := map[string]bool{}
+    for _, lang := range candidates {
+        allowed[lang.Name] = true
+    }
+
+    var best Language
+    var confidence float32
+    for _, lexer := range lexers.GlobalLexerRegistry.Lexers {
+        analyser, ok := lexer.(chroma.Analyser)
+        if !ok {
+            continue
+        }
+        lang := byAlias[strings.ToLower(lexer.Config().Name)]
+        if !allowed[lang.Name] {
+            continue
+        }
+        if weight := analyser.AnalyseText(content); weight > confidence {
+            best, confidence = lang, weight
+        }
+    }
+    return best, confidence
+}
+
+// Parse turns a comma-separated list of language names (the -languages
+// flag) into languages. An empty list means all of them. Unknown names are
+// an error, so typos don't silently shrink the list.
+func Parse(list string) ([]Language, error) {
+    if strings.TrimSpace(list) == "" {
+        return Languages(), nil
+    }
+
+    var parsed []Language
+    seen := map[string]bool{}
+    for _, name := range strings.Split(list, ",") {
+        if strings.TrimSpace(name) == "" {
+            continue
+        }
+        lang, ok := Lookup(name)
+        if !ok {
+            return nil, fmt.Errorf("highlight: unknown language %q", strings.TrimSpace(name))
+        }
+        if !seen[lang.Name] {
+            seen[lang.Name] = true
+            parsed = append(parsed, lang)
+        }
+    }
+    return parsed, nil
+}
@@ -0,0 +1,46 @@
+package highlight
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+    tests := []struct {
+        name   string
+        want   string
+        wantOK bool
+    }{
+        {"go", "go", true},
+        {"Go", "go", true},
+        {"golang", "go", true},
+        {" GoLang ", "go", true},
+        {"sh", "bash", true},
+        {"bash", "bash", true},
+        {"shell", "bash", true},
+        {"js", "js", true},
+        {"javascript", "js", true},
+        {"node", "js", true},
+        {"py", "python", true},
+        {"yml", "yaml", true},
+        {"txt", PlainText, true},
+        {"no-such-language", "", false},
+        {"", "", false},
+    }
+    for _, tt := range tests {
+        lang, ok := Lookup(tt.name)
+        if ok != tt.wantOK || lang.Name != tt.want {
+            t.Errorf("Lookup(%q) = %q, %t; want %q, %t", tt.name, lang.Name, ok, tt.want, tt.wantOK)
+        }
+    }
+}
+
+func TestCanonical(t *testing.T) {
+    for name, want := range map[string]string{
+        "golang":           "go",
+        "  SH ":            "bash",
+        "Python":           "python",
+        "no-such-language": "no-such-language",
+    } {
+        if got := Canonical(name); got != want {
+            t.Errorf("Canonical(%q) = %q, want %q", name, got, want)
+        }
+    }
+}
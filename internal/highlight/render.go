@@ -0,0 +1,152 @@
+package highlight
+
+import (
+    "bytes"
+    "fmt"
+    "html/template"
+    "sort"
+    "strings"
+
+    "github.com/alecthomas/chroma/v2"
+    "github.com/alecthomas/chroma/v2/formatters/html"
+    "github.com/alecthomas/chroma/v2/lexers"
+    "github.com/alecthomas/chroma/v2/styles"
+)
+
+// Auto is the theme name which follows the browser's light or dark mode,
+// using AutoLight and AutoDark.
+const (
+    Auto      = "auto"
+    AutoLight = "github"
+    AutoDark  = "monokai"
+)
+
+// A Highlighter renders content as syntax highlighted HTML. The markup only
+// carries CSS classes, the colours come from the stylesheet returned by CSS,
+// so the theme can be changed without re-rendering anything (and without
+// inline styles, which our Content-Security-Policy doesn't allow).
+type Highlighter struct {
+    theme     string
+    formatter *html.Formatter
+    css       []byte
+    // fallback is the lexer for languages chroma has none for, and for
+    // content their lexer fails on. Nil means plain text.
+    fallback  chroma.Lexer
+    // Debugf, if set, is told when content falls back.
+    Debugf    func(format string, v ...any)
+}
+
+// New returns a Highlighter using the named chroma style, or Auto. Content
+// in a language chroma doesn't know is highlighted as the fallback language
+// instead, or shown as plain text if that is PlainText or empty.
+func New(theme, fallback string) (*Highlighter, error) {
+    h := &Highlighter{theme: theme, formatter: html.New(html.WithClasses(true), html.TabWidth(4))}
+    if fallback = Canonical(fallback); fallback != "" && fallback != PlainText {
+        lang, ok := Lookup(fallback)
+        if !ok {
+            return nil, fmt.Errorf("highlight: unknown fallback language %q", fallback)
+        }
+        h.fallback = lexers.Get(lang.Name)
+    }
+
+    var css bytes.Buffer
+    switch theme {
+    case Auto:
+        err := h.formatter.WriteCSS(&css, styles.Get(AutoLight))
+        if err != nil {
+            return nil, err
+        }
+        css.WriteString("@media (prefers-color-scheme: dark) {\n")
+        err = h.formatter.WriteCSS(&css, styles.Get(AutoDark))
+        if err != nil {
+            return nil, err
+        }
+        css.WriteString("}\n")
+    default:
+        // styles.Get returns a fallback style for unknown names, so look the
+        // name up in the registry ourselves to catch typos.
+        style, ok := styles.Registry[strings.ToLower(theme)]
+        if !ok {
+            return nil, fmt.Errorf("highlight: unknown theme %q (choose auto or one of: %s)", theme, strings.Join(Themes(), ", "))
+        }
+        err := h.formatter.WriteCSS(&css, style)
+        if err != nil {
+            return nil, err
+        }
+    }
+    h.css = css.Bytes()
+
+    return h, nil
+}
+
+// Themes returns the names of the chroma styles, sorted.
+func Themes() []string {
+    names := styles.Names()
+    sort.Strings(names)
+    return names
+}
+
+// Theme returns the name of the theme, as passed to New.
+func (h *Highlighter) Theme() string {
+    return h.theme
+}
+
+// CSS returns the stylesheet for the theme.
+func (h *Highlighter) CSS() []byte {
+    return h.css
+}
+
+// HTML renders content in the given language as a highlighted <pre> block.
+// Languages chroma doesn't know, and content their lexer fails on, are
+// rendered with the fallback lexer. Plain text, and anything which falls
+// back without a fallback lexer, returns an empty string, in which case the
+// caller shows the content as it is.
+func (h *Highlighter) HTML(language, content string) (template.HTML, error) {
+    language = Canonical(language)
+    if language == "" || language == PlainText {
+        return "", nil
+    }
+    lexer := lexers.Get(language)
+    if lexer == nil {
+        h.debugf("highlight: no lexer for %q, falling back", language)
+        lexer = h.fallback
+    }
+    iterator, err := tokenise(lexer, content)
+    if err != nil && lexer != h.fallback {
+        h.debugf("highlight: the %s lexer failed, falling back: %v", language, err)
+        iterator, err = tokenise(h.fallback, content)
+    }
+    if err != nil {
+        h.debugf("highlight: the fallback lexer failed, showing plain text: %v", err)
+        return "", nil
+    }
+    if iterator == nil {
+        return "", nil
+    }
+
+    // The style is only used for inline styles, which we don't emit, but
+    // the formatter still wants one.
+    var buf bytes.Buffer
+    err = h.formatter.Format(&buf, styles.Fallback, iterator)
+    if err != nil {
+        return "", err
+    }
+
+    // The formatter escapes the content itself.
+    return template.HTML(buf.String()), nil
+}
+
+// tokenise runs a lexer over the content. A nil lexer, for plain text, gives
+// a nil iterator.
+func tokenise(lexer chroma.Lexer, content string) (chroma.Iterator, error) {
+    if lexer == nil {
+        return nil, nil
+    }
+    return chroma.Coalesce(lexer).Tokenise(nil, content)
+}
+
+func (h *Highlighter) debugf(format string, v ...any) {
+    if h.Debugf != nil {
+        h.Debugf(format, v...)
+    }
+}
@@ -0,0 +1,74 @@
+package highlight
+
+import (
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestNewUnknownFallback(t *testing.T) {
+    if _, err := New("github", "no-such-language"); err == nil {
+        t.Error("no error for an unknown -default-lexer")
+    }
+    if _, err := New("no-such-theme", PlainText); err == nil {
+        t.Error("no error for an unknown theme")
+    }
+    // The fallback is looked up like any language.
+    if _, err := New("github", "golang"); err != nil {
+        t.Error(err)
+    }
+}
+
+func TestHTMLFallback(t *testing.T) {
+    const content = "echo hello # greet"
+
+    tests := []struct {
+        name      string
+        fallback  string
+        language  string
+        wantHTML  bool
+        wantDebug bool
+    }{
+        {name: "known", fallback: PlainText, language: "bash", wantHTML: true},
+        {name: "alias", fallback: PlainText, language: "sh", wantHTML: true},
+        {name: "plain text", fallback: "bash", language: PlainText},
+        {name: "unknown, plain fallback", fallback: PlainText, language: "no-such-language", wantDebug: true},
+        {name: "unknown, no fallback", fallback: "", language: "no-such-language", wantDebug: true},
+        {name: "unknown, bash fallback", fallback: "bash", language: "no-such-language", wantHTML: true, wantDebug: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            h, err := New("github", tt.fallback)
+            if err != nil {
+                t.Fatal(err)
+            }
+            var debug []string
+            h.Debugf = func(format string, v ...any) { debug = append(debug, fmt.Sprintf(format, v...)) }
+
+            got, err := h.HTML(tt.language, content)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if (got != "") != tt.wantHTML {
+                t.Errorf("HTML %q, want highlighted = %t", got, tt.wantHTML)
+            }
+            if tt.wantHTML && !strings.Contains(string(got), "echo") {
+                t.Errorf("HTML %q without the content", got)
+            }
+            if (len(debug) > 0) != tt.wantDebug {
+                t.Errorf("debug messages %q, want some = %t", debug, tt.wantDebug)
+            }
+        })
+    }
+
+    // An alias renders the same as the language itself.
+    h, err := New("github", PlainText)
+    if err != nil {
+        t.Fatal(err)
+    }
+    golang, _ := h.HTML("golang", "package main")
+    gohtml, _ := h.HTML("go", "package main")
+    if golang == "" || golang != gohtml {
+        t.Errorf("golang rendered as %q, go as %q", golang, gohtml)
+    }
+}
@@ -0,0 +1,151 @@
+package inbound
+
+import (
+    "bytes"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "mime/multipart"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+const key = "signing-key"
+
+// mailgunForm returns the fields Mailgun posts for an email, signed with
+// the key at the time t.
+func mailgunForm(key string, t time.Time, token string) url.Values {
+    timestamp := strconv.FormatInt(t.Unix(), 10)
+    mac := hmac.New(sha256.New, []byte(key))
+    mac.Write([]byte(timestamp + token))
+    return url.Values{
+        "from":       {"Alice <Alice@Example.com>"},
+        "subject":    {" Hello "},
+        "body-plain": {"fmt.Println(1)\n"},
+        "Message-Id": {"<1@example.com>"},
+        "timestamp":  {timestamp},
+        "token":      {token},
+        "signature":  {hex.EncodeToString(mac.Sum(nil))},
+    }
+}
+
+func parse(t *testing.T, rc *Receiver, r *http.Request) (*Email, error) {
+    t.Helper()
+    return rc.Parse(httptest.NewRecorder(), r, 1<<20)
+}
+
+func formRequest(form url.Values) *http.Request {
+    r := httptest.NewRequest(http.MethodPost, "/api/v1/inbound/email", strings.NewReader(form.Encode()))
+    r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    return r
+}
+
+func TestMailgun(t *testing.T) {
+    rc, err := New("mailgun", key)
+    if err != nil {
+        t.Fatal(err)
+    }
+    now := time.Now()
+
+    email, err := parse(t, rc, formRequest(mailgunForm(key, now, "token")))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if email.From != "alice@example.com" || email.Subject != "Hello" || email.Text != "fmt.Println(1)\n" || email.MessageID != "<1@example.com>" {
+        t.Errorf("got %+v", email)
+    }
+
+    otherToken := mailgunForm(key, now, "token")
+    otherToken.Set("token", "other")
+    badHex := mailgunForm(key, now, "token")
+    badHex.Set("signature", "zz")
+    tests := []struct {
+        name string
+        form url.Values
+    }{
+        {"wrong key", mailgunForm("other-key", now, "token")},
+        {"too old", mailgunForm(key, now.Add(-signatureTolerance-time.Minute), "token")},
+        {"in the future", mailgunForm(key, now.Add(signatureTolerance+time.Minute), "token")},
+        // The signature matches, but without a token it could be replayed.
+        {"no token", mailgunForm(key, now, "")},
+        {"another token", otherToken},
+        {"malformed signature", badHex},
+        {"not signed", url.Values{"from": {"alice@example.com"}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := parse(t, rc, formRequest(tt.form)); !errors.Is(err, ErrInvalidSignature) {
+                t.Errorf("error %v, want %v", err, ErrInvalidSignature)
+            }
+        })
+    }
+
+    // Within the tolerance is fine.
+    if _, err := parse(t, rc, formRequest(mailgunForm(key, now.Add(-signatureTolerance+time.Minute), "token"))); err != nil {
+        t.Errorf("within the tolerance: %v", err)
+    }
+}
+
+// sendgridRequest returns a multipart request like SendGrid's, with an
+// attachment.
+func sendgridRequest(t *testing.T, password string) *http.Request {
+    t.Helper()
+
+    var body bytes.Buffer
+    mw := multipart.NewWriter(&body)
+    mw.WriteField("from", "bob@example.com")
+    mw.WriteField("subject", "Script")
+    mw.WriteField("text", "see attached")
+    mw.WriteField("headers", "Subject: Script\nMessage-Id: <2@example.com>\n")
+    mw.WriteField("attachments", "1")
+    fw, err := mw.CreateFormFile("attachment1", "main.go")
+    if err != nil {
+        t.Fatal(err)
+    }
+    fw.Write([]byte("package main\n"))
+    mw.Close()
+
+    r := httptest.NewRequest(http.MethodPost, "/api/v1/inbound/email", &body)
+    r.Header.Set("Content-Type", mw.FormDataContentType())
+    if password != "" {
+        r.SetBasicAuth("sendgrid", password)
+    }
+    return r
+}
+
+func TestSendGrid(t *testing.T) {
+    rc, err := New("sendgrid", key)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    email, err := parse(t, rc, sendgridRequest(t, key))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if email.From != "bob@example.com" || email.MessageID != "<2@example.com>" || len(email.Attachments) != 1 ||
+        email.Attachments[0].Filename != "main.go" || string(email.Attachments[0].Content) != "package main\n" {
+        t.Errorf("got %+v", email)
+    }
+
+    for _, password := range []string{"wrong", key + "x", ""} {
+        if _, err := parse(t, rc, sendgridRequest(t, password)); !errors.Is(err, ErrInvalidSignature) {
+            t.Errorf("password %q: error %v, want %v", password, err, ErrInvalidSignature)
+        }
+    }
+}
+
+func TestNew(t *testing.T) {
+    if _, err := New("postmark", key); err == nil {
+        t.Error("no error for an unknown provider")
+    }
+    if _, err := New("mailgun", ""); err == nil {
+        t.Error("no error without a secret")
+    }
+}
@@ -0,0 +1,141 @@
+package mailer
+
+import (
+    "bytes"
+    "crypto/rand"
+    "crypto/tls"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "mime"
+    "net"
+    "net/mail"
+    "net/smtp"
+    "strings"
+    "time"
+)
+
+// sendTimeout bounds the whole SMTP conversation of one message, so a slow
+// server can't hold on to the goroutine sending it.
+const sendTimeout = 30 * time.Second
+
+// A Mailer sends plain text emails through an SMTP server. STARTTLS is used
+// whenever the server offers it, and authentication (PLAIN, which net/smtp
+// only allows over TLS or to localhost) when a username is set.
+type Mailer struct {
+    addr string
+    host string
+    auth smtp.Auth
+    from *mail.Address
+}
+
+// New returns a Mailer sending through the server at addr (host:port) as
+// from, logging in with the username and password unless the username is
+// empty.
+func New(addr, username, password, from string) (*Mailer, error) {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        return nil, fmt.Errorf("mailer: %q is not a host:port address", addr)
+    }
+    sender, err := mail.ParseAddress(from)
+    if err != nil {
+        return nil, fmt.Errorf("mailer: invalid from address %q: %v", from, err)
+    }
+
+    m := &Mailer{addr: addr, host: host, from: sender}
+    if username != "" {
+        m.auth = smtp.PlainAuth("", username, password, host)
+    }
+    return m, nil
+}
+
+// Send emails the body to the address, with the subject. inReplyTo is the
+// Message-Id of the email being answered, if any, so the reply is threaded
+// with it.
+func (m *Mailer) Send(to, subject, body, inReplyTo string) error {
+    rcpt, err := mail.ParseAddress(to)
+    if err != nil {
+        return fmt.Errorf("mailer: invalid recipient %q: %v", to, err)
+    }
+    msg, err := m.message(rcpt, subject, body, inReplyTo)
+    if err != nil {
+        return err
+    }
+
+    conn, err := net.DialTimeout("tcp", m.addr, sendTimeout)
+    if err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    conn.SetDeadline(time.Now().Add(sendTimeout))
+    c, err := smtp.NewClient(conn, m.host)
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("mailer: %w", err)
+    }
+    defer c.Close()
+
+    if ok, _ := c.Extension("STARTTLS"); ok {
+        if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+            return fmt.Errorf("mailer: STARTTLS: %w", err)
+        }
+    }
+    if m.auth != nil {
+        if err := c.Auth(m.auth); err != nil {
+            return fmt.Errorf("mailer: %w", err)
+        }
+    }
+    if err := c.Mail(m.from.Address); err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    if err := c.Rcpt(rcpt.Address); err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    w, err := c.Data()
+    if err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    if _, err := w.Write(msg); err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    if err := w.Close(); err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+    return c.Quit()
+}
+
+// message builds the email. The subject is encoded as RFC 2047 as needed,
+// which also takes care of any line breaks in it, and the lines of the body
+// end in CRLF as SMTP wants.
+func (m *Mailer) message(to *mail.Address, subject, body, inReplyTo string) ([]byte, error) {
+    if strings.ContainsAny(inReplyTo, "\r\n") {
+        return nil, errors.New("mailer: invalid In-Reply-To")
+    }
+    id := make([]byte, 16)
+    if _, err := rand.Read(id); err != nil {
+        return nil, err
+    }
+
+    var b bytes.Buffer
+    fmt.Fprintf(&b, "From: %s\r\n", m.from.String())
+    fmt.Fprintf(&b, "To: %s\r\n", to.String())
+    fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+    fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+    domain := m.from.Address[strings.LastIndex(m.from.Address, "@")+1:]
+    fmt.Fprintf(&b, "Message-Id: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+    if inReplyTo != "" {
+        fmt.Fprintf(&b, "In-Reply-To: %s\r\nReferences: %s\r\n", inReplyTo, inReplyTo)
+    }
+    // Tell autoresponders not to answer, so two of them can't loop.
+    b.WriteString("Auto-Submitted: auto-replied\r\n")
+    b.WriteString("MIME-Version: 1.0\r\n")
+    b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+    b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+
+    body = strings.ReplaceAll(body, "\r\n", "\n")
+    for _, line := range strings.Split(body, "\n") {
+        // A line starting with a full stop is escaped by the DATA writer.
+        b.WriteString(line)
+        b.WriteString("\r\n")
+    }
+    return b.Bytes(), nil
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "time"
+)
+
+// An Access is one time a chunk was looked at, for the owner's stats page.
+// Only chunks whose owner turned the log on are recorded (the access_log
+// column of chunks, see Chunk). To keep as little about visitors as
+// possible, IP is the address with its last part zeroed (a /24 for IPv4, a
+// /48 for IPv6) and Agent only the family of the user agent, like "Firefox"
+// or "curl". Kind is how the chunk was accessed: "view", "raw" or
+// "download". They are kept in the "chunk_access" table:
+//
+//  CREATE TABLE chunk_access (
+//      id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//      chunk_id INTEGER NOT NULL,
+//      created DATETIME NOT NULL,
+//      kind VARCHAR(10) NOT NULL,
+//      ip VARCHAR(45) NOT NULL,
+//      agent VARCHAR(30) NOT NULL,
+//      FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+//  );
+//  CREATE INDEX idx_chunk_access_chunk ON chunk_access(chunk_id, id);
+//  CREATE INDEX idx_chunk_access_created ON chunk_access(created);
+type Access struct {
+    ID      int
+    Created time.Time
+    Kind    string
+    IP      string
+    Agent   string
+}
+
+// A DailyAccesses is the number of accesses to a chunk on one day (UTC).
+type DailyAccesses struct {
+    Day   time.Time
+    Count int
+}
+
+// Define an AccessModel type which wraps a sql.DB connection pool. Max is
+// the most entries kept for each chunk: recording one more drops the
+// oldest.
+type AccessModel struct {
+    DB  *sql.DB
+    Max int
+}
+
+// SetEnabled turns the access log of a chunk on or off. Turning it off
+// also deletes what was recorded.
+func (m *AccessModel) SetEnabled(chunkID int, enabled bool) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec("UPDATE chunks SET access_log = ? WHERE id = ?", enabled, chunkID)
+    if err != nil {
+        return err
+    }
+    if !enabled {
+        _, err = tx.Exec("DELETE FROM chunk_access WHERE chunk_id = ?", chunkID)
+        if err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// Record adds an access to the log of a chunk, and drops the entries beyond
+// the newest Max.
+func (m *AccessModel) Record(ctx context.Context, chunkID int, kind, ip, agent string) error {
+    stmt := `INSERT INTO chunk_access (chunk_id, created, kind, ip, agent)
+    VALUES(?, UTC_TIMESTAMP(), ?, ?, ?)`
+
+    _, err := m.DB.ExecContext(ctx, stmt, chunkID, kind, ip, agent)
+    if err != nil {
+        return err
+    }
+
+    // MySQL doesn't allow a LIMIT in an IN subquery, but it does in a
+    // derived table: find the id of the oldest entry to keep, if there are
+    // more than Max, and delete the ones before it.
+    _, err = m.DB.ExecContext(ctx, `DELETE FROM chunk_access WHERE chunk_id = ? AND id < (
+        SELECT id FROM (SELECT id FROM chunk_access WHERE chunk_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?) AS oldest)`,
+        chunkID, chunkID, m.Max-1)
+    return err
+}
+
+// Recent returns the newest accesses to a chunk, at most limit of them.
+func (m *AccessModel) Recent(chunkID, limit int) ([]*Access, error) {
+    stmt := `SELECT id, created, kind, ip, agent FROM chunk_access
+    WHERE chunk_id = ? ORDER BY id DESC LIMIT ?`
+
+    rows, err := m.DB.Query(stmt, chunkID, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    accesses := []*Access{}
+    for rows.Next() {
+        a := &Access{}
+        err = rows.Scan(&a.ID, &a.Created, &a.Kind, &a.IP, &a.Agent)
+        if err != nil {
+            return nil, err
+        }
+        accesses = append(accesses, a)
+    }
+    return accesses, rows.Err()
+}
+
+// Daily returns the number of accesses to a chunk on each of the last days
+// days, today included, oldest first. Days without any have a count of 0.
+func (m *AccessModel) Daily(chunkID, days int) ([]DailyAccesses, error) {
+    today := time.Now().UTC().Truncate(24 * time.Hour)
+    first := today.AddDate(0, 0, -(days - 1))
+
+    stmt := `SELECT DATE(created), COUNT(*) FROM chunk_access
+    WHERE chunk_id = ? AND created >= ? GROUP BY DATE(created)`
+
+    rows, err := m.DB.Query(stmt, chunkID, first)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    counts := map[string]int{}
+    for rows.Next() {
+        var day time.Time
+        var count int
+        if err = rows.Scan(&day, &count); err != nil {
+            return nil, err
+        }
+        counts[day.Format("2006-01-02")] = count
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    daily := make([]DailyAccesses, days)
+    for i := range daily {
+        day := first.AddDate(0, 0, i)
+        daily[i] = DailyAccesses{Day: day, Count: counts[day.Format("2006-01-02")]}
+    }
+    return daily, nil
+}
+
+// Prune deletes the accesses recorded before the time, and returns how many
+// there were.
+func (m *AccessModel) Prune(ctx context.Context, before time.Time) (int64, error) {
+    result, err := m.DB.ExecContext(ctx, "DELETE FROM chunk_access WHERE created < ?", before.UTC())
+    if err != nil {
+        return 0, err
+    }
+    return result.RowsAffected()
+}
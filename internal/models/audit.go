@@ -0,0 +1,102 @@
+package models
+
+import (
+    "context"
+    "database/sql"
+    "time"
+)
+
+// An AuditEntry is one security-relevant event, such as a login or an
+// account deletion. ActorID is the user who did it, or 0 when nobody was
+// logged in (a failed login, or something the server did by itself). They
+// are stored in the "audit_log" table:
+//
+//  CREATE TABLE audit_log (
+//      id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//      created DATETIME NOT NULL,
+//      actor_id INTEGER NULL,
+//      action VARCHAR(50) NOT NULL,
+//      target VARCHAR(255) NOT NULL,
+//      ip VARCHAR(45) NOT NULL
+//  );
+//  CREATE INDEX idx_audit_log_action ON audit_log(action, id);
+//
+// actor_id deliberately has no foreign key: the trail has to outlive the
+// users it mentions.
+type AuditEntry struct {
+    ID      int
+    Created time.Time
+    ActorID int
+    Action  string
+    Target  string
+    IP      string
+}
+
+// Define an AuditModel type which wraps a sql.DB connection pool.
+type AuditModel struct {
+    DB *sql.DB
+}
+
+// Record adds an entry to the audit log.
+func (m *AuditModel) Record(ctx context.Context, actorID int, action, target, ip string) error {
+    stmt := `INSERT INTO audit_log (created, actor_id, action, target, ip)
+    VALUES(UTC_TIMESTAMP(), ?, ?, ?, ?)`
+
+    _, err := m.DB.ExecContext(ctx, stmt, nullUserID(actorID), action, target, ip)
+    return err
+}
+
+// List returns one page of audit entries, newest first, optionally only
+// those with the given action. Pages are numbered from 1. The second return
+// value reports whether there are more entries after this page.
+func (m *AuditModel) List(action string, page, pageSize int) ([]*AuditEntry, bool, error) {
+    stmt := `SELECT id, created, actor_id, action, target, ip FROM audit_log
+    WHERE ? = '' OR action = ?
+    ORDER BY id DESC LIMIT ? OFFSET ?`
+
+    // Ask for one extra row to find out whether there is a next page.
+    rows, err := m.DB.Query(stmt, action, action, pageSize+1, (page-1)*pageSize)
+    if err != nil {
+        return nil, false, err
+    }
+    defer rows.Close()
+
+    entries := []*AuditEntry{}
+    for rows.Next() {
+        e := &AuditEntry{}
+        var actorID sql.NullInt64
+        err = rows.Scan(&e.ID, &e.Created, &actorID, &e.Action, &e.Target, &e.IP)
+        if err != nil {
+            return nil, false, err
+        }
+        e.ActorID = int(actorID.Int64)
+        entries = append(entries, e)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, false, err
+    }
+
+    if len(entries) > pageSize {
+        return entries[:pageSize], true, nil
+    }
+    return entries, false, nil
+}
+
+// Actions returns the distinct actions in the audit log, for the filter.
+func (m *AuditModel) Actions() ([]string, error) {
+    rows, err := m.DB.Query(`SELECT DISTINCT action FROM audit_log ORDER BY action`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var actions []string
+    for rows.Next() {
+        var action string
+        if err = rows.Scan(&action); err != nil {
+            return nil, err
+        }
+        actions = append(actions, action)
+    }
+    return actions, rows.Err()
+}
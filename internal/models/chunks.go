@@ -1,17 +1,191 @@
 package models
 import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
     "database/sql"
+    "io"
+    "sort"
+    "strings"
     "time"
     "errors"
 )
 // define a chunk struct for an individual chunk.
-// This will get stored in sql
+// This will get stored in sql. UserID is the owner of the chunk, or 0 for
+// chunks created anonymously (stored as a NULL user_id):
+//
+//  ALTER TABLE chunks ADD COLUMN user_id INTEGER NULL;
+//  CREATE INDEX idx_chunks_user_id ON chunks(user_id);
+//
+// Private chunks are only visible to their owner (or through a share link):
+//
+//  ALTER TABLE chunks ADD COLUMN private BOOLEAN NOT NULL DEFAULT FALSE;
+//
+// Language is the highlighter's name for the language of the content:
+//
+//  ALTER TABLE chunks ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT 'text';
+//
+// PublicID is the random ID used in URLs (see publicid.go). ID is only used
+// inside the application and never shown:
+//
+//  ALTER TABLE chunks ADD COLUMN public_id CHAR(10) NULL;
+//  CREATE UNIQUE INDEX idx_chunks_public_id ON chunks(public_id);
+//
+// Existing chunks are given a public ID by BackfillPublicIDs when the
+// server starts.
+//
+// Normalized records that the content was changed on the way in, by
+// converting it to UTF-8 or its line endings to LF:
+//
+//  ALTER TABLE chunks ADD COLUMN normalized BOOLEAN NOT NULL DEFAULT FALSE;
+//
+// Slug is made from the title (see slug.go) and names the downloaded file.
+// Chunks from before slugs existed have an empty one:
+//
+//  ALTER TABLE chunks ADD COLUMN slug VARCHAR(80) NULL;
+//  CREATE UNIQUE INDEX idx_chunks_slug ON chunks(slug);
+//
+// Updated is when the chunk was last edited, and zero if it never was. It is
+// kept to the microsecond, so every edit changes it:
+//
+//  ALTER TABLE chunks ADD COLUMN updated DATETIME(6) NULL;
+//
+// The size of the content in bytes, with that of the chunk's other files
+// (see files.go), is kept in a column of its own, so the storage a user has
+// used can be added up from the index rather than by reading every chunk's
+// content (see TotalBytesByUser):
+//
+//  ALTER TABLE chunks ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+//  UPDATE chunks SET size = LENGTH(content);
+//  CREATE INDEX idx_chunks_user_size ON chunks(user_id, expires, size);
+//
+// CreatorIP is the address a chunk was created from, so administrators can
+// clean up after a spam wave (see DeleteMatching). It is never shown. Both
+// stores save it, but the MySQL getters don't read the column back, so the
+// CreatorIP field is only filled in by the in-memory store:
+//
+//  ALTER TABLE chunks ADD COLUMN creator_ip VARCHAR(45) NULL;
+//  CREATE INDEX idx_chunks_creator_ip ON chunks(creator_ip);
+//
+// KeepAlive chunks have their expiry pushed back when they are viewed, so
+// chunks which are still used don't expire (see ExtendExpiry):
+//
+//  ALTER TABLE chunks ADD COLUMN keep_alive BOOLEAN NOT NULL DEFAULT FALSE;
+//
+// Views counts how often the chunk's page was shown (see AddViews):
+//
+//  ALTER TABLE chunks ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+//
+// AccessLog is set when the owner asked for the accesses to the chunk to be
+// recorded (see AccessModel):
+//
+//  ALTER TABLE chunks ADD COLUMN access_log BOOLEAN NOT NULL DEFAULT FALSE;
+//
+// The hex SHA-256 of the content (see ContentSHA256) is kept as well, so the
+// chunks with the same content can be found from the index (see
+// ExistsContent) and GetMeta has the hash without reading the content:
+//
+//  ALTER TABLE chunks ADD COLUMN content_sha256 CHAR(64) NULL;
+//  UPDATE chunks SET content_sha256 = SHA2(content, 256);
+//  CREATE INDEX idx_chunks_user_sha256 ON chunks(user_id, content_sha256);
+//
+// Titles can be up to 100 characters long in the original schema. To allow
+// longer ones with -max-title-length, widen the column first:
+//
+//  ALTER TABLE chunks MODIFY title VARCHAR(255) NOT NULL;
+//
+// The content column is a TEXT in the original schema, which holds up to
+// 64KB. Larger chunks (-max-chunk-bytes, and BenchmarkChunkContent's 8MB
+// one) need a MEDIUMTEXT, up to 16MB:
+//
+//  ALTER TABLE chunks MODIFY content MEDIUMTEXT NOT NULL;
 type Chunk struct {
-    ID      int
-    Title   string
-    Content string
-    Created time.Time
-    Expires time.Time
+    ID       int
+    PublicID string
+    Title    string
+    Slug     string
+    Content  string
+    Created  time.Time
+    Updated  time.Time
+    Expires  time.Time
+    Language string
+    UserID   int
+    Private  bool
+    KeepAlive bool
+    Normalized bool
+    // Views is how many times the chunk was viewed. It is only filled in
+    // by Get and GetByPublicID.
+    Views   int64
+    // AccessLog is only filled in by Get, GetMeta and their public ID
+    // versions, and never by the in-memory store, which has no owners.
+    AccessLog bool
+    // Size is the length of the content in bytes. It is only filled in by
+    // GetMeta, which doesn't load the content itself.
+    Size    int64
+    // SHA256 is the hex SHA-256 of the content (see ContentSHA256), so it
+    // can be sent before content which is streamed. It is only filled in by
+    // GetMeta.
+    SHA256  string
+    // Preview holds the first few characters of the content, and Truncated
+    // is true when there was more. They are only filled in by Latest, which
+    // doesn't load the full content of each chunk.
+    Preview   string
+    Truncated bool
+    // Tags are the chunk's tags (see tags.go), in alphabetical order. They
+    // are only filled in by Get and GetByPublicID.
+    Tags []string
+    // Files are the chunk's other files (see files.go), in order, for a
+    // multi-file chunk. They are only filled in by Get and GetByPublicID.
+    Files []ChunkFile
+    // Score is how well the chunk matched a search, the higher the better.
+    // It is only filled in by SearchRanked.
+    Score float64
+    CreatorIP string
+}
+
+// ContentSHA256 returns the hex SHA-256 of a chunk's content, as in
+// Chunk.SHA256.
+func ContentSHA256(content string) string {
+    sum := sha256.Sum256([]byte(content))
+    return hex.EncodeToString(sum[:])
+}
+
+// Modified returns when the chunk last changed: when it was edited, or
+// created if it never was.
+func (c *Chunk) Modified() time.Time {
+    if c.Updated.After(c.Created) {
+        return c.Updated
+    }
+    return c.Created
+}
+
+// ChunkStore is the set of chunk operations the web application uses. The
+// MySQL-backed ChunkModel is the real implementation, and MemoryChunkModel
+// keeps everything in memory for demos and tests.
+type ChunkStore interface {
+    Insert(title string, content string, expires int, language string, userID int, private bool, keepAlive bool, normalized bool, tags []string, files []ChunkFile, ip string) (string, error)
+    InsertBatch(inputs []ChunkInput) ([]string, error)
+    Get(id int) (*Chunk, error)
+    GetByPublicID(publicID string) (*Chunk, error)
+    GetMeta(id int) (*Chunk, error)
+    GetMetaByPublicID(publicID string) (*Chunk, error)
+    StreamContent(ctx context.Context, id int, w io.Writer) error
+    Latest(previewChars int) ([]*Chunk, error)
+    ListAfter(afterID, limit, previewChars int) ([]*Chunk, error)
+    Search(query string, limit int) ([]*Chunk, error)
+    SearchRanked(query string, minScore float64, limit int) ([]*Chunk, error)
+    Related(chunkID int, limit int) ([]*Chunk, error)
+    LatestModified() (time.Time, error)
+    Count() (int, error)
+    TotalBytesByUser(userID int) (int64, error)
+    ExistsTitleForUser(userID int, title string) (*Chunk, error)
+    ExistsContent(userID int, ip, sha256 string) (*Chunk, error)
+    DeleteOldest(n int) (int, error)
+    DeleteMatching(filter ChunkFilter) (int, error)
+    Update(id int, title, content, language string, normalized bool, tags []string) error
+    Delete(id int) error
+    ExtendExpiry(id int, by, maxLifetime time.Duration) error
+    AddViews(counts map[int]int64) error
 }
 
 // Define a ChunkModel type which wraps a sql.DB connection pool.
@@ -19,50 +193,232 @@ type ChunkModel struct {
     DB *sql.DB
 }
 
-// This will insert a new snippet into the database.
-func (m *ChunkModel) Insert(title string, content string, expires int) (int, error) {
+// This will insert a new snippet into the database and return its public
+// ID. Pass a userID of 0 for chunks created by anonymous visitors, and
+// normalized true if the content was converted before it got here. The ip
+// is the creator's address, and keepAlive makes views extend the expiry
+// (see ExtendExpiry). The content is the chunk's first file; files are the
+// others of a multi-file chunk, and nil for a chunk of one file. The chunk,
+// its tags and its files are added in one transaction.
+func (m *ChunkModel) Insert(title string, content string, expires int, language string, userID int, private bool, keepAlive bool, normalized bool, tags []string, files []ChunkFile, ip string) (string, error) {
     // Write the SQL statement we want to execute.
-    stmt := `INSERT INTO chunks (title, content, created, expires)
-    VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-    // Use the Exec() method on the embedded connection pool to execute the
-    // statement. The first parameter is the SQL statement, followed by the
-    // title, content and expiry values for the placeholder parameters. This
-    // method returns a sql.Result type, which contains some basic
-    // information about what happened when the statement was executed.
-    result, err := m.DB.Exec(stmt, title, content, expires)
+    stmt := `INSERT INTO chunks (public_id, slug, title, content, content_sha256, size, created, expires, language, user_id, private, keep_alive, normalized, creator_ip)
+    VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?, ?, ?, ?)`
+
+    // If the random public ID or the slug is already taken, the unique
+    // index rejects the row and we try again with a new public ID and a
+    // suffixed slug.
+    for attempt := 1; ; attempt++ {
+        publicID, err := newPublicID()
+        if err != nil {
+            return "", err
+        }
+        slug, err := chunkSlug(title, attempt)
+        if err != nil {
+            return "", err
+        }
+        err = m.insertChunk(stmt, []any{publicID, slug, title, content, ContentSHA256(content), filesSize(content, files), expires, language, nullUserID(userID), private, keepAlive, normalized, nullString(ip)}, tags, files)
+        if err == nil {
+            return publicID, nil
+        }
+        if !(isDuplicatePublicID(err) || isDuplicateSlug(err)) || attempt == insertAttempts {
+            return "", err
+        }
+    }
+}
+
+// insertChunk runs the INSERT statement for one chunk and adds its tags and
+// files, in a transaction.
+func (m *ChunkModel) insertChunk(stmt string, args []any, tags []string, files []ChunkFile) error {
+    tx, err := m.DB.Begin()
     if err != nil {
-        return 0, err
+        return err
+    }
+    defer tx.Rollback()
+
+    // Use the Exec() method on the transaction to execute the statement.
+    // The first parameter is the SQL statement, followed by the values for
+    // the placeholder parameters.
+    result, err := tx.Exec(stmt, args...)
+    if err != nil {
+        return err
     }
-    // Use the LastInsertId() method on the result to get the ID of our
-    // newly inserted record in the snippets table.
     id, err := result.LastInsertId()
     if err != nil {
-        return 0, err
+        return err
+    }
+    if err = addTags(tx, int(id), tags); err != nil {
+        return err
     }
-    // The ID returned has the type int64, so we convert it to an int type
-    // before returning.
-    return int(id), nil
+    if err = addFiles(tx, int(id), files); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// A ChunkInput holds the values for one new chunk in an InsertBatch call.
+// Expires is the number of days until the chunk expires, and a UserID of 0
+// means the chunk is anonymous, just like the Insert parameters.
+type ChunkInput struct {
+    Title      string
+    Content    string
+    Expires    int
+    Language   string
+    UserID     int
+    Private    bool
+    KeepAlive  bool
+    Normalized bool
+    Tags       []string
+    Files      []ChunkFile
+    IP         string
+}
+
+// InsertBatch inserts several chunks with a single multi-row INSERT, so
+// either all of them are created or none are. Their tags are added in the
+// same transaction. It returns the new public IDs in the same order as the
+// inputs.
+func (m *ChunkModel) InsertBatch(inputs []ChunkInput) ([]string, error) {
+    if len(inputs) == 0 {
+        return nil, nil
+    }
+
+    // Build one "(?, ?, ...)" group of placeholders per row.
+    row := "(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?, ?, ?, ?)"
+    rows := make([]string, len(inputs))
+    for i := range inputs {
+        rows[i] = row
+    }
+
+    stmt := `INSERT INTO chunks (public_id, slug, title, content, content_sha256, size, created, expires, language, user_id, private, keep_alive, normalized, creator_ip)
+    VALUES ` + strings.Join(rows, ", ")
+
+    // A single statement is atomic on its own. If any of the public IDs or
+    // slugs is taken the whole statement fails, and we try again with new
+    // public IDs and suffixed slugs for every row.
+    for attempt := 1; ; attempt++ {
+        publicIDs := make([]string, len(inputs))
+        args := make([]any, 0, len(inputs)*13)
+        // Titles repeated within the batch would collide with each other
+        // on every attempt, so the repeats get a suffix straight away.
+        seen := make(map[string]bool, len(inputs))
+        for i, in := range inputs {
+            publicID, err := newPublicID()
+            if err != nil {
+                return nil, err
+            }
+            publicIDs[i] = publicID
+            slugAttempt := attempt
+            if base := slugify(in.Title); seen[base] {
+                slugAttempt++
+            } else {
+                seen[base] = true
+            }
+            slug, err := chunkSlug(in.Title, slugAttempt)
+            if err != nil {
+                return nil, err
+            }
+            args = append(args, publicID, slug, in.Title, in.Content, ContentSHA256(in.Content), filesSize(in.Content, in.Files), in.Expires, in.Language, nullUserID(in.UserID), in.Private, in.KeepAlive, in.Normalized, nullString(in.IP))
+        }
+
+        err := m.insertBatch(stmt, args, publicIDs, inputs)
+        if err == nil {
+            return publicIDs, nil
+        }
+        if !(isDuplicatePublicID(err) || isDuplicateSlug(err)) || attempt == insertAttempts {
+            return nil, err
+        }
+    }
+}
+
+// insertBatch runs the multi-row INSERT statement and adds the tags of the
+// new chunks, in a transaction.
+func (m *ChunkModel) insertBatch(stmt string, args []any, publicIDs []string, inputs []ChunkInput) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if _, err = tx.Exec(stmt, args...); err != nil {
+        return err
+    }
+
+    // The ids of the rows of a multi-row INSERT needn't be consecutive, so
+    // they are looked up by the public IDs.
+    ids := make(map[string]int, len(publicIDs))
+    for i, in := range inputs {
+        if len(in.Tags) > 0 || len(in.Files) > 0 {
+            ids[publicIDs[i]] = 0
+        }
+    }
+    if len(ids) > 0 {
+        in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+        lookup := make([]any, 0, len(ids))
+        for publicID := range ids {
+            lookup = append(lookup, publicID)
+        }
+        rows, err := tx.Query(`SELECT id, public_id FROM chunks WHERE public_id IN `+in, lookup...)
+        if err != nil {
+            return err
+        }
+        for rows.Next() {
+            var id int
+            var publicID string
+            if err = rows.Scan(&id, &publicID); err != nil {
+                rows.Close()
+                return err
+            }
+            ids[publicID] = id
+        }
+        rows.Close()
+        if err = rows.Err(); err != nil {
+            return err
+        }
+
+        for i, in := range inputs {
+            if err = addTags(tx, ids[publicIDs[i]], in.Tags); err != nil {
+                return err
+            }
+            if err = addFiles(tx, ids[publicIDs[i]], in.Files); err != nil {
+                return err
+            }
+        }
+    }
+    return tx.Commit()
 }
 
 // This will return a specific snippet based on its id.
 func (m *ChunkModel) Get(id int) (*Chunk, error) {
-    stmt := `SELECT id, title, content, created, expires FROM chunks
-    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+    return m.get("id = ?", id)
+}
+
+// GetByPublicID returns a chunk by the public ID from its URL.
+func (m *ChunkModel) GetByPublicID(publicID string) (*Chunk, error) {
+    return m.get("public_id = ?", publicID)
+}
+
+// get returns the unexpired chunk matching the condition on the id or
+// public_id column.
+func (m *ChunkModel) get(where string, arg any) (*Chunk, error) {
+    stmt := `SELECT id, public_id, COALESCE(slug, ''), title, content, created, expires, language, user_id, private, keep_alive, normalized, views, access_log FROM chunks
+    WHERE expires > UTC_TIMESTAMP() AND ` + where
 
     // Use the QueryRow() method on the connection pool to execute our
     // SQL statement, passing in the untrusted id variable as the value for the
     // placeholder parameter. This returns a pointer to a sql.Row object which
     // holds the result from the database.
-    row := m.DB.QueryRow(stmt, id)
+    row := m.DB.QueryRow(stmt, arg)
 
     // initialize a pointer to a new chunk struct
     c := &Chunk{}
+    // user_id is NULL for anonymous chunks, so scan it via sql.NullInt64.
+    var userID sql.NullInt64
     // Use row.Scan() to copy the values from each field in sql.Row to the
     // corresponding field in the Snippet struct. Notice that the arguments
     // to row.Scan are *pointers* to the place you want to copy the data into,
     // and the number of arguments must be exactly the same as the number of
     // columns returned by your statement.
-    err := row.Scan(&c.ID, &c.Title, &c.Content, &c.Created, &c.Expires)
+    err := row.Scan(&c.ID, &c.PublicID, &c.Slug, &c.Title, &c.Content, &c.Created, &c.Expires, &c.Language, &userID, &c.Private, &c.KeepAlive, &c.Normalized, &c.Views, &c.AccessLog)
 
     if err != nil {
         // If the query returns no rows, then row.Scan() will return a
@@ -74,12 +430,692 @@ func (m *ChunkModel) Get(id int) (*Chunk, error) {
             return nil, err
         }
     }
+    c.UserID = int(userID.Int64)
+
+    c.Tags, err = m.tags(c.ID)
+    if err != nil {
+        return nil, err
+    }
+    c.Files, err = m.files(c.ID)
+    if err != nil {
+        return nil, err
+    }
     // return chunk object
     return c, nil
 }
 
-// This will return the 10 most recently created snippets.
-// We use slice of pointers to Chunk
-func (m *ChunkModel) Latest() ([]*Chunk, error) {
-    return nil, nil
+// GetMeta returns everything about a chunk except its content, plus the size
+// of the content in bytes. Handlers which stream the content (or don't need
+// it at all) use it to check visibility without loading the content into
+// memory.
+func (m *ChunkModel) GetMeta(id int) (*Chunk, error) {
+    return m.getMeta("id = ?", id)
+}
+
+// GetMetaByPublicID is GetMeta for the public ID from a URL.
+func (m *ChunkModel) GetMetaByPublicID(publicID string) (*Chunk, error) {
+    return m.getMeta("public_id = ?", publicID)
+}
+
+// getMeta returns the metadata of the unexpired chunk matching the condition
+// on the id or public_id column.
+func (m *ChunkModel) getMeta(where string, arg any) (*Chunk, error) {
+    // The hash comes from the content_sha256 column, kept up to date by
+    // Insert and Update, so the content isn't read and hashed on every
+    // lookup. Only rows the schema's UPDATE missed are hashed here.
+    stmt := `SELECT id, public_id, COALESCE(slug, ''), title, created, updated, expires, language, user_id, private, normalized, access_log, LENGTH(content), COALESCE(content_sha256, SHA2(content, 256)) FROM chunks
+    WHERE expires > UTC_TIMESTAMP() AND ` + where
+
+    c := &Chunk{}
+    var userID sql.NullInt64
+    var updated sql.NullTime
+
+    err := m.DB.QueryRow(stmt, arg).Scan(&c.ID, &c.PublicID, &c.Slug, &c.Title, &c.Created, &updated, &c.Expires, &c.Language, &userID, &c.Private, &c.Normalized, &c.AccessLog, &c.Size, &c.SHA256)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, ErrNoRecord
+        }
+        return nil, err
+    }
+    c.UserID = int(userID.Int64)
+    c.Updated = updated.Time
+
+    return c, nil
+}
+
+// streamPieceChars is the number of characters StreamContent fetches from the
+// content column per query.
+const streamPieceChars = 64 * 1024
+
+// StreamContent copies the content of a chunk to w without ever holding the
+// whole value in memory. Instead of scanning the content column into a
+// string like Get does, it reads it in fixed-size pieces using SUBSTRING(),
+// writing each piece to w before fetching the next. It returns ErrNoRecord
+// (before anything is written) if there is no unexpired chunk with the id.
+//
+// The length and every piece are read in one read-only transaction, so they
+// all come from the same snapshot: an edit made while the chunk is being
+// copied can't mix new content into the old, which the caller has already
+// sent the ETag and SHA-256 of. A slow reader keeps the snapshot open for as
+// long as the copy takes.
+func (m *ChunkModel) StreamContent(ctx context.Context, id int, w io.Writer) error {
+    // REPEATABLE READ is InnoDB's default, but a server set to READ
+    // COMMITTED would take a new snapshot for each statement.
+    tx, err := m.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    // First look up the length of the content. This also tells us whether
+    // the chunk exists, so a missing chunk is reported before any bytes
+    // reach the writer.
+    var length int
+    stmt := `SELECT CHAR_LENGTH(content) FROM chunks
+    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+
+    err = tx.QueryRowContext(ctx, stmt, id).Scan(&length)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return ErrNoRecord
+        }
+        return err
+    }
+
+    // SUBSTRING() positions are 1-based and counted in characters, not bytes.
+    stmt = `SELECT SUBSTRING(content, ?, ?) FROM chunks WHERE id = ?`
+
+    var piece []byte
+    for pos := 1; pos <= length; pos += streamPieceChars {
+        err = tx.QueryRowContext(ctx, stmt, pos, streamPieceChars, id).Scan(&piece)
+        if err != nil {
+            // The snapshot still has the chunk if it is deleted part way
+            // through the copy, so this is only in case.
+            if errors.Is(err, sql.ErrNoRows) {
+                return ErrNoRecord
+            }
+            return err
+        }
+        if _, err = w.Write(piece); err != nil {
+            return err
+        }
+    }
+
+    return tx.Commit()
+}
+
+// This will return the 10 most recently created public chunks. Chunks
+// created in the same second (an import, say) are ordered by id, newest
+// first, so they still come out the same way every time.
+// We use slice of pointers to Chunk. Only the first previewChars characters
+// of the content are read (into Preview), so listing pages stay small no
+// matter how big the chunks are.
+func (m *ChunkModel) Latest(previewChars int) ([]*Chunk, error) {
+    return m.ListAfter(0, 10, previewChars)
+}
+
+// ListAfter returns up to limit public chunks which come after the one with
+// the id afterID, newest first like Latest, or the newest ones if afterID
+// is 0. Paging from the last chunk of the previous page, rather than with an
+// OFFSET, lets MySQL seek straight to it however deep the page is. The
+// order is by created and then id, which together are unique, so a page
+// ending part way through chunks created in the same second goes on with
+// the rest of them, without skipping or repeating any.
+func (m *ChunkModel) ListAfter(afterID, limit, previewChars int) ([]*Chunk, error) {
+    stmt := `SELECT id, public_id, title, LEFT(content, ?), CHAR_LENGTH(content) > ?, created, expires, language, user_id
+    FROM chunks WHERE expires > UTC_TIMESTAMP() AND private = FALSE
+    AND (? = 0 OR (created, id) < (SELECT created, id FROM chunks WHERE id = ?))
+    ORDER BY created DESC, id DESC LIMIT ?`
+
+    rows, err := m.DB.Query(stmt, previewChars, previewChars, afterID, afterID, limit)
+    if err != nil {
+        return nil, err
+    }
+    // Always close the result set before ListAfter returns, otherwise the
+    // underlying connection stays open.
+    defer rows.Close()
+
+    chunks := []*Chunk{}
+    for rows.Next() {
+        c := &Chunk{}
+        var userID sql.NullInt64
+        err = rows.Scan(&c.ID, &c.PublicID, &c.Title, &c.Preview, &c.Truncated, &c.Created, &c.Expires, &c.Language, &userID)
+        if err != nil {
+            return nil, err
+        }
+        c.UserID = int(userID.Int64)
+        chunks = append(chunks, c)
+    }
+    // rows.Err() reports any error that happened during the iteration.
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return chunks, nil
+}
+
+// Search returns up to limit public, non-expired chunks whose title or
+// content contains query, newest first. The match is case-insensitive (it
+// follows the collation of the columns) and the full content is returned,
+// so the caller can show where it matched.
+func (m *ChunkModel) Search(query string, limit int) ([]*Chunk, error) {
+    stmt := `SELECT id, public_id, title, content, created, expires, language, user_id
+    FROM chunks WHERE expires > UTC_TIMESTAMP() AND private = FALSE
+    AND (title LIKE ? OR content LIKE ?)
+    ORDER BY id DESC LIMIT ?`
+
+    // The query is matched literally, so the LIKE wildcards in it are
+    // escaped.
+    pattern := "%" + likeEscaper.Replace(query) + "%"
+    rows, err := m.DB.Query(stmt, pattern, pattern, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    chunks := []*Chunk{}
+    for rows.Next() {
+        c := &Chunk{}
+        var userID sql.NullInt64
+        err = rows.Scan(&c.ID, &c.PublicID, &c.Title, &c.Content, &c.Created, &c.Expires, &c.Language, &userID)
+        if err != nil {
+            return nil, err
+        }
+        c.UserID = int(userID.Int64)
+        chunks = append(chunks, c)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+    return chunks, nil
+}
+
+// Related returns up to limit public, non-expired chunks related to the
+// chunk with the id, which is left out: first the ones sharing the most tags
+// with it, then, to fill up the list, ones in the same language. Ties go to
+// the newest. Only the id, public ID, title, language and creation time are
+// filled in.
+func (m *ChunkModel) Related(chunkID int, limit int) ([]*Chunk, error) {
+    stmt := `SELECT c.id, c.public_id, c.title, c.language, c.created,
+        COUNT(t.tag) AS shared, c.language = src.language AS same_language
+    FROM chunks c
+    JOIN chunks src ON src.id = ?
+    LEFT JOIN chunk_tags t ON t.chunk_id = c.id
+        AND t.tag IN (SELECT tag FROM chunk_tags WHERE chunk_id = src.id)
+    WHERE c.id <> src.id AND c.expires > UTC_TIMESTAMP() AND c.private = FALSE
+    GROUP BY c.id, src.language
+    HAVING shared > 0 OR same_language
+    ORDER BY shared DESC, same_language DESC, c.id DESC LIMIT ?`
+
+    rows, err := m.DB.Query(stmt, chunkID, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    chunks := []*Chunk{}
+    for rows.Next() {
+        c := &Chunk{}
+        var shared int
+        var sameLanguage bool
+        err = rows.Scan(&c.ID, &c.PublicID, &c.Title, &c.Language, &c.Created, &shared, &sameLanguage)
+        if err != nil {
+            return nil, err
+        }
+        chunks = append(chunks, c)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+
+    return chunks, nil
+}
+
+// likeEscaper escapes the characters with a special meaning in a LIKE
+// pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// LatestModified returns when a public chunk was last created or edited,
+// which is when the listing pages last changed. It returns the zero time if
+// there are no chunks yet.
+func (m *ChunkModel) LatestModified() (time.Time, error) {
+    stmt := `SELECT MAX(GREATEST(created, COALESCE(updated, created))) FROM chunks WHERE private = FALSE`
+
+    var modified sql.NullTime
+    err := m.DB.QueryRow(stmt).Scan(&modified)
+    if err != nil {
+        return time.Time{}, err
+    }
+    return modified.Time, nil
+}
+
+// Count returns the number of chunks which haven't expired yet.
+func (m *ChunkModel) Count() (int, error) {
+    var count int
+    err := m.DB.QueryRow(`SELECT COUNT(*) FROM chunks WHERE expires > UTC_TIMESTAMP()`).Scan(&count)
+    return count, err
+}
+
+// TotalBytesByUser returns the total size in bytes of a user's non-expired
+// chunks, for the -user-byte-quota. It only reads the
+// idx_chunks_user_size index.
+func (m *ChunkModel) TotalBytesByUser(userID int) (int64, error) {
+    var total int64
+    err := m.DB.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM chunks WHERE user_id = ? AND expires > UTC_TIMESTAMP()`, userID).Scan(&total)
+    return total, err
+}
+
+// ExistsTitleForUser returns the newest non-expired chunk of the user with
+// the title, or ErrNoRecord if there is none. Titles are compared with the
+// column's collation, so case doesn't matter. Only the id, public ID, title
+// and creation time are filled in.
+func (m *ChunkModel) ExistsTitleForUser(userID int, title string) (*Chunk, error) {
+    stmt := `SELECT id, public_id, title, created FROM chunks
+    WHERE user_id = ? AND title = ? AND expires > UTC_TIMESTAMP()
+    ORDER BY id DESC LIMIT 1`
+
+    c := &Chunk{}
+    err := m.DB.QueryRow(stmt, userID, title).Scan(&c.ID, &c.PublicID, &c.Title, &c.Created)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrNoRecord
+    }
+    if err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+// ExistsContent returns the newest non-expired chunk of the user whose
+// content has the hex SHA-256, or ErrNoRecord if there is none. A userID of
+// 0 looks among the anonymous chunks created from ip instead. Only the id,
+// public ID, title, language and creation time are filled in.
+func (m *ChunkModel) ExistsContent(userID int, ip, sha256 string) (*Chunk, error) {
+    stmt := `SELECT id, public_id, title, language, created FROM chunks
+    WHERE user_id = ? AND content_sha256 = ? AND expires > UTC_TIMESTAMP()
+    ORDER BY id DESC LIMIT 1`
+    args := []any{userID, sha256}
+    if userID == 0 {
+        stmt = `SELECT id, public_id, title, language, created FROM chunks
+        WHERE user_id IS NULL AND creator_ip = ? AND content_sha256 = ? AND expires > UTC_TIMESTAMP()
+        ORDER BY id DESC LIMIT 1`
+        args = []any{ip, sha256}
+    }
+
+    c := &Chunk{}
+    err := m.DB.QueryRow(stmt, args...).Scan(&c.ID, &c.PublicID, &c.Title, &c.Language, &c.Created)
+    if errors.Is(err, sql.ErrNoRows) {
+        return nil, ErrNoRecord
+    }
+    if err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+// Update replaces the title, content, language and tags of a chunk, and
+// sets its Updated time, in one transaction. Its expiry, owner, visibility,
+// public ID, slug and other files stay as they are. It returns ErrNoRecord if there is
+// no such chunk.
+func (m *ChunkModel) Update(id int, title, content, language string, normalized bool, tags []string) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    stmt := `UPDATE chunks SET title = ?, content = ?, content_sha256 = ?, language = ?, normalized = ?, updated = UTC_TIMESTAMP(6),
+    size = ? + (SELECT COALESCE(SUM(LENGTH(content)), 0) FROM chunk_files WHERE chunk_id = chunks.id) WHERE id = ?`
+
+    result, err := tx.Exec(stmt, title, content, ContentSHA256(content), language, normalized, len(content), id)
+    if err != nil {
+        return err
+    }
+    // MySQL counts only the rows it changed, so an edit which changes
+    // nothing needs a second look to tell it from a missing chunk.
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        var exists bool
+        err = tx.QueryRow("SELECT EXISTS(SELECT true FROM chunks WHERE id = ?)", id).Scan(&exists)
+        if err != nil {
+            return err
+        }
+        if !exists {
+            return ErrNoRecord
+        }
+    }
+
+    if _, err = tx.Exec("DELETE FROM chunk_tags WHERE chunk_id = ?", id); err != nil {
+        return err
+    }
+    if err = addTags(tx, id, tags); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// ExtendExpiry pushes the expiry of a KeepAlive chunk back to by from now,
+// but no later than maxLifetime after it was created. It never brings an
+// expiry forward, and leaves other chunks and expired ones alone. It is a
+// single statement, so views at the same time can't undo each other.
+func (m *ChunkModel) ExtendExpiry(id int, by, maxLifetime time.Duration) error {
+    stmt := `UPDATE chunks SET expires = GREATEST(expires, LEAST(DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), DATE_ADD(created, INTERVAL ? SECOND)))
+    WHERE id = ? AND keep_alive AND expires > UTC_TIMESTAMP()`
+    _, err := m.DB.Exec(stmt, int64(by.Seconds()), int64(maxLifetime.Seconds()), id)
+    return err
+}
+
+// addViewsBatch is the most chunks one statement of AddViews updates. Each
+// takes three placeholders.
+const addViewsBatch = 500
+
+// AddViews adds to the view counts of chunks, by id, in one transaction,
+// with a statement for each batch of chunks rather than one for each
+// chunk. Chunks which have gone since are skipped.
+func (m *ChunkModel) AddViews(counts map[int]int64) error {
+    if len(counts) == 0 {
+        return nil
+    }
+    ids := make([]int, 0, len(counts))
+    for id := range counts {
+        ids = append(ids, id)
+    }
+    // In id order, so two flushes can't deadlock on each other's rows.
+    sort.Ints(ids)
+
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    for start := 0; start < len(ids); start += addViewsBatch {
+        end := start + addViewsBatch
+        if end > len(ids) {
+            end = len(ids)
+        }
+        batch := ids[start:end]
+        args := make([]any, 0, 3*len(batch))
+        for _, id := range batch {
+            args = append(args, id, counts[id])
+        }
+        for _, id := range batch {
+            args = append(args, id)
+        }
+        stmt := `UPDATE chunks SET views = views + CASE id` + strings.Repeat(" WHEN ? THEN ?", len(batch)) + ` END
+        WHERE id IN (?` + strings.Repeat(", ?", len(batch)-1) + `)`
+        if _, err := tx.Exec(stmt, args...); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// Delete deletes a chunk, marking its comments as deleted in the same
+// transaction. It returns ErrNoRecord if there is no such chunk.
+func (m *ChunkModel) Delete(id int) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec(`UPDATE comments SET deleted = UTC_TIMESTAMP()
+    WHERE deleted IS NULL AND chunk_id = ?`, id)
+    if err != nil {
+        return err
+    }
+
+    result, err := tx.Exec("DELETE FROM chunks WHERE id = ?", id)
+    if err != nil {
+        return err
+    }
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNoRecord
+    }
+    return tx.Commit()
+}
+
+// DeleteOldest deletes up to n non-expired chunks, starting with the ones
+// which are closest to expiring (and of those the oldest), to make room for
+// new chunks. It returns the number of chunks deleted. The comments on the
+// deleted chunks are marked as deleted in the same transaction.
+func (m *ChunkModel) DeleteOldest(n int) (int, error) {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    // Pick the chunks first, so the comments and the chunks are sure to be
+    // deleted for the same set. Imported chunks often share their
+    // timestamps, so the id breaks ties and the order is always the same.
+    stmt := `SELECT id FROM chunks WHERE expires > UTC_TIMESTAMP()
+    ORDER BY expires ASC, created ASC, id ASC LIMIT ? FOR UPDATE`
+
+    rows, err := tx.Query(stmt, n)
+    if err != nil {
+        return 0, err
+    }
+    var ids []any
+    for rows.Next() {
+        var id int
+        if err = rows.Scan(&id); err != nil {
+            rows.Close()
+            return 0, err
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err = rows.Err(); err != nil {
+        return 0, err
+    }
+    if len(ids) == 0 {
+        return 0, nil
+    }
+
+    deleted, err := deleteChunks(tx, ids)
+    if err != nil {
+        return 0, err
+    }
+
+    err = tx.Commit()
+    if err != nil {
+        return 0, err
+    }
+    return deleted, nil
+}
+
+// deleteChunks deletes the chunks with the ids, and marks their comments as
+// deleted, as part of a transaction. It returns the number of chunks
+// deleted.
+func deleteChunks(tx *sql.Tx, ids []any) (int, error) {
+    in := "(?" + strings.Repeat(", ?", len(ids)-1) + ")"
+    _, err := tx.Exec(`UPDATE comments SET deleted = UTC_TIMESTAMP()
+    WHERE deleted IS NULL AND chunk_id IN `+in, ids...)
+    if err != nil {
+        return 0, err
+    }
+
+    result, err := tx.Exec("DELETE FROM chunks WHERE id IN "+in, ids...)
+    if err != nil {
+        return 0, err
+    }
+    deleted, err := result.RowsAffected()
+    return int(deleted), err
+}
+
+// A ChunkFilter selects the chunks DeleteMatching deletes, expired or not.
+// A chunk must match every field that is set: one of the PublicIDs or IDs,
+// created before CreatedBefore, and from the CreatorIP.
+type ChunkFilter struct {
+    PublicIDs     []string
+    IDs           []int
+    CreatedBefore time.Time
+    CreatorIP     string
+}
+
+// Empty reports whether the filter has no conditions. DeleteMatching
+// refuses an empty filter rather than delete every chunk.
+func (f ChunkFilter) Empty() bool {
+    return len(f.PublicIDs) == 0 && len(f.IDs) == 0 && f.CreatedBefore.IsZero() && f.CreatorIP == ""
+}
+
+// deleteMatchingBatch is how many chunks DeleteMatching deletes with each
+// statement, to keep the statements (and the IN lists) a reasonable size.
+const deleteMatchingBatch = 500
+
+// DeleteMatching deletes every chunk matching the filter, and marks their
+// comments as deleted, in one transaction: either all of them go or none
+// do. It returns the number of chunks deleted.
+func (m *ChunkModel) DeleteMatching(filter ChunkFilter) (int, error) {
+    if filter.Empty() {
+        return 0, errors.New("models: DeleteMatching needs a filter")
+    }
+
+    var where []string
+    var args []any
+    var ids []string
+    if len(filter.PublicIDs) > 0 {
+        ids = append(ids, "public_id IN (?"+strings.Repeat(", ?", len(filter.PublicIDs)-1)+")")
+        for _, publicID := range filter.PublicIDs {
+            args = append(args, publicID)
+        }
+    }
+    if len(filter.IDs) > 0 {
+        ids = append(ids, "id IN (?"+strings.Repeat(", ?", len(filter.IDs)-1)+")")
+        for _, id := range filter.IDs {
+            args = append(args, id)
+        }
+    }
+    if len(ids) > 0 {
+        where = append(where, "("+strings.Join(ids, " OR ")+")")
+    }
+    if !filter.CreatedBefore.IsZero() {
+        where = append(where, "created < ?")
+        args = append(args, filter.CreatedBefore.UTC())
+    }
+    if filter.CreatorIP != "" {
+        where = append(where, "creator_ip = ?")
+        args = append(args, filter.CreatorIP)
+    }
+    stmt := "SELECT id FROM chunks WHERE " + strings.Join(where, " AND ") + " LIMIT ? FOR UPDATE"
+    args = append(args, deleteMatchingBatch)
+
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    // Deleted chunks no longer match, so each round picks up the next
+    // batch, until one comes back short.
+    total := 0
+    for {
+        rows, err := tx.Query(stmt, args...)
+        if err != nil {
+            return 0, err
+        }
+        var ids []any
+        for rows.Next() {
+            var id int
+            if err = rows.Scan(&id); err != nil {
+                rows.Close()
+                return 0, err
+            }
+            ids = append(ids, id)
+        }
+        rows.Close()
+        if err = rows.Err(); err != nil {
+            return 0, err
+        }
+        if len(ids) == 0 {
+            break
+        }
+
+        deleted, err := deleteChunks(tx, ids)
+        if err != nil {
+            return 0, err
+        }
+        total += deleted
+        if len(ids) < deleteMatchingBatch {
+            break
+        }
+    }
+
+    if err = tx.Commit(); err != nil {
+        return 0, err
+    }
+    return total, nil
+}
+
+// BackfillPublicIDs gives a public ID to every chunk created before there
+// were public IDs, and returns how many it updated. It is run at startup
+// and does nothing once every chunk has one.
+func (m *ChunkModel) BackfillPublicIDs() (int, error) {
+    updated := 0
+    for {
+        rows, err := m.DB.Query("SELECT id FROM chunks WHERE public_id IS NULL LIMIT 500")
+        if err != nil {
+            return updated, err
+        }
+        var ids []int
+        for rows.Next() {
+            var id int
+            if err = rows.Scan(&id); err != nil {
+                rows.Close()
+                return updated, err
+            }
+            ids = append(ids, id)
+        }
+        rows.Close()
+        if err = rows.Err(); err != nil {
+            return updated, err
+        }
+        if len(ids) == 0 {
+            return updated, nil
+        }
+
+        for _, id := range ids {
+            if err = m.setPublicID(id); err != nil {
+                return updated, err
+            }
+            updated++
+        }
+    }
+}
+
+// setPublicID gives the chunk a new public ID, unless another server got
+// there first.
+func (m *ChunkModel) setPublicID(id int) error {
+    for attempt := 1; ; attempt++ {
+        publicID, err := newPublicID()
+        if err != nil {
+            return err
+        }
+        _, err = m.DB.Exec("UPDATE chunks SET public_id = ? WHERE id = ? AND public_id IS NULL", publicID, id)
+        if err == nil {
+            return nil
+        }
+        if !isDuplicatePublicID(err) || attempt == insertAttempts {
+            return err
+        }
+    }
+}
+
+// nullUserID converts our "0 means anonymous" convention into the NULL value
+// stored in the user_id column.
+func nullUserID(userID int) sql.NullInt64 {
+    return sql.NullInt64{Int64: int64(userID), Valid: userID > 0}
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+    return sql.NullString{String: s, Valid: s != ""}
 }
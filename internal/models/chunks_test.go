@@ -0,0 +1,149 @@
+package models
+
+import (
+    "context"
+    "io"
+    "strings"
+    "testing"
+    "time"
+)
+
+// sameSecondChunks inserts n public chunks, all but two with the same
+// created time (set with setCreated), as an import would give them. The
+// first and the last inserted get the newest and the oldest time, so the
+// order isn't simply that of the ids. It returns the ids in the order
+// ListAfter should give them.
+func sameSecondChunks(t *testing.T, store ChunkStore, n int, setCreated func(id int, created time.Time)) []int {
+    t.Helper()
+
+    created := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+    ids := make([]int, n)
+    for i := 0; i < n; i++ {
+        publicID, err := store.Insert("Imported", "content", 1, "text", 0, false, false, false, nil, nil, "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        chunk, err := store.GetMetaByPublicID(publicID)
+        if err != nil {
+            t.Fatal(err)
+        }
+        switch i {
+        case 0:
+            setCreated(chunk.ID, created.Add(time.Minute))
+            ids[0] = chunk.ID
+        case n - 1:
+            setCreated(chunk.ID, created.Add(-time.Minute))
+            ids[n-1] = chunk.ID
+        default:
+            // Newest first by id among the same second.
+            setCreated(chunk.ID, created)
+            ids[n-1-i] = chunk.ID
+        }
+    }
+    return ids
+}
+
+// checkPaging pages through the chunks of the store with ListAfter, and
+// checks they come out in the order of want, each of them once.
+func checkPaging(t *testing.T, store ChunkStore, want []int) {
+    t.Helper()
+
+    var got []int
+    afterID := 0
+    for page := 0; page <= len(want); page++ {
+        chunks, err := store.ListAfter(afterID, 3, 10)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(chunks) == 0 {
+            break
+        }
+        for _, c := range chunks {
+            got = append(got, c.ID)
+        }
+        afterID = chunks[len(chunks)-1].ID
+    }
+    if len(got) != len(want) {
+        t.Fatalf("paged through %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("paged through %v, want %v", got, want)
+        }
+    }
+}
+
+func TestChunkModelListAfterSameSecond(t *testing.T) {
+    db := newTestDB(t)
+    m := &ChunkModel{DB: db}
+    ids := sameSecondChunks(t, m, 10, func(id int, created time.Time) {
+        if _, err := db.Exec("UPDATE chunks SET created = ? WHERE id = ?", created, id); err != nil {
+            t.Fatal(err)
+        }
+    })
+    t.Cleanup(func() {
+        for _, id := range ids {
+            m.Delete(id)
+        }
+    })
+
+    // Other public chunks in the database come before or after these.
+    chunks, err := m.ListAfter(0, 1<<20, 10)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var all []int
+    pos := map[int]int{}
+    for i, c := range chunks {
+        all = append(all, c.ID)
+        pos[c.ID] = i
+    }
+    for i := 1; i < len(ids); i++ {
+        if pos[ids[i]] <= pos[ids[i-1]] {
+            t.Fatalf("chunk %d listed before chunk %d", ids[i], ids[i-1])
+        }
+    }
+    checkPaging(t, m, all)
+}
+
+// BenchmarkChunkContent compares reading the content of a large chunk with
+// Get, which holds all of it in a string, and with StreamContent. Run it with
+// -benchmem: the bytes allocated per read are about the size of the chunk
+// for Get, and of a piece for StreamContent. The chunk doesn't fit in the
+// original TEXT column; see the ALTER TABLE for content at Chunk.
+func BenchmarkChunkContent(b *testing.B) {
+    m := &ChunkModel{DB: newTestDB(b)}
+    content := strings.Repeat("0123456789abcdef\n", 8<<20/17)
+    publicID, err := m.Insert("Benchmark", content, 1, "text", 0, false, false, false, nil, nil, "")
+    if err != nil {
+        b.Fatal(err)
+    }
+    chunk, err := m.GetMetaByPublicID(publicID)
+    if err != nil {
+        b.Fatal(err)
+    }
+    b.Cleanup(func() { m.Delete(chunk.ID) })
+
+    b.Run("Get", func(b *testing.B) {
+        b.ReportAllocs()
+        b.SetBytes(chunk.Size)
+        for i := 0; i < b.N; i++ {
+            c, err := m.Get(chunk.ID)
+            if err != nil {
+                b.Fatal(err)
+            }
+            if _, err = io.WriteString(io.Discard, c.Content); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+    b.Run("StreamContent", func(b *testing.B) {
+        b.ReportAllocs()
+        b.SetBytes(chunk.Size)
+        for i := 0; i < b.N; i++ {
+            if err := m.StreamContent(context.Background(), chunk.ID, io.Discard); err != nil {
+                b.Fatal(err)
+            }
+        }
+    })
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+    "database/sql"
+    "time"
+)
+
+// A Comment is a message posted under a chunk by a logged-in user. UserName
+// is the author's name at the time it is read, or empty once their account
+// has been deleted. Comments are stored in the "comments" table:
+//
+//  CREATE TABLE comments (
+//      id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//      chunk_id INTEGER NOT NULL,
+//      user_id INTEGER NULL,
+//      body TEXT NOT NULL,
+//      created DATETIME NOT NULL,
+//      deleted DATETIME NULL
+//  );
+//  CREATE INDEX idx_comments_chunk_id ON comments(chunk_id, id);
+//
+// Comments are never removed, only marked as deleted when their chunk goes,
+// so there is no foreign key to chunks.
+type Comment struct {
+    ID       int
+    ChunkID  int
+    UserID   int
+    UserName string
+    Body     string
+    Created  time.Time
+}
+
+// Define a CommentModel type which wraps a sql.DB connection pool.
+type CommentModel struct {
+    DB *sql.DB
+}
+
+// Insert adds a comment to a chunk and returns its ID.
+func (m *CommentModel) Insert(chunkID, userID int, body string) (int, error) {
+    stmt := `INSERT INTO comments (chunk_id, user_id, body, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+    result, err := m.DB.Exec(stmt, chunkID, userID, body)
+    if err != nil {
+        return 0, err
+    }
+    id, err := result.LastInsertId()
+    if err != nil {
+        return 0, err
+    }
+    return int(id), nil
+}
+
+// ByChunk returns the comments on a chunk which haven't been deleted, oldest
+// first.
+func (m *CommentModel) ByChunk(chunkID int) ([]*Comment, error) {
+    stmt := `SELECT c.id, c.chunk_id, c.user_id, u.name, c.body, c.created
+    FROM comments c LEFT JOIN users u ON u.id = c.user_id
+    WHERE c.chunk_id = ? AND c.deleted IS NULL
+    ORDER BY c.id ASC`
+
+    rows, err := m.DB.Query(stmt, chunkID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    comments := []*Comment{}
+    for rows.Next() {
+        c := &Comment{}
+        var userID sql.NullInt64
+        var userName sql.NullString
+        err = rows.Scan(&c.ID, &c.ChunkID, &userID, &userName, &c.Body, &c.Created)
+        if err != nil {
+            return nil, err
+        }
+        c.UserID = int(userID.Int64)
+        c.UserName = userName.String
+        comments = append(comments, c)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+    return comments, nil
+}
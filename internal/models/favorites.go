@@ -0,0 +1,111 @@
+package models
+
+import (
+    "database/sql"
+)
+
+// A FavoriteModel keeps track of the chunks users have starred, so they can
+// find them again later. It wraps a sql.DB connection pool. The stars are
+// kept in the "favorites" table, and go away with either the user or the
+// chunk:
+//
+//  CREATE TABLE favorites (
+//      user_id INTEGER NOT NULL,
+//      chunk_id INTEGER NOT NULL,
+//      created DATETIME NOT NULL,
+//      PRIMARY KEY (user_id, chunk_id),
+//      FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+//      FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+//  );
+//  CREATE INDEX idx_favorites_chunk_id ON favorites(chunk_id);
+type FavoriteModel struct {
+    DB *sql.DB
+}
+
+// Toggle stars the chunk for the user if they haven't already, and unstars
+// it otherwise. It returns whether the chunk is starred now.
+func (m *FavoriteModel) Toggle(userID, chunkID int) (bool, error) {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return false, err
+    }
+    defer tx.Rollback()
+
+    result, err := tx.Exec("DELETE FROM favorites WHERE user_id = ? AND chunk_id = ?", userID, chunkID)
+    if err != nil {
+        return false, err
+    }
+    removed, err := result.RowsAffected()
+    if err != nil {
+        return false, err
+    }
+
+    // There was no star to remove, so add one. INSERT IGNORE keeps a double
+    // click from failing on the primary key.
+    if removed == 0 {
+        _, err = tx.Exec(`INSERT IGNORE INTO favorites (user_id, chunk_id, created)
+        VALUES(?, ?, UTC_TIMESTAMP())`, userID, chunkID)
+        if err != nil {
+            return false, err
+        }
+    }
+
+    err = tx.Commit()
+    if err != nil {
+        return false, err
+    }
+    return removed == 0, nil
+}
+
+// IsFavorite reports whether the user has starred the chunk.
+func (m *FavoriteModel) IsFavorite(userID, chunkID int) (bool, error) {
+    var exists bool
+    stmt := "SELECT EXISTS(SELECT true FROM favorites WHERE user_id = ? AND chunk_id = ?)"
+    err := m.DB.QueryRow(stmt, userID, chunkID).Scan(&exists)
+    return exists, err
+}
+
+// Count returns the number of users who have starred the chunk.
+func (m *FavoriteModel) Count(chunkID int) (int, error) {
+    var count int
+    err := m.DB.QueryRow("SELECT COUNT(*) FROM favorites WHERE chunk_id = ?", chunkID).Scan(&count)
+    return count, err
+}
+
+// ByUser returns one page of the chunks a user has starred, most recently
+// starred first. Expired chunks, and private chunks the user doesn't own,
+// are left out. Pages are numbered from 1, and the second return value
+// reports whether there are more chunks after this page.
+func (m *FavoriteModel) ByUser(userID, page, pageSize int) ([]*Chunk, bool, error) {
+    stmt := `SELECT c.id, c.public_id, c.title, c.created, c.expires, c.language, c.user_id, c.private
+    FROM favorites f JOIN chunks c ON c.id = f.chunk_id
+    WHERE f.user_id = ? AND c.expires > UTC_TIMESTAMP() AND (c.private = FALSE OR c.user_id = ?)
+    ORDER BY f.created DESC, c.id DESC LIMIT ? OFFSET ?`
+
+    // Ask for one extra row to find out whether there is a next page.
+    rows, err := m.DB.Query(stmt, userID, userID, pageSize+1, (page-1)*pageSize)
+    if err != nil {
+        return nil, false, err
+    }
+    defer rows.Close()
+
+    chunks := []*Chunk{}
+    for rows.Next() {
+        c := &Chunk{}
+        var owner sql.NullInt64
+        err = rows.Scan(&c.ID, &c.PublicID, &c.Title, &c.Created, &c.Expires, &c.Language, &owner, &c.Private)
+        if err != nil {
+            return nil, false, err
+        }
+        c.UserID = int(owner.Int64)
+        chunks = append(chunks, c)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, false, err
+    }
+
+    if len(chunks) > pageSize {
+        return chunks[:pageSize], true, nil
+    }
+    return chunks, false, nil
+}
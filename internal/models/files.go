@@ -0,0 +1,74 @@
+package models
+
+import (
+    "database/sql"
+    "strings"
+)
+
+// A ChunkFile is one of the other files of a multi-file chunk. The first
+// file of every chunk is the chunk itself: its content and language, saved
+// under the name made from its slug. The others are kept in a table of
+// their own, in the order they were given, and go when the chunk does:
+//
+//  CREATE TABLE chunk_files (
+//      chunk_id INTEGER NOT NULL,
+//      position INTEGER NOT NULL,
+//      filename VARCHAR(100) NOT NULL,
+//      language VARCHAR(50) NOT NULL,
+//      content MEDIUMTEXT NOT NULL,
+//      PRIMARY KEY (chunk_id, position),
+//      FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+//  );
+//
+// The models store the files as they are given. Checking the filenames
+// and sizes is up to the caller.
+type ChunkFile struct {
+    Filename string
+    Language string
+    Content  string
+}
+
+// filesSize is the length in bytes of the content of all the files of a
+// chunk, the first one included, as kept in the size column.
+func filesSize(content string, files []ChunkFile) int {
+    n := len(content)
+    for _, f := range files {
+        n += len(f.Content)
+    }
+    return n
+}
+
+// addFiles adds the other files of a chunk, as part of the transaction
+// which creates it.
+func addFiles(tx *sql.Tx, chunkID int, files []ChunkFile) error {
+    if len(files) == 0 {
+        return nil
+    }
+
+    args := make([]any, 0, len(files)*5)
+    for i, f := range files {
+        args = append(args, chunkID, i+1, f.Filename, f.Language, f.Content)
+    }
+    stmt := `INSERT INTO chunk_files (chunk_id, position, filename, language, content) VALUES (?, ?, ?, ?, ?)` + strings.Repeat(", (?, ?, ?, ?, ?)", len(files)-1)
+    _, err := tx.Exec(stmt, args...)
+    return err
+}
+
+// files returns the other files of a chunk in order.
+func (m *ChunkModel) files(chunkID int) ([]ChunkFile, error) {
+    rows, err := m.DB.Query(`SELECT filename, language, content FROM chunk_files WHERE chunk_id = ? ORDER BY position`, chunkID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var files []ChunkFile
+    for rows.Next() {
+        var f ChunkFile
+        if err = rows.Scan(&f.Filename, &f.Language, &f.Content); err != nil {
+            return nil, err
+        }
+        files = append(files, f)
+    }
+    return files, rows.Err()
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+    "database/sql"
+    "strings"
+    "unicode"
+)
+
+// SearchRanked needs a FULLTEXT index over the title and content:
+//
+//  ALTER TABLE chunks ADD FULLTEXT INDEX idx_chunks_fulltext (title, content);
+//
+// Its queries use a subset of MySQL's boolean mode: words, "quoted
+// phrases", a + in front of a term which must be there, a - in front of
+// one which mustn't, and a * after a word to match it as a prefix. Other
+// operators are dropped rather than passed on, as InnoDB refuses some
+// queries which use them wrongly (a lone "+", say).
+
+// A searchTerm is one term of a boolean-mode query.
+type searchTerm struct {
+    text     string
+    phrase   bool
+    required bool
+    excluded bool
+    prefix   bool
+}
+
+// parseBooleanQuery splits a query into its terms, dropping what they
+// can't express.
+func parseBooleanQuery(query string) []searchTerm {
+    var terms []searchTerm
+    rest := []rune(query)
+    for len(rest) > 0 {
+        var t searchTerm
+        switch rest[0] {
+        case '+':
+            t.required, rest = true, rest[1:]
+        case '-':
+            t.excluded, rest = true, rest[1:]
+        }
+        if len(rest) > 0 && rest[0] == '"' {
+            end := 1
+            for end < len(rest) && rest[end] != '"' {
+                end++
+            }
+            t.phrase = true
+            t.text = strings.Join(strings.FieldsFunc(string(rest[1:end]), notWordRune), " ")
+            if end < len(rest) {
+                end++
+            }
+            rest = rest[end:]
+        } else {
+            end := 0
+            for end < len(rest) && !notWordRune(rest[end]) {
+                end++
+            }
+            t.text = string(rest[:end])
+            rest = rest[end:]
+            if t.text != "" && len(rest) > 0 && rest[0] == '*' {
+                t.prefix, rest = true, rest[1:]
+            }
+            if end == 0 && len(rest) > 0 {
+                // Skip whatever isn't part of a term.
+                rest = rest[1:]
+            }
+        }
+        if t.text != "" {
+            terms = append(terms, t)
+        }
+    }
+    return terms
+}
+
+// notWordRune reports whether r separates the words of a query. Only
+// letters, digits and underscores make up words, as in MySQL's parser.
+func notWordRune(r rune) bool {
+    return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// booleanQuery writes the terms back as a boolean-mode query.
+func booleanQuery(terms []searchTerm) string {
+    parts := make([]string, len(terms))
+    for i, t := range terms {
+        var b strings.Builder
+        switch {
+        case t.required:
+            b.WriteByte('+')
+        case t.excluded:
+            b.WriteByte('-')
+        }
+        if t.phrase {
+            b.WriteString(`"` + t.text + `"`)
+        } else {
+            b.WriteString(t.text)
+        }
+        if t.prefix {
+            b.WriteByte('*')
+        }
+        parts[i] = b.String()
+    }
+    return strings.Join(parts, " ")
+}
+
+// QueryWords returns the words of a query, as the FULLTEXT parser splits
+// them, without any operators.
+func QueryWords(query string) []string {
+    return strings.FieldsFunc(query, notWordRune)
+}
+
+// stopwords are InnoDB's default FULLTEXT stopwords, which the index
+// leaves out, so a search for them alone finds nothing.
+var stopwords = map[string]bool{
+    "a": true, "about": true, "an": true, "are": true, "as": true, "at": true,
+    "be": true, "by": true, "com": true, "de": true, "en": true, "for": true,
+    "from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+    "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+    "this": true, "to": true, "was": true, "what": true, "when": true,
+    "where": true, "who": true, "will": true, "with": true, "und": true,
+    "www": true,
+}
+
+// IsStopword reports whether a word is one of the FULLTEXT stopwords, in
+// any case.
+func IsStopword(word string) bool {
+    return stopwords[strings.ToLower(word)]
+}
+
+// SearchTerms returns the words and phrases a query looks for, leaving out
+// the excluded ones, for marking the matches in the results.
+func SearchTerms(query string) []string {
+    var texts []string
+    for _, t := range parseBooleanQuery(query) {
+        if !t.excluded {
+            texts = append(texts, t.text)
+        }
+    }
+    return texts
+}
+
+// SearchRanked returns up to limit public, non-expired chunks which match
+// the boolean-mode query, the most relevant first, with their relevance in
+// Score. Matches scoring less than minScore are left out. The query only
+// ever reaches MySQL as the parameter of AGAINST, and a query with no
+// terms left matches nothing.
+func (m *ChunkModel) SearchRanked(query string, minScore float64, limit int) ([]*Chunk, error) {
+    against := booleanQuery(parseBooleanQuery(query))
+    if against == "" {
+        return []*Chunk{}, nil
+    }
+
+    stmt := `SELECT id, public_id, title, content, created, expires, language, user_id,
+        MATCH(title, content) AGAINST(? IN BOOLEAN MODE) AS score
+    FROM chunks WHERE expires > UTC_TIMESTAMP() AND private = FALSE
+    AND MATCH(title, content) AGAINST(? IN BOOLEAN MODE)
+    HAVING score >= ?
+    ORDER BY score DESC, id DESC LIMIT ?`
+
+    rows, err := m.DB.Query(stmt, against, against, minScore, limit)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    chunks := []*Chunk{}
+    for rows.Next() {
+        c := &Chunk{}
+        var userID sql.NullInt64
+        err = rows.Scan(&c.ID, &c.PublicID, &c.Title, &c.Content, &c.Created, &c.Expires, &c.Language, &userID, &c.Score)
+        if err != nil {
+            return nil, err
+        }
+        c.UserID = int(userID.Int64)
+        chunks = append(chunks, c)
+    }
+    if err = rows.Err(); err != nil {
+        return nil, err
+    }
+    return chunks, nil
+}
+
+// memoryScore is how the in-memory store ranks a chunk for the terms: the
+// number of times the wanted terms occur in its title and content, or 0 if
+// it doesn't match. It only roughly follows MySQL's scores, which depend
+// on how rare each word is.
+func memoryScore(c *Chunk, terms []searchTerm) float64 {
+    text := strings.ToLower(c.Title + "\n" + c.Content)
+    var score float64
+    anyWanted := false
+    for _, t := range terms {
+        term := strings.ToLower(t.text)
+        n := strings.Count(text, term)
+        switch {
+        case t.excluded:
+            if n > 0 {
+                return 0
+            }
+            continue
+        case t.required && n == 0:
+            return 0
+        }
+        anyWanted = true
+        score += float64(n)
+    }
+    if !anyWanted {
+        return 0
+    }
+    return score
+}
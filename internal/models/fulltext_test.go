@@ -0,0 +1,88 @@
+package models
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseBooleanQuery(t *testing.T) {
+    tests := []struct {
+        query string
+        want  []searchTerm
+        // against is the query passed to AGAINST.
+        against string
+    }{
+        {"", nil, ""},
+        {"go web", []searchTerm{{text: "go"}, {text: "web"}}, "go web"},
+        {`"hello world"`, []searchTerm{{text: "hello world", phrase: true}}, `"hello world"`},
+        {`+"hello,  world!" -bye`, []searchTerm{{text: "hello world", phrase: true, required: true}, {text: "bye", excluded: true}}, `+"hello world" -bye`},
+        {"+go -java", []searchTerm{{text: "go", required: true}, {text: "java", excluded: true}}, "+go -java"},
+        {"data*", []searchTerm{{text: "data", prefix: true}}, "data*"},
+        {`"unterminated phrase`, []searchTerm{{text: "unterminated phrase", phrase: true}}, `"unterminated phrase"`},
+        // Operators which aren't supported, or are used wrongly, are dropped.
+        {"+ - * \"\"", nil, ""},
+        {"(go) >web <java ~php @3", []searchTerm{{text: "go"}, {text: "web"}, {text: "java"}, {text: "php"}, {text: "3"}}, "go web java php 3"},
+        {`go"); DROP TABLE chunks; --`, []searchTerm{{text: "go"}, {text: "DROP TABLE chunks", phrase: true}}, `go "DROP TABLE chunks"`},
+        {"héllo wörld_1", []searchTerm{{text: "héllo"}, {text: "wörld_1"}}, "héllo wörld_1"},
+    }
+    for _, tt := range tests {
+        got := parseBooleanQuery(tt.query)
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("parseBooleanQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+        }
+        if against := booleanQuery(got); against != tt.against {
+            t.Errorf("booleanQuery of %q = %q, want %q", tt.query, against, tt.against)
+        }
+    }
+}
+
+func TestSearchTerms(t *testing.T) {
+    got := SearchTerms(`+go "error handling" -java`)
+    if want := []string{"go", "error handling"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("SearchTerms = %q, want %q", got, want)
+    }
+}
+
+func TestMemoryChunkModelSearchRanked(t *testing.T) {
+    m := NewMemoryChunkModel()
+    for _, c := range []struct{ title, content string }{
+        {"phrase", "error handling in go, and more error handling"},
+        {"words", "handling the error of go"},
+        {"java", "error handling in java"},
+        {"other", "nothing to see"},
+    } {
+        if _, err := m.Insert(c.title, c.content, 7, "text", 0, false, false, false, nil, nil, ""); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    tests := []struct {
+        query    string
+        minScore float64
+        want     []string
+    }{
+        // The phrase only matches the words in that order.
+        {query: `"error handling"`, want: []string{"phrase", "java"}},
+        {query: `+go +"error handling"`, want: []string{"phrase"}},
+        {query: "+error -java", want: []string{"phrase", "words"}},
+        {query: "+go +java"},
+        {query: "-error"},
+        {query: `"error handling"`, minScore: 2, want: []string{"phrase"}},
+    }
+    for _, tt := range tests {
+        chunks, err := m.SearchRanked(tt.query, tt.minScore, 10)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var got []string
+        for _, c := range chunks {
+            if c.Score <= 0 {
+                t.Errorf("%q: %s has score %v", tt.query, c.Title, c.Score)
+            }
+            got = append(got, c.Title)
+        }
+        if !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("SearchRanked(%q, %v) = %q, want %q", tt.query, tt.minScore, got, tt.want)
+        }
+    }
+}
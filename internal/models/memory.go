@@ -0,0 +1,587 @@
+package models
+
+import (
+    "context"
+    "errors"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+    "unicode/utf8"
+)
+
+// MemoryChunkModel is a ChunkStore which keeps the chunks in a map instead
+// of MySQL, so the application can be run without a database for demos and
+// tests. Everything is lost when the process exits. It is safe for
+// concurrent use.
+type MemoryChunkModel struct {
+    mu     sync.RWMutex
+    chunks map[int]*Chunk
+    // publicIDs maps the public IDs to the IDs of the chunks.
+    publicIDs map[string]int
+    // slugs holds the slugs in use, standing in for the unique index.
+    slugs  map[string]bool
+    nextID int
+}
+
+// NewMemoryChunkModel returns an empty MemoryChunkModel.
+func NewMemoryChunkModel() *MemoryChunkModel {
+    return &MemoryChunkModel{chunks: map[int]*Chunk{}, publicIDs: map[string]int{}, slugs: map[string]bool{}, nextID: 1}
+}
+
+// now returns the current time at the precision of a MySQL DATETIME, so the
+// two stores behave the same.
+func (m *MemoryChunkModel) now() time.Time {
+    return time.Now().UTC().Truncate(time.Second)
+}
+
+// insert adds a chunk and returns its public ID. The caller must hold the
+// write lock.
+func (m *MemoryChunkModel) insert(in ChunkInput) (string, error) {
+    var publicID string
+    for attempt := 1; ; attempt++ {
+        var err error
+        publicID, err = newPublicID()
+        if err != nil {
+            return "", err
+        }
+        if _, taken := m.publicIDs[publicID]; !taken {
+            break
+        }
+        if attempt == insertAttempts {
+            return "", errors.New("models: no free public ID")
+        }
+    }
+    var slug string
+    for attempt := 1; ; attempt++ {
+        var err error
+        slug, err = chunkSlug(in.Title, attempt)
+        if err != nil {
+            return "", err
+        }
+        if !m.slugs[slug] {
+            break
+        }
+        if attempt == insertAttempts {
+            return "", errors.New("models: no free slug")
+        }
+    }
+
+    created := m.now()
+    id := m.nextID
+    m.nextID++
+    m.publicIDs[publicID] = id
+    m.slugs[slug] = true
+    m.chunks[id] = &Chunk{
+        ID:       id,
+        PublicID: publicID,
+        Title:    in.Title,
+        Slug:     slug,
+        Content:  in.Content,
+        Created:  created,
+        Expires:  created.AddDate(0, 0, in.Expires),
+        Language: in.Language,
+        UserID:   in.UserID,
+        Private:  in.Private,
+        KeepAlive: in.KeepAlive,
+        Normalized: in.Normalized,
+        Tags:     sortedTags(in.Tags),
+        Files:    append([]ChunkFile(nil), in.Files...),
+        CreatorIP: in.IP,
+    }
+    return publicID, nil
+}
+
+// delete removes a chunk. The caller must hold the write lock.
+func (m *MemoryChunkModel) delete(c *Chunk) {
+    delete(m.chunks, c.ID)
+    delete(m.publicIDs, c.PublicID)
+    delete(m.slugs, c.Slug)
+}
+
+// live returns the chunk with the id if it hasn't expired. The caller must
+// hold the lock.
+func (m *MemoryChunkModel) live(id int) (*Chunk, bool) {
+    c, ok := m.chunks[id]
+    if !ok || !c.Expires.After(m.now()) {
+        return nil, false
+    }
+    return c, true
+}
+
+func (m *MemoryChunkModel) Insert(title string, content string, expires int, language string, userID int, private bool, keepAlive bool, normalized bool, tags []string, files []ChunkFile, ip string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    return m.insert(ChunkInput{
+        Title:    title,
+        Content:  content,
+        Expires:  expires,
+        Language: language,
+        UserID:   userID,
+        Private:  private,
+        KeepAlive: keepAlive,
+        Normalized: normalized,
+        Tags:     tags,
+        Files:    files,
+        IP:       ip,
+    })
+}
+
+func (m *MemoryChunkModel) InsertBatch(inputs []ChunkInput) ([]string, error) {
+    if len(inputs) == 0 {
+        return nil, nil
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    publicIDs := make([]string, len(inputs))
+    for i, in := range inputs {
+        publicID, err := m.insert(in)
+        if err != nil {
+            // Take back the ones already added, so it's all or nothing.
+            for _, added := range publicIDs[:i] {
+                m.delete(m.chunks[m.publicIDs[added]])
+            }
+            return nil, err
+        }
+        publicIDs[i] = publicID
+    }
+    return publicIDs, nil
+}
+
+func (m *MemoryChunkModel) Get(id int) (*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    c, ok := m.live(id)
+    if !ok {
+        return nil, ErrNoRecord
+    }
+    // Hand out a copy, so callers can't modify the stored chunk.
+    chunk := *c
+    chunk.Tags = append([]string(nil), c.Tags...)
+    chunk.Files = append([]ChunkFile(nil), c.Files...)
+    return &chunk, nil
+}
+
+func (m *MemoryChunkModel) GetByPublicID(publicID string) (*Chunk, error) {
+    m.mu.RLock()
+    id, ok := m.publicIDs[publicID]
+    m.mu.RUnlock()
+
+    if !ok {
+        return nil, ErrNoRecord
+    }
+    return m.Get(id)
+}
+
+func (m *MemoryChunkModel) GetMetaByPublicID(publicID string) (*Chunk, error) {
+    m.mu.RLock()
+    id, ok := m.publicIDs[publicID]
+    m.mu.RUnlock()
+
+    if !ok {
+        return nil, ErrNoRecord
+    }
+    return m.GetMeta(id)
+}
+
+func (m *MemoryChunkModel) GetMeta(id int) (*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    c, ok := m.live(id)
+    if !ok {
+        return nil, ErrNoRecord
+    }
+    chunk := *c
+    chunk.Content = ""
+    chunk.Size = int64(len(c.Content))
+    chunk.SHA256 = ContentSHA256(c.Content)
+    return &chunk, nil
+}
+
+func (m *MemoryChunkModel) StreamContent(ctx context.Context, id int, w io.Writer) error {
+    m.mu.RLock()
+    c, ok := m.live(id)
+    var content string
+    if ok {
+        content = c.Content
+    }
+    m.mu.RUnlock()
+
+    if !ok {
+        return ErrNoRecord
+    }
+    _, err := io.WriteString(w, content)
+    return err
+}
+
+func (m *MemoryChunkModel) Latest(previewChars int) ([]*Chunk, error) {
+    return m.ListAfter(0, 10, previewChars)
+}
+
+func (m *MemoryChunkModel) ListAfter(afterID, limit, previewChars int) ([]*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    // Newest first by created and then id, like ChunkModel.ListAfter.
+    before := func(a, b *Chunk) bool {
+        if !a.Created.Equal(b.Created) {
+            return a.Created.After(b.Created)
+        }
+        return a.ID > b.ID
+    }
+    cursor, paged := m.chunks[afterID]
+    var ids []int
+    for id := range m.chunks {
+        if c, ok := m.live(id); ok && !c.Private && (afterID == 0 || paged && before(cursor, c)) {
+            ids = append(ids, id)
+        }
+    }
+    sort.Slice(ids, func(i, j int) bool { return before(m.chunks[ids[i]], m.chunks[ids[j]]) })
+    if len(ids) > limit {
+        ids = ids[:limit]
+    }
+
+    chunks := []*Chunk{}
+    for _, id := range ids {
+        c := *m.chunks[id]
+        c.Preview, c.Truncated = c.Content, false
+        if utf8.RuneCountInString(c.Content) > previewChars {
+            c.Preview, c.Truncated = string([]rune(c.Content)[:previewChars]), true
+        }
+        c.Content = ""
+        chunks = append(chunks, &c)
+    }
+    return chunks, nil
+}
+
+func (m *MemoryChunkModel) Search(query string, limit int) ([]*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    query = strings.ToLower(query)
+    var ids []int
+    for id := range m.chunks {
+        c, ok := m.live(id)
+        if !ok || c.Private {
+            continue
+        }
+        if strings.Contains(strings.ToLower(c.Title), query) || strings.Contains(strings.ToLower(c.Content), query) {
+            ids = append(ids, id)
+        }
+    }
+    sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+    if len(ids) > limit {
+        ids = ids[:limit]
+    }
+
+    chunks := []*Chunk{}
+    for _, id := range ids {
+        c := *m.chunks[id]
+        chunks = append(chunks, &c)
+    }
+    return chunks, nil
+}
+
+// SearchRanked scores the chunks with memoryScore, as there is no FULLTEXT
+// index to ask.
+func (m *MemoryChunkModel) SearchRanked(query string, minScore float64, limit int) ([]*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    terms := parseBooleanQuery(query)
+    chunks := []*Chunk{}
+    for id := range m.chunks {
+        c, ok := m.live(id)
+        if !ok || c.Private {
+            continue
+        }
+        score := memoryScore(c, terms)
+        if score == 0 || score < minScore {
+            continue
+        }
+        found := *c
+        found.Score = score
+        chunks = append(chunks, &found)
+    }
+    sort.Slice(chunks, func(i, j int) bool {
+        if chunks[i].Score != chunks[j].Score {
+            return chunks[i].Score > chunks[j].Score
+        }
+        return chunks[i].ID > chunks[j].ID
+    })
+    if len(chunks) > limit {
+        chunks = chunks[:limit]
+    }
+    return chunks, nil
+}
+
+func (m *MemoryChunkModel) Related(chunkID int, limit int) ([]*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    src, ok := m.chunks[chunkID]
+    if !ok {
+        return []*Chunk{}, nil
+    }
+    tags := make(map[string]bool, len(src.Tags))
+    for _, tag := range src.Tags {
+        tags[tag] = true
+    }
+
+    type candidate struct {
+        c            *Chunk
+        shared       int
+        sameLanguage bool
+    }
+    var candidates []candidate
+    for id := range m.chunks {
+        c, ok := m.live(id)
+        if !ok || c.Private || id == chunkID {
+            continue
+        }
+        cand := candidate{c: c, sameLanguage: c.Language == src.Language}
+        for _, tag := range c.Tags {
+            if tags[tag] {
+                cand.shared++
+            }
+        }
+        if cand.shared > 0 || cand.sameLanguage {
+            candidates = append(candidates, cand)
+        }
+    }
+    sort.Slice(candidates, func(i, j int) bool {
+        a, b := candidates[i], candidates[j]
+        if a.shared != b.shared {
+            return a.shared > b.shared
+        }
+        if a.sameLanguage != b.sameLanguage {
+            return a.sameLanguage
+        }
+        return a.c.ID > b.c.ID
+    })
+    if len(candidates) > limit {
+        candidates = candidates[:limit]
+    }
+
+    chunks := []*Chunk{}
+    for _, cand := range candidates {
+        c := cand.c
+        chunks = append(chunks, &Chunk{ID: c.ID, PublicID: c.PublicID, Title: c.Title, Language: c.Language, Created: c.Created})
+    }
+    return chunks, nil
+}
+
+func (m *MemoryChunkModel) LatestModified() (time.Time, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var modified time.Time
+    for _, c := range m.chunks {
+        if !c.Private && c.Modified().After(modified) {
+            modified = c.Modified()
+        }
+    }
+    return modified, nil
+}
+
+func (m *MemoryChunkModel) Count() (int, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    count := 0
+    for id := range m.chunks {
+        if _, ok := m.live(id); ok {
+            count++
+        }
+    }
+    return count, nil
+}
+
+func (m *MemoryChunkModel) TotalBytesByUser(userID int) (int64, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var total int64
+    for id := range m.chunks {
+        if c, ok := m.live(id); ok && c.UserID == userID {
+            total += int64(filesSize(c.Content, c.Files))
+        }
+    }
+    return total, nil
+}
+
+func (m *MemoryChunkModel) ExistsTitleForUser(userID int, title string) (*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var found *Chunk
+    for id := range m.chunks {
+        c, ok := m.live(id)
+        if ok && c.UserID == userID && strings.EqualFold(c.Title, title) && (found == nil || c.ID > found.ID) {
+            found = c
+        }
+    }
+    if found == nil {
+        return nil, ErrNoRecord
+    }
+    return &Chunk{ID: found.ID, PublicID: found.PublicID, Title: found.Title, Created: found.Created}, nil
+}
+
+func (m *MemoryChunkModel) ExistsContent(userID int, ip, sha256 string) (*Chunk, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var found *Chunk
+    for id := range m.chunks {
+        c, ok := m.live(id)
+        if !ok || c.UserID != userID || (userID == 0 && c.CreatorIP != ip) || (found != nil && c.ID < found.ID) {
+            continue
+        }
+        if ContentSHA256(c.Content) == sha256 {
+            found = c
+        }
+    }
+    if found == nil {
+        return nil, ErrNoRecord
+    }
+    return &Chunk{ID: found.ID, PublicID: found.PublicID, Title: found.Title, Language: found.Language, Created: found.Created}, nil
+}
+
+func (m *MemoryChunkModel) Update(id int, title, content, language string, normalized bool, tags []string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    c, ok := m.chunks[id]
+    if !ok {
+        return ErrNoRecord
+    }
+    c.Title, c.Content, c.Language, c.Normalized = title, content, language, normalized
+    c.Tags = sortedTags(tags)
+    c.Updated = time.Now().UTC()
+    return nil
+}
+
+func (m *MemoryChunkModel) Delete(id int) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    c, ok := m.chunks[id]
+    if !ok {
+        return ErrNoRecord
+    }
+    m.delete(c)
+    return nil
+}
+
+func (m *MemoryChunkModel) ExtendExpiry(id int, by, maxLifetime time.Duration) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    c, ok := m.live(id)
+    if !ok || !c.KeepAlive {
+        return nil
+    }
+    expires := m.now().Add(by.Truncate(time.Second))
+    if limit := c.Created.Add(maxLifetime.Truncate(time.Second)); expires.After(limit) {
+        expires = limit
+    }
+    if expires.After(c.Expires) {
+        c.Expires = expires
+    }
+    return nil
+}
+
+func (m *MemoryChunkModel) AddViews(counts map[int]int64) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for id, n := range counts {
+        if c, ok := m.chunks[id]; ok {
+            c.Views += n
+        }
+    }
+    return nil
+}
+
+func (m *MemoryChunkModel) DeleteOldest(n int) (int, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    // Expired chunks are of no use to anyone, so drop them while we're here.
+    // They don't count towards n, just like in ChunkModel.
+    var live []*Chunk
+    for id, c := range m.chunks {
+        if _, ok := m.live(id); ok {
+            live = append(live, c)
+        } else {
+            m.delete(c)
+        }
+    }
+
+    sort.Slice(live, func(i, j int) bool {
+        if !live[i].Expires.Equal(live[j].Expires) {
+            return live[i].Expires.Before(live[j].Expires)
+        }
+        if !live[i].Created.Equal(live[j].Created) {
+            return live[i].Created.Before(live[j].Created)
+        }
+        return live[i].ID < live[j].ID
+    })
+    if n > len(live) {
+        n = len(live)
+    }
+    for _, c := range live[:n] {
+        m.delete(c)
+    }
+    return n, nil
+}
+
+func (m *MemoryChunkModel) DeleteMatching(filter ChunkFilter) (int, error) {
+    if filter.Empty() {
+        return 0, errors.New("models: DeleteMatching needs a filter")
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    publicIDs := make(map[string]bool, len(filter.PublicIDs))
+    for _, publicID := range filter.PublicIDs {
+        publicIDs[publicID] = true
+    }
+    ids := make(map[int]bool, len(filter.IDs))
+    for _, id := range filter.IDs {
+        ids[id] = true
+    }
+    deleted := 0
+    for _, c := range m.chunks {
+        if (len(publicIDs) > 0 || len(ids) > 0) && !publicIDs[c.PublicID] && !ids[c.ID] {
+            continue
+        }
+        if !filter.CreatedBefore.IsZero() && !c.Created.Before(filter.CreatedBefore) {
+            continue
+        }
+        if filter.CreatorIP != "" && c.CreatorIP != filter.CreatorIP {
+            continue
+        }
+        m.delete(c)
+        deleted++
+    }
+    return deleted, nil
+}
+
+// sortedTags returns a sorted copy of tags, the order the MySQL store
+// returns them in.
+func sortedTags(tags []string) []string {
+    if len(tags) == 0 {
+        return nil
+    }
+    sorted := append([]string(nil), tags...)
+    sort.Strings(sorted)
+    return sorted
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestMemoryChunkModelListAfterSameSecond(t *testing.T) {
+    m := NewMemoryChunkModel()
+    ids := sameSecondChunks(t, m, 10, func(id int, created time.Time) {
+        m.chunks[id].Created = created
+    })
+    checkPaging(t, m, ids)
+}
+
+func TestMemoryChunkModelExistsContent(t *testing.T) {
+    m := NewMemoryChunkModel()
+    insert := func(content string, userID int, ip string) *Chunk {
+        t.Helper()
+        publicID, err := m.Insert("Title", content, 7, "text", userID, false, false, false, nil, nil, ip)
+        if err != nil {
+            t.Fatal(err)
+        }
+        c, err := m.GetByPublicID(publicID)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return c
+    }
+    // An older chunk with the same content, which the newer one wins over.
+    insert("same", 1, "192.0.2.1")
+    newer := insert("same", 1, "192.0.2.1")
+    anon := insert("same", 0, "192.0.2.1")
+    expired := insert("gone", 1, "192.0.2.1")
+    m.chunks[expired.ID].Expires = m.now().Add(-time.Second)
+
+    tests := []struct {
+        name    string
+        userID  int
+        ip      string
+        content string
+        want    *Chunk
+    }{
+        {"newest of the user", 1, "", "same", newer},
+        {"another user", 2, "192.0.2.1", "same", nil},
+        {"anonymous from the address", 0, "192.0.2.1", "same", anon},
+        {"anonymous from another address", 0, "192.0.2.2", "same", nil},
+        {"other content", 1, "", "other", nil},
+        {"expired", 1, "", "gone", nil},
+    }
+    for _, tt := range tests {
+        got, err := m.ExistsContent(tt.userID, tt.ip, ContentSHA256(tt.content))
+        switch {
+        case tt.want == nil && !errors.Is(err, ErrNoRecord):
+            t.Errorf("%s: got %v, %v; want ErrNoRecord", tt.name, got, err)
+        case tt.want != nil && (err != nil || got.ID != tt.want.ID):
+            t.Errorf("%s: got %v, %v; want chunk %d", tt.name, got, err, tt.want.ID)
+        }
+    }
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+    "crypto/rand"
+    "errors"
+    "strings"
+
+    "github.com/go-sql-driver/mysql"
+)
+
+// Chunks are addressed in URLs by a random public ID rather than their
+// sequential database ID, so nobody can walk through every chunk by counting.
+// It is publicIDLength characters from publicIDAlphabet, about 59 bits.
+const (
+    publicIDLength   = 10
+    publicIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// insertAttempts is how many times we try to insert a chunk, generating a
+// new public ID (or slug suffix) when the one we picked is already taken.
+// At 59 bits a public ID collision is already very unlikely, and so is a
+// slug colliding again with a random suffix, so running out of attempts
+// means something else is wrong.
+const insertAttempts = 5
+
+// newPublicID returns a random public ID.
+func newPublicID() (string, error) {
+    // Bytes of 248 and above are thrown away rather than wrapped around, so
+    // every character is equally likely (248 is the largest multiple of 62
+    // that fits in a byte).
+    const limit = 256 - 256%len(publicIDAlphabet)
+
+    id := make([]byte, 0, publicIDLength)
+    buf := make([]byte, publicIDLength*2)
+    for len(id) < publicIDLength {
+        if _, err := rand.Read(buf); err != nil {
+            return "", err
+        }
+        for _, b := range buf {
+            if int(b) >= limit {
+                continue
+            }
+            id = append(id, publicIDAlphabet[int(b)%len(publicIDAlphabet)])
+            if len(id) == publicIDLength {
+                break
+            }
+        }
+    }
+    return string(id), nil
+}
+
+// isDuplicatePublicID reports whether err is MySQL refusing a public ID that
+// another chunk already has.
+func isDuplicatePublicID(err error) bool {
+    var mySQLError *mysql.MySQLError
+    if errors.As(err, &mySQLError) {
+        return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "idx_chunks_public_id")
+    }
+    return false
+}
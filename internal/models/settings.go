@@ -0,0 +1,61 @@
+package models
+
+import (
+    "database/sql"
+    "errors"
+)
+
+// A SettingModel keeps the settings administrators can change while the
+// server runs, like the banner, as name and value pairs in the "settings"
+// table. A setting which was never saved falls back to its flag.
+//
+//  CREATE TABLE settings (
+//      name VARCHAR(100) NOT NULL PRIMARY KEY,
+//      value TEXT NOT NULL,
+//      updated DATETIME NOT NULL
+//  );
+type SettingModel struct {
+    DB *sql.DB
+}
+
+// Get returns the value of a setting, or ErrNoRecord if it was never saved.
+func (m *SettingModel) Get(name string) (string, error) {
+    var value string
+    err := m.DB.QueryRow("SELECT value FROM settings WHERE name = ?", name).Scan(&value)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return "", ErrNoRecord
+        }
+        return "", err
+    }
+    return value, nil
+}
+
+// Set saves the values of several settings together, in a transaction, so
+// settings which belong together never get out of step.
+func (m *SettingModel) Set(values map[string]string) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    stmt := `INSERT INTO settings (name, value, updated) VALUES (?, ?, UTC_TIMESTAMP())
+    ON DUPLICATE KEY UPDATE value = VALUES(value), updated = VALUES(updated)`
+    for name, value := range values {
+        if _, err = tx.Exec(stmt, name, value); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// Delete forgets the settings, so they fall back to their flags again.
+func (m *SettingModel) Delete(names ...string) error {
+    for _, name := range names {
+        if _, err := m.DB.Exec("DELETE FROM settings WHERE name = ?", name); err != nil {
+            return err
+        }
+    }
+    return nil
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+    "context"
+    "database/sql/driver"
+    "errors"
+    "runtime"
+    "strings"
+    "time"
+)
+
+// A SlowQuery is a statement which took at least the threshold given to
+// SlowQueryConnector. Name is the model method which ran it, such as
+// "ChunkModel.Latest", so the SQL, and the values in it, never need to be
+// logged to know which query it was.
+type SlowQuery struct {
+    Name     string
+    Duration time.Duration
+}
+
+// SlowQueryConnector wraps the connector of a database so every statement
+// run on it is timed, and report is called with the ones which take at
+// least threshold. Rows are timed until the driver returns them, not until
+// they are read. Timing costs two clock readings a statement; the work of
+// naming the query is only done for slow ones.
+func SlowQueryConnector(c driver.Connector, threshold time.Duration, report func(SlowQuery)) driver.Connector {
+    return &slowConnector{Connector: c, timer: &queryTimer{threshold: threshold, report: report}}
+}
+
+type queryTimer struct {
+    threshold time.Duration
+    report    func(SlowQuery)
+}
+
+// done reports the statement if it was slow. Statements the driver
+// skipped (driver.ErrSkip) are run again another way and timed then.
+func (t *queryTimer) done(query string, start time.Time, err error) {
+    d := time.Since(start)
+    if d < t.threshold || err == driver.ErrSkip {
+        return
+    }
+    t.report(SlowQuery{Name: queryName(query), Duration: d})
+}
+
+// queryName names a statement after the method of this package it was run
+// from, or, outside the models, after its first words.
+func queryName(query string) string {
+    pcs := make([]uintptr, 32)
+    frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+    for {
+        frame, more := frames.Next()
+        if strings.Contains(frame.Function, "/internal/models.") && !strings.Contains(frame.Function, "models.(*slow") {
+            name := frame.Function[strings.LastIndex(frame.Function, "/internal/models.")+len("/internal/models."):]
+            return strings.NewReplacer("(*", "", ")", "").Replace(name)
+        }
+        if !more {
+            break
+        }
+    }
+    words := strings.Fields(query)
+    if len(words) > 4 {
+        words = words[:4]
+    }
+    return strings.Join(words, " ")
+}
+
+type slowConnector struct {
+    driver.Connector
+    timer *queryTimer
+}
+
+func (c *slowConnector) Connect(ctx context.Context) (driver.Conn, error) {
+    conn, err := c.Connector.Connect(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return &slowConn{Conn: conn, timer: c.timer}, nil
+}
+
+// A slowConn times the statements run on a connection, directly or
+// through a prepared statement. It passes on the optional interfaces of
+// database/sql/driver, so database/sql uses the driver as it would without
+// it.
+type slowConn struct {
+    driver.Conn
+    timer *queryTimer
+}
+
+func (c *slowConn) Prepare(query string) (driver.Stmt, error) {
+    stmt, err := c.Conn.Prepare(query)
+    if err != nil {
+        return nil, err
+    }
+    return &slowStmt{Stmt: stmt, query: query, timer: c.timer}, nil
+}
+
+func (c *slowConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+    p, ok := c.Conn.(driver.ConnPrepareContext)
+    if !ok {
+        return c.Prepare(query)
+    }
+    stmt, err := p.PrepareContext(ctx, query)
+    if err != nil {
+        return nil, err
+    }
+    return &slowStmt{Stmt: stmt, query: query, timer: c.timer}, nil
+}
+
+func (c *slowConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+    if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+        return b.BeginTx(ctx, opts)
+    }
+    return c.Conn.Begin()
+}
+
+func (c *slowConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+    e, ok := c.Conn.(driver.ExecerContext)
+    if !ok {
+        return nil, driver.ErrSkip
+    }
+    start := time.Now()
+    result, err := e.ExecContext(ctx, query, args)
+    c.timer.done(query, start, err)
+    return result, err
+}
+
+func (c *slowConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+    q, ok := c.Conn.(driver.QueryerContext)
+    if !ok {
+        return nil, driver.ErrSkip
+    }
+    start := time.Now()
+    rows, err := q.QueryContext(ctx, query, args)
+    c.timer.done(query, start, err)
+    return rows, err
+}
+
+func (c *slowConn) Ping(ctx context.Context) error {
+    if p, ok := c.Conn.(driver.Pinger); ok {
+        return p.Ping(ctx)
+    }
+    return nil
+}
+
+func (c *slowConn) ResetSession(ctx context.Context) error {
+    if r, ok := c.Conn.(driver.SessionResetter); ok {
+        return r.ResetSession(ctx)
+    }
+    return nil
+}
+
+func (c *slowConn) IsValid() bool {
+    if v, ok := c.Conn.(driver.Validator); ok {
+        return v.IsValid()
+    }
+    return true
+}
+
+func (c *slowConn) CheckNamedValue(nv *driver.NamedValue) error {
+    if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+        return n.CheckNamedValue(nv)
+    }
+    return driver.ErrSkip
+}
+
+type slowStmt struct {
+    driver.Stmt
+    query string
+    timer *queryTimer
+}
+
+func (s *slowStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+    start := time.Now()
+    var (
+        result driver.Result
+        err    error
+    )
+    if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+        result, err = e.ExecContext(ctx, args)
+    } else {
+        var values []driver.Value
+        if values, err = namedToValues(args); err == nil {
+            result, err = s.Stmt.Exec(values)
+        }
+    }
+    s.timer.done(s.query, start, err)
+    return result, err
+}
+
+func (s *slowStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+    start := time.Now()
+    var (
+        rows driver.Rows
+        err  error
+    )
+    if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+        rows, err = q.QueryContext(ctx, args)
+    } else {
+        var values []driver.Value
+        if values, err = namedToValues(args); err == nil {
+            rows, err = s.Stmt.Query(values)
+        }
+    }
+    s.timer.done(s.query, start, err)
+    return rows, err
+}
+
+func (s *slowStmt) CheckNamedValue(nv *driver.NamedValue) error {
+    if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+        return n.CheckNamedValue(nv)
+    }
+    return driver.ErrSkip
+}
+
+func (s *slowStmt) ColumnConverter(idx int) driver.ValueConverter {
+    if c, ok := s.Stmt.(driver.ColumnConverter); ok {
+        return c.ColumnConverter(idx)
+    }
+    return driver.DefaultParameterConverter
+}
+
+// namedToValues is what database/sql does for drivers which only take
+// positional arguments.
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+    values := make([]driver.Value, len(args))
+    for i, arg := range args {
+        if arg.Name != "" {
+            return nil, errors.New("models: the driver doesn't take named arguments")
+        }
+        values[i] = arg.Value
+    }
+    return values, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+    "crypto/rand"
+    "errors"
+    "strings"
+
+    "github.com/go-sql-driver/mysql"
+)
+
+// Every chunk gets a slug made from its title, which names the file when the
+// chunk is downloaded. Slugs are unique: when two chunks have the same title
+// the later one gets a random suffix.
+const (
+    // maxSlugLength is the longest slug made from a title, leaving room for
+    // the suffix in the VARCHAR(80) column.
+    maxSlugLength = 60
+    // slugSuffixLength is the length of the random suffix added on a
+    // collision.
+    slugSuffixLength = 5
+    slugSuffixAlphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+    // defaultSlug is used for titles without a single ASCII letter or digit.
+    defaultSlug = "chunk"
+)
+
+// slugify turns a title into a slug: lowercase ASCII letters and digits,
+// with every run of anything else (spaces, punctuation, non-ASCII letters)
+// becoming a single hyphen, and no hyphens at either end.
+func slugify(title string) string {
+    var b strings.Builder
+    hyphen := false
+    for _, r := range strings.ToLower(title) {
+        if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+            if hyphen && b.Len() > 0 {
+                b.WriteByte('-')
+            }
+            hyphen = false
+            b.WriteRune(r)
+            continue
+        }
+        hyphen = true
+    }
+
+    slug := b.String()
+    if len(slug) > maxSlugLength {
+        slug = slug[:maxSlugLength]
+        // Cut back to the last whole word, if there is one.
+        if i := strings.LastIndexByte(slug, '-'); i > 0 {
+            slug = slug[:i]
+        }
+        slug = strings.TrimRight(slug, "-")
+    }
+    if slug == "" {
+        return defaultSlug
+    }
+    return slug
+}
+
+// chunkSlug returns the slug to try for a title on the given attempt: the
+// plain slug first, then with a random suffix each time it collides.
+func chunkSlug(title string, attempt int) (string, error) {
+    slug := slugify(title)
+    if attempt == 1 {
+        return slug, nil
+    }
+
+    buf := make([]byte, slugSuffixLength)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    for i, c := range buf {
+        buf[i] = slugSuffixAlphabet[int(c)%len(slugSuffixAlphabet)]
+    }
+    return slug + "-" + string(buf), nil
+}
+
+// isDuplicateSlug reports whether err is MySQL refusing a slug that another
+// chunk already has.
+func isDuplicateSlug(err error) bool {
+    var mySQLError *mysql.MySQLError
+    if errors.As(err, &mySQLError) {
+        return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "idx_chunks_slug")
+    }
+    return false
+}
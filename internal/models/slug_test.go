@@ -0,0 +1,118 @@
+package models
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+
+    "github.com/go-sql-driver/mysql"
+)
+
+func TestSlugify(t *testing.T) {
+    tests := []struct {
+        title string
+        want  string
+    }{
+        {"Hello, World!", "hello-world"},
+        {"  --Leading and trailing--  ", "leading-and-trailing"},
+        {"Go 1.20 release notes", "go-1-20-release-notes"},
+        {"Café déjà vu", "caf-d-j-vu"},
+        {"Straße", "stra-e"},
+        // Titles with no ASCII letter or digit get the default.
+        {"日本語のタイトル", defaultSlug},
+        {"Привет", defaultSlug},
+        {"🙂🙂", defaultSlug},
+        {"", defaultSlug},
+        {"Emoji 🙂 between", "emoji-between"},
+    }
+    for _, tt := range tests {
+        if got := slugify(tt.title); got != tt.want {
+            t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+        }
+    }
+}
+
+func TestSlugifyLongTitle(t *testing.T) {
+    title := strings.Repeat("word ", 20)
+    got := slugify(title)
+    if len(got) > maxSlugLength {
+        t.Errorf("slug of %d bytes, want at most %d", len(got), maxSlugLength)
+    }
+    // It is cut back to a whole word.
+    if strings.HasSuffix(got, "-") || !strings.HasSuffix(got, "word") {
+        t.Errorf("slug %q isn't cut at a word", got)
+    }
+
+    // One long word is cut where it has to be.
+    if got := slugify(strings.Repeat("x", 100)); got != strings.Repeat("x", maxSlugLength) {
+        t.Errorf("slug of one long word %q", got)
+    }
+}
+
+func TestChunkSlug(t *testing.T) {
+    slug, err := chunkSlug("My Title", 1)
+    if err != nil || slug != "my-title" {
+        t.Fatalf("first attempt %q, %v", slug, err)
+    }
+
+    seen := map[string]bool{}
+    for attempt := 2; attempt <= 10; attempt++ {
+        slug, err := chunkSlug("My Title", attempt)
+        if err != nil {
+            t.Fatal(err)
+        }
+        suffix, ok := strings.CutPrefix(slug, "my-title-")
+        if !ok || len(suffix) != slugSuffixLength || strings.Trim(suffix, slugSuffixAlphabet) != "" {
+            t.Errorf("attempt %d: slug %q", attempt, slug)
+        }
+        seen[slug] = true
+    }
+    if len(seen) < 2 {
+        t.Error("the suffixes are all the same")
+    }
+}
+
+func TestIsDuplicateSlug(t *testing.T) {
+    slugErr := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'my-title' for key 'chunks.idx_chunks_slug'"}
+    tests := []struct {
+        err  error
+        want bool
+    }{
+        {slugErr, true},
+        {fmt.Errorf("inserting: %w", slugErr), true},
+        {&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'abc' for key 'chunks.idx_chunks_public_id'"}, false},
+        {&mysql.MySQLError{Number: 1406, Message: "Data too long for column 'slug'"}, false},
+        {errors.New("idx_chunks_slug"), false},
+    }
+    for _, tt := range tests {
+        if got := isDuplicateSlug(tt.err); got != tt.want {
+            t.Errorf("isDuplicateSlug(%v) = %t, want %t", tt.err, got, tt.want)
+        }
+    }
+}
+
+func TestMemoryChunkModelSlugCollisions(t *testing.T) {
+    m := NewMemoryChunkModel()
+    slugs := map[string]bool{}
+    for i := 0; i < 20; i++ {
+        id, err := m.Insert("Same title", "content", 7, "text", 0, false, false, false, nil, nil, "")
+        if err != nil {
+            t.Fatal(err)
+        }
+        chunk, err := m.GetByPublicID(id)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if slugs[chunk.Slug] {
+            t.Fatalf("slug %q given out twice", chunk.Slug)
+        }
+        if i == 0 && chunk.Slug != "same-title" {
+            t.Errorf("first slug %q, want same-title", chunk.Slug)
+        }
+        if !strings.HasPrefix(chunk.Slug, "same-title") {
+            t.Errorf("slug %q", chunk.Slug)
+        }
+        slugs[chunk.Slug] = true
+    }
+}
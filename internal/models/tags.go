@@ -0,0 +1,55 @@
+package models
+
+import (
+    "database/sql"
+    "strings"
+)
+
+// Tags are kept in a table of their own, one row per tag of a chunk, and go
+// when the chunk does:
+//
+//  CREATE TABLE chunk_tags (
+//      chunk_id INTEGER NOT NULL,
+//      tag VARCHAR(64) NOT NULL,
+//      PRIMARY KEY (chunk_id, tag),
+//      FOREIGN KEY (chunk_id) REFERENCES chunks(id) ON DELETE CASCADE
+//  );
+//  CREATE INDEX idx_chunk_tags_tag ON chunk_tags(tag);
+//
+// The models store tags as they are given. Normalizing them (and removing
+// duplicates, which the primary key would refuse) is up to the caller.
+
+// addTags adds tags to a chunk, as part of the transaction which creates or
+// edits it.
+func addTags(tx *sql.Tx, chunkID int, tags []string) error {
+    if len(tags) == 0 {
+        return nil
+    }
+
+    args := make([]any, 0, len(tags)*2)
+    for _, tag := range tags {
+        args = append(args, chunkID, tag)
+    }
+    stmt := `INSERT INTO chunk_tags (chunk_id, tag) VALUES (?, ?)` + strings.Repeat(", (?, ?)", len(tags)-1)
+    _, err := tx.Exec(stmt, args...)
+    return err
+}
+
+// tags returns the tags of a chunk in alphabetical order.
+func (m *ChunkModel) tags(chunkID int) ([]string, error) {
+    rows, err := m.DB.Query(`SELECT tag FROM chunk_tags WHERE chunk_id = ? ORDER BY tag`, chunkID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var tags []string
+    for rows.Next() {
+        var tag string
+        if err = rows.Scan(&tag); err != nil {
+            return nil, err
+        }
+        tags = append(tags, tag)
+    }
+    return tags, rows.Err()
+}
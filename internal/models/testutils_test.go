@@ -0,0 +1,31 @@
+package models
+
+import (
+    "database/sql"
+    "os"
+    "testing"
+
+    _ "github.com/go-sql-driver/mysql"
+)
+
+// newTestDB connects to the MySQL database in CHUNKBOX_TEST_DSN, which must
+// have the chunkbox tables, and skips the test without one. Whatever the
+// test adds to it, it has to delete.
+func newTestDB(tb testing.TB) *sql.DB {
+    tb.Helper()
+
+    dsn := os.Getenv("CHUNKBOX_TEST_DSN")
+    if dsn == "" {
+        tb.Skip("CHUNKBOX_TEST_DSN is not set")
+    }
+    db, err := sql.Open("mysql", dsn)
+    if err != nil {
+        tb.Fatal(err)
+    }
+    if err = db.Ping(); err != nil {
+        db.Close()
+        tb.Fatal(err)
+    }
+    tb.Cleanup(func() { db.Close() })
+    return db
+}
@@ -0,0 +1,449 @@
+package models
+
+import (
+    "database/sql"
+    "errors"
+    "strings"
+    "time"
+
+    "github.com/go-sql-driver/mysql"
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Define a new User type. Notice how the field names and types align
+// with the columns in the database "users" table:
+//
+//  CREATE TABLE users (
+//      id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+//      name VARCHAR(255) NOT NULL,
+//      email VARCHAR(255) NOT NULL,
+//      hashed_password CHAR(60) NOT NULL,
+//      created DATETIME NOT NULL
+//  );
+//  ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+//
+// Users who signed up through an OAuth provider have an empty
+// hashed_password until they choose a password. Their provider accounts are
+// kept in a separate table, keyed by provider and the provider's subject:
+//
+//  CREATE TABLE user_identities (
+//      provider VARCHAR(32) NOT NULL,
+//      subject VARCHAR(255) NOT NULL,
+//      user_id INTEGER NOT NULL,
+//      created DATETIME NOT NULL,
+//      PRIMARY KEY (provider, subject),
+//      FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+//  );
+//
+// Administrators are flagged in the database, there is no UI for it:
+//
+//  ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+//
+// A new email address is kept aside until the user follows the link sent
+// to it, and only then replaces the old one (see ConfirmEmail):
+//
+//  ALTER TABLE users ADD COLUMN pending_email VARCHAR(255) NULL;
+//
+// An email address is verified once the user has followed a link sent to
+// it, or if the OAuth provider they signed up with vouched for it. Only a
+// verified address lets a provider account with the same address be linked
+// without logging in first:
+//
+//  ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+type User struct {
+    ID             int
+    Name           string
+    Email          string
+    HashedPassword []byte
+    Created        time.Time
+    // HasPassword is false for users who have only ever logged in with an
+    // OAuth provider.
+    HasPassword    bool
+    IsAdmin        bool
+    // PendingEmail is the new email address the user asked for, waiting
+    // for them to confirm it, or empty.
+    PendingEmail   string
+    // EmailVerified is true if the user has shown the email address is
+    // theirs.
+    EmailVerified  bool
+}
+
+// Define a new UserModel type which wraps a database connection pool.
+// BcryptCost is the cost factor used when hashing passwords; if it is left
+// at zero we fall back to defaultBcryptCost.
+type UserModel struct {
+    DB         *sql.DB
+    BcryptCost int
+}
+
+// UserStore is the set of user account operations the web application
+// uses. UserModel, in MySQL, is the only real implementation: without a
+// database there are no accounts.
+type UserStore interface {
+    Insert(name, email, password string) error
+    Authenticate(email, password string) (int, error)
+    Exists(id int) (bool, error)
+    Get(id int) (*User, error)
+    CheckPassword(id int, password string) error
+    UpdateName(id int, name string) error
+    SetPendingEmail(id int, email string) error
+    ConfirmEmail(id int, email string) error
+    PasswordUpdate(id int, currentPassword, newPassword string) error
+    SetPassword(id int, newPassword string) error
+    IdentityUserID(provider, subject string) (int, error)
+    IDByEmail(email string) (int, error)
+    LinkIdentity(userID int, provider, subject string) error
+    InsertWithIdentity(name, email string, emailVerified bool, provider, subject string) (int, error)
+    Delete(id int, deleteChunks bool) error
+}
+
+// The defaultBcryptCost is used when no cost factor has been configured.
+const defaultBcryptCost = 12
+
+// hashPassword creates a bcrypt hash of the plain-text password using the
+// configured cost factor.
+func (m *UserModel) hashPassword(password string) ([]byte, error) {
+    cost := m.BcryptCost
+    if cost == 0 {
+        cost = defaultBcryptCost
+    }
+    return bcrypt.GenerateFromPassword([]byte(password), cost)
+}
+
+// We'll use the Insert method to add a new record to the "users" table.
+func (m *UserModel) Insert(name, email, password string) error {
+    // Create a bcrypt hash of the plain-text password.
+    hashedPassword, err := m.hashPassword(password)
+    if err != nil {
+        return err
+    }
+
+    stmt := `INSERT INTO users (name, email, hashed_password, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+    // Use the Exec() method to insert the user details and hashed password
+    // into the users table.
+    _, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+    if err != nil {
+        // If this returns an error because the email address violates our
+        // users_uc_email unique constraint, we return an ErrDuplicateEmail
+        // error instead of the raw MySQL error.
+        if isDuplicateEmail(err) {
+            return ErrDuplicateEmail
+        }
+        return err
+    }
+
+    return nil
+}
+
+// We'll use the Authenticate method to verify whether a user exists with
+// the provided email address and password. This will return the relevant
+// user ID if they do.
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+    // Retrieve the id and hashed password associated with the given email. If
+    // no matching email exists we return the ErrInvalidCredentials error.
+    var id int
+    var hashedPassword []byte
+
+    stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+
+    err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return 0, ErrInvalidCredentials
+        } else {
+            return 0, err
+        }
+    }
+
+    // Check whether the hashed password and plain-text password provided match.
+    // If they don't, we return the ErrInvalidCredentials error.
+    err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+    if err != nil {
+        if isWrongPassword(err) {
+            return 0, ErrInvalidCredentials
+        } else {
+            return 0, err
+        }
+    }
+
+    // Otherwise, the password is correct. Return the user ID.
+    return id, nil
+}
+
+// We'll use the Exists method to check if a user exists with a specific ID.
+func (m *UserModel) Exists(id int) (bool, error) {
+    var exists bool
+
+    stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+
+    err := m.DB.QueryRow(stmt, id).Scan(&exists)
+    return exists, err
+}
+
+// The Get method returns the user details (without the password hash) for
+// a specific user ID.
+func (m *UserModel) Get(id int) (*User, error) {
+    var user User
+
+    stmt := `SELECT id, name, email, created, hashed_password <> '', is_admin, COALESCE(pending_email, ''), email_verified FROM users WHERE id = ?`
+
+    err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Email, &user.Created, &user.HasPassword, &user.IsAdmin, &user.PendingEmail, &user.EmailVerified)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return nil, ErrNoRecord
+        } else {
+            return nil, err
+        }
+    }
+
+    return &user, nil
+}
+
+// The CheckPassword method confirms that the plain-text password matches the
+// one stored for the given user ID. We use it to re-verify the user before
+// sensitive account changes (new email address, account deletion).
+func (m *UserModel) CheckPassword(id int, password string) error {
+    var hashedPassword []byte
+
+    stmt := "SELECT hashed_password FROM users WHERE id = ?"
+
+    err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return ErrNoRecord
+        }
+        return err
+    }
+
+    err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+    if err != nil {
+        if isWrongPassword(err) {
+            return ErrInvalidCredentials
+        }
+        return err
+    }
+
+    return nil
+}
+
+// The UpdateName method changes the display name of a user.
+func (m *UserModel) UpdateName(id int, name string) error {
+    stmt := "UPDATE users SET name = ? WHERE id = ?"
+
+    _, err := m.DB.Exec(stmt, name, id)
+    return err
+}
+
+// The SetPendingEmail method keeps a new email address for a user until they
+// confirm it, in place of any other they asked for before.
+func (m *UserModel) SetPendingEmail(id int, email string) error {
+    stmt := "UPDATE users SET pending_email = ? WHERE id = ?"
+
+    _, err := m.DB.Exec(stmt, email, id)
+    return err
+}
+
+// The ConfirmEmail method makes the pending email address of a user their
+// verified email address, if it is still the one given. It returns ErrNoRecord if
+// the user has since asked for another address (or none), and like Insert
+// ErrDuplicateEmail if another account has taken the address meanwhile.
+func (m *UserModel) ConfirmEmail(id int, email string) error {
+    stmt := "UPDATE users SET email = pending_email, pending_email = NULL, email_verified = TRUE WHERE id = ? AND pending_email = ?"
+
+    result, err := m.DB.Exec(stmt, id, email)
+    if err != nil {
+        if isDuplicateEmail(err) {
+            return ErrDuplicateEmail
+        }
+        return err
+    }
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNoRecord
+    }
+
+    return nil
+}
+
+// The PasswordUpdate method checks the current password before replacing it
+// with a bcrypt hash of the new one. If the current password is wrong it
+// returns ErrInvalidCredentials.
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+    err := m.CheckPassword(id, currentPassword)
+    if err != nil {
+        return err
+    }
+
+    return m.SetPassword(id, newPassword)
+}
+
+// The SetPassword method replaces a user's password without checking the
+// current one. It is used directly by users who signed up through an OAuth
+// provider and don't have a password yet.
+func (m *UserModel) SetPassword(id int, newPassword string) error {
+    newHashedPassword, err := m.hashPassword(newPassword)
+    if err != nil {
+        return err
+    }
+
+    stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
+
+    _, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+    return err
+}
+
+// The IdentityUserID method returns the ID of the user linked to an OAuth
+// provider account, or ErrNoRecord if the account isn't linked yet.
+func (m *UserModel) IdentityUserID(provider, subject string) (int, error) {
+    var id int
+
+    stmt := "SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?"
+
+    err := m.DB.QueryRow(stmt, provider, subject).Scan(&id)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return 0, ErrNoRecord
+        }
+        return 0, err
+    }
+    return id, nil
+}
+
+// The IDByEmail method returns the ID of the user with the given email
+// address, or ErrNoRecord.
+func (m *UserModel) IDByEmail(email string) (int, error) {
+    var id int
+
+    err := m.DB.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&id)
+    if err != nil {
+        if errors.Is(err, sql.ErrNoRows) {
+            return 0, ErrNoRecord
+        }
+        return 0, err
+    }
+    return id, nil
+}
+
+// The LinkIdentity method links an OAuth provider account to an existing
+// user, so they can log in with it from now on.
+func (m *UserModel) LinkIdentity(userID int, provider, subject string) error {
+    stmt := `INSERT INTO user_identities (provider, subject, user_id, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+    _, err := m.DB.Exec(stmt, provider, subject, userID)
+    return err
+}
+
+// The InsertWithIdentity method creates a new user without a password and
+// links it to an OAuth provider account, in a single transaction. The email
+// address is verified if the provider vouched for it. Like Insert it returns
+// ErrDuplicateEmail if the email address is taken.
+func (m *UserModel) InsertWithIdentity(name, email string, emailVerified bool, provider, subject string) (int, error) {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    stmt := `INSERT INTO users (name, email, hashed_password, email_verified, created)
+    VALUES(?, ?, '', ?, UTC_TIMESTAMP())`
+
+    result, err := tx.Exec(stmt, name, email, emailVerified)
+    if err != nil {
+        if isDuplicateEmail(err) {
+            return 0, ErrDuplicateEmail
+        }
+        return 0, err
+    }
+    id, err := result.LastInsertId()
+    if err != nil {
+        return 0, err
+    }
+
+    stmt = `INSERT INTO user_identities (provider, subject, user_id, created)
+    VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+    _, err = tx.Exec(stmt, provider, subject, id)
+    if err != nil {
+        return 0, err
+    }
+
+    err = tx.Commit()
+    if err != nil {
+        return 0, err
+    }
+    return int(id), nil
+}
+
+// The Delete method removes a user record. If deleteChunks is true then all
+// chunks owned by the user are deleted along with it, otherwise they are kept
+// and reassigned to anonymous (a NULL user_id). The comments on deleted
+// chunks are marked as deleted, and the user's own comments are kept without
+// an author. Everything runs inside a single transaction so we never end up
+// with orphaned ownership.
+func (m *UserModel) Delete(id int, deleteChunks bool) error {
+    tx, err := m.DB.Begin()
+    if err != nil {
+        return err
+    }
+    // Calling Rollback() after a successful Commit() is a no-op, so it is
+    // safe to always defer it.
+    defer tx.Rollback()
+
+    if deleteChunks {
+        _, err = tx.Exec(`UPDATE comments SET deleted = UTC_TIMESTAMP()
+        WHERE deleted IS NULL AND chunk_id IN (SELECT id FROM chunks WHERE user_id = ?)`, id)
+        if err != nil {
+            return err
+        }
+        _, err = tx.Exec("DELETE FROM chunks WHERE user_id = ?", id)
+    } else {
+        _, err = tx.Exec("UPDATE chunks SET user_id = NULL WHERE user_id = ?", id)
+    }
+    if err != nil {
+        return err
+    }
+
+    _, err = tx.Exec("UPDATE comments SET user_id = NULL WHERE user_id = ?", id)
+    if err != nil {
+        return err
+    }
+
+    result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+    if err != nil {
+        return err
+    }
+    rows, err := result.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if rows == 0 {
+        return ErrNoRecord
+    }
+
+    return tx.Commit()
+}
+
+// isWrongPassword reports whether a bcrypt comparison error means the
+// password was wrong. A too-short hash is the empty hashed_password of an
+// OAuth-only user, for whom every password is wrong.
+func isWrongPassword(err error) bool {
+    return errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrHashTooShort)
+}
+
+// isDuplicateEmail uses the errors.As() function to check whether the error
+// has the type *mysql.MySQLError. If it does, we check whether or not the
+// error relates to our users_uc_email key by checking if the error code equals
+// 1062 and the contents of the error message string.
+func isDuplicateEmail(err error) bool {
+    var mySQLError *mysql.MySQLError
+    if errors.As(err, &mySQLError) {
+        return mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email")
+    }
+    return false
+}
@@ -0,0 +1,178 @@
+package oauth
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "golang.org/x/oauth2"
+    "golang.org/x/oauth2/endpoints"
+)
+
+// profileTimeout bounds the calls made to the provider's API after the code
+// exchange.
+const profileTimeout = 10 * time.Second
+
+// A Profile is the information about a user we get back from a provider.
+// Subject is the provider's stable, unique identifier for the account.
+// EmailVerified is only true if the provider vouches for the address, and
+// only then do we use it to link to an existing account.
+type Profile struct {
+    Subject       string
+    Name          string
+    Email         string
+    EmailVerified bool
+}
+
+// A Provider wraps the oauth2 configuration for one identity provider along
+// with the code to fetch the user's profile once we have a token.
+type Provider struct {
+    Name   string
+    config oauth2.Config
+    fetch  func(ctx context.Context, client *http.Client) (*Profile, error)
+}
+
+// GitHub returns a Provider for signing in with GitHub. The user:email scope
+// is needed to read the (verified) email addresses of the account.
+func GitHub(clientID, clientSecret string) *Provider {
+    return &Provider{
+        Name: "github",
+        config: oauth2.Config{
+            ClientID:     clientID,
+            ClientSecret: clientSecret,
+            Endpoint:     endpoints.GitHub,
+            Scopes:       []string{"read:user", "user:email"},
+        },
+        fetch: fetchGitHubProfile,
+    }
+}
+
+// Google returns a Provider for signing in with Google.
+func Google(clientID, clientSecret string) *Provider {
+    return &Provider{
+        Name: "google",
+        config: oauth2.Config{
+            ClientID:     clientID,
+            ClientSecret: clientSecret,
+            Endpoint:     endpoints.Google,
+            Scopes:       []string{"openid", "email", "profile"},
+        },
+        fetch: fetchGoogleProfile,
+    }
+}
+
+// AuthCodeURL returns the URL of the provider's consent page. The state is
+// echoed back to the callback, where it must be checked against the value
+// stored in the user's session.
+func (p *Provider) AuthCodeURL(state, redirectURL string) string {
+    cfg := p.config
+    cfg.RedirectURL = redirectURL
+    return cfg.AuthCodeURL(state)
+}
+
+// Exchange trades the authorization code from the callback for a token and
+// uses it to fetch the user's profile.
+func (p *Provider) Exchange(ctx context.Context, code, redirectURL string) (*Profile, error) {
+    cfg := p.config
+    cfg.RedirectURL = redirectURL
+
+    ctx, cancel := context.WithTimeout(ctx, profileTimeout)
+    defer cancel()
+
+    token, err := cfg.Exchange(ctx, code)
+    if err != nil {
+        return nil, err
+    }
+
+    profile, err := p.fetch(ctx, cfg.Client(ctx, token))
+    if err != nil {
+        return nil, err
+    }
+    if profile.Subject == "" {
+        return nil, errors.New("oauth: provider returned no subject")
+    }
+    return profile, nil
+}
+
+// getJSON fetches url with the authorized client and decodes the JSON
+// response into dst.
+func getJSON(ctx context.Context, client *http.Client, url string, dst any) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Accept", "application/json")
+
+    resp, err := client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("oauth: GET %s returned %s", url, resp.Status)
+    }
+    return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func fetchGitHubProfile(ctx context.Context, client *http.Client) (*Profile, error) {
+    var user struct {
+        ID    int64  `json:"id"`
+        Login string `json:"login"`
+        Name  string `json:"name"`
+    }
+    err := getJSON(ctx, client, "https://api.github.com/user", &user)
+    if err != nil {
+        return nil, err
+    }
+
+    profile := &Profile{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+    if profile.Name == "" {
+        profile.Name = user.Login
+    }
+
+    // The email on the /user response is the public one and may be missing
+    // or unverified, so use the primary address from /user/emails instead.
+    var emails []struct {
+        Email    string `json:"email"`
+        Primary  bool   `json:"primary"`
+        Verified bool   `json:"verified"`
+    }
+    err = getJSON(ctx, client, "https://api.github.com/user/emails", &emails)
+    if err != nil {
+        return nil, err
+    }
+    for _, e := range emails {
+        if e.Primary {
+            profile.Email = e.Email
+            profile.EmailVerified = e.Verified
+            break
+        }
+    }
+
+    return profile, nil
+}
+
+func fetchGoogleProfile(ctx context.Context, client *http.Client) (*Profile, error) {
+    var info struct {
+        Sub           string `json:"sub"`
+        Name          string `json:"name"`
+        Email         string `json:"email"`
+        EmailVerified bool   `json:"email_verified"`
+    }
+    err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", &info)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Profile{
+        Subject:       info.Sub,
+        Name:          info.Name,
+        Email:         info.Email,
+        EmailVerified: info.EmailVerified,
+    }, nil
+}
@@ -0,0 +1,183 @@
+package ogimage
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/draw"
+    "image/png"
+    "strings"
+    "sync"
+    "unicode"
+
+    "golang.org/x/image/font"
+    "golang.org/x/image/font/gofont/gobold"
+    "golang.org/x/image/font/gofont/gomono"
+    "golang.org/x/image/font/gofont/goregular"
+    "golang.org/x/image/font/opentype"
+    "golang.org/x/image/math/fixed"
+)
+
+// The size of a card: the 1.91:1 ratio Open Graph and Twitter previews use.
+const (
+    Width  = 1200
+    Height = 630
+)
+
+const (
+    margin = 60
+    // maxTitleLines and maxContentLines are how much of the title and the
+    // content fit on a card.
+    maxTitleLines   = 2
+    maxContentLines = 10
+    tabWidth        = 4
+    // maxLineRunes is more than fits on a line of the card, so longer lines
+    // are cut before they are measured.
+    maxLineRunes = 200
+)
+
+var (
+    background = color.RGBA{0xF7, 0xF9, 0xFA, 0xFF}
+    accent     = color.RGBA{0x34, 0x49, 0x5E, 0xFF}
+    text       = color.RGBA{0x23, 0x23, 0x23, 0xFF}
+    muted      = color.RGBA{0x6A, 0x6C, 0x6F, 0xFF}
+    codeBox    = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+// The faces are made once. The Go fonts are embedded in x/image, so they
+// always parse. A face isn't safe for concurrent use, so mu is held while
+// a card is drawn.
+var (
+    mu        sync.Mutex
+    titleFace = mustFace(gobold.TTF, 52)
+    codeFace  = mustFace(gomono.TTF, 24)
+    smallFace = mustFace(goregular.TTF, 26)
+)
+
+func mustFace(ttf []byte, size float64) font.Face {
+    f, err := opentype.Parse(ttf)
+    if err != nil {
+        panic(err)
+    }
+    face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+    if err != nil {
+        panic(err)
+    }
+    return face
+}
+
+// A Card is what a preview image shows: the title of a chunk, the first
+// lines of its content, and a footer such as the site name and language.
+// Content may be empty, for a card with only the title.
+type Card struct {
+    Title   string
+    Content string
+    Footer  string
+}
+
+// Render draws the card as a PNG.
+func Render(c Card) ([]byte, error) {
+    mu.Lock()
+    defer mu.Unlock()
+
+    img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+    draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+    draw.Draw(img, image.Rect(0, 0, Width, 12), image.NewUniform(accent), image.Point{}, draw.Src)
+
+    y := margin + 52
+    for _, line := range wrap(titleFace, clean(c.Title), Width-2*margin, maxTitleLines) {
+        drawText(img, titleFace, text, margin, y, line)
+        y += 64
+    }
+
+    footerY := Height - margin
+    if lines := contentLines(c.Content); len(lines) > 0 {
+        top, bottom := y, footerY-50
+        draw.Draw(img, image.Rect(margin, top, Width-margin, bottom), image.NewUniform(codeBox), image.Point{}, draw.Src)
+        lineY := top + 40
+        for _, line := range lines {
+            if lineY > bottom-12 {
+                break
+            }
+            drawText(img, codeFace, text, margin+24, lineY, fit(codeFace, line, Width-2*margin-48))
+            lineY += 32
+        }
+    }
+    drawText(img, smallFace, muted, margin, footerY, fit(smallFace, clean(c.Footer), Width-2*margin))
+
+    var buf bytes.Buffer
+    if err := png.Encode(&buf, img); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func drawText(img draw.Image, face font.Face, c color.Color, x, y int, s string) {
+    d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+    d.DrawString(s)
+}
+
+// contentLines returns the first lines of the content, with tabs expanded
+// and control characters dropped.
+func contentLines(content string) []string {
+    content = strings.TrimRight(content, "\n\r\t ")
+    if content == "" {
+        return nil
+    }
+    lines := strings.SplitN(content, "\n", maxContentLines+1)
+    if len(lines) > maxContentLines {
+        lines = lines[:maxContentLines]
+    }
+    for i, line := range lines {
+        if runes := []rune(line); len(runes) > maxLineRunes {
+            line = string(runes[:maxLineRunes])
+        }
+        lines[i] = clean(strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", strings.Repeat(" ", tabWidth)))
+    }
+    return lines
+}
+
+// clean drops the characters which don't draw as anything sensible.
+func clean(s string) string {
+    return strings.Map(func(r rune) rune {
+        if unicode.IsControl(r) {
+            return -1
+        }
+        return r
+    }, s)
+}
+
+// wrap breaks s into lines at most width wide, at most max of them; the
+// last one is cut short with "…" if there is more.
+func wrap(face font.Face, s string, width, max int) []string {
+    var lines []string
+    line := ""
+    words := strings.Fields(s)
+    for i, word := range words {
+        candidate := strings.TrimSpace(line + " " + word)
+        if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+            lines = append(lines, fit(face, line, width))
+            if len(lines) == max-1 {
+                return append(lines, fit(face, strings.Join(words[i:], " "), width))
+            }
+            candidate = word
+        }
+        line = candidate
+    }
+    if line != "" {
+        lines = append(lines, fit(face, line, width))
+    }
+    return lines
+}
+
+// fit cuts s to at most width, marking the cut with "…".
+func fit(face font.Face, s string, width int) string {
+    if font.MeasureString(face, s).Ceil() <= width {
+        return s
+    }
+    runes := []rune(s)
+    for len(runes) > 0 && font.MeasureString(face, string(runes)+"…").Ceil() > width {
+        runes = runes[:len(runes)-1]
+    }
+    return string(runes) + "…"
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+    "context"
+    "math"
+    "sync"
+    "time"
+)
+
+// A Limiter decides whether the client identified by key may make another
+// request. Both limiters implement a token bucket: every key has a bucket
+// holding up to Burst tokens which refills at Rate tokens per second, and
+// each request takes one token.
+type Limiter interface {
+    Allow(ctx context.Context, key string) (bool, error)
+}
+
+// Memory is a Limiter which keeps the buckets in memory. It is only correct
+// for a single instance of the application: behind a load balancer each
+// instance would have its own buckets. Use Redis for that.
+type Memory struct {
+    rate  float64
+    burst float64
+
+    mu      sync.Mutex
+    buckets map[string]*bucket
+    swept   time.Time
+}
+
+type bucket struct {
+    tokens float64
+    last   time.Time
+}
+
+// NewMemory returns an in-memory Limiter allowing rate requests per second
+// with bursts of up to burst requests.
+func NewMemory(rate float64, burst int) *Memory {
+    return &Memory{
+        rate:    rate,
+        burst:   float64(burst),
+        buckets: map[string]*bucket{},
+        swept:   time.Now(),
+    }
+}
+
+// Allow takes a token from the bucket for key. It never returns an error.
+func (m *Memory) Allow(ctx context.Context, key string) (bool, error) {
+    now := time.Now()
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    m.sweep(now)
+
+    b, ok := m.buckets[key]
+    if !ok {
+        b = &bucket{tokens: m.burst, last: now}
+        m.buckets[key] = b
+    }
+
+    b.tokens = math.Min(m.burst, b.tokens+now.Sub(b.last).Seconds()*m.rate)
+    b.last = now
+    if b.tokens < 1 {
+        return false, nil
+    }
+    b.tokens--
+    return true, nil
+}
+
+// sweep drops the buckets which have refilled completely, as they are the
+// same as a new bucket. It runs at most once a minute so the map doesn't grow
+// with every client ever seen. The caller must hold the lock.
+func (m *Memory) sweep(now time.Time) {
+    if now.Sub(m.swept) < time.Minute {
+        return
+    }
+    m.swept = now
+
+    full := time.Duration(m.burst / m.rate * float64(time.Second))
+    for key, b := range m.buckets {
+        if now.Sub(b.last) > full {
+            delete(m.buckets, key)
+        }
+    }
+}
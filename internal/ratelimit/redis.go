@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+    "context"
+
+    "github.com/gomodule/redigo/redis"
+)
+
+// The token bucket, in Lua so that reading and updating a bucket is atomic
+// in Redis no matter how many application instances share it. The time
+// comes from the Redis server, so the instances' clocks don't have to
+// agree. Buckets expire once they would have refilled completely.
+//
+// KEYS[1] is the bucket, ARGV[1] the rate (tokens per second) and ARGV[2]
+// the burst size. It returns 1 if the request is allowed and 0 if not.
+var tokenBucket = redis.NewScript(1, `
+if redis.replicate_commands then redis.replicate_commands() end
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or burst
+local ts = tonumber(b[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000))
+return allowed
+`)
+
+// Redis is a Limiter which keeps the buckets in Redis, so every instance of
+// the application behind a load balancer shares them.
+type Redis struct {
+    pool   *redis.Pool
+    prefix string
+    rate   float64
+    burst  int
+}
+
+// NewRedis returns a Limiter storing its buckets in Redis under keys
+// starting with prefix, allowing rate requests per second with bursts of up
+// to burst requests.
+func NewRedis(pool *redis.Pool, prefix string, rate float64, burst int) *Redis {
+    return &Redis{pool: pool, prefix: prefix, rate: rate, burst: burst}
+}
+
+// Allow takes a token from the bucket for key. It returns an error if Redis
+// can't be reached, leaving it to the caller to decide what to do.
+func (l *Redis) Allow(ctx context.Context, key string) (bool, error) {
+    conn, err := l.pool.GetContext(ctx)
+    if err != nil {
+        return false, err
+    }
+    defer conn.Close()
+
+    allowed, err := redis.Int(tokenBucket.Do(conn, l.prefix+key, l.rate, l.burst))
+    if err != nil {
+        return false, err
+    }
+    return allowed == 1, nil
+}
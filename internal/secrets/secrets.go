@@ -0,0 +1,140 @@
+package secrets
+
+import (
+    "errors"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// windowBytes is about how much text is matched at a time. A Scanner checks
+// its time budget between windows, which end at line breaks so that no
+// secret (they are all on one line) is cut in two.
+const windowBytes = 64 << 10
+
+// ErrBudget is returned by Scan when it ran out of time before the end of
+// the text. Nothing was found in the part it scanned.
+var ErrBudget = errors.New("secrets: scan ran out of time")
+
+// A kind is a kind of secret looked for. Matching a regular expression
+// without a literal prefix costs a step per byte, so the pattern of a kind
+// only runs over text which has one of its keywords, which ordinary text,
+// checked with a fast substring search, mostly hasn't.
+type kind struct {
+    name     string
+    keywords []string
+    rx       *regexp.Regexp
+}
+
+// kinds are compiled once, when the program starts.
+var kinds = []kind{
+    {"AWS access key ID", []string{"AKIA", "ASIA", "ABIA", "ACCA"},
+        regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+    {"AWS secret access key", []string{"secret", "SECRET", "Secret"},
+        regexp.MustCompile(`(?i:aws_?secret_?access_?key)["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+    {"private key", []string{"PRIVATE KEY"},
+        regexp.MustCompile(`-----BEGIN (?:RSA |DSA |EC |OPENSSH |ENCRYPTED |PGP )?PRIVATE KEY(?: BLOCK)?-----`)},
+    {"JSON Web Token", []string{"eyJ"},
+        regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+    {"GitHub token", []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"},
+        regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`)},
+    {"Slack token", []string{"xox"},
+        regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+    {"Stripe secret key", []string{"_live_"},
+        regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{24,}\b`)},
+}
+
+// find returns where the first secret of the kind in the text is, or nil.
+// The pattern only runs over the lines with a keyword.
+func (k *kind) find(text string) []int {
+    var first []int
+    for _, keyword := range k.keywords {
+        for from := 0; from < len(text); {
+            i := strings.Index(text[from:], keyword)
+            if i < 0 {
+                break
+            }
+            i += from
+            start := strings.LastIndexByte(text[:i], '\n') + 1
+            end := len(text)
+            if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+                end = i + j
+            }
+            if m := k.rx.FindStringIndex(text[start:end]); m != nil {
+                if first == nil || start+m[0] < first[0] {
+                    first = []int{start + m[0], start + m[1]}
+                }
+                break
+            }
+            from = end + 1
+        }
+    }
+    return first
+}
+
+// A Finding is a secret found in a text. Part is the index of the text it
+// was found in, among those given to Scan, and Line the line of that text,
+// from 1. Redacted is the secret with all but its first four characters
+// masked, for logs.
+type Finding struct {
+    Kind     string
+    Part     int
+    Line     int
+    Redacted string
+}
+
+// A Scanner looks for secrets in texts, for at most Budget in all.
+type Scanner struct {
+    Budget time.Duration
+}
+
+// New returns a Scanner which gives up after the budget.
+func New(budget time.Duration) *Scanner {
+    return &Scanner{Budget: budget}
+}
+
+// Scan returns the first secret found in the texts, or nil if there is none.
+func (s *Scanner) Scan(texts ...string) (*Finding, error) {
+    deadline := time.Now().Add(s.Budget)
+    for part, text := range texts {
+        line := 1
+        for len(text) > 0 {
+            if time.Now().After(deadline) {
+                return nil, ErrBudget
+            }
+            window := text
+            if len(window) > windowBytes {
+                if i := strings.IndexByte(window[windowBytes:], '\n'); i >= 0 {
+                    window = window[:windowBytes+i+1]
+                }
+            }
+            // Report the secret found earliest in the window.
+            var first []int
+            var firstKind string
+            for i := range kinds {
+                if m := kinds[i].find(window); m != nil && (first == nil || m[0] < first[0]) {
+                    first, firstKind = m, kinds[i].name
+                }
+            }
+            if first != nil {
+                return &Finding{
+                    Kind:     firstKind,
+                    Part:     part,
+                    Line:     line + strings.Count(window[:first[0]], "\n"),
+                    Redacted: redact(window[first[0]:first[1]]),
+                }, nil
+            }
+            line += strings.Count(window, "\n")
+            text = text[len(window):]
+        }
+    }
+    return nil, nil
+}
+
+// redact masks all but the first four characters of a secret.
+func redact(secret string) string {
+    if len(secret) <= 4 {
+        return strings.Repeat("*", len(secret))
+    }
+    return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
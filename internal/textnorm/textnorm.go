@@ -0,0 +1,245 @@
+package textnorm
+
+import (
+    "errors"
+    "io"
+    "strings"
+    "unicode/utf8"
+)
+
+var (
+    // ErrInvalidUTF8 is returned for content which isn't valid UTF-8 and
+    // doesn't come with a charset to convert it from.
+    ErrInvalidUTF8 = errors.New("textnorm: content is not valid UTF-8")
+    // ErrUnknownCharset is returned for a charset we can't convert from.
+    ErrUnknownCharset = errors.New("textnorm: unsupported charset")
+)
+
+// The standard library has no charset tables, but the two single-byte
+// charsets people actually paste in are small enough to carry here.
+//
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252 to Unicode. The
+// rest of the charset is the same as ISO-8859-1, where every byte is the
+// code point of the same number. The five unused bytes map to themselves,
+// as browsers do.
+var windows1252 = [32]rune{
+    '€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+    0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// IsUTF8 reports whether the charset name means UTF-8 (or plain ASCII, which
+// is a subset of it). An empty name counts as UTF-8 too.
+func IsUTF8(charset string) bool {
+    switch strings.ToLower(strings.TrimSpace(charset)) {
+    case "", "utf-8", "utf8", "us-ascii", "ascii":
+        return true
+    }
+    return false
+}
+
+// CanonicalCharset returns the usual name of a charset we can convert to and
+// from: "utf-8", "iso-8859-1" or "windows-1252". It reports false for any
+// other charset.
+func CanonicalCharset(charset string) (string, bool) {
+    if IsUTF8(charset) {
+        return "utf-8", true
+    }
+    switch strings.ToLower(strings.TrimSpace(charset)) {
+    case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "l1":
+        return "iso-8859-1", true
+    case "windows-1252", "cp1252":
+        return "windows-1252", true
+    }
+    return "", false
+}
+
+// ToUTF8 converts s from the named charset to UTF-8. For UTF-8 itself s is
+// checked and returned as it is, or ErrInvalidUTF8 if it isn't valid. The
+// other charsets supported are ISO-8859-1 (Latin-1) and Windows-1252, under
+// their usual names.
+func ToUTF8(s, charset string) (string, error) {
+    if IsUTF8(charset) {
+        if !utf8.ValidString(s) {
+            return "", ErrInvalidUTF8
+        }
+        return s, nil
+    }
+
+    name, ok := CanonicalCharset(charset)
+    if !ok {
+        return "", ErrUnknownCharset
+    }
+    cp1252 := name == "windows-1252"
+
+    var b strings.Builder
+    b.Grow(len(s))
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case c < 0x80:
+            b.WriteByte(c)
+        case cp1252 && c < 0xA0:
+            b.WriteRune(windows1252[c-0x80])
+        default:
+            b.WriteRune(rune(c))
+        }
+    }
+    return b.String(), nil
+}
+
+// NormalizeNewlines converts Windows (CRLF) line endings to LF. It reports
+// whether anything was changed.
+func NormalizeNewlines(s string) (string, bool) {
+    if !strings.Contains(s, "\r\n") {
+        return s, false
+    }
+    return strings.ReplaceAll(s, "\r\n", "\n"), true
+}
+
+// A crlfWriter converts LF line endings to CRLF on the way through, leaving
+// any CRLF already there alone.
+type crlfWriter struct {
+    w io.Writer
+    // lastCR records whether the previous write ended in a CR, so a CRLF
+    // split across two writes isn't doubled up.
+    lastCR bool
+}
+
+// NewCRLFWriter returns a writer which writes to w with every LF line ending
+// turned into CRLF.
+func NewCRLFWriter(w io.Writer) io.Writer {
+    return &crlfWriter{w: w}
+}
+
+func (cw *crlfWriter) Write(p []byte) (int, error) {
+    out := make([]byte, 0, len(p)+len(p)/16)
+    for _, c := range p {
+        if c == '\n' && !cw.lastCR {
+            out = append(out, '\r')
+        }
+        out = append(out, c)
+        cw.lastCR = c == '\r'
+    }
+    if _, err := cw.w.Write(out); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+// BOM is the byte order mark some editors put at the start of UTF-8 files.
+const BOM = "\uFEFF"
+
+// StripBOM removes a byte order mark from the start of s. It reports
+// whether there was one.
+func StripBOM(s string) (string, bool) {
+    if !strings.HasPrefix(s, BOM) {
+        return s, false
+    }
+    return s[len(BOM):], true
+}
+
+// A bomWriter drops a byte order mark from the start of what is written
+// through it.
+type bomWriter struct {
+    w io.Writer
+    // head holds the first bytes until there are enough to tell whether
+    // they are a BOM, and done is set once they have been dealt with.
+    head []byte
+    done bool
+}
+
+// NewBOMStripWriter returns a writer which writes to w without the byte
+// order mark the content may start with. Content shorter than a BOM which
+// starts like one is held back, so it must be valid UTF-8.
+func NewBOMStripWriter(w io.Writer) io.Writer {
+    return &bomWriter{w: w}
+}
+
+func (bw *bomWriter) Write(p []byte) (int, error) {
+    if bw.done {
+        return bw.w.Write(p)
+    }
+
+    n := len(p)
+    bw.head = append(bw.head, p...)
+    if len(bw.head) < len(BOM) && strings.HasPrefix(BOM, string(bw.head)) {
+        return n, nil
+    }
+    bw.done = true
+    out := bw.head
+    if strings.HasPrefix(string(out), BOM) {
+        out = out[len(BOM):]
+    }
+    bw.head = nil
+    if _, err := bw.w.Write(out); err != nil {
+        return 0, err
+    }
+    return n, nil
+}
+
+// An encodingWriter converts UTF-8 to a single-byte charset on the way
+// through.
+type encodingWriter struct {
+    w      io.Writer
+    cp1252 bool
+    // partial holds the start of a character split across two writes.
+    partial []byte
+}
+
+// NewEncodingWriter returns a writer which converts the UTF-8 written to it
+// to the named charset (see CanonicalCharset) and writes that to w.
+// Characters the charset doesn't have are written as "?". For UTF-8 it
+// returns w itself.
+func NewEncodingWriter(w io.Writer, charset string) (io.Writer, error) {
+    name, ok := CanonicalCharset(charset)
+    switch {
+    case !ok:
+        return nil, ErrUnknownCharset
+    case name == "utf-8":
+        return w, nil
+    }
+    return &encodingWriter{w: w, cp1252: name == "windows-1252"}, nil
+}
+
+func (ew *encodingWriter) Write(p []byte) (int, error) {
+    in := p
+    if len(ew.partial) > 0 {
+        in = append(ew.partial, p...)
+        ew.partial = nil
+    }
+
+    out := make([]byte, 0, len(in))
+    for len(in) > 0 {
+        r, size := utf8.DecodeRune(in)
+        if r == utf8.RuneError && size == 1 && !utf8.FullRune(in) {
+            ew.partial = append([]byte(nil), in...)
+            break
+        }
+        in = in[size:]
+        out = append(out, ew.encode(r))
+    }
+    if _, err := ew.w.Write(out); err != nil {
+        return 0, err
+    }
+    return len(p), nil
+}
+
+// encode returns the byte for r in the writer's charset, or '?'.
+func (ew *encodingWriter) encode(r rune) byte {
+    switch {
+    case r < 0x80:
+        return byte(r)
+    case ew.cp1252:
+        for i, c := range windows1252 {
+            if c == r {
+                return byte(0x80 + i)
+            }
+        }
+        if r >= 0xA0 && r <= 0xFF {
+            return byte(r)
+        }
+    case r <= 0xFF:
+        return byte(r)
+    }
+    return '?'
+}
@@ -0,0 +1,157 @@
+package textnorm
+
+import (
+    "bytes"
+    "io"
+    "testing"
+)
+
+func TestToUTF8(t *testing.T) {
+    tests := []struct {
+        name    string
+        in      string
+        charset string
+        want    string
+        wantErr error
+    }{
+        {name: "UTF-8", in: "café", want: "café"},
+        {name: "ASCII", in: "plain", charset: "us-ascii", want: "plain"},
+        {name: "invalid UTF-8", in: "caf\xe9", wantErr: ErrInvalidUTF8},
+        {name: "Latin-1", in: "caf\xe9 \xa9 \x80", charset: "ISO-8859-1", want: "café © \u0080"},
+        {name: "Latin-1 alias", in: "na\xefve", charset: " latin1 ", want: "naïve"},
+        {name: "Windows-1252", in: "\x93quoted\x94 \x80 \x81", charset: "cp1252", want: "“quoted” € \u0081"},
+        {name: "unknown charset", in: "text", charset: "koi8-r", wantErr: ErrUnknownCharset},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := ToUTF8(tt.in, tt.charset)
+            if err != tt.wantErr {
+                t.Fatalf("error %v, want %v", err, tt.wantErr)
+            }
+            if got != tt.want {
+                t.Errorf("got %q, want %q", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+    tests := []struct {
+        in          string
+        want        string
+        wantChanged bool
+    }{
+        {"one\ntwo\n", "one\ntwo\n", false},
+        {"one\r\ntwo\r\n", "one\ntwo\n", true},
+        {"mixed\r\nends\nhere\r\n", "mixed\nends\nhere\n", true},
+        // A lone CR is an old Mac line ending, and is kept.
+        {"lone\rcr", "lone\rcr", false},
+    }
+    for _, tt := range tests {
+        got, changed := NormalizeNewlines(tt.in)
+        if got != tt.want || changed != tt.wantChanged {
+            t.Errorf("NormalizeNewlines(%q) = %q, %t; want %q, %t", tt.in, got, changed, tt.want, tt.wantChanged)
+        }
+    }
+}
+
+// writeInPieces writes s to w a byte at a time, so that every character and
+// line ending is split across writes.
+func writeInPieces(t *testing.T, w io.Writer, s string) {
+    t.Helper()
+
+    for i := 0; i < len(s); i++ {
+        if _, err := w.Write([]byte{s[i]}); err != nil {
+            t.Fatal(err)
+        }
+    }
+}
+
+func TestCRLFWriter(t *testing.T) {
+    for _, in := range []string{"one\ntwo\n", "one\r\ntwo\n"} {
+        var whole, pieces bytes.Buffer
+        if _, err := NewCRLFWriter(&whole).Write([]byte(in)); err != nil {
+            t.Fatal(err)
+        }
+        writeInPieces(t, NewCRLFWriter(&pieces), in)
+
+        for _, got := range []string{whole.String(), pieces.String()} {
+            if got != "one\r\ntwo\r\n" {
+                t.Errorf("CRLF of %q = %q", in, got)
+            }
+        }
+    }
+}
+
+func TestEncodingWriter(t *testing.T) {
+    tests := []struct {
+        charset string
+        in      string
+        want    string
+    }{
+        {"iso-8859-1", "café ©", "caf\xe9 \xa9"},
+        {"windows-1252", "“quoted” €", "\x93quoted\x94 \x80"},
+        // Neither has the euro sign and the snowman, respectively.
+        {"iso-8859-1", "5 €", "5 ?"},
+        {"windows-1252", "☃", "?"},
+    }
+    for _, tt := range tests {
+        var buf bytes.Buffer
+        w, err := NewEncodingWriter(&buf, tt.charset)
+        if err != nil {
+            t.Fatal(err)
+        }
+        writeInPieces(t, w, tt.in)
+        if buf.String() != tt.want {
+            t.Errorf("%s of %q = %q, want %q", tt.charset, tt.in, buf.String(), tt.want)
+        }
+
+        // Converting back gives the text again, where the charset had it.
+        back, err := ToUTF8(tt.want, tt.charset)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if back != tt.in && !bytes.Contains([]byte(tt.want), []byte("?")) {
+            t.Errorf("%s round trip of %q = %q", tt.charset, tt.in, back)
+        }
+    }
+}
+
+func TestStripBOM(t *testing.T) {
+    tests := []struct {
+        in           string
+        want         string
+        wantStripped bool
+    }{
+        {BOM + "content", "content", true},
+        {"content", "content", false},
+        // Only a BOM at the very start is one.
+        {"content" + BOM, "content" + BOM, false},
+        {BOM + BOM + "twice", BOM + "twice", true},
+        {"", "", false},
+    }
+    for _, tt := range tests {
+        got, stripped := StripBOM(tt.in)
+        if got != tt.want || stripped != tt.wantStripped {
+            t.Errorf("StripBOM(%q) = %q, %t; want %q, %t", tt.in, got, stripped, tt.want, tt.wantStripped)
+        }
+    }
+}
+
+func TestBOMStripWriter(t *testing.T) {
+    for _, in := range []string{BOM + "content", "content", "\xefx", "é"} {
+        want, _ := StripBOM(in)
+
+        var whole, pieces bytes.Buffer
+        if _, err := NewBOMStripWriter(&whole).Write([]byte(in)); err != nil {
+            t.Fatal(err)
+        }
+        writeInPieces(t, NewBOMStripWriter(&pieces), in)
+
+        for _, got := range []string{whole.String(), pieces.String()} {
+            if got != want {
+                t.Errorf("BOM stripped from %q = %q, want %q", in, got, want)
+            }
+        }
+    }
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+    "strings"
+)
+
+// A ContentTransformer post-processes the content of a chunk before it is
+// stored, for example to strip secrets or reformat it. lang is the name of
+// the chunk's language. An error rejects the chunk, and its message is shown
+// to the person submitting it, so it should say what is wrong in their
+// terms.
+type ContentTransformer interface {
+    Transform(lang, content string) (string, error)
+}
+
+// A Pipeline runs transformers one after the other, each on the output of
+// the one before. An empty Pipeline leaves content as it is.
+type Pipeline []ContentTransformer
+
+// Transform runs the content through every transformer in order, stopping
+// at the first error.
+func (p Pipeline) Transform(lang, content string) (string, error) {
+    for _, t := range p {
+        var err error
+        content, err = t.Transform(lang, content)
+        if err != nil {
+            return "", err
+        }
+    }
+    return content, nil
+}
+
+// TrimTrailingWhitespace removes the spaces and tabs at the end of every
+// line. Trailing blank lines are left alone.
+type TrimTrailingWhitespace struct{}
+
+func (TrimTrailingWhitespace) Transform(lang, content string) (string, error) {
+    lines := strings.Split(content, "\n")
+    for i, line := range lines {
+        // A CRLF line ending stays, only what comes before it goes.
+        cr := strings.HasSuffix(line, "\r")
+        line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+        if cr {
+            line += "\r"
+        }
+        lines[i] = line
+    }
+    return strings.Join(lines, "\n"), nil
+}
+
+// TabsToSpaces expands tabs to spaces, up to the next multiple of Width
+// columns. Makefiles are left alone, since make needs the tabs.
+type TabsToSpaces struct {
+    Width int
+}
+
+func (t TabsToSpaces) Transform(lang, content string) (string, error) {
+    if lang == "make" || t.Width < 1 || !strings.Contains(content, "\t") {
+        return content, nil
+    }
+
+    var b strings.Builder
+    b.Grow(len(content))
+    column := 0
+    for _, r := range content {
+        switch r {
+        case '\t':
+            n := t.Width - column%t.Width
+            b.WriteString(strings.Repeat(" ", n))
+            column += n
+        case '\n':
+            b.WriteRune(r)
+            column = 0
+        default:
+            b.WriteRune(r)
+            if r != '\r' {
+                column++
+            }
+        }
+    }
+    return b.String(), nil
+}
@@ -0,0 +1,121 @@
+package validator
+
+import (
+    _ "embed"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+)
+
+// Use the regexp.MustCompile() function to parse a regular expression pattern
+// for sanity checking the format of an email address. This returns a pointer to
+// a 'compiled' regexp.Regexp type, or panics in the event of an error. Parsing
+// this pattern once at startup and storing the compiled *regexp.Regexp in a
+// variable is more performant than re-parsing the pattern each time we need it.
+var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
+
+// Embed the list of common passwords which are rejected by
+// NotCommonPassword(). The file has one password per line.
+//
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// Define a new Validator type which contains a map of validation errors for our
+// form fields, plus a slice for errors which aren't related to a specific field
+// (for example a wrong email/password combination).
+type Validator struct {
+    NonFieldErrors []string
+    FieldErrors    map[string]string
+}
+
+// Valid() returns true if the FieldErrors map and NonFieldErrors slice don't
+// contain any entries.
+func (v *Validator) Valid() bool {
+    return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
+}
+
+// AddNonFieldError() adds an error message to the NonFieldErrors slice.
+func (v *Validator) AddNonFieldError(message string) {
+    v.NonFieldErrors = append(v.NonFieldErrors, message)
+}
+
+// AddFieldError() adds an error message to the FieldErrors map (so long as no
+// entry already exists for the given key).
+func (v *Validator) AddFieldError(key, message string) {
+    // Note: We need to initialize the map first, if it isn't already
+    // initialized.
+    if v.FieldErrors == nil {
+        v.FieldErrors = make(map[string]string)
+    }
+
+    if _, exists := v.FieldErrors[key]; !exists {
+        v.FieldErrors[key] = message
+    }
+}
+
+// CheckField() adds an error message to the FieldErrors map only if a
+// validation check is not 'ok'.
+func (v *Validator) CheckField(ok bool, key, message string) {
+    if !ok {
+        v.AddFieldError(key, message)
+    }
+}
+
+// NotBlank() returns true if a value is not an empty string.
+func NotBlank(value string) bool {
+    return strings.TrimSpace(value) != ""
+}
+
+// MaxChars() returns true if a value contains no more than n characters.
+func MaxChars(value string, n int) bool {
+    return utf8.RuneCountInString(value) <= n
+}
+
+// MaxBytes() returns true if a value is no more than n bytes long.
+func MaxBytes(value string, n int) bool {
+    return len(value) <= n
+}
+
+// MinChars() returns true if a value contains at least n characters.
+func MinChars(value string, n int) bool {
+    return utf8.RuneCountInString(value) >= n
+}
+
+// Matches() returns true if a value matches a provided compiled regular
+// expression pattern.
+func Matches(value string, rx *regexp.Regexp) bool {
+    return rx.MatchString(value)
+}
+
+// PermittedInt() returns true if a value is in a list of permitted integers.
+func PermittedInt(value int, permittedValues ...int) bool {
+    for i := range permittedValues {
+        if value == permittedValues[i] {
+            return true
+        }
+    }
+    return false
+}
+
+// The commonPasswords set is built once at package initialization from the
+// embedded common_passwords.txt list.
+var commonPasswords = loadCommonPasswords()
+
+func loadCommonPasswords() map[string]struct{} {
+    set := make(map[string]struct{})
+    for _, line := range strings.Split(commonPasswordsFile, "\n") {
+        line = strings.TrimSpace(line)
+        if line != "" {
+            set[strings.ToLower(line)] = struct{}{}
+        }
+    }
+    return set
+}
+
+// NotCommonPassword() returns true if a value does not appear in the embedded
+// list of the most common passwords. The comparison is case-insensitive, so
+// "Password1" is rejected just like "password1".
+func NotCommonPassword(value string) bool {
+    _, found := commonPasswords[strings.ToLower(value)]
+    return !found
+}
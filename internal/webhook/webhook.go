@@ -0,0 +1,131 @@
+package webhook
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// SignatureHeader is the request header carrying the signature of a webhook.
+//
+// Its value looks like
+//
+//  t=1791950400,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd
+//
+// where t is the Unix time the webhook was sent and v1 is the hex encoded
+// HMAC-SHA256, keyed with the shared secret (-webhook-secret), of the time,
+// a full stop and the request body:
+//
+//  HMAC-SHA256(secret, "1791950400." + body)
+//
+// To check a webhook, a consumer computes the same HMAC over the t it
+// received and the raw body, compares it with v1 in constant time, and
+// refuses t values too far from its own clock so a captured request can't
+// be replayed later. Verify does all of this.
+const SignatureHeader = "X-Chunkbox-Signature"
+
+// sendTimeout bounds one delivery, so a slow consumer can't hold on to
+// the goroutines sending to it.
+const sendTimeout = 10 * time.Second
+
+var (
+    // ErrInvalidSignature is returned by Verify when the header is malformed
+    // or its signature doesn't match the payload.
+    ErrInvalidSignature = errors.New("webhook: invalid signature")
+    // ErrExpired is returned by Verify when the signature is valid but was
+    // made too long ago, or too far in the future.
+    ErrExpired = errors.New("webhook: signature timestamp out of tolerance")
+)
+
+// Sign returns the SignatureHeader value for payload signed now with secret.
+func Sign(payload []byte, secret string) string {
+    ts := strconv.FormatInt(time.Now().Unix(), 10)
+    return "t=" + ts + ",v1=" + hex.EncodeToString(mac(payload, secret, ts))
+}
+
+// Verify checks a SignatureHeader value against payload and secret. The
+// signature is only accepted if its timestamp is within tolerance of now.
+func Verify(payload []byte, secret, header string, tolerance time.Duration) error {
+    var ts, sig string
+    for _, field := range strings.Split(header, ",") {
+        key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+        switch key {
+        case "t":
+            ts = value
+        case "v1":
+            sig = value
+        }
+    }
+
+    unix, err := strconv.ParseInt(ts, 10, 64)
+    if err != nil {
+        return ErrInvalidSignature
+    }
+    got, err := hex.DecodeString(sig)
+    if err != nil || !hmac.Equal(got, mac(payload, secret, ts)) {
+        return ErrInvalidSignature
+    }
+
+    age := time.Since(time.Unix(unix, 0))
+    if age > tolerance || age < -tolerance {
+        return ErrExpired
+    }
+    return nil
+}
+
+func mac(payload []byte, secret, ts string) []byte {
+    h := hmac.New(sha256.New, []byte(secret))
+    h.Write([]byte(ts))
+    h.Write([]byte("."))
+    h.Write(payload)
+    return h.Sum(nil)
+}
+
+// A Sender posts JSON webhooks to a single URL, signed with a shared secret.
+type Sender struct {
+    url    string
+    secret string
+    client *http.Client
+}
+
+// New returns a Sender for the URL. Without a secret the webhooks are sent
+// unsigned.
+func New(url, secret string) *Sender {
+    return &Sender{
+        url:    url,
+        secret: secret,
+        client: &http.Client{Timeout: sendTimeout},
+    }
+}
+
+// Send posts the JSON payload to the webhook URL. Any response other than a
+// 2xx status is reported as an error.
+func (s *Sender) Send(ctx context.Context, payload []byte) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if s.secret != "" {
+        req.Header.Set(SignatureHeader, Sign(payload, s.secret))
+    }
+
+    resp, err := s.client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return fmt.Errorf("webhook: %s returned %s", s.url, resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+    "context"
+    "encoding/hex"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+const secret = "s3cret"
+
+// signAt returns the SignatureHeader value for payload as Sign would have
+// at the time t.
+func signAt(payload []byte, t time.Time) string {
+    ts := strconv.FormatInt(t.Unix(), 10)
+    return "t=" + ts + ",v1=" + hex.EncodeToString(mac(payload, secret, ts))
+}
+
+func TestVerify(t *testing.T) {
+    payload := []byte(`{"event":"chunk.created","id":"abc"}`)
+    header := Sign(payload, secret)
+    tField, v1Field, _ := strings.Cut(header, ",")
+    signedAt, _ := strconv.ParseInt(strings.TrimPrefix(tField, "t="), 10, 64)
+    now := time.Now()
+
+    tests := []struct {
+        name    string
+        payload []byte
+        secret  string
+        header  string
+        want    error
+    }{
+        {"signed", payload, secret, header, nil},
+        {"fields swapped, with spaces", payload, secret, v1Field + ", " + tField, nil},
+        {"within tolerance", payload, secret, signAt(payload, now.Add(-4*time.Minute)), nil},
+        {"tampered body", []byte(`{"event":"chunk.created","id":"abd"}`), secret, header, ErrInvalidSignature},
+        {"wrong secret", payload, "other", header, ErrInvalidSignature},
+        {"too old", payload, secret, signAt(payload, now.Add(-10*time.Minute)), ErrExpired},
+        {"in the future", payload, secret, signAt(payload, now.Add(10*time.Minute)), ErrExpired},
+        {"empty", payload, secret, "", ErrInvalidSignature},
+        {"no signature", payload, secret, "t=" + strconv.FormatInt(now.Unix(), 10), ErrInvalidSignature},
+        {"no timestamp", payload, secret, v1Field, ErrInvalidSignature},
+        {"bad timestamp", payload, secret, "t=yesterday," + v1Field, ErrInvalidSignature},
+        {"bad hex", payload, secret, tField + ",v1=zz", ErrInvalidSignature},
+        // Moving t makes the signature wrong before it makes it expired.
+        {"moved timestamp", payload, secret, "t=" + strconv.FormatInt(signedAt+1, 10) + "," + v1Field, ErrInvalidSignature},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if err := Verify(tt.payload, tt.secret, tt.header, 5*time.Minute); !errors.Is(err, tt.want) {
+                t.Errorf("Verify(%q) = %v, want %v", tt.header, err, tt.want)
+            }
+        })
+    }
+}
+
+func TestSenderSend(t *testing.T) {
+    payload := []byte(`{"event":"chunk.created"}`)
+
+    var gotHeader, gotType string
+    var gotBody []byte
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotHeader = r.Header.Get(SignatureHeader)
+        gotType = r.Header.Get("Content-Type")
+        gotBody, _ = io.ReadAll(r.Body)
+        if r.URL.Path == "/fail" {
+            http.Error(w, "no", http.StatusInternalServerError)
+        }
+    }))
+    defer ts.Close()
+
+    if err := New(ts.URL, secret).Send(context.Background(), payload); err != nil {
+        t.Fatal(err)
+    }
+    if string(gotBody) != string(payload) || gotType != "application/json" {
+        t.Errorf("received %q as %q", gotBody, gotType)
+    }
+    if err := Verify(gotBody, secret, gotHeader, time.Minute); err != nil {
+        t.Errorf("%s = %q: %v", SignatureHeader, gotHeader, err)
+    }
+
+    // Without a secret the webhook isn't signed.
+    if err := New(ts.URL, "").Send(context.Background(), payload); err != nil {
+        t.Fatal(err)
+    }
+    if gotHeader != "" {
+        t.Errorf("unsigned webhook with %s = %q", SignatureHeader, gotHeader)
+    }
+
+    if err := New(ts.URL+"/fail", secret).Send(context.Background(), payload); err == nil {
+        t.Error("no error for a 500 response")
+    }
+}
@@ -0,0 +1,12 @@
+package ui
+
+import (
+    "embed"
+)
+
+// Files embeds the default assets that are compiled into the binary, so
+// the application still has a favicon when no custom one is configured,
+// and its templates when no -templates-dir is.
+//
+//go:embed "static/img/favicon.ico" "html"
+var Files embed.FS